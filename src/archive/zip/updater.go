@@ -0,0 +1,95 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zip
+
+import (
+	"errors"
+	"io"
+)
+
+// An Updater adds files to an existing zip archive in place.
+//
+// The files already in the archive are left where they are. New files
+// are written over the central directory at the end of the archive,
+// and Close writes the existing directory records again after them,
+// followed by the records for the new files.
+//
+// The archive is corrupt from the first write until Close returns,
+// so the caller must call Close, and should keep a copy of an archive
+// that must not be lost.
+type Updater struct {
+	*Writer
+
+	// File holds the files that were in the archive when the
+	// Updater was created. Their contents stay readable while
+	// files are added, and they may be passed to Copy.
+	File []*File
+
+	rw interface {
+		io.ReaderAt
+		io.WriterAt
+	}
+}
+
+// NewUpdater returns an Updater adding files to the zip archive in rw,
+// such as an *os.File, which is assumed to have the given size in bytes.
+// The archive keeps its comment unless SetComment is called.
+func NewUpdater(rw interface {
+	io.ReaderAt
+	io.WriterAt
+}, size int64) (*Updater, error) {
+	if size < 0 {
+		return nil, errors.New("zip: size cannot be negative")
+	}
+	end, err := readDirectoryEnd(rw, size)
+	if err != nil {
+		return nil, err
+	}
+	r := new(Reader)
+	if err := r.init(rw, size); err != nil {
+		return nil, err
+	}
+	if end.directorySize > uint64(size)-end.directoryOffset {
+		return nil, ErrFormat
+	}
+	dir := make([]byte, end.directorySize)
+	if _, err := rw.ReadAt(dir, int64(end.directoryOffset)); err != nil {
+		return nil, err
+	}
+
+	w := NewWriter(&writerAtWriter{w: rw, off: int64(end.directoryOffset)})
+	w.cw.count = int64(end.directoryOffset)
+	w.comment = r.Comment
+	w.dirPrefix = dir
+	w.dirPrefixRecords = uint64(len(r.File))
+	return &Updater{Writer: w, File: r.File, rw: rw}, nil
+}
+
+// Close finishes updating the archive by writing the central directory.
+// If the storage has a Truncate method, as *os.File does, Close also
+// truncates it to the end of the archive, which may be shorter than
+// before if no files were added. Close does not close the storage.
+func (u *Updater) Close() error {
+	if err := u.Writer.Close(); err != nil {
+		return err
+	}
+	if t, ok := u.rw.(interface{ Truncate(int64) error }); ok {
+		return t.Truncate(u.cw.count)
+	}
+	return nil
+}
+
+// A writerAtWriter writes sequentially to an io.WriterAt,
+// starting at off.
+type writerAtWriter struct {
+	w   io.WriterAt
+	off int64
+}
+
+func (w *writerAtWriter) Write(p []byte) (int, error) {
+	n, err := w.w.WriteAt(p, w.off)
+	w.off += int64(n)
+	return n, err
+}
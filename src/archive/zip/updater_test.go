@@ -0,0 +1,249 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// memFile is an in-memory io.ReaderAt and io.WriterAt.
+type memFile []byte
+
+func (m *memFile) ReadAt(p []byte, off int64) (int, error) {
+	if off >= int64(len(*m)) {
+		return 0, io.EOF
+	}
+	n := copy(p, (*m)[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (m *memFile) WriteAt(p []byte, off int64) (int, error) {
+	if end := off + int64(len(p)); end > int64(len(*m)) {
+		*m = append(*m, make([]byte, end-int64(len(*m)))...)
+	}
+	return copy((*m)[off:], p), nil
+}
+
+func TestUpdater(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "test.zip")
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := NewWriter(f)
+	for _, wt := range writeTests[:3] {
+		testCreate(t, w, &wt)
+	}
+	w.SetComment("a comment that is longer than the final one")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Add a file, and a copy of one already in the archive.
+	fi, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := NewUpdater(f, fi.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(u.File) != 3 {
+		t.Fatalf("Updater has %d files, want 3", len(u.File))
+	}
+	testCreate(t, u.Writer, &writeTests[len(writeTests)-1])
+	if err := u.Copy(u.File[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := OpenReader(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	want := append(append([]WriteTest{}, writeTests[:3]...), writeTests[len(writeTests)-1], writeTests[0])
+	if len(r.File) != len(want) {
+		t.Fatalf("archive has %d files, want %d", len(r.File), len(want))
+	}
+	for i, wt := range want {
+		testReadFile(t, r.File[i], &wt)
+	}
+	if r.Comment != "a comment that is longer than the final one" {
+		t.Errorf("comment = %q, want original comment", r.Comment)
+	}
+
+	// Shorten the comment without adding files: the file must shrink.
+	fi, err = f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err = NewUpdater(f, fi.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.SetComment("short")
+	if err := u.Close(); err != nil {
+		t.Fatal(err)
+	}
+	fi2, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fi2.Size(), fi.Size()-int64(len("a comment that is longer than the final one")-len("short")); got != want {
+		t.Errorf("updated archive has size %d, want %d", got, want)
+	}
+	zr, err := NewReader(f, fi2.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zr.File) != len(want) || zr.Comment != "short" {
+		t.Errorf("updated archive has %d files and comment %q, want %d and %q", len(zr.File), zr.Comment, len(want), "short")
+	}
+}
+
+func TestUpdaterZip64Records(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode")
+	}
+	// Start with an archive with a zip64 end record, as it has
+	// too many files for the regular one.
+	const nFiles = 1<<16 - 1
+	m := new(memFile)
+	w := NewWriter(&writerAtWriter{w: m})
+	for i := 0; i < nFiles; i++ {
+		if _, err := w.CreateHeader(&FileHeader{Name: fmt.Sprintf("%d.dat", i), Method: Store}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !suffixIsZip64(t, bytes.NewReader(*m)) {
+		t.Fatal("not a zip64")
+	}
+
+	u, err := NewUpdater(m, int64(len(*m)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(u.File) != nFiles {
+		t.Fatalf("Updater has %d files, want %d", len(u.File), nFiles)
+	}
+	fw, err := u.Create("last")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(fw, "last file")
+	if err := u.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReader(m, int64(len(*m)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.File) != nFiles+1 {
+		t.Fatalf("archive has %d files, want %d", len(r.File), nFiles+1)
+	}
+	testReadFile(t, r.File[nFiles-1], &WriteTest{Name: fmt.Sprint(nFiles-1, ".dat"), Mode: 0666})
+	testReadFile(t, r.File[nFiles], &WriteTest{Name: "last", Data: []byte("last file"), Mode: 0666})
+}
+
+// countZip64Extra returns the number of zip64 extra blocks in extra.
+func countZip64Extra(extra []byte) int {
+	n := 0
+	for len(extra) >= 4 {
+		if binary.LittleEndian.Uint16(extra) == zip64ExtraID {
+			n++
+		}
+		extra = extra[4+int(binary.LittleEndian.Uint16(extra[2:])):]
+	}
+	return n
+}
+
+// TestCopyZip64 checks that copying a file whose sizes need zip64
+// fields keeps them, and does not modify the source file.
+func TestCopyZip64(t *testing.T) {
+	const size = 1 << 33 // uncompressed size, as the data is not checked
+	content := []byte("compressed data")
+	var src bytes.Buffer
+	w := NewWriter(&src)
+	fw, err := w.CreateRaw(&FileHeader{
+		Name:               "huge",
+		Method:             Deflate,
+		CRC32:              1234,
+		CompressedSize64:   uint64(len(content)),
+		UncompressedSize64: size,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(content)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The local header must record the size in a zip64 extra block.
+	b := src.Bytes()
+	extra := b[fileHeaderLen+len("huge"):][:binary.LittleEndian.Uint16(b[28:])]
+	if countZip64Extra(extra) != 1 {
+		t.Fatalf("local header extra %x has no zip64 block", extra)
+	}
+	if got := binary.LittleEndian.Uint64(extra[4:]); got != size {
+		t.Fatalf("local header zip64 size %d, want %d", got, size)
+	}
+
+	r, err := NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := r.File[0]
+	before := f.FileHeader
+	for i := 0; i < 2; i++ {
+		var dst bytes.Buffer
+		w := NewWriter(&dst)
+		if err := w.Copy(f); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		r2, err := NewReader(bytes.NewReader(dst.Bytes()), int64(dst.Len()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		g := r2.File[0]
+		if g.UncompressedSize64 != size || g.CompressedSize64 != uint64(len(content)) || g.CRC32 != 1234 {
+			t.Errorf("copy %d: sizes %d, %d and CRC %d, want %d, %d and %d",
+				i, g.UncompressedSize64, g.CompressedSize64, g.CRC32, size, len(content), 1234)
+		}
+		if n := countZip64Extra(g.Extra); n != 1 {
+			t.Errorf("copy %d: %d zip64 extra blocks, want 1", i, n)
+		}
+		raw, err := g.OpenRaw()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := io.ReadAll(raw); !bytes.Equal(got, content) {
+			t.Errorf("copy %d: raw content %q, want %q", i, got, content)
+		}
+	}
+	if f.CompressedSize != before.CompressedSize || !bytes.Equal(f.Extra, before.Extra) {
+		t.Error("Copy modified the source file header")
+	}
+}
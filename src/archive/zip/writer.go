@@ -29,6 +29,12 @@ type Writer struct {
 	compressors map[uint16]Compressor
 	comment     string
 
+	// dirPrefix holds the central directory records of the files
+	// already in an archive being updated, which Close writes before
+	// those of the files in dir. dirPrefixRecords is their number.
+	dirPrefix        []byte
+	dirPrefixRecords uint64
+
 	// testHookCloseSizeOffset if non-nil is called with the size
 	// of offset of the central directory at Close.
 	testHookCloseSizeOffset func(size, offset uint64)
@@ -88,6 +94,9 @@ func (w *Writer) Close() error {
 
 	// write central directory
 	start := w.cw.count
+	if _, err := w.cw.Write(w.dirPrefix); err != nil {
+		return err
+	}
 	for _, h := range w.dir {
 		var buf [directoryHeaderLen]byte
 		b := writeBuf(buf[:])
@@ -99,6 +108,7 @@ func (w *Writer) Close() error {
 		b.uint16(h.ModifiedTime)
 		b.uint16(h.ModifiedDate)
 		b.uint32(h.CRC32)
+		extra := stripZip64Extra(h.Extra)
 		if h.isZip64() || h.offset >= uint32max {
 			// the file needs a zip64 header. store maxint in both
 			// 32 bit size fields (and offset later) to signal that the
@@ -106,7 +116,8 @@ func (w *Writer) Close() error {
 			b.uint32(uint32max) // compressed size
 			b.uint32(uint32max) // uncompressed size
 
-			// append a zip64 extra block to Extra
+			// append a zip64 extra block to Extra,
+			// leaving the caller's Extra unmodified
 			var buf [28]byte // 2x uint16 + 3x uint64
 			eb := writeBuf(buf[:])
 			eb.uint16(zip64ExtraID)
@@ -114,14 +125,14 @@ func (w *Writer) Close() error {
 			eb.uint64(h.UncompressedSize64)
 			eb.uint64(h.CompressedSize64)
 			eb.uint64(h.offset)
-			h.Extra = append(h.Extra, buf[:]...)
+			extra = append(extra[:len(extra):len(extra)], buf[:]...)
 		} else {
 			b.uint32(h.CompressedSize)
 			b.uint32(h.UncompressedSize)
 		}
 
 		b.uint16(uint16(len(h.Name)))
-		b.uint16(uint16(len(h.Extra)))
+		b.uint16(uint16(len(extra)))
 		b.uint16(uint16(len(h.Comment)))
 		b = b[4:] // skip disk number start and internal file attr (2x uint16)
 		b.uint32(h.ExternalAttrs)
@@ -136,7 +147,7 @@ func (w *Writer) Close() error {
 		if _, err := io.WriteString(w.cw, h.Name); err != nil {
 			return err
 		}
-		if _, err := w.cw.Write(h.Extra); err != nil {
+		if _, err := w.cw.Write(extra); err != nil {
 			return err
 		}
 		if _, err := io.WriteString(w.cw, h.Comment); err != nil {
@@ -145,7 +156,7 @@ func (w *Writer) Close() error {
 	}
 	end := w.cw.count
 
-	records := uint64(len(w.dir))
+	records := w.dirPrefixRecords + uint64(len(w.dir))
 	size := uint64(end - start)
 	offset := uint64(start)
 
@@ -388,7 +399,19 @@ func writeHeader(w io.Writer, h *header) error {
 	if len(h.Name) > maxUint16 {
 		return errLongName
 	}
-	if len(h.Extra) > maxUint16 {
+	extra := stripZip64Extra(h.Extra)
+	if h.raw && !h.hasDataDescriptor() && h.isZip64() {
+		// The sizes are known but do not fit in the header,
+		// so record them in a zip64 extra block.
+		var buf [20]byte // 2x uint16 + 2x uint64
+		eb := writeBuf(buf[:])
+		eb.uint16(zip64ExtraID)
+		eb.uint16(16) // size = 2x uint64
+		eb.uint64(h.UncompressedSize64)
+		eb.uint64(h.CompressedSize64)
+		extra = append(extra[:len(extra):len(extra)], buf[:]...)
+	}
+	if len(extra) > maxUint16 {
 		return errLongExtra
 	}
 
@@ -415,17 +438,43 @@ func writeHeader(w io.Writer, h *header) error {
 		b.uint32(0) // uncompressed size
 	}
 	b.uint16(uint16(len(h.Name)))
-	b.uint16(uint16(len(h.Extra)))
+	b.uint16(uint16(len(extra)))
 	if _, err := w.Write(buf[:]); err != nil {
 		return err
 	}
 	if _, err := io.WriteString(w, h.Name); err != nil {
 		return err
 	}
-	_, err := w.Write(h.Extra)
+	_, err := w.Write(extra)
 	return err
 }
 
+// stripZip64Extra returns extra without any zip64 extra blocks.
+// The Writer adds its own where needed, so those present in a
+// FileHeader, such as one read by a Reader, must not be copied.
+func stripZip64Extra(extra []byte) []byte {
+	var out []byte
+	found := false
+	b := extra
+	for len(b) >= 4 {
+		tag := binary.LittleEndian.Uint16(b)
+		size := 4 + int(binary.LittleEndian.Uint16(b[2:]))
+		if size > len(b) {
+			break
+		}
+		if tag == zip64ExtraID {
+			found = true
+		} else {
+			out = append(out, b[:size]...)
+		}
+		b = b[size:]
+	}
+	if !found {
+		return extra
+	}
+	return append(out, b...)
+}
+
 func min64(x, y uint64) uint64 {
 	if x < y {
 		return x
@@ -446,6 +495,9 @@ func (w *Writer) CreateRaw(fh *FileHeader) (io.Writer, error) {
 
 	fh.CompressedSize = uint32(min64(fh.CompressedSize64, uint32max))
 	fh.UncompressedSize = uint32(min64(fh.UncompressedSize64, uint32max))
+	if fh.isZip64() && fh.ReaderVersion < zipVersion45 {
+		fh.ReaderVersion = zipVersion45 // requires 4.5 - File uses ZIP64 format extensions
+	}
 
 	h := &header{
 		FileHeader: fh,
@@ -470,14 +522,17 @@ func (w *Writer) CreateRaw(fh *FileHeader) (io.Writer, error) {
 	return fw, nil
 }
 
-// Copy copies the file f (obtained from a Reader) into w. It copies the raw
-// form directly bypassing decompression, compression, and validation.
+// Copy copies the file f (obtained from a Reader or an Updater) into w.
+// It copies the raw form directly bypassing decompression, compression,
+// and validation. The file may come from any archive, including the
+// one being updated by an Updater, and f is not modified.
 func (w *Writer) Copy(f *File) error {
 	r, err := f.OpenRaw()
 	if err != nil {
 		return err
 	}
-	fw, err := w.CreateRaw(&f.FileHeader)
+	fh := f.FileHeader
+	fw, err := w.CreateRaw(&fh)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,207 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package devirtualize
+
+import (
+	"fmt"
+	"strings"
+
+	"cmd/compile/internal/base"
+	"cmd/compile/internal/ir"
+	"cmd/compile/internal/pgo"
+	"cmd/compile/internal/typecheck"
+	"cmd/compile/internal/types"
+)
+
+// ProfileGuided performs conditional devirtualization of the interface
+// calls in fn, using profile p to pick the hottest concrete callee of
+// each call site. That is, it rewrites
+//
+//	func foo(i Iface) {
+//		i.Foo()
+//	}
+//
+// to
+//
+//	func foo(i Iface) {
+//		if c, ok := i.(Concrete); ok {
+//			c.Foo()
+//		} else {
+//			i.Foo()
+//		}
+//	}
+//
+// The primary benefit of this transformation is that it lets the
+// inliner inline the direct call.
+func ProfileGuided(fn *ir.Func, p *pgo.Profile) {
+	if len(p.OutEdges(ir.LinkFuncName(fn))) == 0 || fn.Type().HasShape() {
+		return
+	}
+
+	ir.CurFunc = fn
+
+	// Calls in go and defer statements must stay where they are.
+	goDeferCall := make(map[*ir.CallExpr]bool)
+
+	var edit func(n ir.Node) ir.Node
+	edit = func(n ir.Node) ir.Node {
+		if n == nil {
+			return n
+		}
+		if gds, ok := n.(*ir.GoDeferStmt); ok {
+			if call, ok := gds.Call.(*ir.CallExpr); ok {
+				goDeferCall[call] = true
+			}
+		}
+
+		ir.EditChildren(n, edit)
+
+		call, ok := n.(*ir.CallExpr)
+		if !ok || call.Op() != ir.OCALLINTER || goDeferCall[call] {
+			return n
+		}
+		if len(call.Args) == 1 && call.Args[0].Type().IsFuncArgStruct() {
+			return n
+		}
+
+		callee, typ := findHotConcreteCallee(p, fn, call)
+		if callee == nil {
+			return n
+		}
+		return rewriteCondCall(call, fn, callee, typ)
+	}
+	ir.EditChildren(fn, edit)
+}
+
+// findHotConcreteCallee returns the hottest concrete method, and its
+// receiver type, that the profile records being called from the
+// interface call at call in caller. It returns nil if there is none
+// that can be used for devirtualization.
+func findHotConcreteCallee(p *pgo.Profile, caller *ir.Func, call *ir.CallExpr) (*ir.Func, *types.Type) {
+	sel := call.X.(*ir.SelectorExpr)
+	offset := p.CallSiteOffset(call, caller)
+
+	for _, e := range p.OutEdges(ir.LinkFuncName(caller)) {
+		if e.CallSiteOffset != offset {
+			continue
+		}
+		callee := p.Func(e.CalleeName)
+		if callee == nil {
+			if base.Debug.PGODebug >= 2 {
+				fmt.Printf("%v: edge %s:%d -> %s: callee not available\n", ir.Line(call), e.CallerName, e.CallSiteOffset, e.CalleeName)
+			}
+			continue
+		}
+		recv := callee.Type().Recv()
+		if recv == nil || !strings.HasSuffix(callee.Sym().Name, "."+sel.Sel.Name) {
+			continue
+		}
+		typ := recv.Type
+		if typ.IsInterface() || typ.HasShape() {
+			continue
+		}
+		if !typecheck.Implements(typ, sel.X.Type()) {
+			if base.Debug.PGODebug >= 2 {
+				fmt.Printf("%v: edge %s:%d -> %s: %v does not implement %v\n", ir.Line(call), e.CallerName, e.CallSiteOffset, e.CalleeName, typ, sel.X.Type())
+			}
+			continue
+		}
+		return callee, typ
+	}
+	return nil, nil
+}
+
+// rewriteCondCall rewrites the interface call to call a concrete
+// method of typ directly when the receiver has that type, falling back
+// to the interface call otherwise.
+func rewriteCondCall(call *ir.CallExpr, curfn, callee *ir.Func, typ *types.Type) ir.Node {
+	if base.Flag.LowerM != 0 {
+		fmt.Printf("%v: PGO devirtualizing %v to %v\n", ir.Line(call), call.X, ir.PkgFuncName(callee))
+	}
+
+	// We generate an OINLCALL of:
+	//
+	//	var recv Iface
+	//	var arg1 A1
+	//	var argN AN
+	//	var ret1 R1
+	//	var retN RN
+	//
+	//	recv, arg1, argN = recv expr, arg1 expr, argN expr
+	//
+	//	t, ok := recv.(Concrete)
+	//	if ok {
+	//		ret1, retN = t.Method(arg1, ... argN)
+	//	} else {
+	//		ret1, retN = recv.Method(arg1, ... argN)
+	//	}
+	//
+	// with ret1, ... retN as its result. This isn't really an inlined
+	// call, but InlinedCallExpr makes handling the results easy.
+
+	sel := call.X.(*ir.SelectorExpr)
+	pos := call.Pos()
+	init := ir.TakeInit(call)
+
+	recv := typecheck.TempAt(pos, curfn, sel.X.Type())
+	init.Append(ir.NewDecl(pos, ir.ODCL, recv))
+	init.Append(typecheck.Stmt(ir.NewAssignStmt(pos, recv, sel.X)))
+	sel.X = recv
+
+	argvars := make([]ir.Node, len(call.Args))
+	for i, arg := range call.Args {
+		v := typecheck.TempAt(pos, curfn, arg.Type())
+		init.Append(ir.NewDecl(pos, ir.ODCL, v))
+		init.Append(typecheck.Stmt(ir.NewAssignStmt(pos, v, arg)))
+		argvars[i] = v
+	}
+	call.Args = argvars
+
+	var retvars []ir.Node
+	for _, ret := range sel.Type().Results().FieldSlice() {
+		v := typecheck.TempAt(pos, curfn, ret.Type)
+		init.Append(ir.NewDecl(pos, ir.ODCL, v))
+		init.Append(typecheck.Stmt(ir.NewAssignStmt(pos, v, nil)))
+		retvars = append(retvars, v)
+	}
+
+	tmpnode := typecheck.TempAt(pos, curfn, typ)
+	tmpok := typecheck.TempAt(pos, curfn, types.Types[types.TBOOL])
+
+	assert := ir.NewTypeAssertExpr(pos, recv, nil)
+	assert.SetType(typ)
+	assertAsList := ir.NewAssignListStmt(pos, ir.OAS2, []ir.Node{tmpnode, tmpok}, []ir.Node{assert})
+	init.Append(typecheck.Stmt(assertAsList))
+
+	concreteCallee := typecheck.Callee(ir.NewSelectorExpr(pos, ir.OXDOT, tmpnode, sel.Sel))
+	// Copy slice so edits in one call don't affect the other.
+	concreteCall := typecheck.Call(pos, concreteCallee, append([]ir.Node(nil), argvars...), call.IsDDD)
+
+	var thenBlock, elseBlock ir.Nodes
+	if len(retvars) == 0 {
+		thenBlock.Append(concreteCall)
+		elseBlock.Append(call)
+	} else {
+		thenRet := append([]ir.Node(nil), retvars...)
+		thenAsList := ir.NewAssignListStmt(pos, ir.OAS2, thenRet, []ir.Node{concreteCall})
+		thenBlock.Append(typecheck.Stmt(thenAsList))
+
+		elseRet := append([]ir.Node(nil), retvars...)
+		elseAsList := ir.NewAssignListStmt(pos, ir.OAS2, elseRet, []ir.Node{call})
+		elseBlock.Append(typecheck.Stmt(elseAsList))
+	}
+
+	cond := ir.NewIfStmt(pos, nil, nil, nil)
+	cond.SetInit(init)
+	cond.Cond = tmpok
+	cond.Body = thenBlock
+	cond.Else = elseBlock
+	cond.Likely = true
+
+	res := ir.NewInlinedCallExpr(pos, []ir.Node{typecheck.Stmt(cond)}, retvars)
+	res.SetType(call.Type())
+	res.SetTypecheck(1)
+	return res
+}
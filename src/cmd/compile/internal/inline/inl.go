@@ -29,11 +29,13 @@ package inline
 import (
 	"fmt"
 	"go/constant"
+	"strconv"
 	"strings"
 
 	"cmd/compile/internal/base"
 	"cmd/compile/internal/ir"
 	"cmd/compile/internal/logopt"
+	"cmd/compile/internal/pgo"
 	"cmd/compile/internal/typecheck"
 	"cmd/compile/internal/types"
 	"cmd/internal/obj"
@@ -53,8 +55,55 @@ const (
 	inlineBigFunctionMaxCost = 20   // Max cost of inlinee when inlining into a "big" function.
 )
 
+var (
+	// Profile used for profile-guided inlining, if any.
+	profile *pgo.Profile
+
+	// List of all hot callee nodes.
+	candHotCalleeMap = make(map[string]bool)
+
+	// List of all hot call sites.
+	candHotEdgeMap = make(map[pgo.CallEdge]bool)
+
+	// Threshold in percentage for hot call site inlining.
+	inlineCDFHotCallSiteThresholdPercent = float64(99)
+
+	// Budget increased due to hotness.
+	inlineHotMaxBudget int32 = 2000
+)
+
+// pgoInlinePrologue records the hot callees and hot call sites of
+// profile p for use by CanInline and mkinlcall.
+func pgoInlinePrologue(p *pgo.Profile) {
+	if s := base.Debug.PGOInlineCDFThreshold; s != "" {
+		if f, err := strconv.ParseFloat(s, 64); err == nil && f >= 0 && f <= 100 {
+			inlineCDFHotCallSiteThresholdPercent = f
+		} else {
+			base.Fatalf("invalid PGOInlineCDFThreshold, must be between 0 and 100")
+		}
+	}
+	if base.Debug.PGOInlineBudget != 0 {
+		inlineHotMaxBudget = int32(base.Debug.PGOInlineBudget)
+	}
+
+	profile = p
+	hot := p.HotEdges(inlineCDFHotCallSiteThresholdPercent)
+	for _, e := range hot {
+		candHotEdgeMap[e] = true
+		candHotCalleeMap[e.CalleeName] = true
+	}
+	if base.Debug.PGODebug > 0 {
+		fmt.Printf("hot-callsite-thres-from-CDF=%v, %d of %d call edges are hot\n", inlineCDFHotCallSiteThresholdPercent, len(hot), len(p.Edges))
+	}
+}
+
 // InlinePackage finds functions that can be inlined and clones them before walk expands them.
-func InlinePackage() {
+// If p is non-nil, hot functions and call sites in the profile get a larger inlining budget.
+func InlinePackage(p *pgo.Profile) {
+	if p != nil && base.Debug.PGOInline > 0 {
+		pgoInlinePrologue(p)
+	}
+
 	ir.VisitFuncsBottomUp(typecheck.Target.Decls, func(list []*ir.Func, recursive bool) {
 		numfns := numNonClosures(list)
 		for _, n := range list {
@@ -166,8 +215,10 @@ func CanInline(fn *ir.Func) {
 	// locals, and we use this map to produce a pruned Inline.Dcl
 	// list. See issue 25249 for more context.
 
+	budget := inlineBudget(fn)
 	visitor := hairyVisitor{
-		budget:        inlineMaxBudget,
+		budget:        budget,
+		maxBudget:     budget,
 		extraCallCost: cc,
 	}
 	if visitor.tooHairy(fn) {
@@ -176,7 +227,7 @@ func CanInline(fn *ir.Func) {
 	}
 
 	n.Func.Inl = &ir.Inline{
-		Cost: inlineMaxBudget - visitor.budget,
+		Cost: budget - visitor.budget,
 		Dcl:  pruneUnusedAutos(n.Defn.(*ir.Func).Dcl, &visitor),
 		Body: inlcopylist(fn.Body),
 
@@ -184,13 +235,25 @@ func CanInline(fn *ir.Func) {
 	}
 
 	if base.Flag.LowerM > 1 {
-		fmt.Printf("%v: can inline %v with cost %d as: %v { %v }\n", ir.Line(fn), n, inlineMaxBudget-visitor.budget, fn.Type(), ir.Nodes(n.Func.Inl.Body))
+		fmt.Printf("%v: can inline %v with cost %d as: %v { %v }\n", ir.Line(fn), n, n.Func.Inl.Cost, fn.Type(), ir.Nodes(n.Func.Inl.Body))
 	} else if base.Flag.LowerM != 0 {
 		fmt.Printf("%v: can inline %v\n", ir.Line(fn), n)
 	}
 	if logopt.Enabled() {
-		logopt.LogOpt(fn.Pos(), "canInlineFunction", "inline", ir.FuncName(fn), fmt.Sprintf("cost: %d", inlineMaxBudget-visitor.budget))
+		logopt.LogOpt(fn.Pos(), "canInlineFunction", "inline", ir.FuncName(fn), fmt.Sprintf("cost: %d", n.Func.Inl.Cost))
+	}
+}
+
+// inlineBudget returns the inlining budget for fn: inlineMaxBudget,
+// or inlineHotMaxBudget if fn is the callee of a hot call site.
+func inlineBudget(fn *ir.Func) int32 {
+	if len(candHotCalleeMap) > 0 && candHotCalleeMap[ir.LinkFuncName(fn)] {
+		if base.Debug.PGODebug > 0 {
+			fmt.Printf("hot-node enabled increased budget=%v for func=%v\n", inlineHotMaxBudget, ir.PkgFuncName(fn))
+		}
+		return inlineHotMaxBudget
 	}
+	return inlineMaxBudget
 }
 
 // canDelayResults reports whether inlined calls to fn can delay
@@ -229,6 +292,7 @@ func canDelayResults(fn *ir.Func) bool {
 // hairiness and whether or not it can be inlined.
 type hairyVisitor struct {
 	budget        int32
+	maxBudget     int32
 	reason        string
 	extraCallCost int32
 	usedLocals    ir.NameSet
@@ -241,7 +305,7 @@ func (v *hairyVisitor) tooHairy(fn *ir.Func) bool {
 		return true
 	}
 	if v.budget < 0 {
-		v.reason = fmt.Sprintf("function too complex: cost %d exceeds budget %d", inlineMaxBudget-v.budget, inlineMaxBudget)
+		v.reason = fmt.Sprintf("function too complex: cost %d exceeds budget %d", v.maxBudget-v.budget, v.maxBudget)
 		return true
 	}
 	return false
@@ -309,7 +373,9 @@ func (v *hairyVisitor) doNode(n ir.Node) bool {
 			break
 		}
 
-		if fn := inlCallee(n.X); fn != nil && typecheck.HaveInlineBody(fn) {
+		if fn := inlCallee(n.X); fn != nil && typecheck.HaveInlineBody(fn) && fn.Inl.Cost <= inlineMaxBudget {
+			// Functions over inlineMaxBudget are only inlined at
+			// hot call sites, so they are charged like any other call.
 			v.budget -= fn.Inl.Cost
 			break
 		}
@@ -492,10 +558,7 @@ func inlcopy(n ir.Node) ir.Node {
 func InlineCalls(fn *ir.Func) {
 	savefn := ir.CurFunc
 	ir.CurFunc = fn
-	maxCost := int32(inlineMaxBudget)
-	if isBigFunc(fn) {
-		maxCost = inlineBigFunctionMaxCost
-	}
+	bigCaller := isBigFunc(fn)
 	// Map to keep track of functions that have been inlined at a particular
 	// call site, in order to stop inlining when we reach the beginning of a
 	// recursion cycle again. We don't inline immediately recursive functions,
@@ -505,7 +568,7 @@ func InlineCalls(fn *ir.Func) {
 	inlMap := make(map[*ir.Func]bool)
 	var edit func(ir.Node) ir.Node
 	edit = func(n ir.Node) ir.Node {
-		return inlnode(n, maxCost, inlMap, edit)
+		return inlnode(n, bigCaller, inlMap, edit)
 	}
 	ir.EditChildren(fn, edit)
 	ir.CurFunc = savefn
@@ -524,7 +587,7 @@ func InlineCalls(fn *ir.Func) {
 // shorter and less complicated.
 // The result of inlnode MUST be assigned back to n, e.g.
 // 	n.Left = inlnode(n.Left)
-func inlnode(n ir.Node, maxCost int32, inlMap map[*ir.Func]bool, edit func(ir.Node) ir.Node) ir.Node {
+func inlnode(n ir.Node, bigCaller bool, inlMap map[*ir.Func]bool, edit func(ir.Node) ir.Node) ir.Node {
 	if n == nil {
 		return n
 	}
@@ -586,7 +649,7 @@ func inlnode(n ir.Node, maxCost int32, inlMap map[*ir.Func]bool, edit func(ir.No
 			break
 		}
 		if fn := inlCallee(call.X); fn != nil && typecheck.HaveInlineBody(fn) {
-			n = mkinlcall(call, fn, maxCost, inlMap, edit)
+			n = mkinlcall(call, fn, bigCaller, inlMap, edit)
 		}
 	}
 
@@ -659,7 +722,7 @@ var NewInline = func(call *ir.CallExpr, fn *ir.Func, inlIndex int) *ir.InlinedCa
 // parameters.
 // The result of mkinlcall MUST be assigned back to n, e.g.
 // 	n.Left = mkinlcall(n.Left, fn, isddd)
-func mkinlcall(n *ir.CallExpr, fn *ir.Func, maxCost int32, inlMap map[*ir.Func]bool, edit func(ir.Node) ir.Node) ir.Node {
+func mkinlcall(n *ir.CallExpr, fn *ir.Func, bigCaller bool, inlMap map[*ir.Func]bool, edit func(ir.Node) ir.Node) ir.Node {
 	if fn.Inl == nil {
 		if logopt.Enabled() {
 			logopt.LogOpt(n.Pos(), "cannotInlineCall", "inline", ir.FuncName(ir.CurFunc),
@@ -667,12 +730,12 @@ func mkinlcall(n *ir.CallExpr, fn *ir.Func, maxCost int32, inlMap map[*ir.Func]b
 		}
 		return n
 	}
-	if fn.Inl.Cost > maxCost {
+	if ok, maxCost := inlineCostOK(n, ir.CurFunc, fn, bigCaller); !ok {
 		// The inlined function body is too big. Typically we use this check to restrict
 		// inlining into very big functions.  See issue 26546 and 17566.
 		if logopt.Enabled() {
 			logopt.LogOpt(n.Pos(), "cannotInlineCall", "inline", ir.FuncName(ir.CurFunc),
-				fmt.Sprintf("cost %d of %s exceeds max large caller cost %d", fn.Inl.Cost, ir.PkgFuncName(fn), maxCost))
+				fmt.Sprintf("cost %d of %s exceeds max caller cost %d", fn.Inl.Cost, ir.PkgFuncName(fn), maxCost))
 		}
 		return n
 	}
@@ -768,6 +831,48 @@ func mkinlcall(n *ir.CallExpr, fn *ir.Func, maxCost int32, inlMap map[*ir.Func]b
 	return res
 }
 
+// inlineCostOK reports whether the call n from caller to callee is
+// cheap enough to inline. bigCaller reports whether caller is a big
+// function. If inlineCostOK returns false, it also returns the maximum
+// cost that callee exceeded.
+func inlineCostOK(n *ir.CallExpr, caller, callee *ir.Func, bigCaller bool) (bool, int32) {
+	maxCost := int32(inlineMaxBudget)
+	if bigCaller {
+		maxCost = inlineBigFunctionMaxCost
+	}
+	if callee.Inl.Cost <= maxCost {
+		// Simple case. Function is already cheap enough.
+		return true, 0
+	}
+
+	// Hot call sites may inline callees up to inlineHotMaxBudget, but
+	// only into functions that aren't big.
+	if len(candHotEdgeMap) == 0 || caller == nil || n.Op() != ir.OCALLFUNC {
+		return false, maxCost
+	}
+	e := pgo.CallEdge{
+		CallerName: ir.LinkFuncName(caller),
+		CalleeName: ir.LinkFuncName(callee),
+	}
+	e.CallSiteOffset = profile.CallSiteOffset(n, caller)
+	if !candHotEdgeMap[e] {
+		return false, maxCost
+	}
+	if bigCaller {
+		if base.Debug.PGODebug > 0 {
+			fmt.Printf("hot-big check disallows inlining for call %s (cost %d) at %v in big function %s\n", ir.PkgFuncName(callee), callee.Inl.Cost, ir.Line(n), ir.PkgFuncName(caller))
+		}
+		return false, maxCost
+	}
+	if callee.Inl.Cost > inlineHotMaxBudget {
+		return false, inlineHotMaxBudget
+	}
+	if base.Debug.PGODebug > 0 {
+		fmt.Printf("hot-budget check allows inlining for call %s (cost %d) at %v in function %s\n", ir.PkgFuncName(callee), callee.Inl.Cost, ir.Line(n), ir.PkgFuncName(caller))
+	}
+	return true, 0
+}
+
 // CalleeEffects appends any side effects from evaluating callee to init.
 func CalleeEffects(init *ir.Nodes, callee ir.Node) {
 	for {
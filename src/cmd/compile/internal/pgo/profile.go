@@ -0,0 +1,273 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package pgo reads CPU profiles in pprof format and summarizes them
+// for profile-guided optimization.
+//
+// A profile is reduced to a weighted call graph. Each edge is a call
+// from a caller to a callee at a particular call site, weighted by the
+// total sample value of the stacks in which that call appears.
+// Functions are identified by their linker symbol names, which is how
+// the runtime reports them in profiles (see ir.LinkFuncName).
+//
+// When the profile records the start line of the calling function, a
+// call site is identified by its line offset from that start line, so
+// that a profile stays useful as unrelated code in the same file is
+// edited. Otherwise, call sites are identified by absolute line number.
+package pgo
+
+import (
+	"fmt"
+	"internal/profile"
+	"os"
+	"sort"
+
+	"cmd/compile/internal/base"
+	"cmd/compile/internal/ir"
+	"cmd/compile/internal/typecheck"
+)
+
+// CallEdge identifies a call from one function to another at a
+// particular call site.
+type CallEdge struct {
+	CallerName     string // linker symbol name of the caller
+	CalleeName     string // linker symbol name of the callee
+	CallSiteOffset int    // call site line; see Profile.CallSiteOffset
+}
+
+// Profile is the compiler's summary of a CPU profile.
+type Profile struct {
+	// TotalEdgeWeight is the sum of the weights of all edges.
+	TotalEdgeWeight int64
+
+	// Edges maps each call edge observed in the profile to its weight.
+	Edges map[CallEdge]int64
+
+	// outEdges indexes the keys of Edges by caller name, hottest first.
+	outEdges map[string][]CallEdge
+
+	// startLine records the start line of each function for which the
+	// profile has one.
+	startLine map[string]int
+
+	// funcs maps the linker symbol names of functions that appear in
+	// the profile to their IR, for those functions whose IR is
+	// available to this compilation.
+	funcs map[string]*ir.Func
+}
+
+// New reads the profile in profileFile and summarizes it against the
+// functions in typecheck.Target. It returns a nil Profile if the
+// profile contains no samples.
+func New(profileFile string) (*Profile, error) {
+	f, err := os.Open(profileFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	prof, err := profile.Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing profile: %v", err)
+	}
+	if len(prof.Sample) == 0 {
+		// A profile without samples is valid, but there is nothing to do.
+		return nil, nil
+	}
+
+	// Samples count is the raw data collected, and CPU nanoseconds is
+	// just a scaled version of it, so either one will do.
+	valueIndex := -1
+	for i, s := range prof.SampleType {
+		if (s.Type == "samples" && s.Unit == "count") ||
+			(s.Type == "cpu" && s.Unit == "nanoseconds") {
+			valueIndex = i
+			break
+		}
+	}
+	if valueIndex == -1 {
+		return nil, fmt.Errorf("profile does not contain a sample index with value/type samples/count or cpu/nanoseconds")
+	}
+
+	p := &Profile{
+		Edges:     make(map[CallEdge]int64),
+		outEdges:  make(map[string][]CallEdge),
+		startLine: make(map[string]int),
+		funcs:     make(map[string]*ir.Func),
+	}
+	p.addSamples(prof, valueIndex)
+	p.indexEdges()
+	p.indexFuncs()
+	return p, nil
+}
+
+// addSamples accumulates the call edges of each sample in prof.
+func (p *Profile) addSamples(prof *profile.Profile, valueIndex int) {
+	var frames []profile.Line
+	seen := make(map[CallEdge]bool)
+	for _, s := range prof.Sample {
+		w := s.Value[valueIndex]
+		if w == 0 {
+			continue
+		}
+
+		// Flatten the stack into logical frames, leaf first. Within a
+		// location, inlined frames are also listed innermost first.
+		frames = frames[:0]
+		for _, loc := range s.Location {
+			frames = append(frames, loc.Line...)
+		}
+
+		for i := 0; i+1 < len(frames); i++ {
+			callee, caller := frames[i], frames[i+1]
+			if callee.Function == nil || caller.Function == nil {
+				continue
+			}
+			if start := caller.Function.StartLine; start != 0 {
+				p.startLine[caller.Function.Name] = int(start)
+			}
+			e := CallEdge{
+				CallerName:     caller.Function.Name,
+				CalleeName:     callee.Function.Name,
+				CallSiteOffset: int(caller.Line - caller.Function.StartLine),
+			}
+			// Count an edge once per sample, even if recursion puts
+			// it on the stack more than once.
+			if seen[e] {
+				continue
+			}
+			seen[e] = true
+			p.Edges[e] += w
+			p.TotalEdgeWeight += w
+		}
+		for e := range seen {
+			delete(seen, e)
+		}
+	}
+}
+
+// indexEdges builds outEdges from Edges.
+func (p *Profile) indexEdges() {
+	for _, e := range p.sortedEdges() {
+		p.outEdges[e.CallerName] = append(p.outEdges[e.CallerName], e)
+	}
+}
+
+// indexFuncs records the IR of each function in the profile that is
+// declared in this package or statically called from it.
+func (p *Profile) indexFuncs() {
+	names := make(map[string]bool)
+	for e := range p.Edges {
+		names[e.CallerName] = true
+		names[e.CalleeName] = true
+	}
+	add := func(fn *ir.Func) {
+		if fn == nil || fn.Nname == nil {
+			return
+		}
+		if name := ir.LinkFuncName(fn); names[name] {
+			p.funcs[name] = fn
+		}
+	}
+	for _, n := range typecheck.Target.Decls {
+		fn, ok := n.(*ir.Func)
+		if !ok {
+			continue
+		}
+		add(fn)
+		ir.VisitList(fn.Body, func(n ir.Node) {
+			if call, ok := n.(*ir.CallExpr); ok && call.Op() == ir.OCALLFUNC {
+				add(staticCallee(call.X))
+			}
+		})
+	}
+}
+
+// staticCallee returns the function called by a call to fn, if it is
+// statically known.
+func staticCallee(fn ir.Node) *ir.Func {
+	fn = ir.StaticValue(fn)
+	switch fn.Op() {
+	case ir.OMETHEXPR:
+		if n := ir.MethodExprName(fn); n != nil {
+			return n.Func
+		}
+	case ir.ONAME:
+		fn := fn.(*ir.Name)
+		if fn.Class == ir.PFUNC {
+			return fn.Func
+		}
+	}
+	return nil
+}
+
+// sortedEdges returns the edges of p, hottest first. Edges of equal
+// weight are ordered by their fields so the result is deterministic.
+func (p *Profile) sortedEdges() []CallEdge {
+	edges := make([]CallEdge, 0, len(p.Edges))
+	for e := range p.Edges {
+		edges = append(edges, e)
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		ei, ej := edges[i], edges[j]
+		if wi, wj := p.Edges[ei], p.Edges[ej]; wi != wj {
+			return wi > wj
+		}
+		if ei.CallerName != ej.CallerName {
+			return ei.CallerName < ej.CallerName
+		}
+		if ei.CalleeName != ej.CalleeName {
+			return ei.CalleeName < ej.CalleeName
+		}
+		return ei.CallSiteOffset < ej.CallSiteOffset
+	})
+	return edges
+}
+
+// HotEdges returns the hottest edges of p, hottest first, that
+// together account for thresholdPercent of the total edge weight.
+func (p *Profile) HotEdges(thresholdPercent float64) []CallEdge {
+	var hot []CallEdge
+	var cum int64
+	for _, e := range p.sortedEdges() {
+		if WeightInPercentage(cum, p.TotalEdgeWeight) >= thresholdPercent {
+			break
+		}
+		cum += p.Edges[e]
+		hot = append(hot, e)
+	}
+	return hot
+}
+
+// OutEdges returns the edges whose caller is the function named
+// caller, hottest first.
+func (p *Profile) OutEdges(caller string) []CallEdge {
+	return p.outEdges[caller]
+}
+
+// Func returns the IR of the function with the given linker symbol
+// name, or nil if it is not available to this compilation.
+func (p *Profile) Func(name string) *ir.Func {
+	return p.funcs[name]
+}
+
+// CallSiteOffset returns the call site line of n within caller, in the
+// form used by CallEdge: the line offset of n from the start of caller
+// if the profile records caller's start line, and the absolute line of
+// n otherwise.
+func (p *Profile) CallSiteOffset(n ir.Node, caller *ir.Func) int {
+	line := int(base.Ctxt.InnermostPos(n.Pos()).Line())
+	if _, ok := p.startLine[ir.LinkFuncName(caller)]; !ok {
+		return line
+	}
+	return line - int(base.Ctxt.InnermostPos(caller.Pos()).Line())
+}
+
+// WeightInPercentage converts a weight into a percentage of total.
+func WeightInPercentage(value int64, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(value) / float64(total) * 100
+}
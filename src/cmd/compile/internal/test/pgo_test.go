@@ -0,0 +1,197 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"fmt"
+	"internal/profile"
+	"internal/testenv"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+const pgoSrc = `package main
+
+type Iface interface {
+	M(int) int
+}
+
+type T struct {
+	n int
+}
+
+func (t *T) M(a int) int {
+	for i := 0; i < a; i++ {
+		t.n += i
+		if t.n > 1000 {
+			t.n -= 1000
+		}
+		switch {
+		case t.n%3 == 0:
+			t.n += 7
+		case t.n%5 == 0:
+			t.n += 11
+		case t.n%7 == 0:
+			t.n ^= 3
+		case t.n%11 == 0:
+			t.n += 13
+		case t.n%13 == 0:
+			t.n -= 17
+		case t.n%17 == 0:
+			t.n *= 19
+		case t.n%19 == 0:
+			t.n /= 23
+		}
+		if t.n < 0 {
+			t.n = -t.n
+		}
+	}
+	return t.n
+}
+
+func hot(a int) int {
+	t := 0
+	for i := 0; i < a; i++ {
+		t += i * i
+		if t > 1000 {
+			t -= 1000
+		}
+		switch {
+		case t%3 == 0:
+			t += 7
+		case t%5 == 0:
+			t += 11
+		case t%7 == 0:
+			t ^= 3
+		case t%11 == 0:
+			t += 13
+		case t%13 == 0:
+			t -= 17
+		case t%17 == 0:
+			t *= 19
+		case t%19 == 0:
+			t /= 23
+		}
+		if t < 0 {
+			t = -t
+		}
+	}
+	return t
+}
+
+func caller(i Iface, a int) int {
+	x := i.M(a)
+	y := hot(a)
+	return x + y
+}
+
+func main() {
+	println(caller(&T{}, 10))
+}
+`
+
+// Lines of interest in pgoSrc.
+const (
+	pgoCallerLine = 70 // func caller
+	pgoMLine      = 71 // i.M(a)
+	pgoHotLine    = 72 // hot(a)
+)
+
+// writePGOProfile writes a CPU profile in which caller calls
+// (*T).M at pgoMLine and hot at pgoHotLine. If withStartLine is
+// set, the profile records the start line of caller.
+func writePGOProfile(t *testing.T, path string, withStartLine bool) {
+	callerFn := &profile.Function{ID: 1, Name: "main.caller", SystemName: "main.caller", Filename: "x.go"}
+	if withStartLine {
+		callerFn.StartLine = pgoCallerLine
+	}
+	mFn := &profile.Function{ID: 2, Name: "main.(*T).M", SystemName: "main.(*T).M", Filename: "x.go"}
+	hotFn := &profile.Function{ID: 3, Name: "main.hot", SystemName: "main.hot", Filename: "x.go"}
+
+	loc := func(id uint64, fn *profile.Function, line int64) *profile.Location {
+		return &profile.Location{ID: id, Address: id, Line: []profile.Line{{Function: fn, Line: line}}}
+	}
+	mLoc := loc(1, mFn, 20)
+	hotLoc := loc(2, hotFn, 50)
+	callMLoc := loc(3, callerFn, pgoMLine)
+	callHotLoc := loc(4, callerFn, pgoHotLine)
+
+	p := &profile.Profile{
+		SampleType: []*profile.ValueType{
+			{Type: "samples", Unit: "count"},
+			{Type: "cpu", Unit: "nanoseconds"},
+		},
+		PeriodType: &profile.ValueType{Type: "cpu", Unit: "nanoseconds"},
+		Period:     10000000,
+		Sample: []*profile.Sample{
+			{Location: []*profile.Location{mLoc, callMLoc}, Value: []int64{100, 1000000000}},
+			{Location: []*profile.Location{hotLoc, callHotLoc}, Value: []int64{100, 1000000000}},
+		},
+		Location: []*profile.Location{mLoc, hotLoc, callMLoc, callHotLoc},
+		Function: []*profile.Function{callerFn, mFn, hotFn},
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := p.Write(f); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestPGO checks that a profile makes the compiler inline a hot call
+// whose callee is over the normal inlining budget, and devirtualize a
+// hot interface call.
+func TestPGO(t *testing.T) {
+	testenv.MustHaveGoBuild(t)
+	t.Parallel()
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "x.go")
+	if err := os.WriteFile(src, []byte(pgoSrc), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	compile := func(args ...string) string {
+		t.Helper()
+		args = append([]string{"tool", "compile", "-p=main", "-o", filepath.Join(dir, "x.o"), "-m"}, args...)
+		args = append(args, src)
+		out, err := testenv.CleanCmdEnv(exec.Command(testenv.GoToolPath(t), args...)).CombinedOutput()
+		if err != nil {
+			t.Fatalf("go %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+		return string(out)
+	}
+
+	want := []*regexp.Regexp{
+		regexp.MustCompile(fmt.Sprintf(`x\.go:%d:\d+: inlining call to hot`, pgoHotLine)),
+		regexp.MustCompile(fmt.Sprintf(`x\.go:%d:\d+: PGO devirtualizing i\.M to main\.\(\*T\)\.M`, pgoMLine)),
+		regexp.MustCompile(fmt.Sprintf(`x\.go:%d:\d+: inlining call to \(\*T\)\.M`, pgoMLine)),
+	}
+
+	out := compile()
+	for _, re := range want {
+		if re.MatchString(out) {
+			t.Errorf("without a profile, output unexpectedly matches %q:\n%s", re, out)
+		}
+	}
+
+	for _, withStartLine := range []bool{false, true} {
+		prof := filepath.Join(dir, fmt.Sprintf("start%v.pprof", withStartLine))
+		writePGOProfile(t, prof, withStartLine)
+		out := compile("-pgoprofile=" + prof)
+		for _, re := range want {
+			if !re.MatchString(out) {
+				t.Errorf("with profile (start line %v), output does not match %q:\n%s", withStartLine, re, out)
+			}
+		}
+	}
+}
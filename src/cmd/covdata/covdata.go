@@ -0,0 +1,195 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"internal/coverage"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const usageMessage = `usage: go tool covdata <mode> -i=<dir1,dir2,...> [flags]
+
+The modes are:
+
+	merge     merge data files from the input directories
+	subtract  subtract later input directories from the first
+	textfmt   convert data files to the legacy text profile format
+
+For the flags of a mode, run "go tool covdata <mode> -help".
+`
+
+func usage() {
+	fmt.Fprint(os.Stderr, usageMessage)
+	os.Exit(2)
+}
+
+var (
+	inputs  commaList // -i flag
+	outFlag string    // -o flag
+)
+
+type commaList []string
+
+func (l *commaList) String() string { return strings.Join(*l, ",") }
+
+func (l *commaList) Set(s string) error {
+	*l = nil
+	for _, f := range strings.Split(s, ",") {
+		if f != "" {
+			*l = append(*l, f)
+		}
+	}
+	return nil
+}
+
+type mode struct {
+	help string // description of -o
+	run  func()
+}
+
+var modes = map[string]mode{
+	"merge":    {"output directory", runMerge},
+	"subtract": {"output directory", runSubtract},
+	"textfmt":  {"output file", runTextfmt},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	name := os.Args[1]
+	m, ok := modes[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "covdata: unknown mode %q\n", name)
+		usage()
+	}
+
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Var(&inputs, "i", "comma-separated list of input directories")
+	fs.StringVar(&outFlag, "o", "", m.help)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: go tool covdata %s -i=<dir1,dir2,...> -o=<%s>\n", name, m.help)
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(os.Args[2:])
+	if fs.NArg() != 0 || len(inputs) == 0 || outFlag == "" {
+		fs.Usage()
+	}
+	m.run()
+}
+
+func fatalf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "covdata: "+format+"\n", args...)
+	os.Exit(1)
+}
+
+// A pod is a decoded meta-data file together with the counters of
+// all its counter data files, summed.
+type pod struct {
+	meta     *coverage.Meta
+	hash     coverage.Hash
+	counters [][]uint32
+}
+
+// readPods reads the coverage data files in dirs, returning one pod
+// for each distinct meta-data file.
+func readPods(dirs []string) []*pod {
+	cps, err := coverage.CollectPods(dirs)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	var pods []*pod
+	for _, cp := range cps {
+		data, err := os.ReadFile(cp.MetaFile)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		m, err := coverage.DecodeMeta(data)
+		if err != nil {
+			fatalf("%s: %v", cp.MetaFile, err)
+		}
+		p := &pod{meta: m, hash: m.Hash()}
+		p.counters = make([][]uint32, len(m.Files))
+		for i, f := range m.Files {
+			p.counters[i] = make([]uint32, len(f.Blocks))
+		}
+		for _, file := range cp.CounterFiles {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				fatalf("%v", err)
+			}
+			c, err := coverage.DecodeCounters(data)
+			if err == nil && c.MetaHash != p.hash {
+				err = fmt.Errorf("counter data is for meta-data file %s", coverage.MetaFileName(c.MetaHash))
+			}
+			if err == nil {
+				err = c.Check(m)
+			}
+			if err != nil {
+				fatalf("%s: %v", file, err)
+			}
+			p.add(c.Files)
+		}
+		pods = append(pods, p)
+	}
+	return pods
+}
+
+// add adds the given counter values to p.
+// In set mode, the counters only record whether a block executed.
+func (p *pod) add(files [][]uint32) {
+	for i, f := range files {
+		for j, v := range f {
+			p.counters[i][j] = addCount(p.meta.Mode, p.counters[i][j], v)
+		}
+	}
+}
+
+// addCount returns the sum of two counter values in the given mode,
+// saturating rather than overflowing.
+func addCount(mode string, x, y uint32) uint32 {
+	if mode == "set" {
+		if x != 0 || y != 0 {
+			return 1
+		}
+		return 0
+	}
+	if x > math.MaxUint32-y {
+		return math.MaxUint32
+	}
+	return x + y
+}
+
+// writePods writes the meta-data and counter data files of pods to dir.
+func writePods(dir string, pods []*pod) {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		fatalf("%v", err)
+	}
+	for _, p := range pods {
+		meta := filepath.Join(dir, coverage.MetaFileName(p.hash))
+		if err := os.WriteFile(meta, p.meta.Encode(), 0666); err != nil {
+			fatalf("%v", err)
+		}
+		c := &coverage.Counters{MetaHash: p.hash, Files: p.counters}
+		name := filepath.Join(dir, coverage.CounterFileName(p.hash, os.Getpid(), time.Now().UnixNano()))
+		if err := os.WriteFile(name, c.Encode(), 0666); err != nil {
+			fatalf("%v", err)
+		}
+	}
+}
+
+// A blockKey identifies a basic block independently of the program
+// that was instrumented.
+type blockKey struct {
+	pkgPath, file string
+	block         coverage.Block
+}
@@ -0,0 +1,130 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main_test
+
+import (
+	"internal/testenv"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const progSrc = `package main
+
+import "os"
+
+func main() {
+	switch len(os.Args) {
+	case 1:
+		println("no args")
+	case 2:
+		println("one arg")
+	default:
+		println("more args")
+	}
+}
+`
+
+// run runs the command cmd with arguments args, with GOCOVERDIR set
+// to covdir if it is not empty, and returns its output.
+func run(t *testing.T, covdir, cmd string, args ...string) string {
+	t.Helper()
+	c := exec.Command(cmd, args...)
+	c.Env = os.Environ()
+	if covdir != "" {
+		c.Env = append(c.Env, "GOCOVERDIR="+covdir)
+	}
+	out, err := c.CombinedOutput()
+	if err != nil {
+		t.Fatalf("%s %s: %v\n%s", cmd, strings.Join(args, " "), err, out)
+	}
+	return string(out)
+}
+
+func TestCovdata(t *testing.T) {
+	testenv.MustHaveGoBuild(t)
+	if testing.Short() {
+		t.Skip("skipping in short mode")
+	}
+
+	dir := t.TempDir()
+	covdata := filepath.Join(dir, "covdata.exe")
+	run(t, "", testenv.GoToolPath(t), "build", "-o", covdata, ".")
+
+	src := filepath.Join(dir, "prog.go")
+	if err := os.WriteFile(src, []byte(progSrc), 0666); err != nil {
+		t.Fatal(err)
+	}
+	prog := filepath.Join(dir, "prog.exe")
+	run(t, "", testenv.GoToolPath(t), "build", "-cover", "-covermode=count", "-o", prog, src)
+
+	// Run the program twice with one argument and once with two,
+	// writing the data to different directories.
+	dir1 := filepath.Join(dir, "d1")
+	dir2 := filepath.Join(dir, "d2")
+	for _, d := range []string{dir1, dir2} {
+		if err := os.Mkdir(d, 0777); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run(t, dir1, prog, "a")
+	run(t, dir1, prog, "a")
+	run(t, dir2, prog, "a", "b")
+
+	// textfmt writes one line per block, with the counts summed.
+	profile := func(dirs ...string) map[string]string {
+		t.Helper()
+		out := filepath.Join(dir, "profile.txt")
+		run(t, "", covdata, "textfmt", "-i="+strings.Join(dirs, ","), "-o="+out)
+		data, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+		if lines[0] != "mode: count" {
+			t.Fatalf("profile begins with %q, want %q", lines[0], "mode: count")
+		}
+		// Map the line number of each block's start to its count.
+		counts := make(map[string]string)
+		for _, line := range lines[1:] {
+			_, pos, _ := strings.Cut(line, ".go:")
+			start, _, _ := strings.Cut(pos, ".")
+			f := strings.Fields(line)
+			counts[start] = f[len(f)-1]
+		}
+		return counts
+	}
+	check := func(name string, got map[string]string, want map[string]string) {
+		t.Helper()
+		for line, count := range want {
+			if got[line] != count {
+				t.Errorf("%s: block at line %s has count %q, want %q", name, line, got[line], count)
+			}
+		}
+	}
+	// Line 5 is the switch, line 7 is "no args", line 9 is "one arg",
+	// and line 11 is "more args".
+	check("textfmt d1", profile(dir1), map[string]string{"5": "2", "7": "0", "9": "2", "11": "0"})
+	check("textfmt d1,d2", profile(dir1, dir2), map[string]string{"5": "3", "7": "0", "9": "2", "11": "1"})
+
+	// merge combines the counter data files into one.
+	merged := filepath.Join(dir, "merged")
+	run(t, "", covdata, "merge", "-i="+dir1+","+dir2, "-o="+merged)
+	ents, err := os.ReadDir(merged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ents) != 2 {
+		t.Errorf("merge wrote %d files, want 2", len(ents))
+	}
+	check("merge", profile(merged), map[string]string{"5": "3", "7": "0", "9": "2", "11": "1"})
+
+	// subtract removes the blocks covered by d1 from the merged data.
+	diff := filepath.Join(dir, "diff")
+	run(t, "", covdata, "subtract", "-i="+merged+","+dir1, "-o="+diff)
+	check("subtract", profile(diff), map[string]string{"5": "0", "7": "0", "9": "0", "11": "1"})
+}
@@ -0,0 +1,42 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Covdata is a program for manipulating and generating reports
+from the coverage data files written by programs built with
+"go build -cover". Such a program writes a meta-data file and a
+counter data file to the directory named by the GOCOVERDIR
+environment variable each time it runs.
+
+Usage:
+
+	go tool covdata <mode> -i=<dir1,dir2,...> [flags]
+
+The modes are:
+
+	merge     merge data files from the input directories, writing
+	          the result to the directory given by -o
+	subtract  subtract the coverage of the second and later input
+	          directories from that of the first, writing the result
+	          to the directory given by -o
+	textfmt   convert the data files to the legacy text profile
+	          format used by 'go test -coverprofile', writing the
+	          result to the file given by -o
+
+Examples:
+
+Merge the data files from two runs of a program:
+
+	$ go tool covdata merge -i=run1,run2 -o=merged
+
+Find the code that was covered by the first run but not the second:
+
+	$ go tool covdata subtract -i=run1,run2 -o=diff
+
+Produce a profile for use with 'go tool cover':
+
+	$ go tool covdata textfmt -i=merged -o=cov.txt
+	$ go tool cover -html=cov.txt
+*/
+package main
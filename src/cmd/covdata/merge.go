@@ -0,0 +1,13 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+// runMerge implements the merge mode. The counter data files for
+// each meta-data file in the input directories are summed into a
+// single counter data file, which is written together with the
+// meta-data file to the output directory.
+func runMerge() {
+	writePods(outFlag, readPods(inputs))
+}
@@ -0,0 +1,40 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+// runSubtract implements the subtract mode. It writes the data files
+// of the first input directory to the output directory, with the
+// counters zeroed for every block that executed according to any of
+// the other input directories. Blocks are matched by package, file
+// and position, so the other directories may hold data from
+// different programs.
+func runSubtract() {
+	if len(inputs) < 2 {
+		fatalf("subtract requires at least two input directories")
+	}
+	pods := readPods(inputs[:1])
+
+	covered := make(map[blockKey]bool)
+	for _, p := range readPods(inputs[1:]) {
+		for i, f := range p.meta.Files {
+			for j, b := range f.Blocks {
+				if p.counters[i][j] != 0 {
+					covered[blockKey{f.PkgPath, f.Name, b}] = true
+				}
+			}
+		}
+	}
+
+	for _, p := range pods {
+		for i, f := range p.meta.Files {
+			for j, b := range f.Blocks {
+				if covered[blockKey{f.PkgPath, f.Name, b}] {
+					p.counters[i][j] = 0
+				}
+			}
+		}
+	}
+	writePods(outFlag, pods)
+}
@@ -0,0 +1,78 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// runTextfmt implements the textfmt mode. It writes the coverage
+// data in the input directories as a single profile in the text
+// format produced by 'go test -coverprofile'. Blocks that appear in
+// the data of more than one program have their counts combined.
+func runTextfmt() {
+	pods := readPods(inputs)
+
+	mode := ""
+	counts := make(map[blockKey]uint32)
+	for _, p := range pods {
+		if mode == "" {
+			mode = p.meta.Mode
+		} else if p.meta.Mode != mode {
+			fatalf("cannot combine coverage modes %q and %q", mode, p.meta.Mode)
+		}
+		for i, f := range p.meta.Files {
+			for j, b := range f.Blocks {
+				k := blockKey{f.PkgPath, f.Name, b}
+				counts[k] = addCount(mode, counts[k], p.counters[i][j])
+			}
+		}
+	}
+	if mode == "" {
+		fatalf("no coverage data files found in %s", inputs.String())
+	}
+
+	keys := make([]blockKey, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		ki, kj := keys[i], keys[j]
+		if ki.file != kj.file {
+			return ki.file < kj.file
+		}
+		bi, bj := ki.block, kj.block
+		if bi.StartLine != bj.StartLine {
+			return bi.StartLine < bj.StartLine
+		}
+		if bi.StartCol != bj.StartCol {
+			return bi.StartCol < bj.StartCol
+		}
+		if bi.EndLine != bj.EndLine {
+			return bi.EndLine < bj.EndLine
+		}
+		return bi.EndCol < bj.EndCol
+	})
+
+	f, err := os.Create(outFlag)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	w := bufio.NewWriter(f)
+	fmt.Fprintf(w, "mode: %s\n", mode)
+	for _, k := range keys {
+		b := k.block
+		fmt.Fprintf(w, "%s:%d.%d,%d.%d %d %d\n", k.file, b.StartLine, b.StartCol, b.EndLine, b.EndCol, b.NumStmt, counts[k])
+	}
+	if err := w.Flush(); err != nil {
+		fatalf("%v", err)
+	}
+	if err := f.Close(); err != nil {
+		fatalf("%v", err)
+	}
+}
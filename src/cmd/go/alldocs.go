@@ -132,6 +132,27 @@
 // 		do not delete it when exiting.
 // 	-x
 // 		print the commands.
+// 	-cover
+// 		enable code coverage instrumentation. A program built with
+// 		-cover writes coverage data files to the directory named by
+// 		the GOCOVERDIR environment variable when it exits.
+// 		See 'go tool covdata' for processing the data files.
+// 	-covermode set,count,atomic
+// 		set the mode for coverage analysis.
+// 		The default is "set" unless -race is enabled,
+// 		in which case it is "atomic".
+// 		The values:
+// 		set: bool: does this statement run?
+// 		count: int: how many times does this statement run?
+// 		atomic: int: count, but correct in multithreaded tests;
+// 			significantly more expensive.
+// 		Sets -cover.
+// 	-coverpkg pattern1,pattern2,pattern3
+// 		apply coverage analysis to the packages matching the patterns.
+// 		The default is to apply coverage analysis only to the packages
+// 		named on the command line and the other packages in the main module.
+// 		See 'go help packages' for a description of package patterns.
+// 		Sets -cover.
 //
 // 	-asmflags '[pattern=]arg list'
 // 		arguments to pass on each go tool asm invocation.
@@ -188,6 +209,13 @@
 // 		include path must be in the same directory as the Go package they are
 // 		included from, and overlays will not appear when binaries and tests are
 // 		run through go run and go test respectively.
+// 	-pgo file
+// 		specify the file path of a CPU profile in pprof format for
+// 		profile-guided optimization (PGO). The profile is applied to all
+// 		packages in the build. When the special name "auto" is specified
+// 		and the build has a single main package, the go command selects a
+// 		file named "default.pgo" in that package's directory, if it exists.
+// 		The special name "off" turns off PGO. The default is "auto".
 // 	-pkgdir dir
 // 		install and load all packages from dir instead of the usual locations.
 // 		For example, when building with a non-standard configuration,
@@ -2041,6 +2069,9 @@
 // 	GOCACHE
 // 		The directory where the go command will store cached
 // 		information for reuse in future builds.
+// 	GOCOVERDIR
+// 		The directory into which programs built with "go build -cover"
+// 		write their coverage data files. See 'go tool covdata'.
 // 	GOMODCACHE
 // 		The directory where the go command will store downloaded modules.
 // 	GODEBUG
@@ -0,0 +1,268 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package load
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"cmd/go/internal/base"
+	"cmd/go/internal/cfg"
+)
+
+// CoverMainDeps are the packages imported by the code that
+// PrepareForCoverageBuild adds to main packages.
+var CoverMainDeps = []string{
+	"internal/coverage/rtcov",
+	"runtime/coverage",
+}
+
+// EnsureImport ensures that package p imports the named package.
+func EnsureImport(p *Package, pkg string) {
+	for _, d := range p.Internal.Imports {
+		if d.Name == pkg {
+			return
+		}
+	}
+
+	p1 := LoadImportWithFlags(pkg, p.Dir, p, &ImportStack{}, nil, 0)
+	if p1.Error != nil {
+		base.Fatalf("load %s: %v", pkg, p1.Error)
+	}
+
+	p.Internal.Imports = append(p.Internal.Imports, p1)
+}
+
+// DeclareCoverVars attaches the required cover variables names
+// to the files, to be used when annotating the files.
+func DeclareCoverVars(p *Package, files ...string) map[string]*CoverVar {
+	coverVars := make(map[string]*CoverVar)
+	coverIndex := 0
+	// We create the cover counters as new top-level variables in the package.
+	// We need to avoid collisions with user variables (GoCover_0 is unlikely but still)
+	// and more importantly with dot imports of other covered packages,
+	// so we append 12 hex digits from the SHA-256 of the import path.
+	// The point is only to avoid accidents, not to defeat users determined to
+	// break things.
+	sum := sha256.Sum256([]byte(p.ImportPath))
+	h := fmt.Sprintf("%x", sum[:6])
+	for _, file := range files {
+		if base.IsTestFile(file) {
+			continue
+		}
+		// For a package that is "local" (imported via ./ import or command line, outside GOPATH),
+		// we record the full path to the file name.
+		// Otherwise we record the import path, then a forward slash, then the file name.
+		// This makes profiles within GOPATH file system-independent.
+		// These names appear in the cmd/cover HTML interface.
+		var longFile string
+		if p.Internal.Local {
+			longFile = filepath.Join(p.Dir, file)
+		} else {
+			longFile = path.Join(p.ImportPath, file)
+		}
+		coverVars[file] = &CoverVar{
+			File: longFile,
+			Var:  fmt.Sprintf("GoCover_%d_%x", coverIndex, h),
+		}
+		coverIndex++
+	}
+	return coverVars
+}
+
+// PrepareForCoverageBuild is called for "go build -cover" and its
+// relatives. It selects the packages to instrument, as directed by
+// the -coverpkg flag, and arranges for each main package in pkgs to
+// register the coverage counters of the instrumented packages it
+// depends on, so that the program writes coverage data when it exits.
+func PrepareForCoverageBuild(pkgs []*Package) {
+	var match []func(*Package) bool
+	if len(cfg.BuildCoverPkg) != 0 {
+		match = make([]func(*Package) bool, len(cfg.BuildCoverPkg))
+		for i := range cfg.BuildCoverPkg {
+			match[i] = MatchPackage(cfg.BuildCoverPkg[i], base.Cwd())
+		}
+	} else {
+		// By default, instrument the packages named on the command
+		// line and the other packages of the main module.
+		match = []func(*Package) bool{func(p *Package) bool {
+			return p.Internal.CmdlinePkg || p.Internal.CmdlineFiles || p.Module != nil && p.Module.Main
+		}}
+	}
+
+	matched := make([]bool, len(match))
+	for _, p := range PackageList(pkgs) {
+		haveMatch := false
+		for i := range match {
+			if match[i](p) {
+				matched[i] = true
+				haveMatch = true
+			}
+		}
+		if !haveMatch {
+			continue
+		}
+
+		// There is nothing to cover in package unsafe; it comes from the compiler.
+		if p.ImportPath == "unsafe" {
+			continue
+		}
+
+		// A package which only has test files can't be imported
+		// as a dependency, nor can it be instrumented for coverage.
+		if len(p.GoFiles)+len(p.CgoFiles) == 0 {
+			continue
+		}
+
+		// Silently ignore attempts to run coverage on
+		// sync/atomic when using atomic coverage mode.
+		// Atomic coverage mode uses sync/atomic, so
+		// we can't also do coverage on it.
+		if cfg.BuildCoverMode == "atomic" && p.Standard && p.ImportPath == "sync/atomic" {
+			continue
+		}
+
+		// If using the race detector, silently ignore
+		// attempts to run coverage on the runtime
+		// packages. It will cause the race detector
+		// to be invoked before it has been initialized.
+		if cfg.BuildRace && p.Standard && (p.ImportPath == "runtime" || strings.HasPrefix(p.ImportPath, "runtime/internal")) {
+			continue
+		}
+
+		p.Internal.CoverMode = cfg.BuildCoverMode
+		var coverFiles []string
+		coverFiles = append(coverFiles, p.GoFiles...)
+		coverFiles = append(coverFiles, p.CgoFiles...)
+		p.Internal.CoverVars = DeclareCoverVars(p, coverFiles...)
+		if cfg.BuildCoverMode == "atomic" {
+			EnsureImport(p, "sync/atomic")
+		}
+	}
+
+	// Warn about -coverpkg arguments that are not actually used.
+	for i := range cfg.BuildCoverPkg {
+		if !matched[i] {
+			fmt.Fprintf(os.Stderr, "warning: no packages being built depend on matches for pattern %s\n", cfg.BuildCoverPkg[i])
+		}
+	}
+
+	for _, p := range pkgs {
+		if p.Name == "main" {
+			addCoverMain(p)
+		}
+	}
+}
+
+// addCoverMain arranges for the main package p to register the
+// coverage counters of the instrumented packages in its build.
+func addCoverMain(p *Package) {
+	var t coverMain
+	t.Mode = cfg.BuildCoverMode
+	for _, p1 := range PackageList([]*Package{p}) {
+		if len(p1.Internal.CoverVars) == 0 {
+			continue
+		}
+		t.Pkgs = append(t.Pkgs, coverMainPkg{
+			Package: p1,
+			Main:    p1 == p,
+			Vars:    sortedCoverVars(p1.Internal.CoverVars),
+		})
+	}
+	if len(t.Pkgs) == 0 {
+		return
+	}
+
+	for _, dep := range CoverMainDeps {
+		alreadyImported := false
+		for _, p1 := range p.Internal.Imports {
+			if p1.ImportPath == dep {
+				alreadyImported = true
+				break
+			}
+		}
+		if alreadyImported {
+			continue
+		}
+		var stk ImportStack
+		p1 := LoadImportWithFlags(dep, cfg.GOROOTsrc, nil, &stk, nil, 0)
+		if p1.Error != nil {
+			base.Fatalf("load %s: %v", dep, p1.Error)
+		}
+		p.Internal.Imports = append(p.Internal.Imports, p1)
+	}
+	for _, cp := range t.Pkgs {
+		if cp.Main {
+			continue
+		}
+		alreadyImported := false
+		for _, p1 := range p.Internal.Imports {
+			if p1 == cp.Package {
+				alreadyImported = true
+				break
+			}
+		}
+		if !alreadyImported {
+			p.Internal.Imports = append(p.Internal.Imports, cp.Package)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := coverMainTmpl.Execute(&buf, &t); err != nil {
+		base.Fatalf("go: generating coverage registration for %s: %v", p.ImportPath, err)
+	}
+	p.Internal.CoverMainGo = buf.Bytes()
+}
+
+// sortedCoverVars returns the values of vars, sorted by file name.
+func sortedCoverVars(vars map[string]*CoverVar) []*CoverVar {
+	list := make([]*CoverVar, 0, len(vars))
+	for _, v := range vars {
+		list = append(list, v)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].File < list[j].File })
+	return list
+}
+
+type coverMain struct {
+	Mode string
+	Pkgs []coverMainPkg
+}
+
+type coverMainPkg struct {
+	*Package
+	Main bool // package is the main package itself
+	Vars []*CoverVar
+}
+
+var coverMainTmpl = template.Must(template.New("main").Parse(`
+// Code generated by 'go build -cover'. DO NOT EDIT.
+
+package main
+
+import (
+	_covrt "internal/coverage/rtcov"
+	_ "runtime/coverage"
+{{range $i, $p := .Pkgs}}{{if not $p.Main}}
+	_cover{{$i}} {{printf "%q" $p.ImportPath}}
+{{- end}}{{end}}
+)
+
+func init() {
+	_covrt.Mode = {{printf "%q" .Mode}}
+{{- range $i, $p := .Pkgs}}
+{{- range $v := $p.Vars}}
+	_covrt.AddFile({{printf "%q" $p.ImportPath}}, {{printf "%q" $v.File}}, {{if not $p.Main}}_cover{{$i}}.{{end}}{{$v.Var}}.Count[:], {{if not $p.Main}}_cover{{$i}}.{{end}}{{$v.Var}}.Pos[:], {{if not $p.Main}}_cover{{$i}}.{{end}}{{$v.Var}}.NumStmt[:])
+{{- end}}
+{{- end}}
+}
+`))
@@ -221,12 +221,14 @@ type PackageInternal struct {
 	FuzzInstrument    bool                 // package should be instrumented for fuzzing
 	CoverMode         string               // preprocess Go source files with the coverage tool in this mode
 	CoverVars         map[string]*CoverVar // variables created by coverage analysis
+	CoverMainGo       []byte               // content for _covermain.go, registering coverage variables
 	OmitDebug         bool                 // tell linker not to write debug information
 	GobinSubdir       bool                 // install target would be subdir of GOBIN
 	BuildInfo         string               // add this info to package main
 	TestmainGo        *[]byte              // content for _testmain.go
 	Embed             map[string][]string  // //go:embed comment mapping
 	OrigImportPath    string               // original import path before adding '_test' suffix
+	PGOProfile        string               // path to PGO profile
 
 	Asmflags   []string // -asmflags for this package
 	Gcflags    []string // -gcflags for this package
@@ -2764,9 +2766,47 @@ func PackagesAndErrors(ctx context.Context, opts PackageOpts, patterns []string)
 	// their dependencies).
 	setToolFlags(pkgs...)
 
+	setPGOProfilePath(pkgs)
+
 	return pkgs
 }
 
+// setPGOProfilePath sets the PGO profile path, as selected by the
+// -pgo flag, for pkgs and all of their dependencies.
+func setPGOProfilePath(pkgs []*Package) {
+	var file string
+	switch cfg.BuildPGO {
+	case "", "off":
+		return
+	case "auto":
+		// Use the default.pgo file in the directory of the main
+		// package, but only if the build has a single main package.
+		var mainPkg *Package
+		for _, p := range pkgs {
+			if p.Name != "main" {
+				continue
+			}
+			if mainPkg != nil {
+				return
+			}
+			mainPkg = p
+		}
+		if mainPkg == nil || mainPkg.Dir == "" {
+			return
+		}
+		file = filepath.Join(mainPkg.Dir, "default.pgo")
+		if fi, err := os.Stat(file); err != nil || !fi.Mode().IsRegular() {
+			return
+		}
+	default:
+		file = cfg.BuildPGO
+	}
+
+	for _, p := range PackageList(pkgs) {
+		p.Internal.PGOProfile = file
+	}
+}
+
 // CheckPackageErrors prints errors encountered loading pkgs and their
 // dependencies, then exits with a non-zero status if any errors were found.
 func CheckPackageErrors(pkgs []*Package) {
@@ -2975,6 +3015,7 @@ func GoFilesPackage(ctx context.Context, opts PackageOpts, gofiles []string) *Pa
 		pkg.Error = &PackageError{Err: &mainPackageError{importPath: pkg.ImportPath}}
 	}
 	setToolFlags(pkg)
+	setPGOProfilePath([]*Package{pkg})
 
 	return pkg
 }
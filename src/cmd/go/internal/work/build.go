@@ -7,6 +7,7 @@ package work
 import (
 	"context"
 	"errors"
+	"flag"
 	"fmt"
 	"go/build"
 	exec "internal/execabs"
@@ -85,6 +86,27 @@ and test commands:
 		do not delete it when exiting.
 	-x
 		print the commands.
+	-cover
+		enable code coverage instrumentation. A program built with
+		-cover writes coverage data files to the directory named by
+		the GOCOVERDIR environment variable when it exits.
+		See 'go tool covdata' for processing the data files.
+	-covermode set,count,atomic
+		set the mode for coverage analysis.
+		The default is "set" unless -race is enabled,
+		in which case it is "atomic".
+		The values:
+		set: bool: does this statement run?
+		count: int: how many times does this statement run?
+		atomic: int: count, but correct in multithreaded tests;
+			significantly more expensive.
+		Sets -cover.
+	-coverpkg pattern1,pattern2,pattern3
+		apply coverage analysis to the packages matching the patterns.
+		The default is to apply coverage analysis only to the packages
+		named on the command line and the other packages in the main module.
+		See 'go help packages' for a description of package patterns.
+		Sets -cover.
 
 	-asmflags '[pattern=]arg list'
 		arguments to pass on each go tool asm invocation.
@@ -141,6 +163,13 @@ and test commands:
 		include path must be in the same directory as the Go package they are
 		included from, and overlays will not appear when binaries and tests are
 		run through go run and go test respectively.
+	-pgo file
+		specify the file path of a CPU profile in pprof format for
+		profile-guided optimization (PGO). The profile is applied to all
+		packages in the build. When the special name "auto" is specified
+		and the build has a single main package, the go command selects a
+		file named "default.pgo" in that package's directory, if it exists.
+		The special name "off" turns off PGO. The default is "auto".
 	-pkgdir dir
 		install and load all packages from dir instead of the usual locations.
 		For example, when building with a non-standard configuration,
@@ -209,6 +238,8 @@ func init() {
 
 	AddBuildFlags(CmdBuild, DefaultBuildFlags)
 	AddBuildFlags(CmdInstall, DefaultBuildFlags)
+	AddCoverFlags(CmdBuild)
+	AddCoverFlags(CmdInstall)
 }
 
 // Note that flags consulted by other parts of the code
@@ -294,6 +325,7 @@ func AddBuildFlags(cmd *base.Command, mask BuildFlagMask) {
 	cmd.Flag.StringVar(&cfg.BuildContext.InstallSuffix, "installsuffix", "", "")
 	cmd.Flag.Var(&load.BuildLdflags, "ldflags", "")
 	cmd.Flag.BoolVar(&cfg.BuildLinkshared, "linkshared", false, "")
+	cmd.Flag.StringVar(&cfg.BuildPGO, "pgo", "auto", "")
 	cmd.Flag.StringVar(&cfg.BuildPkgdir, "pkgdir", "", "")
 	cmd.Flag.BoolVar(&cfg.BuildRace, "race", false, "")
 	cmd.Flag.BoolVar(&cfg.BuildMSan, "msan", false, "")
@@ -309,6 +341,54 @@ func AddBuildFlags(cmd *base.Command, mask BuildFlagMask) {
 	cmd.Flag.StringVar(&cfg.DebugTrace, "debug-trace", "", "")
 }
 
+// AddCoverFlags adds the coverage-related flags to the build, install,
+// and run commands. The test command has its own coverage flags.
+func AddCoverFlags(cmd *base.Command) {
+	cmd.Flag.BoolVar(&cfg.BuildCover, "cover", false, "")
+	cmd.Flag.Var(coverFlag{(*coverModeFlag)(&cfg.BuildCoverMode)}, "covermode", "")
+	cmd.Flag.Var(coverFlag{commaListFlag{&cfg.BuildCoverPkg}}, "coverpkg", "")
+}
+
+// A coverFlag is a flag.Value that also implies -cover.
+type coverFlag struct{ v flag.Value }
+
+func (f coverFlag) String() string { return f.v.String() }
+
+func (f coverFlag) Set(value string) error {
+	if err := f.v.Set(value); err != nil {
+		return err
+	}
+	cfg.BuildCover = true
+	return nil
+}
+
+type coverModeFlag string
+
+func (f *coverModeFlag) String() string { return string(*f) }
+func (f *coverModeFlag) Set(value string) error {
+	switch value {
+	case "", "set", "count", "atomic":
+		*f = coverModeFlag(value)
+		return nil
+	default:
+		return errors.New(`valid modes are "set", "count", or "atomic"`)
+	}
+}
+
+// A commaListFlag is a flag.Value representing a comma-separated list.
+type commaListFlag struct{ vals *[]string }
+
+func (f commaListFlag) String() string { return strings.Join(*f.vals, ",") }
+
+func (f commaListFlag) Set(value string) error {
+	if value == "" {
+		*f.vals = nil
+	} else {
+		*f.vals = strings.Split(value, ",")
+	}
+	return nil
+}
+
 // tagsFlag is the implementation of the -tags flag.
 type tagsFlag []string
 
@@ -381,6 +461,9 @@ func runBuild(ctx context.Context, cmd *base.Command, args []string) {
 
 	pkgs := load.PackagesAndErrors(ctx, load.PackageOpts{LoadVCS: cfg.BuildBuildvcs}, args)
 	load.CheckPackageErrors(pkgs)
+	if cfg.BuildCover {
+		load.PrepareForCoverageBuild(pkgs)
+	}
 
 	explicitO := len(cfg.BuildO) > 0
 
@@ -627,6 +710,9 @@ func runInstall(ctx context.Context, cmd *base.Command, args []string) {
 		}
 	}
 	load.CheckPackageErrors(pkgs)
+	if cfg.BuildCover {
+		load.PrepareForCoverageBuild(pkgs)
+	}
 	if cfg.BuildI {
 		allGoroot := true
 		for _, pkg := range pkgs {
@@ -787,6 +873,9 @@ func installOutsideModule(ctx context.Context, args []string) {
 		base.Fatalf("go: %v", err)
 	}
 	load.CheckPackageErrors(pkgs)
+	if cfg.BuildCover {
+		load.PrepareForCoverageBuild(pkgs)
+	}
 	patterns := make([]string, len(args))
 	for i, arg := range args {
 		patterns[i] = arg[:strings.Index(arg, "@")]
@@ -9,6 +9,7 @@ package work
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -263,6 +264,9 @@ func (b *Builder) buildActionID(a *Action) cache.ActionID {
 	if p.Internal.ForceLibrary {
 		fmt.Fprintf(h, "forcelibrary\n")
 	}
+	if p.Internal.PGOProfile != "" {
+		fmt.Fprintf(h, "pgofile %s\n", b.fileHash(p.Internal.PGOProfile))
+	}
 	if len(p.CgoFiles)+len(p.SwigFiles)+len(p.SwigCXXFiles) > 0 {
 		fmt.Fprintf(h, "cgo %q\n", b.toolID("cgo"))
 		cppflags, cflags, cxxflags, fflags, ldflags, _ := b.CFlags(p)
@@ -298,6 +302,9 @@ func (b *Builder) buildActionID(a *Action) cache.ActionID {
 	if p.Internal.CoverMode != "" {
 		fmt.Fprintf(h, "cover %q %q\n", p.Internal.CoverMode, b.toolID("cover"))
 	}
+	if p.Internal.CoverMainGo != nil {
+		fmt.Fprintf(h, "covermain %x\n", sha256.Sum256(p.Internal.CoverMainGo))
+	}
 	if p.Internal.FuzzInstrument {
 		if fuzzFlags := fuzzInstrumentFlags(); fuzzFlags != nil {
 			fmt.Fprintf(h, "fuzz %q\n", fuzzFlags)
@@ -659,6 +666,16 @@ OverlayLoop:
 		}
 	}
 
+	// If this is a main package built with -cover, add the file that
+	// registers the coverage counters of the program's packages.
+	if a.Package.Internal.CoverMainGo != nil {
+		coverMain := objdir + "_covermain.go"
+		if err := b.writeFile(coverMain, a.Package.Internal.CoverMainGo); err != nil {
+			return err
+		}
+		gofiles = append(gofiles, coverMain)
+	}
+
 	// Run cgo.
 	if a.Package.UsesCgo() || a.Package.UsesSwig() {
 		// In a package using cgo, cgo compiles the C, C++ and assembly files with gcc.
@@ -0,0 +1,52 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package constraints
+
+import "testing"
+
+type (
+	testSigned[T Signed]     struct{ f T }
+	testUnsigned[T Unsigned] struct{ f T }
+	testInteger[T Integer]   struct{ f T }
+	testFloat[T Float]       struct{ f T }
+	testComplex[T Complex]   struct{ f T }
+	testOrdered[T Ordered]   struct{ f T }
+)
+
+// TestTypes passes if it compiles.
+type TestTypes struct {
+	_ testSigned[int]
+	_ testSigned[int64]
+	_ testUnsigned[uint]
+	_ testUnsigned[uintptr]
+	_ testInteger[int8]
+	_ testInteger[uint8]
+	_ testInteger[uintptr]
+	_ testFloat[float32]
+	_ testComplex[complex64]
+	_ testOrdered[int]
+	_ testOrdered[float64]
+	_ testOrdered[string]
+}
+
+func min[T Ordered](a, b T) T {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func TestOrdered(t *testing.T) {
+	type myString string
+	if got := min(3, 2); got != 2 {
+		t.Errorf("min(3, 2) = %d, want 2", got)
+	}
+	if got := min(myString("b"), "a"); got != "a" {
+		t.Errorf(`min("b", "a") = %q, want "a"`, got)
+	}
+	if got := min(1.5, -0.5); got != -0.5 {
+		t.Errorf("min(1.5, -0.5) = %v, want -0.5", got)
+	}
+}
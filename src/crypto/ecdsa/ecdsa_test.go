@@ -6,6 +6,7 @@ package ecdsa
 
 import (
 	"bufio"
+	"bytes"
 	"compress/bzip2"
 	"crypto/elliptic"
 	"crypto/rand"
@@ -327,6 +328,64 @@ func testZeroHashSignature(t *testing.T, curve elliptic.Curve) {
 	}
 }
 
+func TestECDH(t *testing.T) {
+	testAllCurves(t, testECDH)
+}
+
+func testECDH(t *testing.T, c elliptic.Curve) {
+	priv, err := GenerateKey(c, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	peer, err := GenerateKey(c, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c == elliptic.P224() {
+		if _, err := priv.ECDH(); err == nil {
+			t.Error("ECDH succeeded for P-224 private key")
+		}
+		if _, err := priv.PublicKey.ECDH(); err == nil {
+			t.Error("ECDH succeeded for P-224 public key")
+		}
+		return
+	}
+
+	ecdhPriv, err := priv.ECDH()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(ecdhPriv.Bytes(), priv.D.FillBytes(make([]byte, len(ecdhPriv.Bytes())))) {
+		t.Errorf("ECDH private key encoding does not match D")
+	}
+	ecdhPub, err := priv.PublicKey.ECDH()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ecdhPub.Equal(ecdhPriv.PublicKey()) {
+		t.Errorf("converted public key does not match the converted private key's")
+	}
+
+	peerPub, err := peer.PublicKey.ECDH()
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, err := ecdhPriv.ECDH(peerPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x, _ := c.ScalarMult(peer.X, peer.Y, priv.D.Bytes())
+	if want := x.FillBytes(make([]byte, len(secret))); !bytes.Equal(secret, want) {
+		t.Errorf("ECDH = %x, want %x", secret, want)
+	}
+
+	invalid := &PublicKey{Curve: c, X: big.NewInt(1), Y: big.NewInt(1)}
+	if _, err := invalid.ECDH(); err == nil {
+		t.Error("ECDH succeeded for a point not on the curve")
+	}
+}
+
 func benchmarkAllCurves(t *testing.B, f func(*testing.B, elliptic.Curve)) {
 	tests := []struct {
 		name  string
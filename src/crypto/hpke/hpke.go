@@ -0,0 +1,298 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package hpke implements Hybrid Public Key Encryption (HPKE), as specified
+// in RFC 9180.
+//
+// An HPKE ciphersuite is the combination of a KEM, a KDF, and an AEAD. This
+// package supports DHKEM(P-256, HKDF-SHA256) and DHKEM(X25519, HKDF-SHA256),
+// HKDF-SHA256, HKDF-SHA384 and HKDF-SHA512, and AES-128-GCM, AES-256-GCM and
+// ChaCha20-Poly1305.
+//
+// All four modes of RFC 9180 are supported: the base mode (NewSender and
+// NewRecipient), the PSK mode, where both parties also share a pre-shared key
+// (NewPSKSender and NewPSKRecipient), the auth mode, where the recipient is
+// assured that the sender held a given private key (NewAuthSender and
+// NewAuthRecipient), and the combined auth-PSK mode (NewAuthPSKSender and
+// NewAuthPSKRecipient).
+package hpke
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// The HPKE modes, as defined in RFC 9180, Section 5.
+const (
+	modeBase    uint8 = 0x00
+	modePSK     uint8 = 0x01
+	modeAuth    uint8 = 0x02
+	modeAuthPSK uint8 = 0x03
+)
+
+type context struct {
+	kdf            KDF
+	suiteID        []byte
+	exporterSecret []byte
+
+	// aead and baseNonce are nil for the ExportOnly AEAD.
+	aead      cipher.AEAD
+	baseNonce []byte
+
+	// seqNum is incremented for each Seal or Open call. RFC 9180 allows up to
+	// 2^96 messages with the supported AEADs, but 2^64 ought to be enough.
+	seqNum uint64
+}
+
+// A Sender is the sending side of an HPKE context, obtained from NewSender,
+// NewPSKSender, NewAuthSender or NewAuthPSKSender.
+//
+// A Sender is stateful, as each call to Seal uses a new nonce, and it's not
+// safe for concurrent use.
+type Sender struct {
+	*context
+}
+
+// A Recipient is the receiving side of an HPKE context, obtained from
+// NewRecipient, NewPSKRecipient, NewAuthRecipient or NewAuthPSKRecipient.
+//
+// A Recipient is stateful, as each successful call to Open uses a new nonce,
+// and it's not safe for concurrent use.
+type Recipient struct {
+	*context
+}
+
+// The minimum PSK length, per RFC 9180, Section 5.1.2.
+const minPSKLength = 32
+
+// verifyPSKInputs implements VerifyPSKInputs, as defined in RFC 9180,
+// Section 5.1.
+func verifyPSKInputs(mode uint8, psk, pskID []byte) error {
+	gotPSK := len(psk) != 0
+	gotPSKID := len(pskID) != 0
+	if gotPSK != gotPSKID {
+		return errors.New("hpke: inconsistent PSK inputs")
+	}
+	if gotPSK && (mode == modeBase || mode == modeAuth) {
+		return errors.New("hpke: PSK input provided when not needed")
+	}
+	if !gotPSK && (mode == modePSK || mode == modeAuthPSK) {
+		return errors.New("hpke: missing required PSK input")
+	}
+	if gotPSK && len(psk) < minPSKLength {
+		return errors.New("hpke: PSK is shorter than 32 bytes")
+	}
+	return nil
+}
+
+// newContext implements KeySchedule, as defined in RFC 9180, Section 5.1.
+func newContext(mode uint8, sharedSecret []byte, kem KEM, kdf KDF, aead AEAD, info, psk, pskID []byte) (*context, error) {
+	if err := verifyPSKInputs(mode, psk, pskID); err != nil {
+		return nil, err
+	}
+
+	sid := suiteID(kem.ID(), kdf.ID(), aead.ID())
+
+	pskIDHash := kdf.labeledExtract(sid, nil, "psk_id_hash", pskID)
+	infoHash := kdf.labeledExtract(sid, nil, "info_hash", info)
+	ksContext := append([]byte{mode}, pskIDHash...)
+	ksContext = append(ksContext, infoHash...)
+
+	secret := kdf.labeledExtract(sid, sharedSecret, "secret", psk)
+
+	ctx := &context{
+		kdf:            kdf,
+		suiteID:        sid,
+		exporterSecret: kdf.labeledExpand(sid, secret, "exp", ksContext, uint16(kdf.size())),
+	}
+	if _, ok := aead.(exportOnlyAEAD); ok {
+		return ctx, nil
+	}
+
+	key := kdf.labeledExpand(sid, secret, "key", ksContext, uint16(aead.keySize()))
+	a, err := aead.aead(key)
+	if err != nil {
+		return nil, err
+	}
+	ctx.aead = a
+	ctx.baseNonce = kdf.labeledExpand(sid, secret, "base_nonce", ksContext, uint16(aead.nonceSize()))
+	return ctx, nil
+}
+
+func suiteID(kemID, kdfID, aeadID uint16) []byte {
+	suiteID := make([]byte, 0, 4+2+2+2)
+	suiteID = append(suiteID, "HPKE"...)
+	suiteID = binary.BigEndian.AppendUint16(suiteID, kemID)
+	suiteID = binary.BigEndian.AppendUint16(suiteID, kdfID)
+	suiteID = binary.BigEndian.AppendUint16(suiteID, aeadID)
+	return suiteID
+}
+
+func newSender(mode uint8, pk PublicKey, sk PrivateKey, kdf KDF, aead AEAD, info, psk, pskID []byte) ([]byte, *Sender, error) {
+	sharedSecret, enc, err := pk.encap(sk)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx, err := newContext(mode, sharedSecret, pk.KEM(), kdf, aead, info, psk, pskID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return enc, &Sender{ctx}, nil
+}
+
+func newRecipient(mode uint8, enc []byte, sk PrivateKey, pk PublicKey, kdf KDF, aead AEAD, info, psk, pskID []byte) (*Recipient, error) {
+	sharedSecret, err := sk.decap(enc, pk)
+	if err != nil {
+		return nil, err
+	}
+	ctx, err := newContext(mode, sharedSecret, sk.KEM(), kdf, aead, info, psk, pskID)
+	if err != nil {
+		return nil, err
+	}
+	return &Recipient{ctx}, nil
+}
+
+// NewSender sets up a base mode HPKE context for encrypting messages to the
+// holder of the private key corresponding to pk, as specified by SetupBaseS
+// in RFC 9180.
+//
+// The info parameter is public information that binds the context to an
+// application, and must match on the recipient side. The returned enc must
+// be transmitted to the recipient, to pass to NewRecipient.
+func NewSender(pk PublicKey, kdf KDF, aead AEAD, info []byte) (enc []byte, s *Sender, err error) {
+	return newSender(modeBase, pk, nil, kdf, aead, info, nil, nil)
+}
+
+// NewRecipient sets up a base mode HPKE context for decrypting messages from
+// the sender that produced enc with NewSender, as specified by SetupBaseR in
+// RFC 9180.
+func NewRecipient(enc []byte, sk PrivateKey, kdf KDF, aead AEAD, info []byte) (*Recipient, error) {
+	return newRecipient(modeBase, enc, sk, nil, kdf, aead, info, nil, nil)
+}
+
+// NewPSKSender is like NewSender, but sets up a PSK mode context, as
+// specified by SetupPSKS in RFC 9180. The recipient must hold the same
+// pre-shared key psk, which must be at least 32 bytes long, and its
+// identifier pskID.
+func NewPSKSender(pk PublicKey, kdf KDF, aead AEAD, info, psk, pskID []byte) (enc []byte, s *Sender, err error) {
+	return newSender(modePSK, pk, nil, kdf, aead, info, psk, pskID)
+}
+
+// NewPSKRecipient is like NewRecipient, but sets up a PSK mode context, as
+// specified by SetupPSKR in RFC 9180.
+func NewPSKRecipient(enc []byte, sk PrivateKey, kdf KDF, aead AEAD, info, psk, pskID []byte) (*Recipient, error) {
+	return newRecipient(modePSK, enc, sk, nil, kdf, aead, info, psk, pskID)
+}
+
+// NewAuthSender is like NewSender, but sets up an auth mode context, as
+// specified by SetupAuthS in RFC 9180. The recipient can only set up the
+// matching context with the public key corresponding to the sender private
+// key skS, which must belong to the same KEM as pk.
+func NewAuthSender(pk PublicKey, skS PrivateKey, kdf KDF, aead AEAD, info []byte) (enc []byte, s *Sender, err error) {
+	if skS == nil {
+		return nil, nil, errors.New("hpke: missing sender private key")
+	}
+	return newSender(modeAuth, pk, skS, kdf, aead, info, nil, nil)
+}
+
+// NewAuthRecipient is like NewRecipient, but sets up an auth mode context, as
+// specified by SetupAuthR in RFC 9180. It fails unless enc was produced with
+// the private key corresponding to pkS.
+func NewAuthRecipient(enc []byte, sk PrivateKey, pkS PublicKey, kdf KDF, aead AEAD, info []byte) (*Recipient, error) {
+	if pkS == nil {
+		return nil, errors.New("hpke: missing sender public key")
+	}
+	return newRecipient(modeAuth, enc, sk, pkS, kdf, aead, info, nil, nil)
+}
+
+// NewAuthPSKSender combines NewAuthSender and NewPSKSender, setting up an
+// auth-PSK mode context, as specified by SetupAuthPSKS in RFC 9180.
+func NewAuthPSKSender(pk PublicKey, skS PrivateKey, kdf KDF, aead AEAD, info, psk, pskID []byte) (enc []byte, s *Sender, err error) {
+	if skS == nil {
+		return nil, nil, errors.New("hpke: missing sender private key")
+	}
+	return newSender(modeAuthPSK, pk, skS, kdf, aead, info, psk, pskID)
+}
+
+// NewAuthPSKRecipient combines NewAuthRecipient and NewPSKRecipient, setting
+// up an auth-PSK mode context, as specified by SetupAuthPSKR in RFC 9180.
+func NewAuthPSKRecipient(enc []byte, sk PrivateKey, pkS PublicKey, kdf KDF, aead AEAD, info, psk, pskID []byte) (*Recipient, error) {
+	if pkS == nil {
+		return nil, errors.New("hpke: missing sender public key")
+	}
+	return newRecipient(modeAuthPSK, enc, sk, pkS, kdf, aead, info, psk, pskID)
+}
+
+var errExportOnly = errors.New("hpke: context uses the export-only AEAD")
+
+func (ctx *context) nextNonce() ([]byte, error) {
+	if ctx.aead == nil {
+		return nil, errExportOnly
+	}
+	if ctx.seqNum == math.MaxUint64 {
+		return nil, errors.New("hpke: message limit reached")
+	}
+	nonce := make([]byte, ctx.aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], ctx.seqNum)
+	for i := range ctx.baseNonce {
+		nonce[i] ^= ctx.baseNonce[i]
+	}
+	return nonce, nil
+}
+
+// Seal encrypts and authenticates plaintext, authenticates the additional
+// data aad, and returns the ciphertext.
+//
+// The recipient must call Open on the ciphertexts in the same order they
+// were produced by Seal.
+func (s *Sender) Seal(aad, plaintext []byte) ([]byte, error) {
+	nonce, err := s.nextNonce()
+	if err != nil {
+		return nil, err
+	}
+	ciphertext := s.aead.Seal(nil, nonce, plaintext, aad)
+	s.seqNum++
+	return ciphertext, nil
+}
+
+// Open decrypts and authenticates ciphertext, authenticates the additional
+// data aad, and returns the plaintext.
+//
+// If authentication fails, Open returns an error, and the next call will
+// still expect the same message.
+func (r *Recipient) Open(aad, ciphertext []byte) ([]byte, error) {
+	nonce, err := r.nextNonce()
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := r.aead.Open(nil, nonce, ciphertext, aad)
+	if err != nil {
+		return nil, err
+	}
+	r.seqNum++
+	return plaintext, nil
+}
+
+// Export derives a secret of the given length from the context and
+// exporterContext, as specified in RFC 9180, Section 5.3. The recipient
+// derives the same secret with Recipient.Export.
+func (s *Sender) Export(exporterContext []byte, length int) ([]byte, error) {
+	return s.export(exporterContext, length)
+}
+
+// Export derives a secret of the given length from the context and
+// exporterContext, as specified in RFC 9180, Section 5.3. The sender derives
+// the same secret with Sender.Export.
+func (r *Recipient) Export(exporterContext []byte, length int) ([]byte, error) {
+	return r.export(exporterContext, length)
+}
+
+func (ctx *context) export(exporterContext []byte, length int) ([]byte, error) {
+	if length < 0 || length > 255*ctx.kdf.size() {
+		return nil, errors.New("hpke: invalid export length")
+	}
+	return ctx.kdf.labeledExpand(ctx.suiteID, ctx.exporterSecret, "sec", exporterContext, uint16(length)), nil
+}
@@ -0,0 +1,461 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hpke
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func mustDecodeHex(t *testing.T, in string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+type testVector struct {
+	Mode        uint8  `json:"mode"`
+	KEMID       uint16 `json:"kem_id"`
+	KDFID       uint16 `json:"kdf_id"`
+	AEADID      uint16 `json:"aead_id"`
+	Info        string `json:"info"`
+	IKME        string `json:"ikmE"`
+	IKMR        string `json:"ikmR"`
+	IKMS        string `json:"ikmS"`
+	SKRm        string `json:"skRm"`
+	SKSm        string `json:"skSm"`
+	PKRm        string `json:"pkRm"`
+	PKSm        string `json:"pkSm"`
+	PSK         string `json:"psk"`
+	PSKID       string `json:"psk_id"`
+	Enc         string `json:"enc"`
+	Encryptions []struct {
+		AAD string `json:"aad"`
+		CT  string `json:"ct"`
+		PT  string `json:"pt"`
+	} `json:"encryptions"`
+	Exports []struct {
+		ExporterContext string `json:"exporter_context"`
+		L               int    `json:"L"`
+		ExportedValue   string `json:"exported_value"`
+	} `json:"exports"`
+}
+
+// TestRFC9180Vectors checks the test vectors from RFC 9180, Appendix A, for
+// the supported KEMs. The encryptions are truncated to the first ten of each
+// vector.
+func TestRFC9180Vectors(t *testing.T) {
+	data, err := os.ReadFile("testdata/rfc9180.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vectors []testVector
+	if err := json.Unmarshal(data, &vectors); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range vectors {
+		name := fmt.Sprintf("mode %d, KEM %04x, KDF %04x, AEAD %04x", v.Mode, v.KEMID, v.KDFID, v.AEADID)
+		t.Run(name, func(t *testing.T) {
+			testVectorCase(t, v)
+		})
+	}
+}
+
+func testVectorCase(t *testing.T, v testVector) {
+	kem, err := NewKEM(v.KEMID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kdf, err := NewKDF(v.KDFID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aead, err := NewAEAD(v.AEADID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := mustDecodeHex(t, v.Info)
+	psk := mustDecodeHex(t, v.PSK)
+	pskID := mustDecodeHex(t, v.PSKID)
+
+	pkR, err := kem.NewPublicKey(mustDecodeHex(t, v.PKRm))
+	if err != nil {
+		t.Fatal(err)
+	}
+	skR, err := kem.DeriveKeyPair(mustDecodeHex(t, v.IKMR))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := skR.Bytes(), mustDecodeHex(t, v.SKRm); !bytes.Equal(got, want) {
+		t.Errorf("DeriveKeyPair(ikmR) = %x, want %x", got, want)
+	}
+	if got := skR.PublicKey().Bytes(); !bytes.Equal(got, pkR.Bytes()) {
+		t.Errorf("derived public key = %x, want %x", got, pkR.Bytes())
+	}
+
+	var skS PrivateKey
+	var pkS PublicKey
+	if v.Mode == modeAuth || v.Mode == modeAuthPSK {
+		skS, err = kem.DeriveKeyPair(mustDecodeHex(t, v.IKMS))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := skS.Bytes(), mustDecodeHex(t, v.SKSm); !bytes.Equal(got, want) {
+			t.Errorf("DeriveKeyPair(ikmS) = %x, want %x", got, want)
+		}
+		pkS, err = kem.NewPublicKey(mustDecodeHex(t, v.PKSm))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	skE, err := kem.DeriveKeyPair(mustDecodeHex(t, v.IKME))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testingOnlyGenerateKey = func() *ecdh.PrivateKey {
+		return skE.(*dhKEMPrivateKey).priv
+	}
+	defer func() { testingOnlyGenerateKey = nil }()
+
+	var enc []byte
+	var sender *Sender
+	var recipient *Recipient
+	switch v.Mode {
+	case modeBase:
+		enc, sender, err = NewSender(pkR, kdf, aead, info)
+		if err == nil {
+			recipient, err = NewRecipient(enc, skR, kdf, aead, info)
+		}
+	case modePSK:
+		enc, sender, err = NewPSKSender(pkR, kdf, aead, info, psk, pskID)
+		if err == nil {
+			recipient, err = NewPSKRecipient(enc, skR, kdf, aead, info, psk, pskID)
+		}
+	case modeAuth:
+		enc, sender, err = NewAuthSender(pkR, skS, kdf, aead, info)
+		if err == nil {
+			recipient, err = NewAuthRecipient(enc, skR, pkS, kdf, aead, info)
+		}
+	case modeAuthPSK:
+		enc, sender, err = NewAuthPSKSender(pkR, skS, kdf, aead, info, psk, pskID)
+		if err == nil {
+			recipient, err = NewAuthPSKRecipient(enc, skR, pkS, kdf, aead, info, psk, pskID)
+		}
+	default:
+		t.Fatalf("unknown mode %d", v.Mode)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := mustDecodeHex(t, v.Enc); !bytes.Equal(enc, want) {
+		t.Errorf("enc = %x, want %x", enc, want)
+	}
+
+	for i, e := range v.Encryptions {
+		aad := mustDecodeHex(t, e.AAD)
+		pt := mustDecodeHex(t, e.PT)
+		wantCT := mustDecodeHex(t, e.CT)
+		ct, err := sender.Seal(aad, pt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(ct, wantCT) {
+			t.Errorf("encryption %d: ciphertext = %x, want %x", i, ct, wantCT)
+		}
+		got, err := recipient.Open(aad, ct)
+		if err != nil {
+			t.Fatalf("encryption %d: %v", i, err)
+		}
+		if !bytes.Equal(got, pt) {
+			t.Errorf("encryption %d: plaintext = %x, want %x", i, got, pt)
+		}
+	}
+	if len(v.Encryptions) == 0 {
+		if _, err := sender.Seal(nil, nil); err == nil {
+			t.Errorf("export-only Seal succeeded")
+		}
+		if _, err := recipient.Open(nil, nil); err == nil {
+			t.Errorf("export-only Open succeeded")
+		}
+	}
+
+	for i, e := range v.Exports {
+		exporterContext := mustDecodeHex(t, e.ExporterContext)
+		want := mustDecodeHex(t, e.ExportedValue)
+		for _, ctx := range []*context{sender.context, recipient.context} {
+			got, err := ctx.export(exporterContext, e.L)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("export %d = %x, want %x", i, got, want)
+			}
+		}
+	}
+}
+
+// setupParams describes a sender and recipient setup. The recipient side uses
+// pkS, pskR and overrideEnc instead of the sender values if they are not nil,
+// so that tests can pass mismatched inputs.
+type setupParams struct {
+	mode        uint8
+	kdf         KDF
+	aead        AEAD
+	info        []byte
+	skR         PrivateKey
+	skS         PrivateKey
+	pkS         PublicKey
+	psk, pskR   []byte
+	pskID       []byte
+	overrideEnc []byte
+}
+
+// setup runs the sender and recipient setup functions for p.mode.
+func (p setupParams) setup() (*Sender, *Recipient, error) {
+	pkR := p.skR.PublicKey()
+	pkS := p.pkS
+	if pkS == nil && p.skS != nil {
+		pkS = p.skS.PublicKey()
+	}
+	pskR := p.pskR
+	if pskR == nil {
+		pskR = p.psk
+	}
+
+	var enc []byte
+	var s *Sender
+	var err error
+	switch p.mode {
+	case modeBase:
+		enc, s, err = NewSender(pkR, p.kdf, p.aead, p.info)
+	case modePSK:
+		enc, s, err = NewPSKSender(pkR, p.kdf, p.aead, p.info, p.psk, p.pskID)
+	case modeAuth:
+		enc, s, err = NewAuthSender(pkR, p.skS, p.kdf, p.aead, p.info)
+	case modeAuthPSK:
+		enc, s, err = NewAuthPSKSender(pkR, p.skS, p.kdf, p.aead, p.info, p.psk, p.pskID)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if p.overrideEnc != nil {
+		enc = p.overrideEnc
+	}
+
+	var r *Recipient
+	switch p.mode {
+	case modeBase:
+		r, err = NewRecipient(enc, p.skR, p.kdf, p.aead, p.info)
+	case modePSK:
+		r, err = NewPSKRecipient(enc, p.skR, p.kdf, p.aead, p.info, pskR, p.pskID)
+	case modeAuth:
+		r, err = NewAuthRecipient(enc, p.skR, pkS, p.kdf, p.aead, p.info)
+	case modeAuthPSK:
+		r, err = NewAuthPSKRecipient(enc, p.skR, pkS, p.kdf, p.aead, p.info, pskR, p.pskID)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return s, r, nil
+}
+
+func TestRoundTrip(t *testing.T) {
+	psk := bytes.Repeat([]byte{0x42}, 32)
+	pskID := []byte("psk id")
+	info := []byte("round trip test")
+
+	for _, kem := range []KEM{DHKEM(ecdh.P256()), DHKEM(ecdh.X25519())} {
+		skR, err := kem.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		skS, err := kem.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, kdf := range []KDF{HKDFSHA256(), HKDFSHA384(), HKDFSHA512()} {
+			for _, aead := range []AEAD{AES128GCM(), AES256GCM(), ChaCha20Poly1305()} {
+				for mode := modeBase; mode <= modeAuthPSK; mode++ {
+					name := fmt.Sprintf("mode %d, KEM %04x, KDF %04x, AEAD %04x", mode, kem.ID(), kdf.ID(), aead.ID())
+					p := setupParams{mode: mode, kdf: kdf, aead: aead, info: info, skR: skR}
+					if mode == modeAuth || mode == modeAuthPSK {
+						p.skS = skS
+					}
+					if mode == modePSK || mode == modeAuthPSK {
+						p.psk, p.pskID = psk, pskID
+					}
+					t.Run(name, func(t *testing.T) {
+						testRoundTrip(t, p)
+					})
+				}
+			}
+		}
+	}
+}
+
+func testRoundTrip(t *testing.T, p setupParams) {
+	s, r, err := p.setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		aad := []byte(fmt.Sprintf("aad %d", i))
+		pt := []byte(fmt.Sprintf("message %d", i))
+		ct, err := s.Seal(aad, pt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := r.Open([]byte("wrong aad"), ct); err == nil {
+			t.Errorf("Open succeeded with the wrong additional data")
+		}
+		got, err := r.Open(aad, ct)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, pt) {
+			t.Errorf("Open = %q, want %q", got, pt)
+		}
+	}
+	exp1, err := s.Export([]byte("context"), 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp2, err := r.Export([]byte("context"), 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(exp1, exp2) {
+		t.Errorf("sender and recipient exported different secrets")
+	}
+	if _, err := s.Export(nil, 255*p.kdf.size()+1); err == nil {
+		t.Errorf("Export succeeded with a length above 255*Nh")
+	}
+}
+
+func TestMismatchedInputs(t *testing.T) {
+	kem := DHKEM(ecdh.X25519())
+	skR, _ := kem.GenerateKey(rand.Reader)
+	skS, _ := kem.GenerateKey(rand.Reader)
+	otherS, _ := kem.GenerateKey(rand.Reader)
+	p256Key, _ := DHKEM(ecdh.P256()).GenerateKey(rand.Reader)
+	psk := bytes.Repeat([]byte{0x42}, 32)
+	pskID := []byte("psk id")
+
+	// open reports whether a message sealed by s can be opened by r.
+	open := func(s *Sender, r *Recipient) bool {
+		ct, err := s.Seal(nil, []byte("hello"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = r.Open(nil, ct)
+		return err == nil
+	}
+
+	base := setupParams{kdf: HKDFSHA256(), aead: AES128GCM(), skR: skR}
+
+	p := base
+	p.mode, p.psk, p.pskR, p.pskID = modePSK, psk, bytes.Repeat([]byte{0x43}, 32), pskID
+	if s, r, err := p.setup(); err != nil {
+		t.Fatal(err)
+	} else if open(s, r) {
+		t.Errorf("PSK mode: message opened with the wrong PSK")
+	}
+
+	p = base
+	p.mode, p.skS, p.pkS = modeAuth, skS, otherS.PublicKey()
+	if s, r, err := p.setup(); err != nil {
+		t.Fatal(err)
+	} else if open(s, r) {
+		t.Errorf("auth mode: message opened with the wrong sender public key")
+	}
+
+	p = base
+	p.mode, p.skS = modeAuth, p256Key
+	if _, _, err := p.setup(); err == nil {
+		t.Errorf("auth mode: setup succeeded with a sender key of a different KEM")
+	}
+
+	p = base
+	p.mode, p.overrideEnc = modeBase, []byte{1, 2, 3}
+	if _, _, err := p.setup(); err == nil {
+		t.Errorf("setup succeeded with an invalid encapsulated key")
+	}
+
+	for _, tt := range []struct {
+		name       string
+		psk, pskID []byte
+	}{
+		{"missing PSK", nil, nil},
+		{"missing PSK ID", psk, nil},
+		{"missing PSK value", nil, pskID},
+		{"short PSK", psk[:31], pskID},
+	} {
+		p = base
+		p.mode, p.psk, p.pskID = modePSK, tt.psk, tt.pskID
+		if _, _, err := p.setup(); err == nil {
+			t.Errorf("%s: setup succeeded", tt.name)
+		}
+	}
+
+	if _, _, err := NewAuthSender(skR.PublicKey(), nil, HKDFSHA256(), AES128GCM(), nil); err == nil {
+		t.Errorf("NewAuthSender succeeded without a sender key")
+	}
+}
+
+func TestExportOnly(t *testing.T) {
+	skR, err := DHKEM(ecdh.P256()).GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := setupParams{kdf: HKDFSHA256(), aead: ExportOnly(), skR: skR}
+	s, r, err := p.setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Seal(nil, []byte("hello")); err == nil {
+		t.Errorf("Seal succeeded with the export-only AEAD")
+	}
+	if _, err := r.Open(nil, []byte("hello")); err == nil {
+		t.Errorf("Open succeeded with the export-only AEAD")
+	}
+	exp1, _ := s.Export([]byte("context"), 32)
+	exp2, _ := r.Export([]byte("context"), 32)
+	if !bytes.Equal(exp1, exp2) {
+		t.Errorf("sender and recipient exported different secrets")
+	}
+}
+
+func TestUnsupported(t *testing.T) {
+	if _, err := NewKEM(0x0011); err == nil {
+		t.Errorf("NewKEM(0x0011) succeeded")
+	}
+	if _, err := NewKDF(0x0004); err == nil {
+		t.Errorf("NewKDF(0x0004) succeeded")
+	}
+	if _, err := NewAEAD(0x0004); err == nil {
+		t.Errorf("NewAEAD(0x0004) succeeded")
+	}
+	if _, err := DHKEM(ecdh.P384()).GenerateKey(rand.Reader); err == nil {
+		t.Errorf("DHKEM(P384).GenerateKey succeeded")
+	}
+	priv, err := ecdh.P521().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewDHKEMPrivateKey(priv); err == nil {
+		t.Errorf("NewDHKEMPrivateKey succeeded with a P-521 key")
+	}
+}
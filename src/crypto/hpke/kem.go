@@ -0,0 +1,326 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hpke
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// A KEM is a Key Encapsulation Mechanism, one of the three components of an
+// HPKE ciphersuite.
+//
+// The KEM interface has unexported methods, so it can only be implemented by
+// this package.
+type KEM interface {
+	// ID returns the HPKE KEM identifier.
+	ID() uint16
+
+	// GenerateKey generates a new key pair using entropy from rand.
+	GenerateKey(rand io.Reader) (PrivateKey, error)
+
+	// NewPublicKey deserializes a public key, as specified by
+	// DeserializePublicKey in RFC 9180.
+	NewPublicKey([]byte) (PublicKey, error)
+
+	// NewPrivateKey deserializes a private key, as specified by
+	// DeserializePrivateKey in RFC 9180.
+	NewPrivateKey([]byte) (PrivateKey, error)
+
+	// DeriveKeyPair deterministically derives a key pair from the input
+	// keying material ikm, as specified by DeriveKeyPair in RFC 9180.
+	DeriveKeyPair(ikm []byte) (PrivateKey, error)
+
+	encSize() int // Nenc
+}
+
+// NewKEM returns the KEM implementation for the given KEM ID.
+//
+// Applications are encouraged to use specific implementations like DHKEM
+// instead, unless runtime agility is required.
+func NewKEM(id uint16) (KEM, error) {
+	switch id {
+	case 0x0010: // DHKEM(P-256, HKDF-SHA256)
+		return dhKEMP256, nil
+	case 0x0020: // DHKEM(X25519, HKDF-SHA256)
+		return dhKEMX25519, nil
+	default:
+		return nil, fmt.Errorf("hpke: unsupported KEM %04x", id)
+	}
+}
+
+// A PublicKey is a KEM public key, to which messages can be encrypted.
+type PublicKey interface {
+	// KEM returns the KEM this key belongs to.
+	KEM() KEM
+
+	// Bytes returns the serialization of the public key, as specified by
+	// SerializePublicKey in RFC 9180.
+	Bytes() []byte
+
+	// encap returns a fresh shared secret and its encapsulation. If sender
+	// is not nil, the encapsulation is authenticated with it (AuthEncap).
+	encap(sender PrivateKey) (sharedSecret, enc []byte, err error)
+}
+
+// A PrivateKey is a KEM private key, which can decrypt messages encrypted to
+// the corresponding PublicKey.
+type PrivateKey interface {
+	// KEM returns the KEM this key belongs to.
+	KEM() KEM
+
+	// Bytes returns the serialization of the private key, as specified by
+	// SerializePrivateKey in RFC 9180.
+	Bytes() []byte
+
+	// PublicKey returns the corresponding public key.
+	PublicKey() PublicKey
+
+	// decap recovers the shared secret from its encapsulation. If sender is
+	// not nil, the encapsulation must be authenticated by it (AuthDecap).
+	decap(enc []byte, sender PublicKey) (sharedSecret []byte, err error)
+}
+
+// dhKEM implements DHKEM, as specified in RFC 9180, Section 4.1.
+type dhKEM struct {
+	kdf     *hkdfKDF
+	id      uint16
+	curve   ecdh.Curve
+	nSecret uint16
+	nSk     uint16
+	nEnc    int
+}
+
+var dhKEMP256 = &dhKEM{hkdfSHA256, 0x0010, ecdh.P256(), 32, 32, 65}
+var dhKEMX25519 = &dhKEM{hkdfSHA256, 0x0020, ecdh.X25519(), 32, 32, 32}
+
+// DHKEM returns the KEM implementing DHKEM(P-256, HKDF-SHA256) or
+// DHKEM(X25519, HKDF-SHA256), depending on curve. Other curves are not
+// supported, and the methods of the returned KEM will return errors.
+func DHKEM(curve ecdh.Curve) KEM {
+	switch curve {
+	case ecdh.P256():
+		return dhKEMP256
+	case ecdh.X25519():
+		return dhKEMX25519
+	default:
+		return unsupportedCurveKEM{}
+	}
+}
+
+func (kem *dhKEM) ID() uint16 {
+	return kem.id
+}
+
+func (kem *dhKEM) encSize() int {
+	return kem.nEnc
+}
+
+func (kem *dhKEM) suiteID() []byte {
+	return binary.BigEndian.AppendUint16([]byte("KEM"), kem.id)
+}
+
+func (kem *dhKEM) extractAndExpand(dh, kemContext []byte) []byte {
+	suiteID := kem.suiteID()
+	eaePRK := kem.kdf.labeledExtract(suiteID, nil, "eae_prk", dh)
+	return kem.kdf.labeledExpand(suiteID, eaePRK, "shared_secret", kemContext, kem.nSecret)
+}
+
+func (kem *dhKEM) GenerateKey(rand io.Reader) (PrivateKey, error) {
+	priv, err := kem.curve.GenerateKey(rand)
+	if err != nil {
+		return nil, err
+	}
+	return &dhKEMPrivateKey{kem, priv}, nil
+}
+
+func (kem *dhKEM) NewPublicKey(data []byte) (PublicKey, error) {
+	pub, err := kem.curve.NewPublicKey(data)
+	if err != nil {
+		return nil, err
+	}
+	return &dhKEMPublicKey{kem, pub}, nil
+}
+
+func (kem *dhKEM) NewPrivateKey(data []byte) (PrivateKey, error) {
+	priv, err := kem.curve.NewPrivateKey(data)
+	if err != nil {
+		return nil, err
+	}
+	return &dhKEMPrivateKey{kem, priv}, nil
+}
+
+// DeriveKeyPair implements DeriveKeyPair, as specified in RFC 9180,
+// Section 7.1.3.
+func (kem *dhKEM) DeriveKeyPair(ikm []byte) (PrivateKey, error) {
+	suiteID := kem.suiteID()
+	prk := kem.kdf.labeledExtract(suiteID, nil, "dkp_prk", ikm)
+	if kem == dhKEMX25519 {
+		return kem.NewPrivateKey(kem.kdf.labeledExpand(suiteID, prk, "sk", nil, kem.nSk))
+	}
+	for counter := 0; counter < 256; counter++ {
+		sk := kem.kdf.labeledExpand(suiteID, prk, "candidate", []byte{uint8(counter)}, kem.nSk)
+		if priv, err := kem.NewPrivateKey(sk); err == nil {
+			return priv, nil
+		}
+	}
+	return nil, errors.New("hpke: DeriveKeyPair failed")
+}
+
+// NewDHKEMPublicKey returns the DHKEM PublicKey corresponding to pub, which
+// must be a P-256 or X25519 key.
+func NewDHKEMPublicKey(pub *ecdh.PublicKey) (PublicKey, error) {
+	kem, ok := DHKEM(pub.Curve()).(*dhKEM)
+	if !ok {
+		return nil, errUnsupportedCurve
+	}
+	return &dhKEMPublicKey{kem, pub}, nil
+}
+
+// NewDHKEMPrivateKey returns the DHKEM PrivateKey corresponding to priv,
+// which must be a P-256 or X25519 key.
+func NewDHKEMPrivateKey(priv *ecdh.PrivateKey) (PrivateKey, error) {
+	kem, ok := DHKEM(priv.Curve()).(*dhKEM)
+	if !ok {
+		return nil, errUnsupportedCurve
+	}
+	return &dhKEMPrivateKey{kem, priv}, nil
+}
+
+type dhKEMPublicKey struct {
+	kem *dhKEM
+	pub *ecdh.PublicKey
+}
+
+func (pk *dhKEMPublicKey) KEM() KEM {
+	return pk.kem
+}
+
+func (pk *dhKEMPublicKey) Bytes() []byte {
+	return pk.pub.Bytes()
+}
+
+// testingOnlyGenerateKey is only used during testing, to provide
+// a fixed ephemeral key to use when checking the RFC 9180 vectors.
+var testingOnlyGenerateKey func() *ecdh.PrivateKey
+
+func (pk *dhKEMPublicKey) encap(sender PrivateKey) (sharedSecret, enc []byte, err error) {
+	var skS *dhKEMPrivateKey
+	if sender != nil {
+		var ok bool
+		if skS, ok = sender.(*dhKEMPrivateKey); !ok || skS.kem != pk.kem {
+			return nil, nil, errors.New("hpke: sender key doesn't match the recipient KEM")
+		}
+	}
+
+	privEph, err := pk.kem.curve.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	if testingOnlyGenerateKey != nil {
+		privEph = testingOnlyGenerateKey()
+	}
+	dh, err := privEph.ECDH(pk.pub)
+	if err != nil {
+		return nil, nil, err
+	}
+	enc = privEph.PublicKey().Bytes()
+
+	kemContext := make([]byte, 0, 3*pk.kem.nEnc)
+	kemContext = append(kemContext, enc...)
+	kemContext = append(kemContext, pk.pub.Bytes()...)
+	if skS != nil {
+		dhS, err := skS.priv.ECDH(pk.pub)
+		if err != nil {
+			return nil, nil, err
+		}
+		dh = append(dh, dhS...)
+		kemContext = append(kemContext, skS.priv.PublicKey().Bytes()...)
+	}
+
+	return pk.kem.extractAndExpand(dh, kemContext), enc, nil
+}
+
+type dhKEMPrivateKey struct {
+	kem  *dhKEM
+	priv *ecdh.PrivateKey
+}
+
+func (k *dhKEMPrivateKey) KEM() KEM {
+	return k.kem
+}
+
+func (k *dhKEMPrivateKey) Bytes() []byte {
+	return k.priv.Bytes()
+}
+
+func (k *dhKEMPrivateKey) PublicKey() PublicKey {
+	return &dhKEMPublicKey{k.kem, k.priv.PublicKey()}
+}
+
+func (k *dhKEMPrivateKey) decap(enc []byte, sender PublicKey) ([]byte, error) {
+	var pkS *dhKEMPublicKey
+	if sender != nil {
+		var ok bool
+		if pkS, ok = sender.(*dhKEMPublicKey); !ok || pkS.kem != k.kem {
+			return nil, errors.New("hpke: sender key doesn't match the recipient KEM")
+		}
+	}
+
+	pubEph, err := k.kem.curve.NewPublicKey(enc)
+	if err != nil {
+		return nil, err
+	}
+	dh, err := k.priv.ECDH(pubEph)
+	if err != nil {
+		return nil, err
+	}
+
+	kemContext := make([]byte, 0, 3*k.kem.nEnc)
+	kemContext = append(kemContext, enc...)
+	kemContext = append(kemContext, k.priv.PublicKey().Bytes()...)
+	if pkS != nil {
+		dhS, err := k.priv.ECDH(pkS.pub)
+		if err != nil {
+			return nil, err
+		}
+		dh = append(dh, dhS...)
+		kemContext = append(kemContext, pkS.pub.Bytes()...)
+	}
+
+	return k.kem.extractAndExpand(dh, kemContext), nil
+}
+
+type unsupportedCurveKEM struct{}
+
+var errUnsupportedCurve = errors.New("hpke: unsupported curve")
+
+func (unsupportedCurveKEM) ID() uint16 {
+	return 0
+}
+
+func (unsupportedCurveKEM) GenerateKey(io.Reader) (PrivateKey, error) {
+	return nil, errUnsupportedCurve
+}
+
+func (unsupportedCurveKEM) NewPublicKey([]byte) (PublicKey, error) {
+	return nil, errUnsupportedCurve
+}
+
+func (unsupportedCurveKEM) NewPrivateKey([]byte) (PrivateKey, error) {
+	return nil, errUnsupportedCurve
+}
+
+func (unsupportedCurveKEM) DeriveKeyPair([]byte) (PrivateKey, error) {
+	return nil, errUnsupportedCurve
+}
+
+func (unsupportedCurveKEM) encSize() int {
+	return 0
+}
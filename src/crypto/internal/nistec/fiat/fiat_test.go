@@ -5,7 +5,7 @@
 package fiat_test
 
 import (
-	"crypto/elliptic/internal/fiat"
+	"crypto/internal/nistec/fiat"
 	"testing"
 )
 
@@ -18,6 +18,14 @@ func BenchmarkMul(b *testing.B) {
 			v.Mul(v, v)
 		}
 	})
+	b.Run("P256", func(b *testing.B) {
+		v := new(fiat.P256Element).One()
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			v.Mul(v, v)
+		}
+	})
 	b.Run("P384", func(b *testing.B) {
 		v := new(fiat.P384Element).One()
 		b.ReportAllocs()
@@ -45,6 +53,14 @@ func BenchmarkSquare(b *testing.B) {
 			v.Square(v)
 		}
 	})
+	b.Run("P256", func(b *testing.B) {
+		v := new(fiat.P256Element).One()
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			v.Square(v)
+		}
+	})
 	b.Run("P384", func(b *testing.B) {
 		v := new(fiat.P384Element).One()
 		b.ReportAllocs()
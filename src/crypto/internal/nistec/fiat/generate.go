@@ -30,15 +30,15 @@ var curves = []struct {
 		FiatType: "[4]uint64",
 		BytesLen: 28,
 	},
-	// The 32-bit pure Go P-256 in crypto/elliptic is still faster than the
-	// autogenerated code here, regrettably.
-	// {
-	//  Element:  "P256Element",
-	//  Prime:    "2^256 - 2^224 + 2^192 + 2^96 - 1",
-	//  Prefix:   "p256",
-	//  FiatType: "[4]uint64",
-	//  BytesLen: 32,
-	// },
+	// The P-256 implementation in crypto/elliptic has its own, faster field
+	// arithmetic. This one backs the byte-oriented crypto/internal/nistec API.
+	{
+		Element:  "P256Element",
+		Prime:    "2^256 - 2^224 + 2^192 + 2^96 - 1",
+		Prefix:   "p256",
+		FiatType: "[4]uint64",
+		BytesLen: 32,
+	},
 	{
 		Element:  "P384Element",
 		Prime:    "2^384 - 2^128 - 2^96 + 2^32 - 1",
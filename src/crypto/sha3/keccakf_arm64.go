@@ -0,0 +1,27 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build gc && !purego
+
+package sha3
+
+import (
+	"internal/cpu"
+	"runtime"
+)
+
+// The Armv8.2 SHA-3 instructions are only used on Apple silicon, since on
+// other cores they have been measured to be slower than the generic code.
+var useSHA3 = cpu.ARM64.HasSHA3 && runtime.GOOS == "darwin"
+
+//go:noescape
+func keccakF1600NEON(a *[200]byte)
+
+func keccakF1600(a *[200]byte) {
+	if useSHA3 {
+		keccakF1600NEON(a)
+	} else {
+		keccakF1600Generic(a)
+	}
+}
@@ -0,0 +1,324 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package sha3 implements the SHA-3 hash algorithms and the SHAKE extendable
+// output functions defined in FIPS 202, as well as the cSHAKE functions
+// defined in NIST SP 800-185.
+//
+// Importing this package registers the SHA-3 hashes with crypto.RegisterHash,
+// making them available through crypto.SHA3_224 and friends.
+package sha3
+
+import (
+	"crypto"
+	"errors"
+	"hash"
+)
+
+func init() {
+	crypto.RegisterHash(crypto.SHA3_224, func() hash.Hash { return New224() })
+	crypto.RegisterHash(crypto.SHA3_256, func() hash.Hash { return New256() })
+	crypto.RegisterHash(crypto.SHA3_384, func() hash.Hash { return New384() })
+	crypto.RegisterHash(crypto.SHA3_512, func() hash.Hash { return New512() })
+}
+
+const (
+	// The domain separation bytes, including the first bit of the padding.
+	// Using a little-endian bit-ordering convention, the FIPS 202 suffixes
+	// are "01" for SHA-3 and "1111" for SHAKE, and SP 800-185 uses "00" for
+	// cSHAKE. The first "1" bit of the padding rule is merged into the byte.
+	dsbyteSHA3   = 0b00000110
+	dsbyteShake  = 0b00011111
+	dsbyteCShake = 0b00000100
+
+	// rateK[c] is the rate in bytes for Keccak[c] where c is the capacity in
+	// bits. Given the sponge size is 1600 bits, the rate is 1600 - c bits.
+	rateK256  = (1600 - 256) / 8
+	rateK448  = (1600 - 448) / 8
+	rateK512  = (1600 - 512) / 8
+	rateK768  = (1600 - 768) / 8
+	rateK1024 = (1600 - 1024) / 8
+)
+
+// spongeDirection indicates the direction bytes are flowing through the sponge.
+type spongeDirection int
+
+const (
+	// spongeAbsorbing indicates that the sponge is absorbing input.
+	spongeAbsorbing spongeDirection = iota
+	// spongeSqueezing indicates that the sponge is being squeezed.
+	spongeSqueezing
+)
+
+// state is a Keccak sponge, shared by the SHA-3 and SHAKE functions.
+type state struct {
+	a [1600 / 8]byte // main state of the hash
+
+	// a[n:rate] is the buffer. If absorbing, it's the remaining space to XOR
+	// into before running the permutation. If squeezing, it's the remaining
+	// output to produce before running the permutation.
+	n, rate int
+
+	dsbyte    byte            // domain separation byte, see above
+	outputLen int             // the default output size in bytes
+	direction spongeDirection // whether the sponge is absorbing or squeezing
+}
+
+func (d *state) reset() {
+	d.a = [len(d.a)]byte{}
+	d.direction = spongeAbsorbing
+	d.n = 0
+}
+
+// permute applies the KeccakF-1600 permutation.
+func (d *state) permute() {
+	keccakF1600(&d.a)
+	d.n = 0
+}
+
+// padAndPermute appends the domain separation bits in dsbyte, applies
+// the multi-bitrate 10..1 padding rule, and permutes the state.
+func (d *state) padAndPermute() {
+	// There's at least one byte of space in the sponge because, if it were
+	// full, permute would have been called to empty it.
+	d.a[d.n] ^= d.dsbyte
+	// This adds the final one bit for the padding. Because of the way that
+	// bits are numbered from the LSB upwards, the final bit is the MSB of
+	// the last byte.
+	d.a[d.rate-1] ^= 0x80
+	d.permute()
+	d.direction = spongeSqueezing
+}
+
+func (d *state) write(p []byte) (n int, err error) {
+	if d.direction != spongeAbsorbing {
+		panic("sha3: Write after Read")
+	}
+
+	n = len(p)
+	for len(p) > 0 {
+		buf := d.a[d.n:d.rate]
+		if len(buf) > len(p) {
+			buf = buf[:len(p)]
+		}
+		for i := range buf {
+			buf[i] ^= p[i]
+		}
+		d.n += len(buf)
+		p = p[len(buf):]
+
+		// If the sponge is full, apply the permutation.
+		if d.n == d.rate {
+			d.permute()
+		}
+	}
+	return
+}
+
+// read squeezes an arbitrary number of bytes from the sponge.
+func (d *state) read(out []byte) (n int, err error) {
+	// If we're still absorbing, pad and apply the permutation.
+	if d.direction == spongeAbsorbing {
+		d.padAndPermute()
+	}
+
+	n = len(out)
+	for len(out) > 0 {
+		// Apply the permutation if we've squeezed the sponge dry.
+		if d.n == d.rate {
+			d.permute()
+		}
+
+		x := copy(out, d.a[d.n:d.rate])
+		d.n += x
+		out = out[x:]
+	}
+	return
+}
+
+func (d *state) sum(b []byte) []byte {
+	if d.direction != spongeAbsorbing {
+		panic("sha3: Sum after Read")
+	}
+
+	// Make a copy of the original hash so that caller can keep writing
+	// and summing.
+	dup := *d
+	hash := make([]byte, dup.outputLen, 64) // explicit cap to allow stack allocation
+	dup.read(hash)
+	return append(b, hash...)
+}
+
+const (
+	magicSHA3   = "sha\x08"
+	magicShake  = "sha\x09"
+	magicCShake = "sha\x0a"
+	// magic || rate || main state || n || sponge direction
+	marshaledSize = len(magicSHA3) + 1 + 200 + 1 + 1
+)
+
+func (d *state) appendBinary(b []byte) []byte {
+	switch d.dsbyte {
+	case dsbyteSHA3:
+		b = append(b, magicSHA3...)
+	case dsbyteShake:
+		b = append(b, magicShake...)
+	case dsbyteCShake:
+		b = append(b, magicCShake...)
+	default:
+		panic("sha3: unknown dsbyte")
+	}
+	// rate is at most 168, and n is at most rate.
+	b = append(b, byte(d.rate))
+	b = append(b, d.a[:]...)
+	b = append(b, byte(d.n), byte(d.direction))
+	return b
+}
+
+func (d *state) unmarshalBinary(b []byte) error {
+	if len(b) != marshaledSize {
+		return errors.New("sha3: invalid hash state")
+	}
+
+	magic := string(b[:len(magicSHA3)])
+	b = b[len(magicSHA3):]
+	switch {
+	case magic == magicSHA3 && d.dsbyte == dsbyteSHA3:
+	case magic == magicShake && d.dsbyte == dsbyteShake:
+	case magic == magicCShake && d.dsbyte == dsbyteCShake:
+	default:
+		return errors.New("sha3: invalid hash state identifier")
+	}
+
+	rate := int(b[0])
+	b = b[1:]
+	if rate != d.rate {
+		return errors.New("sha3: invalid hash state function")
+	}
+
+	copy(d.a[:], b)
+	b = b[len(d.a):]
+
+	n, direction := int(b[0]), spongeDirection(b[1])
+	if n > d.rate {
+		return errors.New("sha3: invalid hash state")
+	}
+	if direction != spongeAbsorbing && direction != spongeSqueezing {
+		return errors.New("sha3: invalid hash state")
+	}
+	d.n = n
+	d.direction = direction
+	return nil
+}
+
+// Sum224 returns the SHA3-224 hash of data.
+func Sum224(data []byte) [28]byte {
+	var out [28]byte
+	h := New224()
+	h.Write(data)
+	h.Sum(out[:0])
+	return out
+}
+
+// Sum256 returns the SHA3-256 hash of data.
+func Sum256(data []byte) [32]byte {
+	var out [32]byte
+	h := New256()
+	h.Write(data)
+	h.Sum(out[:0])
+	return out
+}
+
+// Sum384 returns the SHA3-384 hash of data.
+func Sum384(data []byte) [48]byte {
+	var out [48]byte
+	h := New384()
+	h.Write(data)
+	h.Sum(out[:0])
+	return out
+}
+
+// Sum512 returns the SHA3-512 hash of data.
+func Sum512(data []byte) [64]byte {
+	var out [64]byte
+	h := New512()
+	h.Write(data)
+	h.Sum(out[:0])
+	return out
+}
+
+// SHA3 is an instance of a SHA-3 hash. It implements hash.Hash.
+// The zero value is a usable SHA3-256 hash.
+type SHA3 struct {
+	s state
+}
+
+// New224 creates a new SHA3-224 hash.
+func New224() *SHA3 {
+	return &SHA3{state{rate: rateK448, outputLen: 28, dsbyte: dsbyteSHA3}}
+}
+
+// New256 creates a new SHA3-256 hash.
+func New256() *SHA3 {
+	return &SHA3{state{rate: rateK512, outputLen: 32, dsbyte: dsbyteSHA3}}
+}
+
+// New384 creates a new SHA3-384 hash.
+func New384() *SHA3 {
+	return &SHA3{state{rate: rateK768, outputLen: 48, dsbyte: dsbyteSHA3}}
+}
+
+// New512 creates a new SHA3-512 hash.
+func New512() *SHA3 {
+	return &SHA3{state{rate: rateK1024, outputLen: 64, dsbyte: dsbyteSHA3}}
+}
+
+func (s *SHA3) init() {
+	if s.s.rate == 0 {
+		*s = *New256()
+	}
+}
+
+// Write absorbs more data into the hash's state.
+func (s *SHA3) Write(p []byte) (n int, err error) {
+	s.init()
+	return s.s.write(p)
+}
+
+// Sum appends the current hash to b and returns the resulting slice.
+// It does not change the underlying hash state.
+func (s *SHA3) Sum(b []byte) []byte {
+	s.init()
+	return s.s.sum(b)
+}
+
+// Reset resets the hash to its initial state.
+func (s *SHA3) Reset() {
+	s.init()
+	s.s.reset()
+}
+
+// Size returns the number of bytes Sum will produce.
+func (s *SHA3) Size() int {
+	s.init()
+	return s.s.outputLen
+}
+
+// BlockSize returns the hash's rate.
+func (s *SHA3) BlockSize() int {
+	s.init()
+	return s.s.rate
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (s *SHA3) MarshalBinary() ([]byte, error) {
+	s.init()
+	return s.s.appendBinary(make([]byte, 0, marshaledSize)), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (s *SHA3) UnmarshalBinary(data []byte) error {
+	s.init()
+	return s.s.unmarshalBinary(data)
+}
@@ -0,0 +1,270 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sha3
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/hex"
+	"hash"
+	"math/rand"
+	"testing"
+)
+
+func sequentialBytes(size int) []byte {
+	b := make([]byte, size)
+	for i := range b {
+		b[i] = byte(i)
+	}
+	return b
+}
+
+var golden = []struct {
+	name string
+	new  func() hash.Hash
+	in   []byte
+	out  string
+}{
+	{"SHA3-224", func() hash.Hash { return New224() }, nil, "6b4e03423667dbb73b6e15454f0eb1abd4597f9a1b078e3f5b5a6bc7"},
+	{"SHA3-224", func() hash.Hash { return New224() }, []byte("abc"), "e642824c3f8cf24ad09234ee7d3c766fc9a3a5168d0c94ad73b46fdf"},
+	{"SHA3-256", func() hash.Hash { return New256() }, nil, "a7ffc6f8bf1ed76651c14756a061d662f580ff4de43b49fa82d80a4b80f8434a"},
+	{"SHA3-256", func() hash.Hash { return New256() }, []byte("abc"), "3a985da74fe225b2045c172d6bd390bd855f086e3e9d525b46bfe24511431532"},
+	{"SHA3-256", func() hash.Hash { return New256() }, bytes.Repeat(sequentialBytes(200), 10), "7f88c9f5fa40256f3907bf9b01308565e9f33e3c3d47120f926a42e2544c0007"},
+	{"SHA3-256 zero value", func() hash.Hash { return &SHA3{} }, []byte("abc"), "3a985da74fe225b2045c172d6bd390bd855f086e3e9d525b46bfe24511431532"},
+	{"SHA3-384", func() hash.Hash { return New384() }, nil, "0c63a75b845e4f7d01107d852e4c2485c51a50aaaa94fc61995e71bbee983a2ac3713831264adb47fb6bd1e058d5f004"},
+	{"SHA3-384", func() hash.Hash { return New384() }, []byte("abc"), "ec01498288516fc926459f58e2c6ad8df9b473cb0fc08c2596da7cf0e49be4b298d88cea927ac7f539f1edf228376d25"},
+	{"SHA3-512", func() hash.Hash { return New512() }, nil, "a69f73cca23a9ac5c8b567dc185a756e97c982164fe25859e0d1dcc1475c80a615b2123af1f5f94c11e3e9402c3ac558f500199d95b6d3e301758586281dcd26"},
+	{"SHA3-512", func() hash.Hash { return New512() }, []byte("abc"), "b751850b1a57168a5693cd924b6b096e08f621827444f70d884f5d0240d2712e10e116e9192af3c91a7ec57647e3934057340b4cf408d5a56592f8274eec53f0"},
+}
+
+func TestGolden(t *testing.T) {
+	for _, g := range golden {
+		h := g.new()
+		h.Write(g.in)
+		if got := hex.EncodeToString(h.Sum(nil)); got != g.out {
+			t.Errorf("%s(%q) = %s, want %s", g.name, g.in, got, g.out)
+		}
+
+		// Write the input one byte at a time, and check that Sum doesn't
+		// change the state.
+		h.Reset()
+		for i := range g.in {
+			h.Write(g.in[i : i+1])
+			h.Sum(nil)
+		}
+		if got := hex.EncodeToString(h.Sum(nil)); got != g.out {
+			t.Errorf("%s(%q) with byte writes = %s, want %s", g.name, g.in, got, g.out)
+		}
+	}
+
+	if got := Sum256([]byte("abc")); hex.EncodeToString(got[:]) != golden[3].out {
+		t.Errorf("Sum256 = %x", got)
+	}
+}
+
+var goldenSHAKE = []struct {
+	name string
+	new  func() *SHAKE
+	in   []byte
+	out  string
+}{
+	{"SHAKE128", NewSHAKE128, nil, "7f9c2ba4e88f827d616045507605853ed73b8093f6efbc88eb1a6eacfa66ef26"},
+	{"SHAKE128", NewSHAKE128, []byte("abc"), "5881092dd818bf5cf8a3ddb793fbcba74097d5c526a6d35f97b83351940f2cc8"},
+	{"SHAKE256", NewSHAKE256, nil, "46b9dd2b0ba88d13233b3feb743eeb243fcd52ea62b81b82b50c27646ed5762fd75dc4ddd8c0f200cb05019d67b592f6fc821c49479ab48640292eacb3b7c4be"},
+	{"SHAKE256 zero value", func() *SHAKE { return &SHAKE{} }, []byte("abc"), "483366601360a8771c6863080cc4114d8db44530f8f1e1ee4f94ea37e78b5739d5a15bef186a5386c75744c0527e1faa9f8726e462a12a4feb06bd8801e751e4"},
+	{"cSHAKE128 without customization", func() *SHAKE { return NewCSHAKE128(nil, nil) }, nil, "7f9c2ba4e88f827d616045507605853ed73b8093f6efbc88eb1a6eacfa66ef26"},
+	// Samples from NIST SP 800-185.
+	{"cSHAKE128", func() *SHAKE { return NewCSHAKE128(nil, []byte("Email Signature")) }, sequentialBytes(4), "c1c36925b6409a04f1b504fcbca9d82b4017277cb5ed2b2065fc1d3814d5aaf5"},
+	{"cSHAKE128", func() *SHAKE { return NewCSHAKE128(nil, []byte("Email Signature")) }, sequentialBytes(200), "c5221d50e4f822d96a2e8881a961420f294b7b24fe3d2094baed2c6524cc166b"},
+	{"cSHAKE256", func() *SHAKE { return NewCSHAKE256(nil, []byte("Email Signature")) }, sequentialBytes(4), "d008828e2b80ac9d2218ffee1d070c48b8e4c87bff32c9699d5b6896eee0edd164020e2be0560858d9c00c037e34a96937c561a74c412bb4c746469527281c8c"},
+}
+
+func TestGoldenSHAKE(t *testing.T) {
+	for _, g := range goldenSHAKE {
+		out := make([]byte, hex.DecodedLen(len(g.out)))
+		h := g.new()
+		h.Write(g.in)
+		h.Read(out)
+		if got := hex.EncodeToString(out); got != g.out {
+			t.Errorf("%s(%q) = %s, want %s", g.name, g.in, got, g.out)
+		}
+
+		// Squeeze the output in small pieces after a Reset.
+		h.Reset()
+		h.Write(g.in)
+		for i := range out {
+			h.Read(out[i : i+1])
+		}
+		if got := hex.EncodeToString(out); got != g.out {
+			t.Errorf("%s(%q) with byte reads = %s, want %s", g.name, g.in, got, g.out)
+		}
+	}
+
+	if got := SumSHAKE128([]byte("abc"), 32); hex.EncodeToString(got) != goldenSHAKE[1].out {
+		t.Errorf("SumSHAKE128 = %x", got)
+	}
+}
+
+// TestSqueezing checks that squeezing the full output a single time produces
+// the same output as repeatedly squeezing smaller pieces, across several
+// permutations.
+func TestSqueezing(t *testing.T) {
+	for _, newSHAKE := range []func() *SHAKE{NewSHAKE128, NewSHAKE256} {
+		d0 := newSHAKE()
+		d0.Write([]byte("brekeccakkeccak koax koax"))
+		ref := make([]byte, 1000)
+		d0.Read(ref)
+
+		d1 := newSHAKE()
+		d1.Write([]byte("brekeccakkeccak koax koax"))
+		var multiple []byte
+		for len(multiple) < len(ref) {
+			out := make([]byte, 7)
+			d1.Read(out)
+			multiple = append(multiple, out...)
+		}
+		if !bytes.Equal(ref, multiple[:len(ref)]) {
+			t.Errorf("%d-byte reads don't match a single read", 7)
+		}
+	}
+}
+
+func TestKeccakF1600(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		var a [200]byte
+		rand.Read(a[:])
+		b := a
+		keccakF1600(&a)
+		keccakF1600Generic(&b)
+		if a != b {
+			t.Fatalf("keccakF1600 doesn't match keccakF1600Generic")
+		}
+	}
+}
+
+func TestMarshalUnmarshal(t *testing.T) {
+	for _, newHash := range []func() *SHA3{New224, New256, New384, New512} {
+		h := newHash()
+		h.Write(sequentialBytes(300))
+		state, err := h.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		h2 := newHash()
+		if err := h2.UnmarshalBinary(state); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := h2.Sum(nil), h.Sum(nil); !bytes.Equal(got, want) {
+			t.Errorf("got %x after unmarshaling, want %x", got, want)
+		}
+	}
+
+	for _, newSHAKE := range []func() *SHAKE{
+		NewSHAKE128,
+		NewSHAKE256,
+		func() *SHAKE { return NewCSHAKE128([]byte("N"), []byte("S")) },
+	} {
+		h := newSHAKE()
+		h.Write(sequentialBytes(300))
+		state, err := h.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		h2 := newSHAKE()
+		if err := h2.UnmarshalBinary(state); err != nil {
+			t.Fatal(err)
+		}
+		got, want := make([]byte, 100), make([]byte, 100)
+		h.Read(want)
+		h2.Read(got)
+		if !bytes.Equal(got, want) {
+			t.Errorf("got %x after unmarshaling, want %x", got, want)
+		}
+	}
+
+	if err := New256().UnmarshalBinary(make([]byte, marshaledSize)); err == nil {
+		t.Errorf("UnmarshalBinary accepted an invalid state")
+	}
+	b, _ := New512().MarshalBinary()
+	if err := New256().UnmarshalBinary(b); err == nil {
+		t.Errorf("UnmarshalBinary accepted a state of a different function")
+	}
+}
+
+func TestRegisterHash(t *testing.T) {
+	for _, tt := range []struct {
+		h    crypto.Hash
+		size int
+	}{
+		{crypto.SHA3_224, 28},
+		{crypto.SHA3_256, 32},
+		{crypto.SHA3_384, 48},
+		{crypto.SHA3_512, 64},
+	} {
+		if !tt.h.Available() {
+			t.Errorf("%v is not available", tt.h)
+			continue
+		}
+		if got := tt.h.New().Size(); got != tt.size {
+			t.Errorf("%v.New().Size() = %d, want %d", tt.h, got, tt.size)
+		}
+	}
+}
+
+func TestAllocations(t *testing.T) {
+	if n := testing.AllocsPerRun(10, func() {
+		Sum256([]byte("abc"))
+	}); n > 0 {
+		t.Errorf("Sum256 allocated %v times", n)
+	}
+}
+
+func benchmarkHash(b *testing.B, h hash.Hash, size, num int) {
+	b.StopTimer()
+	h.Reset()
+	data := sequentialBytes(size)
+	b.SetBytes(int64(size * num))
+	b.StartTimer()
+
+	var state []byte
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < num; j++ {
+			h.Write(data)
+		}
+		state = h.Sum(state[:0])
+	}
+	b.StopTimer()
+	h.Reset()
+}
+
+func benchmarkShake(b *testing.B, h *SHAKE, size, num int) {
+	b.StopTimer()
+	h.Reset()
+	data := sequentialBytes(size)
+	d := make([]byte, 32)
+
+	b.SetBytes(int64(size * num))
+	b.StartTimer()
+
+	for i := 0; i < b.N; i++ {
+		h.Reset()
+		for j := 0; j < num; j++ {
+			h.Write(data)
+		}
+		h.Read(d)
+	}
+}
+
+func BenchmarkSha3_512_MTU(b *testing.B) { benchmarkHash(b, New512(), 1350, 1) }
+func BenchmarkSha3_384_MTU(b *testing.B) { benchmarkHash(b, New384(), 1350, 1) }
+func BenchmarkSha3_256_MTU(b *testing.B) { benchmarkHash(b, New256(), 1350, 1) }
+func BenchmarkSha3_224_MTU(b *testing.B) { benchmarkHash(b, New224(), 1350, 1) }
+
+func BenchmarkShake128_MTU(b *testing.B)  { benchmarkShake(b, NewSHAKE128(), 1350, 1) }
+func BenchmarkShake256_MTU(b *testing.B)  { benchmarkShake(b, NewSHAKE256(), 1350, 1) }
+func BenchmarkShake256_16x(b *testing.B)  { benchmarkShake(b, NewSHAKE256(), 16, 1024) }
+func BenchmarkShake256_1MiB(b *testing.B) { benchmarkShake(b, NewSHAKE256(), 1024, 1024) }
+
+func BenchmarkSha3_512_1MiB(b *testing.B) { benchmarkHash(b, New512(), 1024, 1024) }
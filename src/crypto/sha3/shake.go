@@ -0,0 +1,173 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sha3
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/bits"
+)
+
+// SumSHAKE128 applies the SHAKE128 extendable output function to data and
+// returns an output of the given length in bytes.
+func SumSHAKE128(data []byte, length int) []byte {
+	out := make([]byte, length)
+	h := NewSHAKE128()
+	h.Write(data)
+	h.Read(out)
+	return out
+}
+
+// SumSHAKE256 applies the SHAKE256 extendable output function to data and
+// returns an output of the given length in bytes.
+func SumSHAKE256(data []byte, length int) []byte {
+	out := make([]byte, length)
+	h := NewSHAKE256()
+	h.Write(data)
+	h.Read(out)
+	return out
+}
+
+// SHAKE is an instance of a SHAKE or cSHAKE extendable output function.
+// The zero value is a usable SHAKE256 hash.
+type SHAKE struct {
+	s state
+
+	// initBlock is the cSHAKE specific initialization set of bytes. It is
+	// initialized by newCSHAKE and stores the concatenation of N followed by
+	// S, encoded by the method specified in Section 3.3 of SP 800-185. It is
+	// stored here in order for Reset to be able to put the XOF back into its
+	// initial state.
+	initBlock []byte
+}
+
+// NewSHAKE128 creates a new SHAKE128 XOF.
+func NewSHAKE128() *SHAKE {
+	return &SHAKE{s: state{rate: rateK256, outputLen: 32, dsbyte: dsbyteShake}}
+}
+
+// NewSHAKE256 creates a new SHAKE256 XOF.
+func NewSHAKE256() *SHAKE {
+	return &SHAKE{s: state{rate: rateK512, outputLen: 64, dsbyte: dsbyteShake}}
+}
+
+// NewCSHAKE128 creates a new cSHAKE128 XOF.
+//
+// N is used to define functions based on cSHAKE, it can be empty when plain
+// cSHAKE is desired. S is a customization byte string used for domain
+// separation. When N and S are both empty, this is equivalent to NewSHAKE128.
+func NewCSHAKE128(N, S []byte) *SHAKE {
+	if len(N) == 0 && len(S) == 0 {
+		return NewSHAKE128()
+	}
+	return newCSHAKE(N, S, rateK256, 32)
+}
+
+// NewCSHAKE256 creates a new cSHAKE256 XOF.
+//
+// N is used to define functions based on cSHAKE, it can be empty when plain
+// cSHAKE is desired. S is a customization byte string used for domain
+// separation. When N and S are both empty, this is equivalent to NewSHAKE256.
+func NewCSHAKE256(N, S []byte) *SHAKE {
+	if len(N) == 0 && len(S) == 0 {
+		return NewSHAKE256()
+	}
+	return newCSHAKE(N, S, rateK512, 64)
+}
+
+func newCSHAKE(N, S []byte, rate, outputLen int) *SHAKE {
+	c := &SHAKE{s: state{rate: rate, outputLen: outputLen, dsbyte: dsbyteCShake}}
+	c.initBlock = make([]byte, 0, 9+len(N)+9+len(S)) // leftEncode returns max 9 bytes
+	c.initBlock = append(c.initBlock, leftEncode(uint64(len(N))*8)...)
+	c.initBlock = append(c.initBlock, N...)
+	c.initBlock = append(c.initBlock, leftEncode(uint64(len(S))*8)...)
+	c.initBlock = append(c.initBlock, S...)
+	c.bytepadWrite()
+	return c
+}
+
+// bytepadWrite absorbs bytepad(initBlock, rate), as defined in Section 2.3.3
+// of SP 800-185.
+func (c *SHAKE) bytepadWrite() {
+	rateEnc := leftEncode(uint64(c.s.rate))
+	c.s.write(rateEnc)
+	c.s.write(c.initBlock)
+	if padlen := c.s.rate - (len(rateEnc)+len(c.initBlock))%c.s.rate; padlen < c.s.rate {
+		c.s.write(make([]byte, padlen))
+	}
+}
+
+// leftEncode implements left_encode, as defined in Section 2.3.1 of
+// SP 800-185.
+func leftEncode(x uint64) []byte {
+	// Let n be the smallest positive integer for which 2^(8n) > x.
+	n := (bits.Len64(x) + 7) / 8
+	if n == 0 {
+		n = 1
+	}
+	// Return n || x with n as a byte and x an n bytes in big-endian order.
+	b := make([]byte, 9)
+	binary.BigEndian.PutUint64(b[1:], x)
+	b = b[9-n-1:]
+	b[0] = byte(n)
+	return b
+}
+
+func (s *SHAKE) init() {
+	if s.s.rate == 0 {
+		*s = *NewSHAKE256()
+	}
+}
+
+// Write absorbs more data into the XOF's state.
+//
+// It panics if any output has already been read.
+func (s *SHAKE) Write(p []byte) (n int, err error) {
+	s.init()
+	return s.s.write(p)
+}
+
+// Read squeezes more output from the XOF.
+//
+// Any call to Write after a call to Read will panic.
+func (s *SHAKE) Read(p []byte) (n int, err error) {
+	s.init()
+	return s.s.read(p)
+}
+
+// Reset resets the XOF to its initial state.
+func (s *SHAKE) Reset() {
+	s.init()
+	s.s.reset()
+	if len(s.initBlock) != 0 {
+		s.bytepadWrite()
+	}
+}
+
+// BlockSize returns the rate of the XOF.
+func (s *SHAKE) BlockSize() int {
+	s.init()
+	return s.s.rate
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (s *SHAKE) MarshalBinary() ([]byte, error) {
+	s.init()
+	b := s.s.appendBinary(make([]byte, 0, marshaledSize+len(s.initBlock)))
+	return append(b, s.initBlock...), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (s *SHAKE) UnmarshalBinary(data []byte) error {
+	s.init()
+	if len(data) < marshaledSize {
+		return errors.New("sha3: invalid hash state")
+	}
+	if err := s.s.unmarshalBinary(data[:marshaledSize]); err != nil {
+		return err
+	}
+	s.initBlock = append([]byte(nil), data[marshaledSize:]...)
+	return nil
+}
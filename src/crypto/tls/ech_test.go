@@ -0,0 +1,257 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// newTestECHKey returns a DHKEM(X25519, HKDF-SHA256) ECHConfig, along with
+// the corresponding server key.
+func newTestECHKey(t *testing.T, configID uint8, publicName string) EncryptedClientHelloKey {
+	t.Helper()
+	priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b cryptobyte.Builder
+	b.AddUint16(extensionEncryptedClientHello)
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddUint8(configID)
+		b.AddUint16(0x0020) // DHKEM(X25519, HKDF-SHA256)
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddBytes(priv.PublicKey().Bytes())
+		})
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddUint16(0x0001) // HKDF-SHA256
+			b.AddUint16(0x0001) // AES-128-GCM
+			b.AddUint16(0x0001) // HKDF-SHA256
+			b.AddUint16(0x0003) // ChaCha20-Poly1305
+		})
+		b.AddUint8(32) // maximum_name_length
+		b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddBytes([]byte(publicName))
+		})
+		b.AddUint16(0) // extensions
+	})
+	return EncryptedClientHelloKey{
+		Config:      b.BytesOrPanic(),
+		PrivateKey:  priv.Bytes(),
+		SendAsRetry: true,
+	}
+}
+
+func marshalECHConfigList(configs ...[]byte) []byte {
+	var b cryptobyte.Builder
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		for _, c := range configs {
+			b.AddBytes(c)
+		}
+	})
+	return b.BytesOrPanic()
+}
+
+func TestParseECHConfigList(t *testing.T) {
+	key := newTestECHKey(t, 1, "public.example")
+	unknown := []byte{0xff, 0xff, 0x00, 0x02, 0x01, 0x02}
+
+	configs, err := parseECHConfigList(marshalECHConfigList(unknown, key.Config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(configs) != 1 {
+		t.Fatalf("got %d configs, want 1", len(configs))
+	}
+	if !bytes.Equal(configs[0].raw, key.Config) {
+		t.Errorf("config raw bytes don't match the input")
+	}
+	if configs[0].ConfigID != 1 || string(configs[0].PublicName) != "public.example" {
+		t.Errorf("unexpected config: %+v", configs[0])
+	}
+	if ec, _, cs := pickECHConfig(configs); ec == nil || cs != (echCipher{KDFID: 1, AEADID: 1}) {
+		t.Errorf("pickECHConfig = %v, %v; want the config with AES-128-GCM", ec, cs)
+	}
+
+	for _, bad := range [][]byte{
+		nil,
+		{0x00},
+		{0x00, 0x05, 0xfe, 0x0d},
+		marshalECHConfigList(key.Config[:len(key.Config)-1]),
+		marshalECHConfigList(unknown[:4]),
+	} {
+		if _, err := parseECHConfigList(bad); err == nil {
+			t.Errorf("parseECHConfigList(%x) succeeded, want error", bad)
+		}
+	}
+
+	badName := newTestECHKey(t, 1, "-invalid")
+	configs, err = parseECHConfigList(marshalECHConfigList(badName.Config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ec, _, _ := pickECHConfig(configs); ec != nil {
+		t.Errorf("pickECHConfig picked a config with an invalid public name")
+	}
+}
+
+// echHandshake runs a handshake between clientConfig and serverConfig over
+// a local connection, returning the errors of both sides.
+func echHandshake(t *testing.T, clientConfig, serverConfig *Config) (clientState, serverState ConnectionState, clientErr, serverErr error) {
+	c, s := localPipe(t)
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		server := Server(s, serverConfig)
+		defer server.Close()
+		serverErr = server.Handshake()
+		if serverErr == nil {
+			serverState = server.ConnectionState()
+			// Wait for the client to either read the data or close the
+			// connection after rejecting ECH.
+			server.Write([]byte("hello"))
+		}
+	}()
+	client := Client(c, clientConfig)
+	clientErr = client.Handshake()
+	if clientErr == nil {
+		clientState = client.ConnectionState()
+		buf := make([]byte, 5)
+		if _, err := client.Read(buf); err != nil {
+			t.Errorf("client read failed: %v", err)
+		}
+	}
+	client.Close()
+	<-done
+	return
+}
+
+func TestECHHandshake(t *testing.T) {
+	key := newTestECHKey(t, 42, "public.example")
+	echConfigList := marshalECHConfigList(key.Config)
+
+	newConfigs := func() (clientConfig, serverConfig *Config) {
+		serverConfig = testConfig.Clone()
+		serverConfig.EncryptedClientHelloKeys = []EncryptedClientHelloKey{key}
+		clientConfig = testConfig.Clone()
+		clientConfig.MinVersion = VersionTLS13
+		clientConfig.ServerName = "secret.example"
+		clientConfig.EncryptedClientHelloConfigList = echConfigList
+		return clientConfig, serverConfig
+	}
+
+	checkAccepted := func(t *testing.T, clientState, serverState ConnectionState, clientErr, serverErr error) {
+		t.Helper()
+		if clientErr != nil || serverErr != nil {
+			t.Fatalf("handshake failed: client: %v, server: %v", clientErr, serverErr)
+		}
+		if !clientState.ECHAccepted || !serverState.ECHAccepted {
+			t.Errorf("ECH not accepted: client %v, server %v", clientState.ECHAccepted, serverState.ECHAccepted)
+		}
+		if serverState.ServerName != "secret.example" {
+			t.Errorf("server saw ServerName %q, want the inner one", serverState.ServerName)
+		}
+		if clientState.ServerName != "secret.example" {
+			t.Errorf("client ServerName is %q, want the inner one", clientState.ServerName)
+		}
+	}
+
+	t.Run("Accepted", func(t *testing.T) {
+		clientConfig, serverConfig := newConfigs()
+		clientState, serverState, clientErr, serverErr := echHandshake(t, clientConfig, serverConfig)
+		checkAccepted(t, clientState, serverState, clientErr, serverErr)
+	})
+
+	t.Run("HelloRetryRequest", func(t *testing.T) {
+		clientConfig, serverConfig := newConfigs()
+		clientConfig.CurvePreferences = []CurveID{X25519, CurveP256}
+		serverConfig.CurvePreferences = []CurveID{CurveP256}
+		clientState, serverState, clientErr, serverErr := echHandshake(t, clientConfig, serverConfig)
+		checkAccepted(t, clientState, serverState, clientErr, serverErr)
+	})
+
+	t.Run("Resumption", func(t *testing.T) {
+		clientConfig, serverConfig := newConfigs()
+		clientConfig.ClientSessionCache = NewLRUClientSessionCache(1)
+		clientState, serverState, clientErr, serverErr := echHandshake(t, clientConfig, serverConfig)
+		checkAccepted(t, clientState, serverState, clientErr, serverErr)
+		clientState, serverState, clientErr, serverErr = echHandshake(t, clientConfig, serverConfig)
+		checkAccepted(t, clientState, serverState, clientErr, serverErr)
+		if !clientState.DidResume || !serverState.DidResume {
+			t.Errorf("session was not resumed")
+		}
+	})
+
+	t.Run("RejectedWithRetryConfigs", func(t *testing.T) {
+		clientConfig, serverConfig := newConfigs()
+		retryKey := newTestECHKey(t, 7, "public.example")
+		serverConfig.EncryptedClientHelloKeys = []EncryptedClientHelloKey{retryKey}
+		var verifyCalled bool
+		clientConfig.EncryptedClientHelloRejectionVerify = func(cs ConnectionState) error {
+			verifyCalled = true
+			if cs.ServerName != "public.example" {
+				t.Errorf("rejection verified for ServerName %q, want the public name", cs.ServerName)
+			}
+			if len(cs.PeerCertificates) == 0 {
+				t.Errorf("rejection verified without peer certificates")
+			}
+			return nil
+		}
+		_, serverState, clientErr, _ := echHandshake(t, clientConfig, serverConfig)
+		var echErr *ECHRejectionError
+		if !errors.As(clientErr, &echErr) {
+			t.Fatalf("client error = %v, want an ECHRejectionError", clientErr)
+		}
+		if !bytes.Equal(echErr.RetryConfigList, marshalECHConfigList(retryKey.Config)) {
+			t.Errorf("unexpected retry configs: %x", echErr.RetryConfigList)
+		}
+		if !verifyCalled {
+			t.Errorf("EncryptedClientHelloRejectionVerify was not called")
+		}
+		if serverState.ECHAccepted || serverState.ServerName != "public.example" {
+			t.Errorf("server accepted ECH or saw the inner ServerName %q", serverState.ServerName)
+		}
+	})
+
+	t.Run("RejectedWithoutServerSupport", func(t *testing.T) {
+		clientConfig, serverConfig := newConfigs()
+		serverConfig.EncryptedClientHelloKeys = nil
+		clientConfig.EncryptedClientHelloRejectionVerify = func(ConnectionState) error { return nil }
+		_, _, clientErr, _ := echHandshake(t, clientConfig, serverConfig)
+		var echErr *ECHRejectionError
+		if !errors.As(clientErr, &echErr) {
+			t.Fatalf("client error = %v, want an ECHRejectionError", clientErr)
+		}
+		if echErr.RetryConfigList != nil {
+			t.Errorf("unexpected retry configs: %x", echErr.RetryConfigList)
+		}
+	})
+
+	t.Run("RejectionVerifyFails", func(t *testing.T) {
+		clientConfig, serverConfig := newConfigs()
+		serverConfig.EncryptedClientHelloKeys = nil
+		verifyErr := errors.New("rejection verification failed")
+		clientConfig.EncryptedClientHelloRejectionVerify = func(ConnectionState) error { return verifyErr }
+		_, _, clientErr, _ := echHandshake(t, clientConfig, serverConfig)
+		if clientErr != verifyErr {
+			t.Errorf("client error = %v, want %v", clientErr, verifyErr)
+		}
+	})
+
+	t.Run("OldMinVersion", func(t *testing.T) {
+		clientConfig, _ := newConfigs()
+		clientConfig.MinVersion = VersionTLS12
+		c, _ := localPipe(t)
+		defer c.Close()
+		if err := Client(c, clientConfig).Handshake(); err == nil {
+			t.Errorf("handshake succeeded with MinVersion < TLS 1.3 and ECH")
+		}
+	})
+}
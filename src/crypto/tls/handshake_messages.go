@@ -92,6 +92,8 @@ type clientHelloMsg struct {
 	pskModes                         []uint8
 	pskIdentities                    []pskIdentity
 	pskBinders                       [][]byte
+	quicTransportParameters          []byte
+	encryptedClientHello             []byte
 }
 
 func (m *clientHelloMsg) marshal() []byte {
@@ -99,13 +101,30 @@ func (m *clientHelloMsg) marshal() []byte {
 		return m.raw
 	}
 
+	m.raw = m.marshalMsg(false)
+	return m.raw
+}
+
+// marshalMsg serializes the ClientHello. If echInner is true, it produces
+// the EncodedClientHelloInner form of draft-ietf-tls-esni, Section 5.1:
+// the legacy_session_id is omitted, and the extensions which are copied
+// verbatim into the outer ClientHello are replaced by a single
+// ech_outer_extensions extension.
+//
+// The compressed extensions, status_request through key_share, are
+// contiguous, so the server can reconstruct the exact ClientHelloInner
+// that is hashed into the transcript. The outer ClientHello must not
+// carry early_data or pre_shared_key, which are never compressed.
+func (m *clientHelloMsg) marshalMsg(echInner bool) []byte {
 	var b cryptobyte.Builder
 	b.AddUint8(typeClientHello)
 	b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
 		b.AddUint16(m.vers)
 		addBytesWithLength(b, m.random, 32)
 		b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
-			b.AddBytes(m.sessionId)
+			if !echInner {
+				b.AddBytes(m.sessionId)
+			}
 		})
 		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
 			for _, suite := range m.cipherSuites {
@@ -133,27 +152,36 @@ func (m *clientHelloMsg) marshal() []byte {
 					})
 				})
 			}
+			var echOuterExts []uint16
 			if m.ocspStapling {
 				// RFC 4366, Section 3.6
-				b.AddUint16(extensionStatusRequest)
-				b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
-					b.AddUint8(1)  // status_type = ocsp
-					b.AddUint16(0) // empty responder_id_list
-					b.AddUint16(0) // empty request_extensions
-				})
+				if echInner {
+					echOuterExts = append(echOuterExts, extensionStatusRequest)
+				} else {
+					b.AddUint16(extensionStatusRequest)
+					b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+						b.AddUint8(1)  // status_type = ocsp
+						b.AddUint16(0) // empty responder_id_list
+						b.AddUint16(0) // empty request_extensions
+					})
+				}
 			}
 			if len(m.supportedCurves) > 0 {
 				// RFC 4492, sections 5.1.1 and RFC 8446, Section 4.2.7
-				b.AddUint16(extensionSupportedCurves)
-				b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+				if echInner {
+					echOuterExts = append(echOuterExts, extensionSupportedCurves)
+				} else {
+					b.AddUint16(extensionSupportedCurves)
 					b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
-						for _, curve := range m.supportedCurves {
-							b.AddUint16(uint16(curve))
-						}
+						b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+							for _, curve := range m.supportedCurves {
+								b.AddUint16(uint16(curve))
+							}
+						})
 					})
-				})
+				}
 			}
-			if len(m.supportedPoints) > 0 {
+			if len(m.supportedPoints) > 0 && !echInner {
 				// RFC 4492, Section 5.1.2
 				b.AddUint16(extensionSupportedPoints)
 				b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
@@ -162,7 +190,7 @@ func (m *clientHelloMsg) marshal() []byte {
 					})
 				})
 			}
-			if m.ticketSupported {
+			if m.ticketSupported && !echInner {
 				// RFC 5077, Section 3.2
 				b.AddUint16(extensionSessionTicket)
 				b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
@@ -171,27 +199,35 @@ func (m *clientHelloMsg) marshal() []byte {
 			}
 			if len(m.supportedSignatureAlgorithms) > 0 {
 				// RFC 5246, Section 7.4.1.4.1
-				b.AddUint16(extensionSignatureAlgorithms)
-				b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+				if echInner {
+					echOuterExts = append(echOuterExts, extensionSignatureAlgorithms)
+				} else {
+					b.AddUint16(extensionSignatureAlgorithms)
 					b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
-						for _, sigAlgo := range m.supportedSignatureAlgorithms {
-							b.AddUint16(uint16(sigAlgo))
-						}
+						b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+							for _, sigAlgo := range m.supportedSignatureAlgorithms {
+								b.AddUint16(uint16(sigAlgo))
+							}
+						})
 					})
-				})
+				}
 			}
 			if len(m.supportedSignatureAlgorithmsCert) > 0 {
 				// RFC 8446, Section 4.2.3
-				b.AddUint16(extensionSignatureAlgorithmsCert)
-				b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+				if echInner {
+					echOuterExts = append(echOuterExts, extensionSignatureAlgorithmsCert)
+				} else {
+					b.AddUint16(extensionSignatureAlgorithmsCert)
 					b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
-						for _, sigAlgo := range m.supportedSignatureAlgorithmsCert {
-							b.AddUint16(uint16(sigAlgo))
-						}
+						b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+							for _, sigAlgo := range m.supportedSignatureAlgorithmsCert {
+								b.AddUint16(uint16(sigAlgo))
+							}
+						})
 					})
-				})
+				}
 			}
-			if m.secureRenegotiationSupported {
+			if m.secureRenegotiationSupported && !echInner {
 				// RFC 5746, Section 3.2
 				b.AddUint16(extensionRenegotiationInfo)
 				b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
@@ -202,52 +238,83 @@ func (m *clientHelloMsg) marshal() []byte {
 			}
 			if len(m.alpnProtocols) > 0 {
 				// RFC 7301, Section 3.1
-				b.AddUint16(extensionALPN)
-				b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+				if echInner {
+					echOuterExts = append(echOuterExts, extensionALPN)
+				} else {
+					b.AddUint16(extensionALPN)
 					b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
-						for _, proto := range m.alpnProtocols {
-							b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
-								b.AddBytes([]byte(proto))
-							})
-						}
+						b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+							for _, proto := range m.alpnProtocols {
+								b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
+									b.AddBytes([]byte(proto))
+								})
+							}
+						})
 					})
-				})
+				}
 			}
 			if m.scts {
 				// RFC 6962, Section 3.3.1
-				b.AddUint16(extensionSCT)
-				b.AddUint16(0) // empty extension_data
+				if echInner {
+					echOuterExts = append(echOuterExts, extensionSCT)
+				} else {
+					b.AddUint16(extensionSCT)
+					b.AddUint16(0) // empty extension_data
+				}
 			}
 			if len(m.supportedVersions) > 0 {
 				// RFC 8446, Section 4.2.1
-				b.AddUint16(extensionSupportedVersions)
-				b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
-					b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
-						for _, vers := range m.supportedVersions {
-							b.AddUint16(vers)
-						}
+				if echInner {
+					echOuterExts = append(echOuterExts, extensionSupportedVersions)
+				} else {
+					b.AddUint16(extensionSupportedVersions)
+					b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+						b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
+							for _, vers := range m.supportedVersions {
+								b.AddUint16(vers)
+							}
+						})
 					})
-				})
+				}
 			}
 			if len(m.cookie) > 0 {
 				// RFC 8446, Section 4.2.2
-				b.AddUint16(extensionCookie)
-				b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+				if echInner {
+					echOuterExts = append(echOuterExts, extensionCookie)
+				} else {
+					b.AddUint16(extensionCookie)
 					b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
-						b.AddBytes(m.cookie)
+						b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+							b.AddBytes(m.cookie)
+						})
 					})
-				})
+				}
 			}
 			if len(m.keyShares) > 0 {
 				// RFC 8446, Section 4.2.8
-				b.AddUint16(extensionKeyShare)
-				b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+				if echInner {
+					echOuterExts = append(echOuterExts, extensionKeyShare)
+				} else {
+					b.AddUint16(extensionKeyShare)
 					b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
-						for _, ks := range m.keyShares {
-							b.AddUint16(uint16(ks.group))
-							b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
-								b.AddBytes(ks.data)
-							})
+						b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+							for _, ks := range m.keyShares {
+								b.AddUint16(uint16(ks.group))
+								b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+									b.AddBytes(ks.data)
+								})
+							}
+						})
+					})
+				}
+			}
+			if len(echOuterExts) > 0 {
+				// draft-ietf-tls-esni, Section 5.1
+				b.AddUint16(extensionECHOuterExtensions)
+				b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+					b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
+						for _, ext := range echOuterExts {
+							b.AddUint16(ext)
 						}
 					})
 				})
@@ -257,6 +324,13 @@ func (m *clientHelloMsg) marshal() []byte {
 				b.AddUint16(extensionEarlyData)
 				b.AddUint16(0) // empty extension_data
 			}
+			if m.quicTransportParameters != nil { // marshal zero-length parameters when present
+				// RFC 9001, Section 8.2
+				b.AddUint16(extensionQUICTransportParameters)
+				b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+					b.AddBytes(m.quicTransportParameters)
+				})
+			}
 			if len(m.pskModes) > 0 {
 				// RFC 8446, Section 4.2.9
 				b.AddUint16(extensionPSKModes)
@@ -266,6 +340,13 @@ func (m *clientHelloMsg) marshal() []byte {
 					})
 				})
 			}
+			if len(m.encryptedClientHello) > 0 {
+				// draft-ietf-tls-esni, Section 5
+				b.AddUint16(extensionEncryptedClientHello)
+				b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+					b.AddBytes(m.encryptedClientHello)
+				})
+			}
 			if len(m.pskIdentities) > 0 { // pre_shared_key must be the last extension
 				// RFC 8446, Section 4.2.11
 				b.AddUint16(extensionPreSharedKey)
@@ -296,8 +377,7 @@ func (m *clientHelloMsg) marshal() []byte {
 		}
 	})
 
-	m.raw = b.BytesOrPanic()
-	return m.raw
+	return b.BytesOrPanic()
 }
 
 // marshalWithoutBinders returns the ClientHello through the
@@ -540,6 +620,12 @@ func (m *clientHelloMsg) unmarshal(data []byte) bool {
 		case extensionEarlyData:
 			// RFC 8446, Section 4.2.10
 			m.earlyData = true
+		case extensionQUICTransportParameters:
+			// RFC 9001, Section 8.2
+			m.quicTransportParameters = make([]byte, len(extData))
+			if !extData.CopyBytes(m.quicTransportParameters) {
+				return false
+			}
 		case extensionPSKModes:
 			// RFC 8446, Section 4.2.9
 			if !readUint8LengthPrefixed(&extData, &m.pskModes) {
@@ -575,6 +661,12 @@ func (m *clientHelloMsg) unmarshal(data []byte) bool {
 				}
 				m.pskBinders = append(m.pskBinders, binder)
 			}
+		case extensionEncryptedClientHello:
+			// draft-ietf-tls-esni, Section 5
+			if !extData.ReadBytes(&m.encryptedClientHello, len(extData)) ||
+				len(m.encryptedClientHello) == 0 {
+				return false
+			}
 		default:
 			// Ignore unknown extensions.
 			continue
@@ -608,8 +700,9 @@ type serverHelloMsg struct {
 	supportedPoints              []uint8
 
 	// HelloRetryRequest extensions
-	cookie        []byte
-	selectedGroup CurveID
+	cookie               []byte
+	selectedGroup        CurveID
+	encryptedClientHello []byte
 }
 
 func (m *serverHelloMsg) marshal() []byte {
@@ -715,6 +808,13 @@ func (m *serverHelloMsg) marshal() []byte {
 					})
 				})
 			}
+			if len(m.encryptedClientHello) > 0 {
+				// draft-ietf-tls-esni, Section 7.2.1
+				b.AddUint16(extensionEncryptedClientHello)
+				b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+					b.AddBytes(m.encryptedClientHello)
+				})
+			}
 
 			extensionsPresent = len(b.BytesOrPanic()) > 2
 		})
@@ -825,6 +925,12 @@ func (m *serverHelloMsg) unmarshal(data []byte) bool {
 				len(m.supportedPoints) == 0 {
 				return false
 			}
+		case extensionEncryptedClientHello:
+			// draft-ietf-tls-esni, Section 7.2.1
+			m.encryptedClientHello = make([]byte, len(extData))
+			if !extData.CopyBytes(m.encryptedClientHello) {
+				return false
+			}
 		default:
 			// Ignore unknown extensions.
 			continue
@@ -839,8 +945,11 @@ func (m *serverHelloMsg) unmarshal(data []byte) bool {
 }
 
 type encryptedExtensionsMsg struct {
-	raw          []byte
-	alpnProtocol string
+	raw                     []byte
+	alpnProtocol            string
+	quicTransportParameters []byte
+	earlyData               bool
+	echRetryConfigs         []byte
 }
 
 func (m *encryptedExtensionsMsg) marshal() []byte {
@@ -862,6 +971,25 @@ func (m *encryptedExtensionsMsg) marshal() []byte {
 					})
 				})
 			}
+			if m.quicTransportParameters != nil { // marshal zero-length parameters when present
+				// RFC 9001, Section 8.2
+				b.AddUint16(extensionQUICTransportParameters)
+				b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+					b.AddBytes(m.quicTransportParameters)
+				})
+			}
+			if m.earlyData {
+				// RFC 8446, Section 4.2.10
+				b.AddUint16(extensionEarlyData)
+				b.AddUint16(0) // empty extension_data
+			}
+			if len(m.echRetryConfigs) > 0 {
+				// draft-ietf-tls-esni, Section 5
+				b.AddUint16(extensionEncryptedClientHello)
+				b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+					b.AddBytes(m.echRetryConfigs)
+				})
+			}
 		})
 	})
 
@@ -899,6 +1027,20 @@ func (m *encryptedExtensionsMsg) unmarshal(data []byte) bool {
 				return false
 			}
 			m.alpnProtocol = string(proto)
+		case extensionQUICTransportParameters:
+			m.quicTransportParameters = make([]byte, len(extData))
+			if !extData.CopyBytes(m.quicTransportParameters) {
+				return false
+			}
+		case extensionEarlyData:
+			// RFC 8446, Section 4.2.10
+			m.earlyData = true
+		case extensionEncryptedClientHello:
+			// draft-ietf-tls-esni, Section 5
+			m.echRetryConfigs = make([]byte, len(extData))
+			if !extData.CopyBytes(m.echRetryConfigs) {
+				return false
+			}
 		default:
 			// Ignore unknown extensions.
 			continue
@@ -8,13 +8,18 @@ import (
 	"bytes"
 	"context"
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"internal/testenv"
 	"io"
 	"math"
+	"math/big"
 	"net"
 	"os"
 	"reflect"
@@ -734,7 +739,7 @@ func TestWarningAlertFlood(t *testing.T) {
 }
 
 func TestCloneFuncFields(t *testing.T) {
-	const expectedCount = 6
+	const expectedCount = 7
 	called := 0
 
 	c1 := Config{
@@ -762,6 +767,10 @@ func TestCloneFuncFields(t *testing.T) {
 			called |= 1 << 5
 			return nil
 		},
+		EncryptedClientHelloRejectionVerify: func(ConnectionState) error {
+			called |= 1 << 6
+			return nil
+		},
 	}
 
 	c2 := c1.Clone()
@@ -772,6 +781,7 @@ func TestCloneFuncFields(t *testing.T) {
 	c2.GetConfigForClient(nil)
 	c2.VerifyPeerCertificate(nil, nil)
 	c2.VerifyConnection(ConnectionState{})
+	c2.EncryptedClientHelloRejectionVerify(ConnectionState{})
 
 	if called != (1<<expectedCount)-1 {
 		t.Fatalf("expected %d calls but saw calls %b", expectedCount, called)
@@ -790,7 +800,7 @@ func TestCloneNonFuncFields(t *testing.T) {
 		switch fn := typ.Field(i).Name; fn {
 		case "Rand":
 			f.Set(reflect.ValueOf(io.Reader(os.Stdin)))
-		case "Time", "GetCertificate", "GetConfigForClient", "VerifyPeerCertificate", "VerifyConnection", "GetClientCertificate":
+		case "Time", "GetCertificate", "GetConfigForClient", "VerifyPeerCertificate", "VerifyConnection", "GetClientCertificate", "EncryptedClientHelloRejectionVerify":
 			// DeepEqual can't compare functions. If you add a
 			// function field to this list, you must also change
 			// TestCloneFuncFields to ensure that the func field is
@@ -821,6 +831,12 @@ func TestCloneNonFuncFields(t *testing.T) {
 			f.Set(reflect.ValueOf([32]byte{}))
 		case "CipherSuites":
 			f.Set(reflect.ValueOf([]uint16{1, 2}))
+		case "EncryptedClientHelloConfigList":
+			f.Set(reflect.ValueOf([]byte{'x'}))
+		case "EncryptedClientHelloKeys":
+			f.Set(reflect.ValueOf([]EncryptedClientHelloKey{
+				{Config: []byte{1}, PrivateKey: []byte{1}},
+			}))
 		case "CurvePreferences":
 			f.Set(reflect.ValueOf([]CurveID{CurveP256}))
 		case "Renegotiation":
@@ -1609,3 +1625,197 @@ func TestPKCS1OnlyCert(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestKeyShareGroups(t *testing.T) {
+	tests := []struct {
+		name         string
+		clientCurves []CurveID
+		serverCurves []CurveID
+		clientMax    uint16
+		wantCurve    CurveID
+		wantHRR      bool
+	}{
+		{
+			name:         "hybrid",
+			clientCurves: []CurveID{X25519MLKEM768, X25519},
+			serverCurves: []CurveID{X25519MLKEM768, X25519},
+			wantCurve:    X25519MLKEM768,
+		},
+		{
+			name:         "hybrid only",
+			clientCurves: []CurveID{X25519MLKEM768},
+			serverCurves: []CurveID{X25519MLKEM768},
+			wantCurve:    X25519MLKEM768,
+		},
+		{
+			name:         "server without hybrid",
+			clientCurves: []CurveID{X25519MLKEM768, X25519},
+			serverCurves: []CurveID{X25519, CurveP256},
+			wantCurve:    X25519,
+		},
+		{
+			// The server gives priority to groups with a key share.
+			name:         "server prefers hybrid",
+			clientCurves: []CurveID{X25519, X25519MLKEM768},
+			serverCurves: []CurveID{X25519MLKEM768, X25519},
+			wantCurve:    X25519,
+		},
+		{
+			name:         "hybrid retry",
+			clientCurves: []CurveID{CurveP256, X25519MLKEM768},
+			serverCurves: []CurveID{X25519MLKEM768},
+			wantCurve:    X25519MLKEM768,
+			wantHRR:      true,
+		},
+		{
+			name:         "server without hybrid retry",
+			clientCurves: []CurveID{X25519MLKEM768, CurveP256},
+			serverCurves: []CurveID{CurveP256},
+			wantCurve:    CurveP256,
+			wantHRR:      true,
+		},
+		{
+			name:         "TLS 1.2",
+			clientCurves: []CurveID{X25519MLKEM768, CurveP256},
+			serverCurves: []CurveID{X25519MLKEM768, CurveP256},
+			clientMax:    VersionTLS12,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientConfig := testConfig.Clone()
+			clientConfig.CurvePreferences = tt.clientCurves
+			if tt.clientMax != 0 {
+				clientConfig.MaxVersion = tt.clientMax
+			}
+			serverConfig := testConfig.Clone()
+			serverConfig.CurvePreferences = tt.serverCurves
+			ss, cs, err := testHandshake(t, clientConfig, serverConfig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ss.testingOnlyCurveID != tt.wantCurve || cs.testingOnlyCurveID != tt.wantCurve {
+				t.Errorf("got groups %v (server) and %v (client), want %v",
+					ss.testingOnlyCurveID, cs.testingOnlyCurveID, tt.wantCurve)
+			}
+			if ss.testingOnlyDidHRR != tt.wantHRR || cs.testingOnlyDidHRR != tt.wantHRR {
+				t.Errorf("got HelloRetryRequest %v (server) and %v (client), want %v",
+					ss.testingOnlyDidHRR, cs.testingOnlyDidHRR, tt.wantHRR)
+			}
+		})
+	}
+}
+
+func TestKeyShareGroupsMismatch(t *testing.T) {
+	clientConfig := testConfig.Clone()
+	clientConfig.CurvePreferences = []CurveID{X25519MLKEM768}
+	serverConfig := testConfig.Clone()
+	serverConfig.CurvePreferences = []CurveID{X25519}
+	if _, _, err := testHandshake(t, clientConfig, serverConfig); err == nil {
+		t.Fatal("expected handshake to fail without a mutually supported group")
+	}
+
+	// X25519MLKEM768 can't be used in TLS 1.2.
+	clientConfig.MaxVersion = VersionTLS12
+	clientConfig.CipherSuites = []uint16{TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}
+	serverConfig.CurvePreferences = []CurveID{X25519MLKEM768}
+	if _, _, err := testHandshake(t, clientConfig, serverConfig); err == nil {
+		t.Fatal("expected TLS 1.2 handshake to fail with only X25519MLKEM768")
+	}
+}
+
+func TestStapledOCSPRevocation(t *testing.T) {
+	now := time.Now()
+	newCert := func(template, parent *x509.Certificate, parentKey crypto.Signer) (*x509.Certificate, crypto.Signer) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parent == nil {
+			parent, parentKey = template, key
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert, key
+	}
+	ca, caKey := newCert(&x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "OCSP Test CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, nil, nil)
+	leaf, leafKey := newCert(&x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "example.golang"},
+		DNSNames:     []string{"example.golang"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca, caKey)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	for _, status := range []x509.OCSPStatus{x509.OCSPGood, x509.OCSPRevoked} {
+		staple, err := x509.CreateOCSPResponse(rand.Reader, &x509.OCSPResponse{
+			Status:       status,
+			SerialNumber: leaf.SerialNumber,
+			ThisUpdate:   now.Add(-time.Minute),
+			NextUpdate:   now.Add(time.Hour),
+			RevokedAt:    now.Add(-time.Minute),
+		}, ca, ca, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, version := range []uint16{VersionTLS12, VersionTLS13} {
+			serverConfig := testConfig.Clone()
+			serverConfig.MaxVersion = version
+			serverConfig.Certificates = []Certificate{{
+				Certificate: [][]byte{leaf.Raw},
+				PrivateKey:  leafKey,
+				OCSPStaple:  staple,
+			}}
+			clientConfig := testConfig.Clone()
+			clientConfig.MaxVersion = version
+			clientConfig.ServerName = "example.golang"
+			var verifyErr error
+			clientConfig.VerifyConnection = func(cs ConnectionState) error {
+				resp, err := x509.ParseOCSPResponseForCert(cs.OCSPResponse, cs.PeerCertificates[0], ca)
+				if err != nil {
+					verifyErr = err
+					return err
+				}
+				policy := &x509.RevocationPolicy{
+					OCSPResponses: []*x509.OCSPResponse{resp},
+					RequireStatus: true,
+				}
+				_, err = cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+					DNSName:         cs.ServerName,
+					Roots:           roots,
+					CheckRevocation: policy.Check,
+				})
+				verifyErr = err
+				return err
+			}
+
+			_, _, err := testHandshake(t, clientConfig, serverConfig)
+			if status == x509.OCSPGood && err != nil {
+				t.Errorf("%x: handshake with good OCSP staple failed: %v", version, err)
+			}
+			var invalidErr x509.CertificateInvalidError
+			if status == x509.OCSPRevoked && (err == nil || !errors.As(verifyErr, &invalidErr) || invalidErr.Reason != x509.Revoked) {
+				t.Errorf("%x: handshake with revoked OCSP staple returned %v (verification error %v), want revocation error", version, err, verifyErr)
+			}
+		}
+	}
+}
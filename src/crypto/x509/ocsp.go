@@ -0,0 +1,637 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"time"
+)
+
+// This file implements the Online Certificate Status Protocol (OCSP) request
+// and response formats, as specified in RFC 6960.
+
+var oidOCSPBasicResponse = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+
+var ocspHashOIDs = []struct {
+	hash crypto.Hash
+	oid  asn1.ObjectIdentifier
+}{
+	{crypto.SHA1, asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}},
+	{crypto.SHA256, asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}},
+	{crypto.SHA384, asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}},
+	{crypto.SHA512, asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}},
+}
+
+func ocspHashFromOID(oid asn1.ObjectIdentifier) crypto.Hash {
+	for _, h := range ocspHashOIDs {
+		if h.oid.Equal(oid) {
+			return h.hash
+		}
+	}
+	return 0
+}
+
+func ocspOIDFromHash(hash crypto.Hash) (asn1.ObjectIdentifier, bool) {
+	for _, h := range ocspHashOIDs {
+		if h.hash == hash {
+			return h.oid, true
+		}
+	}
+	return nil, false
+}
+
+// These structures mirror the ASN.1 definitions in RFC 6960, Section 4.
+
+type ocspCertID struct {
+	HashAlgorithm  pkix.AlgorithmIdentifier
+	IssuerNameHash []byte
+	IssuerKeyHash  []byte
+	SerialNumber   *big.Int
+}
+
+type ocspRequest struct {
+	TBSRequest        ocspTBSRequest
+	OptionalSignature asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+type ocspTBSRequest struct {
+	Version       int           `asn1:"explicit,tag:0,default:0,optional"`
+	RequestorName asn1.RawValue `asn1:"explicit,tag:1,optional"`
+	RequestList   []ocspSingleRequest
+	Extensions    []pkix.Extension `asn1:"explicit,tag:2,optional"`
+}
+
+type ocspSingleRequest struct {
+	Cert       ocspCertID
+	Extensions []pkix.Extension `asn1:"explicit,tag:0,optional"`
+}
+
+type ocspResponse struct {
+	Status   asn1.Enumerated
+	Response ocspResponseBytes `asn1:"explicit,tag:0,optional"`
+}
+
+type ocspResponseBytes struct {
+	ResponseType asn1.ObjectIdentifier
+	Response     []byte
+}
+
+type ocspBasicResponse struct {
+	TBSResponseData    ocspResponseData
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+	Certificates       []asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+type ocspResponseData struct {
+	Raw                asn1.RawContent
+	Version            int `asn1:"explicit,tag:0,default:0,optional"`
+	RawResponderID     asn1.RawValue
+	ProducedAt         time.Time `asn1:"generalized"`
+	Responses          []ocspSingleResponse
+	ResponseExtensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+type ocspSingleResponse struct {
+	CertID           ocspCertID
+	Good             asn1.Flag        `asn1:"tag:0,optional"`
+	Revoked          ocspRevokedInfo  `asn1:"tag:1,optional"`
+	Unknown          asn1.Flag        `asn1:"tag:2,optional"`
+	ThisUpdate       time.Time        `asn1:"generalized"`
+	NextUpdate       time.Time        `asn1:"generalized,explicit,tag:0,optional"`
+	SingleExtensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+type ocspRevokedInfo struct {
+	RevocationTime time.Time       `asn1:"generalized"`
+	Reason         asn1.Enumerated `asn1:"explicit,tag:0,optional"`
+}
+
+// OCSPResponseStatus is the status of an OCSP response, as opposed to the
+// status of the certificate it is about. See RFC 6960, Section 4.2.1.
+type OCSPResponseStatus int
+
+const (
+	OCSPSuccessful        OCSPResponseStatus = 0
+	OCSPMalformedRequest  OCSPResponseStatus = 1
+	OCSPInternalError     OCSPResponseStatus = 2
+	OCSPTryLater          OCSPResponseStatus = 3
+	OCSPSignatureRequired OCSPResponseStatus = 5
+	OCSPUnauthorized      OCSPResponseStatus = 6
+)
+
+func (s OCSPResponseStatus) String() string {
+	switch s {
+	case OCSPSuccessful:
+		return "successful"
+	case OCSPMalformedRequest:
+		return "malformed request"
+	case OCSPInternalError:
+		return "internal error"
+	case OCSPTryLater:
+		return "try later"
+	case OCSPSignatureRequired:
+		return "signature required"
+	case OCSPUnauthorized:
+		return "unauthorized"
+	}
+	return "unknown OCSP response status: " + strconv.Itoa(int(s))
+}
+
+// OCSPResponseError is returned by ParseOCSPResponse and
+// ParseOCSPResponseForCert when the responder returned an error status
+// instead of a response.
+type OCSPResponseError struct {
+	Status OCSPResponseStatus
+}
+
+func (e OCSPResponseError) Error() string {
+	return "x509: OCSP error response: " + e.Status.String()
+}
+
+// OCSPStatus is the revocation status of a certificate in an OCSP response.
+type OCSPStatus int
+
+const (
+	// OCSPGood means the certificate is not revoked.
+	OCSPGood OCSPStatus = iota
+	// OCSPRevoked means the certificate has been revoked.
+	OCSPRevoked
+	// OCSPUnknown means the responder doesn't know about the certificate.
+	OCSPUnknown
+)
+
+// OCSPRequest represents an OCSP request for the status of a single
+// certificate. See RFC 6960, Section 4.1.
+type OCSPRequest struct {
+	// HashAlgorithm is the hash used to compute IssuerNameHash and
+	// IssuerKeyHash.
+	HashAlgorithm crypto.Hash
+	// IssuerNameHash is the hash of the DER encoded subject of the issuer.
+	IssuerNameHash []byte
+	// IssuerKeyHash is the hash of the issuer's public key, excluding the
+	// tag, length, and unused bits count of the subjectPublicKey BIT STRING.
+	IssuerKeyHash []byte
+	// SerialNumber is the serial number of the certificate.
+	SerialNumber *big.Int
+}
+
+// ocspIssuerHashes returns the hashes of the issuer name and key, as used in
+// the CertID structure of OCSP requests and responses.
+func ocspIssuerHashes(issuer *Certificate, hash crypto.Hash) (nameHash, keyHash []byte, err error) {
+	if !hash.Available() {
+		return nil, nil, errors.New("x509: OCSP hash function is not available")
+	}
+	var spki publicKeyInfo
+	if rest, err := asn1.Unmarshal(issuer.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return nil, nil, err
+	} else if len(rest) != 0 {
+		return nil, nil, errors.New("x509: trailing data after issuer public key")
+	}
+
+	h := hash.New()
+	h.Write(issuer.RawSubject)
+	nameHash = h.Sum(nil)
+	h.Reset()
+	h.Write(spki.PublicKey.RightAlign())
+	keyHash = h.Sum(nil)
+	return nameHash, keyHash, nil
+}
+
+// CreateOCSPRequest returns a DER encoded OCSP request for the status of
+// cert, which must have been issued by issuer. If hash is zero, SHA-1 is used,
+// as it is the hash most widely supported by OCSP responders.
+func CreateOCSPRequest(cert, issuer *Certificate, hash crypto.Hash) ([]byte, error) {
+	if hash == 0 {
+		hash = crypto.SHA1
+	}
+	hashOID, ok := ocspOIDFromHash(hash)
+	if !ok {
+		return nil, errors.New("x509: unsupported OCSP hash function")
+	}
+	nameHash, keyHash, err := ocspIssuerHashes(issuer, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(ocspRequest{
+		TBSRequest: ocspTBSRequest{
+			RequestList: []ocspSingleRequest{{
+				Cert: ocspCertID{
+					HashAlgorithm: pkix.AlgorithmIdentifier{
+						Algorithm:  hashOID,
+						Parameters: asn1.NullRawValue,
+					},
+					IssuerNameHash: nameHash,
+					IssuerKeyHash:  keyHash,
+					SerialNumber:   cert.SerialNumber,
+				},
+			}},
+		},
+	})
+}
+
+// ParseOCSPRequest parses an OCSP request in DER form. Only the first
+// certificate in the request is returned. Request signatures and extensions
+// are ignored.
+func ParseOCSPRequest(der []byte) (*OCSPRequest, error) {
+	var req ocspRequest
+	rest, err := asn1.Unmarshal(der, &req)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, errors.New("x509: trailing data after OCSP request")
+	}
+	if len(req.TBSRequest.RequestList) == 0 {
+		return nil, errors.New("x509: OCSP request contains no request body")
+	}
+	certID := req.TBSRequest.RequestList[0].Cert
+
+	hash := ocspHashFromOID(certID.HashAlgorithm.Algorithm)
+	if hash == 0 {
+		return nil, errors.New("x509: unsupported OCSP request hash algorithm")
+	}
+	return &OCSPRequest{
+		HashAlgorithm:  hash,
+		IssuerNameHash: certID.IssuerNameHash,
+		IssuerKeyHash:  certID.IssuerKeyHash,
+		SerialNumber:   certID.SerialNumber,
+	}, nil
+}
+
+// OCSPResponse represents an OCSP response about a single certificate.
+// See RFC 6960, Section 4.2.
+type OCSPResponse struct {
+	// Raw contains the complete ASN.1 DER content of the response. It is set
+	// when parsing a response.
+	Raw []byte
+	// RawTBSResponseData contains just the signed tbsResponseData portion
+	// of the ASN.1 DER. It is set when parsing a response.
+	RawTBSResponseData []byte
+
+	// Status is the revocation status of the certificate.
+	Status OCSPStatus
+	// SerialNumber is the serial number of the certificate.
+	SerialNumber *big.Int
+
+	// HashAlgorithm is the hash used to compute IssuerNameHash and
+	// IssuerKeyHash. When creating a response, the zero value means SHA-1.
+	HashAlgorithm crypto.Hash
+	// IssuerNameHash and IssuerKeyHash identify the issuer of the
+	// certificate, as in OCSPRequest. They are set when parsing a response,
+	// and computed from the issuer when creating one.
+	IssuerNameHash []byte
+	IssuerKeyHash  []byte
+
+	// ProducedAt is the time at which the response was signed, ThisUpdate
+	// the time at which the status was known to be correct, and NextUpdate,
+	// if not zero, the time at or before which newer information will be
+	// available.
+	ProducedAt, ThisUpdate, NextUpdate time.Time
+
+	// RevokedAt and RevocationReason are only meaningful if Status is
+	// OCSPRevoked. RevocationReason uses the values of the CRL reasonCode
+	// extension, specified in RFC 5280, Section 5.3.1.
+	RevokedAt        time.Time
+	RevocationReason int
+
+	// RawResponderName is the DER encoded name of the responder, if it
+	// identified itself by name. Otherwise, ResponderKeyHash is the SHA-1
+	// hash of its public key. One of the two is set when parsing a response.
+	RawResponderName []byte
+	ResponderKeyHash []byte
+
+	// Certificate is the delegated responder certificate included in the
+	// response, if any. A delegated responder must be issued directly by
+	// the issuer of the certificate and have the OCSP signing extended key
+	// usage. When creating a response, it is populated from the responder
+	// certificate if it's different from the issuer.
+	Certificate *Certificate
+
+	Signature          []byte
+	SignatureAlgorithm SignatureAlgorithm
+
+	// Extensions contains the raw responseExtensions of the response, and
+	// SingleExtensions the raw singleExtensions of the certificate status.
+	// They are ignored when creating a response, see ExtraExtensions.
+	Extensions       []pkix.Extension
+	SingleExtensions []pkix.Extension
+
+	// ExtraExtensions contains extensions to be copied, raw, into the
+	// responseExtensions of a created response.
+	ExtraExtensions []pkix.Extension
+}
+
+// ParseOCSPResponse parses an OCSP response in DER form. The response must
+// contain the status of exactly one certificate. The signature on the
+// response is not checked, see OCSPResponse.CheckSignatureFrom.
+//
+// If the responder returned an error status instead of a response, the
+// returned error is an OCSPResponseError.
+func ParseOCSPResponse(der []byte) (*OCSPResponse, error) {
+	return parseOCSPResponse(der, nil)
+}
+
+// ParseOCSPResponseForCert parses an OCSP response in DER form, selecting the
+// status of cert if the response contains more than one. It also checks that
+// the response is about a certificate issued by issuer, and that it is
+// properly signed by issuer or a delegated responder.
+//
+// If the responder returned an error status instead of a response, the
+// returned error is an OCSPResponseError.
+func ParseOCSPResponseForCert(der []byte, cert, issuer *Certificate) (*OCSPResponse, error) {
+	resp, err := parseOCSPResponse(der, cert.SerialNumber)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.matchesIssuer(issuer) {
+		return nil, errors.New("x509: OCSP response is for a different issuer")
+	}
+	if err := resp.CheckSignatureFrom(issuer); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func parseOCSPResponse(der []byte, serial *big.Int) (*OCSPResponse, error) {
+	var resp ocspResponse
+	rest, err := asn1.Unmarshal(der, &resp)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, errors.New("x509: trailing data after OCSP response")
+	}
+	if status := OCSPResponseStatus(resp.Status); status != OCSPSuccessful {
+		return nil, OCSPResponseError{status}
+	}
+	if !resp.Response.ResponseType.Equal(oidOCSPBasicResponse) {
+		return nil, errors.New("x509: unsupported OCSP response type")
+	}
+
+	var basic ocspBasicResponse
+	rest, err = asn1.Unmarshal(resp.Response.Response, &basic)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, errors.New("x509: trailing data after OCSP basic response")
+	}
+
+	responses := basic.TBSResponseData.Responses
+	if serial == nil && len(responses) != 1 {
+		return nil, fmt.Errorf("x509: OCSP response contains %d certificate statuses, expected one", len(responses))
+	}
+	var single *ocspSingleResponse
+	for i := range responses {
+		if serial == nil || responses[i].CertID.SerialNumber.Cmp(serial) == 0 {
+			single = &responses[i]
+			break
+		}
+	}
+	if single == nil {
+		return nil, errors.New("x509: OCSP response does not contain the status of the certificate")
+	}
+
+	out := &OCSPResponse{
+		Raw:                der,
+		RawTBSResponseData: basic.TBSResponseData.Raw,
+		SerialNumber:       single.CertID.SerialNumber,
+		HashAlgorithm:      ocspHashFromOID(single.CertID.HashAlgorithm.Algorithm),
+		IssuerNameHash:     single.CertID.IssuerNameHash,
+		IssuerKeyHash:      single.CertID.IssuerKeyHash,
+		ProducedAt:         basic.TBSResponseData.ProducedAt,
+		ThisUpdate:         single.ThisUpdate,
+		NextUpdate:         single.NextUpdate,
+		Signature:          basic.Signature.RightAlign(),
+		SignatureAlgorithm: getSignatureAlgorithmFromAI(basic.SignatureAlgorithm),
+		Extensions:         basic.TBSResponseData.ResponseExtensions,
+		SingleExtensions:   single.SingleExtensions,
+	}
+	if out.HashAlgorithm == 0 {
+		return nil, errors.New("x509: unsupported OCSP response hash algorithm")
+	}
+
+	switch rawID := basic.TBSResponseData.RawResponderID; {
+	case rawID.Class == asn1.ClassContextSpecific && rawID.Tag == 1:
+		// byName [1] Name
+		out.RawResponderName = rawID.Bytes
+	case rawID.Class == asn1.ClassContextSpecific && rawID.Tag == 2:
+		// byKey [2] KeyHash
+		if rest, err := asn1.Unmarshal(rawID.Bytes, &out.ResponderKeyHash); err != nil || len(rest) != 0 {
+			return nil, errors.New("x509: malformed OCSP responder ID")
+		}
+	default:
+		return nil, errors.New("x509: malformed OCSP responder ID")
+	}
+
+	switch {
+	case bool(single.Good):
+		out.Status = OCSPGood
+	case bool(single.Unknown):
+		out.Status = OCSPUnknown
+	default:
+		out.Status = OCSPRevoked
+		out.RevokedAt = single.Revoked.RevocationTime
+		out.RevocationReason = int(single.Revoked.Reason)
+	}
+
+	switch len(basic.Certificates) {
+	case 0:
+	case 1:
+		out.Certificate, err = ParseCertificate(basic.Certificates[0].FullBytes)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("x509: OCSP response contains more than one certificate")
+	}
+
+	return out, nil
+}
+
+// matchesIssuer reports whether the CertID of the response identifies issuer.
+func (resp *OCSPResponse) matchesIssuer(issuer *Certificate) bool {
+	nameHash, keyHash, err := ocspIssuerHashes(issuer, resp.HashAlgorithm)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(nameHash, resp.IssuerNameHash) && bytes.Equal(keyHash, resp.IssuerKeyHash)
+}
+
+// CheckSignatureFrom verifies that the signature on resp is a valid signature
+// from issuer, or from a delegated responder certificate included in the
+// response and issued by issuer for OCSP signing.
+func (resp *OCSPResponse) CheckSignatureFrom(issuer *Certificate) error {
+	signer := issuer
+	if c := resp.Certificate; c != nil && !bytes.Equal(c.Raw, issuer.Raw) {
+		if err := c.CheckSignatureFrom(issuer); err != nil {
+			return fmt.Errorf("x509: bad OCSP responder certificate: %w", err)
+		}
+		hasOCSPSigning := false
+		for _, eku := range c.ExtKeyUsage {
+			if eku == ExtKeyUsageOCSPSigning {
+				hasOCSPSigning = true
+				break
+			}
+		}
+		if !hasOCSPSigning {
+			return errors.New("x509: OCSP responder certificate is not authorized for OCSP signing")
+		}
+		signer = c
+	}
+	if signer.PublicKeyAlgorithm == UnknownPublicKeyAlgorithm {
+		return ErrUnsupportedAlgorithm
+	}
+	return signer.CheckSignature(resp.SignatureAlgorithm, resp.RawTBSResponseData, resp.Signature)
+}
+
+// CreateOCSPResponse returns a DER encoded OCSP response about a certificate
+// issued by issuer, based on template.
+//
+// The response is signed by priv, which must be the private key associated
+// with responder. responder is either issuer itself or a delegated responder
+// certificate issued by it with the OCSP signing extended key usage, in
+// which case it is included in the response.
+//
+// The Status, SerialNumber, ThisUpdate, NextUpdate, RevokedAt,
+// RevocationReason, HashAlgorithm, SignatureAlgorithm and ExtraExtensions
+// fields of template are used. The response is produced at the current time,
+// and the responder is identified by the hash of its public key.
+func CreateOCSPResponse(rand io.Reader, template *OCSPResponse, issuer, responder *Certificate, priv crypto.Signer) ([]byte, error) {
+	if template == nil {
+		return nil, errors.New("x509: template can not be nil")
+	}
+	if issuer == nil || responder == nil {
+		return nil, errors.New("x509: issuer and responder can not be nil")
+	}
+	if template.SerialNumber == nil {
+		return nil, errors.New("x509: template contains nil SerialNumber field")
+	}
+	if !template.NextUpdate.IsZero() && template.NextUpdate.Before(template.ThisUpdate) {
+		return nil, errors.New("x509: template.ThisUpdate is after template.NextUpdate")
+	}
+
+	hash := template.HashAlgorithm
+	if hash == 0 {
+		hash = crypto.SHA1
+	}
+	hashOID, ok := ocspOIDFromHash(hash)
+	if !ok {
+		return nil, errors.New("x509: unsupported OCSP hash function")
+	}
+	nameHash, keyHash, err := ocspIssuerHashes(issuer, hash)
+	if err != nil {
+		return nil, err
+	}
+	// The responder key hash is always SHA-1. See RFC 6960, Section 4.2.1.
+	_, responderKeyHash, err := ocspIssuerHashes(responder, crypto.SHA1)
+	if err != nil {
+		return nil, err
+	}
+	responderKeyHashDER, err := asn1.Marshal(responderKeyHash)
+	if err != nil {
+		return nil, err
+	}
+
+	single := ocspSingleResponse{
+		CertID: ocspCertID{
+			HashAlgorithm: pkix.AlgorithmIdentifier{
+				Algorithm:  hashOID,
+				Parameters: asn1.NullRawValue,
+			},
+			IssuerNameHash: nameHash,
+			IssuerKeyHash:  keyHash,
+			SerialNumber:   template.SerialNumber,
+		},
+		ThisUpdate: template.ThisUpdate.UTC(),
+		NextUpdate: template.NextUpdate.UTC(),
+	}
+	switch template.Status {
+	case OCSPGood:
+		single.Good = true
+	case OCSPUnknown:
+		single.Unknown = true
+	case OCSPRevoked:
+		single.Revoked = ocspRevokedInfo{
+			RevocationTime: template.RevokedAt.UTC(),
+			Reason:         asn1.Enumerated(template.RevocationReason),
+		}
+	default:
+		return nil, errors.New("x509: invalid OCSP certificate status")
+	}
+
+	tbsResponseData := ocspResponseData{
+		RawResponderID: asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        2,
+			IsCompound: true,
+			Bytes:      responderKeyHashDER,
+		},
+		ProducedAt:         time.Now().Truncate(time.Minute).UTC(),
+		Responses:          []ocspSingleResponse{single},
+		ResponseExtensions: template.ExtraExtensions,
+	}
+	tbsResponseDataContents, err := asn1.Marshal(tbsResponseData)
+	if err != nil {
+		return nil, err
+	}
+	tbsResponseData.Raw = tbsResponseDataContents
+
+	hashFunc, signatureAlgorithm, err := signingParamsForPublicKey(priv.Public(), template.SignatureAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	input := tbsResponseDataContents
+	if hashFunc != 0 {
+		h := hashFunc.New()
+		h.Write(tbsResponseDataContents)
+		input = h.Sum(nil)
+	}
+	var signerOpts crypto.SignerOpts = hashFunc
+	if template.SignatureAlgorithm.isRSAPSS() {
+		signerOpts = &rsa.PSSOptions{
+			SaltLength: rsa.PSSSaltLengthEqualsHash,
+			Hash:       hashFunc,
+		}
+	}
+
+	signature, err := priv.Sign(rand, input, signerOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	basic := ocspBasicResponse{
+		TBSResponseData:    tbsResponseData,
+		SignatureAlgorithm: signatureAlgorithm,
+		Signature:          asn1.BitString{Bytes: signature, BitLength: len(signature) * 8},
+	}
+	if !bytes.Equal(responder.Raw, issuer.Raw) {
+		basic.Certificates = []asn1.RawValue{{FullBytes: responder.Raw}}
+	}
+	basicDER, err := asn1.Marshal(basic)
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(ocspResponse{
+		Status: asn1.Enumerated(OCSPSuccessful),
+		Response: ocspResponseBytes{
+			ResponseType: oidOCSPBasicResponse,
+			Response:     basicDER,
+		},
+	})
+}
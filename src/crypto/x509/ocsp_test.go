@@ -0,0 +1,165 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestOCSPRequest(t *testing.T) {
+	ca, caKey := generateRevocationTestCert(t, "CA", true, 1, nil, nil)
+	leaf, _ := generateRevocationTestCert(t, "Leaf", false, 2, ca, caKey)
+
+	for _, hash := range []crypto.Hash{0, crypto.SHA1, crypto.SHA256, crypto.SHA384, crypto.SHA512} {
+		der, err := CreateOCSPRequest(leaf, ca, hash)
+		if err != nil {
+			t.Fatalf("CreateOCSPRequest(%v): %v", hash, err)
+		}
+		req, err := ParseOCSPRequest(der)
+		if err != nil {
+			t.Fatalf("ParseOCSPRequest(%v): %v", hash, err)
+		}
+		wantHash := hash
+		if wantHash == 0 {
+			wantHash = crypto.SHA1
+		}
+		nameHash, keyHash, err := ocspIssuerHashes(ca, wantHash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if req.HashAlgorithm != wantHash {
+			t.Errorf("HashAlgorithm = %v, want %v", req.HashAlgorithm, wantHash)
+		}
+		if !bytes.Equal(req.IssuerNameHash, nameHash) || !bytes.Equal(req.IssuerKeyHash, keyHash) {
+			t.Errorf("%v: issuer hashes don't match", hash)
+		}
+		if req.SerialNumber.Cmp(leaf.SerialNumber) != 0 {
+			t.Errorf("SerialNumber = %v, want %v", req.SerialNumber, leaf.SerialNumber)
+		}
+	}
+
+	if _, err := CreateOCSPRequest(leaf, ca, crypto.MD5); err == nil {
+		t.Error("CreateOCSPRequest accepted MD5")
+	}
+}
+
+func TestOCSPResponse(t *testing.T) {
+	ca, caKey := generateRevocationTestCert(t, "CA", true, 1, nil, nil)
+	leaf, _ := generateRevocationTestCert(t, "Leaf", false, 2, ca, caKey)
+	otherCA, _ := generateRevocationTestCert(t, "Other CA", true, 1, nil, nil)
+
+	thisUpdate := revocationTestNow.Truncate(time.Second)
+	nextUpdate := thisUpdate.Add(time.Hour)
+	revokedAt := thisUpdate.Add(-time.Hour)
+	extraExt := pkix.Extension{Id: []int{1, 2, 3}, Value: []byte{5, 0}}
+
+	for _, status := range []OCSPStatus{OCSPGood, OCSPRevoked, OCSPUnknown} {
+		template := &OCSPResponse{
+			Status:           status,
+			SerialNumber:     leaf.SerialNumber,
+			HashAlgorithm:    crypto.SHA256,
+			ThisUpdate:       thisUpdate,
+			NextUpdate:       nextUpdate,
+			RevokedAt:        revokedAt,
+			RevocationReason: 4,
+			ExtraExtensions:  []pkix.Extension{extraExt},
+		}
+		der, err := CreateOCSPResponse(rand.Reader, template, ca, ca, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := ParseOCSPResponseForCert(der, leaf, ca)
+		if err != nil {
+			t.Fatalf("status %d: %v", status, err)
+		}
+		if resp.Status != status || resp.SerialNumber.Cmp(leaf.SerialNumber) != 0 ||
+			resp.HashAlgorithm != crypto.SHA256 || resp.Certificate != nil {
+			t.Errorf("status %d: unexpected response %+v", status, resp)
+		}
+		if !resp.ThisUpdate.Equal(thisUpdate) || !resp.NextUpdate.Equal(nextUpdate) {
+			t.Errorf("ThisUpdate, NextUpdate = %v, %v", resp.ThisUpdate, resp.NextUpdate)
+		}
+		if status == OCSPRevoked {
+			if !resp.RevokedAt.Equal(revokedAt) || resp.RevocationReason != 4 {
+				t.Errorf("RevokedAt, RevocationReason = %v, %d", resp.RevokedAt, resp.RevocationReason)
+			}
+		} else if !resp.RevokedAt.IsZero() {
+			t.Errorf("status %d: RevokedAt = %v", status, resp.RevokedAt)
+		}
+		if len(resp.Extensions) != 1 || !resp.Extensions[0].Id.Equal(extraExt.Id) {
+			t.Errorf("Extensions = %v", resp.Extensions)
+		}
+		if _, keyHash, _ := ocspIssuerHashes(ca, crypto.SHA1); !bytes.Equal(resp.ResponderKeyHash, keyHash) {
+			t.Errorf("ResponderKeyHash = %x, want %x", resp.ResponderKeyHash, keyHash)
+		}
+		if !bytes.Equal(resp.Raw, der) {
+			t.Error("Raw doesn't match the response")
+		}
+
+		if _, err := ParseOCSPResponseForCert(der, leaf, otherCA); err == nil {
+			t.Error("ParseOCSPResponseForCert accepted a response for another issuer")
+		}
+		if err := resp.CheckSignatureFrom(otherCA); err == nil {
+			t.Error("CheckSignatureFrom accepted another issuer")
+		}
+	}
+}
+
+func TestOCSPResponseDelegated(t *testing.T) {
+	ca, caKey := generateRevocationTestCert(t, "CA", true, 1, nil, nil)
+	leaf, _ := generateRevocationTestCert(t, "Leaf", false, 2, ca, caKey)
+	responder, responderKey := generateRevocationTestCert(t, "Responder", false, 3, ca, caKey, ExtKeyUsageOCSPSigning)
+	notResponder, notResponderKey := generateRevocationTestCert(t, "Not Responder", false, 4, ca, caKey, ExtKeyUsageServerAuth)
+	otherCA, otherCAKey := generateRevocationTestCert(t, "CA", true, 1, nil, nil)
+	otherResponder, otherResponderKey := generateRevocationTestCert(t, "Responder", false, 3, otherCA, otherCAKey, ExtKeyUsageOCSPSigning)
+
+	template := &OCSPResponse{
+		Status:       OCSPGood,
+		SerialNumber: leaf.SerialNumber,
+		ThisUpdate:   revocationTestNow,
+	}
+	tests := []struct {
+		name      string
+		responder *Certificate
+		key       crypto.Signer
+		ok        bool
+	}{
+		{"delegated", responder, responderKey, true},
+		{"missing EKU", notResponder, notResponderKey, false},
+		{"other issuer", otherResponder, otherResponderKey, false},
+	}
+	for _, tt := range tests {
+		der, err := CreateOCSPResponse(rand.Reader, template, ca, tt.responder, tt.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := ParseOCSPResponseForCert(der, leaf, ca)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: ParseOCSPResponseForCert error = %v", tt.name, err)
+		}
+		if err == nil && !bytes.Equal(resp.Certificate.Raw, tt.responder.Raw) {
+			t.Errorf("%s: Certificate is not the responder", tt.name)
+		}
+	}
+}
+
+func TestOCSPErrorResponse(t *testing.T) {
+	der, err := asn1.Marshal(ocspResponse{Status: asn1.Enumerated(OCSPTryLater)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ParseOCSPResponse(der)
+	var respErr OCSPResponseError
+	if !errors.As(err, &respErr) || respErr.Status != OCSPTryLater {
+		t.Fatalf("ParseOCSPResponse error = %v, want OCSPResponseError", err)
+	}
+}
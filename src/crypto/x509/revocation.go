@@ -0,0 +1,135 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+)
+
+// RevocationPolicy checks the revocation status of certificates against a
+// set of CRLs and OCSP responses that were obtained ahead of time, for
+// example from CRL distribution points, from an OCSP responder, or stapled
+// to a TLS handshake (see crypto/tls.ConnectionState.OCSPResponse). Its
+// Check method can be used as VerifyOptions.CheckRevocation.
+//
+// RevocationPolicy never makes network requests.
+type RevocationPolicy struct {
+	// CRLs is a list of CRLs to consult. Only CRLs issued directly by the
+	// issuer of a certificate and signed with its key are used. CRLs with
+	// critical extensions, such as delta CRLs, indirect CRLs and CRLs whose
+	// scope is limited by an issuing distribution point, are ignored.
+	CRLs []*RevocationList
+
+	// OCSPResponses is a list of parsed OCSP responses to consult. Only
+	// responses signed by the issuer of a certificate, or by a delegated
+	// responder authorized by it, are used.
+	OCSPResponses []*OCSPResponse
+
+	// CurrentTime is used to check that CRLs and OCSP responses are
+	// current. If zero, the current time is used.
+	CurrentTime time.Time
+
+	// RequireStatus makes Check fail with a RevocationStatusUnknown error
+	// if no valid and current CRL or OCSP response covers a certificate,
+	// or if an OCSP responder reported its status as unknown. Otherwise,
+	// such certificates are accepted.
+	RequireStatus bool
+}
+
+// Check returns an error if cert, issued by issuer, has been revoked
+// according to p. The error is a CertificateInvalidError with Reason
+// Revoked or RevocationStatusUnknown.
+func (p *RevocationPolicy) Check(cert, issuer *Certificate) error {
+	now := p.CurrentTime
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	haveStatus := false
+	for _, resp := range p.OCSPResponses {
+		if resp.SerialNumber == nil || resp.SerialNumber.Cmp(cert.SerialNumber) != 0 {
+			continue
+		}
+		if !isCurrent(now, resp.ThisUpdate, resp.NextUpdate) {
+			continue
+		}
+		if !resp.matchesIssuer(issuer) || resp.CheckSignatureFrom(issuer) != nil {
+			continue
+		}
+		if c := resp.Certificate; c != nil && (now.Before(c.NotBefore) || now.After(c.NotAfter)) {
+			continue
+		}
+		switch resp.Status {
+		case OCSPRevoked:
+			return CertificateInvalidError{cert, Revoked, revokedDetail("OCSP", resp.RevokedAt, resp.RevocationReason)}
+		case OCSPGood:
+			haveStatus = true
+		}
+	}
+
+	for _, crl := range p.CRLs {
+		if !bytes.Equal(crl.RawIssuer, issuer.RawSubject) {
+			continue
+		}
+		if !isCurrent(now, crl.ThisUpdate, crl.NextUpdate) {
+			continue
+		}
+		if hasCriticalExtension(crl) {
+			continue
+		}
+		if crl.CheckSignatureFrom(issuer) != nil {
+			continue
+		}
+		for _, entry := range crl.RevokedCertificateEntries {
+			if entry.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+				return CertificateInvalidError{cert, Revoked, revokedDetail("CRL", entry.RevocationTime, entry.ReasonCode)}
+			}
+		}
+		haveStatus = true
+	}
+
+	if !haveStatus && p.RequireStatus {
+		return CertificateInvalidError{cert, RevocationStatusUnknown, ""}
+	}
+	return nil
+}
+
+// isCurrent reports whether now is within the validity window of a CRL or
+// OCSP response. A zero nextUpdate means newer information is always
+// available, so only the thisUpdate bound applies.
+func isCurrent(now, thisUpdate, nextUpdate time.Time) bool {
+	if now.Before(thisUpdate) {
+		return false
+	}
+	return nextUpdate.IsZero() || !now.After(nextUpdate)
+}
+
+// hasCriticalExtension reports whether crl or one of its entries has a
+// critical extension. None are supported, and RFC 5280, Section 5 requires
+// that a CRL with an unrecognized critical extension is not used to
+// determine revocation status. In particular, the delta CRL indicator and
+// the issuing distribution point extensions are critical and would make
+// a CRL incomplete for some of the certificates of its issuer.
+func hasCriticalExtension(crl *RevocationList) bool {
+	for _, ext := range crl.Extensions {
+		if ext.Critical {
+			return true
+		}
+	}
+	for _, entry := range crl.RevokedCertificateEntries {
+		for _, ext := range entry.Extensions {
+			if ext.Critical {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func revokedDetail(source string, at time.Time, reason int) string {
+	return fmt.Sprintf("revoked at %s with reason %d, according to %s", at.UTC().Format(time.RFC3339), reason, source)
+}
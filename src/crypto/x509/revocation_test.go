@@ -0,0 +1,260 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package x509
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+var revocationTestNow = time.Date(2022, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+// generateRevocationTestCert returns a certificate for cn, signed by issuer,
+// or self-signed if issuer is nil. CA certificates can sign CRLs.
+func generateRevocationTestCert(t *testing.T, cn string, isCA bool, serial int64, issuer *Certificate, issuerKey crypto.Signer, eku ...ExtKeyUsage) (*Certificate, crypto.Signer) {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             revocationTestNow.Add(-24 * time.Hour),
+		NotAfter:              revocationTestNow.Add(365 * 24 * time.Hour),
+		KeyUsage:              KeyUsageDigitalSignature,
+		ExtKeyUsage:           eku,
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	if isCA {
+		template.KeyUsage |= KeyUsageCertSign | KeyUsageCRLSign
+	}
+	if issuer == nil {
+		issuer, issuerKey = template, priv
+	}
+	der, err := CreateCertificate(rand.Reader, template, issuer, priv.Public(), issuerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, priv
+}
+
+func createTestRevocationList(t *testing.T, issuer *Certificate, key crypto.Signer, thisUpdate, nextUpdate time.Time, entries ...RevocationListEntry) *RevocationList {
+	t.Helper()
+	der, err := CreateRevocationList(rand.Reader, &RevocationList{
+		Number:                    big.NewInt(1),
+		ThisUpdate:                thisUpdate,
+		NextUpdate:                nextUpdate,
+		RevokedCertificateEntries: entries,
+	}, issuer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crl, err := ParseRevocationList(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crl
+}
+
+func TestParseRevocationList(t *testing.T) {
+	ca, caKey := generateRevocationTestCert(t, "CA", true, 1, nil, nil)
+	otherCA, _ := generateRevocationTestCert(t, "Other CA", true, 1, nil, nil)
+
+	revokedAt := revocationTestNow.Add(-time.Hour)
+	extraExt := pkix.Extension{Id: []int{1, 2, 3}, Value: []byte{5, 0}}
+	der, err := CreateRevocationList(rand.Reader, &RevocationList{
+		Number:     big.NewInt(42),
+		ThisUpdate: revocationTestNow,
+		NextUpdate: revocationTestNow.Add(48 * time.Hour),
+		RevokedCertificateEntries: []RevocationListEntry{
+			{SerialNumber: big.NewInt(10), RevocationTime: revokedAt},
+			{SerialNumber: big.NewInt(11), RevocationTime: revokedAt, ReasonCode: 1,
+				ExtraExtensions: []pkix.Extension{extraExt}},
+		},
+		ExtraExtensions: []pkix.Extension{extraExt},
+	}, ca, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	crl, err := ParseRevocationList(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if crl.Number.Cmp(big.NewInt(42)) != 0 {
+		t.Errorf("Number = %v, want 42", crl.Number)
+	}
+	if !crl.ThisUpdate.Equal(revocationTestNow) || !crl.NextUpdate.Equal(revocationTestNow.Add(48*time.Hour)) {
+		t.Errorf("ThisUpdate, NextUpdate = %v, %v", crl.ThisUpdate, crl.NextUpdate)
+	}
+	if string(crl.RawIssuer) != string(ca.RawSubject) || crl.Issuer.CommonName != "CA" {
+		t.Errorf("Issuer = %v, want CA", crl.Issuer)
+	}
+	if string(crl.AuthorityKeyId) != string(ca.SubjectKeyId) {
+		t.Errorf("AuthorityKeyId = %x, want %x", crl.AuthorityKeyId, ca.SubjectKeyId)
+	}
+	if len(crl.Extensions) != 3 || !crl.Extensions[2].Id.Equal(extraExt.Id) {
+		t.Errorf("Extensions = %v", crl.Extensions)
+	}
+	if len(crl.RevokedCertificateEntries) != 2 || len(crl.RevokedCertificates) != 2 {
+		t.Fatalf("got %d entries, want 2", len(crl.RevokedCertificateEntries))
+	}
+	for i, e := range crl.RevokedCertificateEntries {
+		if e.SerialNumber.Int64() != int64(10+i) || !e.RevocationTime.Equal(revokedAt) || e.ReasonCode != i {
+			t.Errorf("entry %d = %v, %v, %d", i, e.SerialNumber, e.RevocationTime, e.ReasonCode)
+		}
+	}
+	if n := len(crl.RevokedCertificateEntries[1].Extensions); n != 2 {
+		t.Errorf("second entry has %d extensions, want 2", n)
+	}
+
+	if err := crl.CheckSignatureFrom(ca); err != nil {
+		t.Errorf("CheckSignatureFrom(ca) = %v", err)
+	}
+	if err := crl.CheckSignatureFrom(otherCA); err == nil {
+		t.Error("CheckSignatureFrom(otherCA) succeeded")
+	}
+
+	if _, err := ParseRevocationList(append(der, 0)); err == nil {
+		t.Error("ParseRevocationList accepted trailing data")
+	}
+	if _, err := ParseRevocationList(der[:len(der)-1]); err == nil {
+		t.Error("ParseRevocationList accepted truncated CRL")
+	}
+}
+
+func TestVerifyRevocation(t *testing.T) {
+	root, rootKey := generateRevocationTestCert(t, "Root", true, 1, nil, nil)
+	inter, interKey := generateRevocationTestCert(t, "Intermediate", true, 2, root, rootKey)
+	leaf, _ := generateRevocationTestCert(t, "Leaf", false, 3, inter, interKey, ExtKeyUsageServerAuth)
+	responder, responderKey := generateRevocationTestCert(t, "Responder", false, 4, inter, interKey, ExtKeyUsageOCSPSigning)
+
+	roots := NewCertPool()
+	roots.AddCert(root)
+	intermediates := NewCertPool()
+	intermediates.AddCert(inter)
+
+	ocsp := func(status OCSPStatus, thisUpdate time.Time) *OCSPResponse {
+		der, err := CreateOCSPResponse(rand.Reader, &OCSPResponse{
+			Status:       status,
+			SerialNumber: leaf.SerialNumber,
+			ThisUpdate:   thisUpdate,
+			NextUpdate:   thisUpdate.Add(24 * time.Hour),
+			RevokedAt:    thisUpdate,
+		}, inter, responder, responderKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := ParseOCSPResponse(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	revokeLeaf := RevocationListEntry{SerialNumber: leaf.SerialNumber, RevocationTime: revocationTestNow}
+	crlWithExtension := func(ext pkix.Extension) *RevocationList {
+		der, err := CreateRevocationList(rand.Reader, &RevocationList{
+			Number:          big.NewInt(2),
+			ThisUpdate:      revocationTestNow,
+			NextUpdate:      revocationTestNow.Add(time.Hour),
+			ExtraExtensions: []pkix.Extension{ext},
+		}, inter, interKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		crl, err := ParseRevocationList(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return crl
+	}
+	// A delta CRL, based on CRL number 1, only lists changes since then.
+	deltaCRL := crlWithExtension(pkix.Extension{
+		Id:       asn1.ObjectIdentifier{2, 5, 29, 27},
+		Critical: true,
+		Value:    []byte{0x02, 0x01, 0x01},
+	})
+	// An issuing distribution point with onlyContainsCACerts set
+	// limits the scope of a CRL to CA certificates.
+	caOnlyCRL := crlWithExtension(pkix.Extension{
+		Id:       asn1.ObjectIdentifier{2, 5, 29, 28},
+		Critical: true,
+		Value:    []byte{0x30, 0x03, 0x82, 0x01, 0xff},
+	})
+
+	tests := []struct {
+		name   string
+		policy RevocationPolicy
+		reason InvalidReason // -1 means success
+	}{
+		{"no information", RevocationPolicy{}, -1},
+		{"no information required", RevocationPolicy{RequireStatus: true}, RevocationStatusUnknown},
+		{"good CRLs", RevocationPolicy{RequireStatus: true, CRLs: []*RevocationList{
+			createTestRevocationList(t, root, rootKey, revocationTestNow, revocationTestNow.Add(time.Hour)),
+			createTestRevocationList(t, inter, interKey, revocationTestNow, revocationTestNow.Add(time.Hour)),
+		}}, -1},
+		{"revoked by CRL", RevocationPolicy{CRLs: []*RevocationList{
+			createTestRevocationList(t, inter, interKey, revocationTestNow, revocationTestNow.Add(time.Hour), revokeLeaf),
+		}}, Revoked},
+		{"intermediate revoked by CRL", RevocationPolicy{CRLs: []*RevocationList{
+			createTestRevocationList(t, root, rootKey, revocationTestNow, revocationTestNow.Add(time.Hour),
+				RevocationListEntry{SerialNumber: inter.SerialNumber, RevocationTime: revocationTestNow}),
+		}}, Revoked},
+		{"expired CRL", RevocationPolicy{CRLs: []*RevocationList{
+			createTestRevocationList(t, inter, interKey, revocationTestNow.Add(-2*time.Hour), revocationTestNow.Add(-time.Hour), revokeLeaf),
+		}}, -1},
+		{"CRL from wrong issuer", RevocationPolicy{CRLs: []*RevocationList{
+			createTestRevocationList(t, root, rootKey, revocationTestNow, revocationTestNow.Add(time.Hour), revokeLeaf),
+		}}, -1},
+		{"delta CRL required", RevocationPolicy{RequireStatus: true, CRLs: []*RevocationList{
+			createTestRevocationList(t, root, rootKey, revocationTestNow, revocationTestNow.Add(time.Hour)),
+			deltaCRL,
+		}}, RevocationStatusUnknown},
+		{"CRL for CA certificates only required", RevocationPolicy{RequireStatus: true, CRLs: []*RevocationList{
+			createTestRevocationList(t, root, rootKey, revocationTestNow, revocationTestNow.Add(time.Hour)),
+			caOnlyCRL,
+		}}, RevocationStatusUnknown},
+		{"good OCSP", RevocationPolicy{OCSPResponses: []*OCSPResponse{ocsp(OCSPGood, revocationTestNow)}}, -1},
+		{"revoked by OCSP", RevocationPolicy{OCSPResponses: []*OCSPResponse{ocsp(OCSPRevoked, revocationTestNow)}}, Revoked},
+		{"stale OCSP", RevocationPolicy{OCSPResponses: []*OCSPResponse{ocsp(OCSPRevoked, revocationTestNow.Add(-48*time.Hour))}}, -1},
+		{"unknown OCSP required", RevocationPolicy{RequireStatus: true, OCSPResponses: []*OCSPResponse{ocsp(OCSPUnknown, revocationTestNow)}}, RevocationStatusUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := tt.policy
+			policy.CurrentTime = revocationTestNow
+			_, err := leaf.Verify(VerifyOptions{
+				Roots:           roots,
+				Intermediates:   intermediates,
+				CurrentTime:     revocationTestNow,
+				CheckRevocation: policy.Check,
+			})
+			if tt.reason == -1 {
+				if err != nil {
+					t.Fatalf("Verify failed: %v", err)
+				}
+				return
+			}
+			var invalidErr CertificateInvalidError
+			if !errors.As(err, &invalidErr) || invalidErr.Reason != tt.reason {
+				t.Fatalf("Verify error = %v, want reason %d", err, tt.reason)
+			}
+		})
+	}
+}
@@ -54,6 +54,13 @@ const (
 	// CANotAuthorizedForExtKeyUsage results when an intermediate or root
 	// certificate does not permit a requested extended key usage.
 	CANotAuthorizedForExtKeyUsage
+	// Revoked results when a certificate has been revoked by its issuer,
+	// according to a RevocationPolicy.
+	Revoked
+	// RevocationStatusUnknown results when a RevocationPolicy that requires
+	// revocation information has no valid and current information for a
+	// certificate.
+	RevocationStatusUnknown
 )
 
 // CertificateInvalidError results when an odd error occurs. Users of this
@@ -84,6 +91,10 @@ func (e CertificateInvalidError) Error() string {
 		return "x509: issuer has name constraints but leaf doesn't have a SAN extension"
 	case UnconstrainedName:
 		return "x509: issuer has name constraints but leaf contains unknown or unconstrained name: " + e.Detail
+	case Revoked:
+		return "x509: certificate has been revoked: " + e.Detail
+	case RevocationStatusUnknown:
+		return "x509: certificate revocation status is unknown"
 	}
 	return "x509: unknown error"
 }
@@ -199,6 +210,17 @@ type VerifyOptions struct {
 	// certificates from consuming excessive amounts of CPU time when
 	// validating. It does not apply to the platform verifier.
 	MaxConstraintComparisions int
+
+	// CheckRevocation, if not nil, is called for each certificate in the
+	// chains that would otherwise be returned, except the root, along with
+	// the certificate that issued it. If it returns an error, the chains
+	// containing that certificate are discarded, and if no chains remain,
+	// Verify returns the first such error. It also applies to chains built
+	// by the platform verifier.
+	//
+	// RevocationPolicy.Check implements a policy based on CRLs and OCSP
+	// responses, such as those stapled to TLS handshakes.
+	CheckRevocation func(cert, issuer *Certificate) error
 }
 
 const (
@@ -724,8 +746,16 @@ func (c *Certificate) isValid(certType int, currentChain []*Certificate, opts *V
 // list. (While this is not specified, it is common practice in order to limit
 // the types of certificates a CA can issue.)
 //
-// WARNING: this function doesn't do any revocation checking.
+// Revocation checking is only performed if opts.CheckRevocation is set.
 func (c *Certificate) Verify(opts VerifyOptions) (chains [][]*Certificate, err error) {
+	chains, err = c.verify(opts)
+	if err != nil || opts.CheckRevocation == nil {
+		return chains, err
+	}
+	return checkChainsForRevocation(chains, opts.CheckRevocation)
+}
+
+func (c *Certificate) verify(opts VerifyOptions) (chains [][]*Certificate, err error) {
 	// Platform-specific verification needs the ASN.1 contents so
 	// this makes the behavior consistent across platforms.
 	if len(c.Raw) == 0 {
@@ -1056,6 +1086,39 @@ func (c *Certificate) VerifyHostname(h string) error {
 	return HostnameError{c, h}
 }
 
+// checkChainsForRevocation returns the chains in which check accepts all
+// certificates, or the first error returned by check if there are none.
+func checkChainsForRevocation(chains [][]*Certificate, check func(cert, issuer *Certificate) error) ([][]*Certificate, error) {
+	// Chains often share certificates, so remember the results.
+	type certPair struct{ cert, issuer *Certificate }
+	results := make(map[certPair]error)
+
+	var valid [][]*Certificate
+	var firstErr error
+NextChain:
+	for _, chain := range chains {
+		for i := 0; i < len(chain)-1; i++ {
+			pair := certPair{chain[i], chain[i+1]}
+			err, ok := results[pair]
+			if !ok {
+				err = check(pair.cert, pair.issuer)
+				results[pair] = err
+			}
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue NextChain
+			}
+		}
+		valid = append(valid, chain)
+	}
+	if len(valid) == 0 {
+		return nil, firstErr
+	}
+	return valid, nil
+}
+
 func checkChainForKeyUsage(chain []*Certificate, keyUsages []ExtKeyUsage) bool {
 	usages := make([]ExtKeyUsage, len(keyUsages))
 	copy(usages, keyUsages)
@@ -0,0 +1,188 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sql
+
+import (
+	"context"
+	"time"
+)
+
+// Hooks is a set of callbacks that a DB runs as it manages its pool
+// of connections and executes operations on them. Any particular hook
+// may be nil. Hooks are installed with DB.SetHooks.
+//
+// Each hook receives the context of the operation that triggered it.
+// Hooks for events that are not tied to a caller, such as connections
+// opened in the background or closed by the pool, receive the context
+// passed to the driver or context.Background.
+//
+// Hooks may be called concurrently from multiple goroutines, and some
+// are called while a connection is locked. Hooks must therefore return
+// quickly and must not call methods on the DB or on any Conn, Tx, Stmt
+// or Rows obtained from it.
+type Hooks struct {
+	// ConnOpen is called after the DB attempts to open a new
+	// connection using its driver.
+	ConnOpen func(ctx context.Context, info HookDoneInfo)
+
+	// ConnClose is called after a connection has been closed,
+	// with the error, if any, returned by the driver.
+	ConnClose func(ctx context.Context, err error)
+
+	// ConnReuse is called when an operation is given a connection
+	// from the pool instead of opening a new one. idle is the time
+	// since the connection was opened or last returned to the pool.
+	ConnReuse func(ctx context.Context, idle time.Duration)
+
+	// WaitStart is called when an operation must wait for a
+	// connection because the limit set by SetMaxOpenConns
+	// has been reached.
+	WaitStart func(ctx context.Context)
+
+	// WaitDone is called when the wait that started with WaitStart
+	// ends, either because a connection became available or
+	// because of an error such as the context being canceled.
+	WaitDone func(ctx context.Context, info HookDoneInfo)
+
+	// PrepareStart and PrepareDone are called before and after
+	// a Stmt is prepared on a connection. Statements prepared
+	// implicitly to run a single query or exec are reported only
+	// by the query and exec hooks.
+	PrepareStart func(ctx context.Context, query string)
+	PrepareDone  func(ctx context.Context, query string, info HookDoneInfo)
+
+	// QueryStart and QueryDone are called before and after a query
+	// is executed. QueryDone is called once the driver has returned
+	// the result set, not when the Rows are closed.
+	QueryStart func(ctx context.Context, query string, args []any)
+	QueryDone  func(ctx context.Context, query string, args []any, info HookDoneInfo)
+
+	// ExecStart and ExecDone are called before and after a
+	// statement that does not return rows is executed.
+	ExecStart func(ctx context.Context, query string, args []any)
+	ExecDone  func(ctx context.Context, query string, args []any, info HookDoneInfo)
+
+	// TxBegin is called after the DB attempts to begin a transaction.
+	TxBegin func(ctx context.Context, opts *TxOptions, info HookDoneInfo)
+
+	// TxCommit and TxRollback are called after a transaction is
+	// committed or rolled back. The context is the one passed to
+	// BeginTx, and may have been canceled.
+	TxCommit   func(ctx context.Context, info HookDoneInfo)
+	TxRollback func(ctx context.Context, info HookDoneInfo)
+}
+
+// HookDoneInfo describes a completed operation reported to Hooks.
+type HookDoneInfo struct {
+	// Duration is how long the operation took.
+	Duration time.Duration
+
+	// Err is the error, if any, that the operation returned.
+	Err error
+}
+
+// SetHooks sets the hooks that db runs on connection, statement and
+// transaction events. Passing nil removes any hooks.
+//
+// Operations in progress when SetHooks is called may run either the
+// old or the new hooks.
+func (db *DB) SetHooks(h *Hooks) {
+	db.hooks.Store(h)
+}
+
+// loadHooks returns db's hooks, or nil if there are none.
+// The methods of Hooks below may be called on a nil *Hooks.
+func (db *DB) loadHooks() *Hooks {
+	h, _ := db.hooks.Load().(*Hooks)
+	return h
+}
+
+func doneInfo(start time.Time, err error) HookDoneInfo {
+	return HookDoneInfo{Duration: time.Since(start), Err: err}
+}
+
+func (h *Hooks) connOpen(ctx context.Context, start time.Time, err error) {
+	if h != nil && h.ConnOpen != nil {
+		h.ConnOpen(ctx, doneInfo(start, err))
+	}
+}
+
+func (h *Hooks) connClose(err error) {
+	if h != nil && h.ConnClose != nil {
+		h.ConnClose(context.Background(), err)
+	}
+}
+
+func (h *Hooks) connReuse(ctx context.Context, returnedAt time.Time) {
+	if h != nil && h.ConnReuse != nil {
+		h.ConnReuse(ctx, nowFunc().Sub(returnedAt))
+	}
+}
+
+func (h *Hooks) waitStart(ctx context.Context) {
+	if h != nil && h.WaitStart != nil {
+		h.WaitStart(ctx)
+	}
+}
+
+func (h *Hooks) waitDone(ctx context.Context, start time.Time, err error) {
+	if h != nil && h.WaitDone != nil {
+		h.WaitDone(ctx, doneInfo(start, err))
+	}
+}
+
+func (h *Hooks) prepareStart(ctx context.Context, query string) {
+	if h != nil && h.PrepareStart != nil {
+		h.PrepareStart(ctx, query)
+	}
+}
+
+func (h *Hooks) prepareDone(ctx context.Context, query string, start time.Time, err error) {
+	if h != nil && h.PrepareDone != nil {
+		h.PrepareDone(ctx, query, doneInfo(start, err))
+	}
+}
+
+func (h *Hooks) queryStart(ctx context.Context, query string, args []any) {
+	if h != nil && h.QueryStart != nil {
+		h.QueryStart(ctx, query, args)
+	}
+}
+
+func (h *Hooks) queryDone(ctx context.Context, query string, args []any, start time.Time, err error) {
+	if h != nil && h.QueryDone != nil {
+		h.QueryDone(ctx, query, args, doneInfo(start, err))
+	}
+}
+
+func (h *Hooks) execStart(ctx context.Context, query string, args []any) {
+	if h != nil && h.ExecStart != nil {
+		h.ExecStart(ctx, query, args)
+	}
+}
+
+func (h *Hooks) execDone(ctx context.Context, query string, args []any, start time.Time, err error) {
+	if h != nil && h.ExecDone != nil {
+		h.ExecDone(ctx, query, args, doneInfo(start, err))
+	}
+}
+
+func (h *Hooks) txBegin(ctx context.Context, opts *TxOptions, start time.Time, err error) {
+	if h != nil && h.TxBegin != nil {
+		h.TxBegin(ctx, opts, doneInfo(start, err))
+	}
+}
+
+func (h *Hooks) txCommit(ctx context.Context, start time.Time, err error) {
+	if h != nil && h.TxCommit != nil {
+		h.TxCommit(ctx, doneInfo(start, err))
+	}
+}
+
+func (h *Hooks) txRollback(ctx context.Context, start time.Time, err error) {
+	if h != nil && h.TxRollback != nil {
+		h.TxRollback(ctx, doneInfo(start, err))
+	}
+}
@@ -0,0 +1,267 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+type hookCtxKey struct{}
+
+// hookRecorder records the events reported to its Hooks.
+type hookRecorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *hookRecorder) add(ctx context.Context, format string, args ...any) {
+	ev := fmt.Sprintf(format, args...)
+	if v, _ := ctx.Value(hookCtxKey{}).(string); v != "" {
+		ev += " @" + v
+	}
+	r.mu.Lock()
+	r.events = append(r.events, ev)
+	r.mu.Unlock()
+}
+
+func errStr(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
+
+func (r *hookRecorder) hooks() *Hooks {
+	return &Hooks{
+		ConnOpen: func(ctx context.Context, info HookDoneInfo) {
+			r.add(ctx, "ConnOpen %v", errStr(info.Err))
+		},
+		ConnClose: func(ctx context.Context, err error) {
+			r.add(ctx, "ConnClose %v", errStr(err))
+		},
+		ConnReuse: func(ctx context.Context, idle time.Duration) {
+			if idle < 0 {
+				panic("negative idle time")
+			}
+			r.add(ctx, "ConnReuse")
+		},
+		WaitStart: func(ctx context.Context) {
+			r.add(ctx, "WaitStart")
+		},
+		WaitDone: func(ctx context.Context, info HookDoneInfo) {
+			r.add(ctx, "WaitDone %v", errStr(info.Err))
+		},
+		PrepareStart: func(ctx context.Context, query string) {
+			r.add(ctx, "PrepareStart %v", query)
+		},
+		PrepareDone: func(ctx context.Context, query string, info HookDoneInfo) {
+			r.add(ctx, "PrepareDone %v %v", query, errStr(info.Err))
+		},
+		QueryStart: func(ctx context.Context, query string, args []any) {
+			r.add(ctx, "QueryStart %v %v", query, args)
+		},
+		QueryDone: func(ctx context.Context, query string, args []any, info HookDoneInfo) {
+			r.add(ctx, "QueryDone %v %v %v", query, args, errStr(info.Err))
+		},
+		ExecStart: func(ctx context.Context, query string, args []any) {
+			r.add(ctx, "ExecStart %v %v", query, args)
+		},
+		ExecDone: func(ctx context.Context, query string, args []any, info HookDoneInfo) {
+			r.add(ctx, "ExecDone %v %v %v", query, args, errStr(info.Err))
+		},
+		TxBegin: func(ctx context.Context, opts *TxOptions, info HookDoneInfo) {
+			r.add(ctx, "TxBegin %v", errStr(info.Err))
+		},
+		TxCommit: func(ctx context.Context, info HookDoneInfo) {
+			r.add(ctx, "TxCommit %v", errStr(info.Err))
+		},
+		TxRollback: func(ctx context.Context, info HookDoneInfo) {
+			r.add(ctx, "TxRollback %v", errStr(info.Err))
+		},
+	}
+}
+
+// take returns and clears the recorded events.
+func (r *hookRecorder) take() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ev := r.events
+	r.events = nil
+	return ev
+}
+
+func (r *hookRecorder) check(t *testing.T, want ...string) {
+	t.Helper()
+	if got := r.take(); !reflect.DeepEqual(got, want) {
+		t.Errorf("hook events:\n\t%q\nwant:\n\t%q", got, want)
+	}
+}
+
+func TestHooks(t *testing.T) {
+	db := newTestDB(t, "people")
+	defer closeDB(t, db)
+	db.SetMaxIdleConns(1)
+
+	var rec hookRecorder
+	db.SetHooks(rec.hooks())
+	ctx := context.WithValue(context.Background(), hookCtxKey{}, "ctx")
+
+	// The connection used to set up the table is reused.
+	if _, err := db.ExecContext(ctx, "INSERT|people|name=Dave,age=?", 4); err != nil {
+		t.Fatal(err)
+	}
+	rec.check(t,
+		"ConnReuse @ctx",
+		"ExecStart INSERT|people|name=Dave,age=? [4] @ctx",
+		"ExecDone INSERT|people|name=Dave,age=? [4] ok @ctx",
+	)
+
+	rows, err := db.QueryContext(ctx, "SELECT|people|name|age=?", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+	rec.check(t,
+		"ConnReuse @ctx",
+		"QueryStart SELECT|people|name|age=? [4] @ctx",
+		"QueryDone SELECT|people|name|age=? [4] ok @ctx",
+	)
+
+	if _, err := db.ExecContext(ctx, "INSERT|nosuchtable|name=Eve"); err == nil {
+		t.Fatal("insert into missing table succeeded")
+	}
+	rec.check(t,
+		"ConnReuse @ctx",
+		"ExecStart INSERT|nosuchtable|name=Eve [] @ctx",
+		"ExecDone INSERT|nosuchtable|name=Eve [] error @ctx",
+	)
+
+	stmt, err := db.PrepareContext(ctx, "SELECT|people|name|age=?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.check(t,
+		"ConnReuse @ctx",
+		"PrepareStart SELECT|people|name|age=? @ctx",
+		"PrepareDone SELECT|people|name|age=? ok @ctx",
+	)
+	var name string
+	if err := stmt.QueryRowContext(ctx, 1).Scan(&name); err != nil || name != "Alice" {
+		t.Fatalf("QueryRow = %q, %v; want Alice", name, err)
+	}
+	stmt.Close()
+	rec.check(t,
+		"ConnReuse @ctx",
+		"QueryStart SELECT|people|name|age=? [1] @ctx",
+		"QueryDone SELECT|people|name|age=? [1] ok @ctx",
+	)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT|people|name=Frank,age=?", 6); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	rec.check(t,
+		"ConnReuse @ctx",
+		"TxBegin ok @ctx",
+		"ExecStart INSERT|people|name=Frank,age=? [6] @ctx",
+		"ExecDone INSERT|people|name=Frank,age=? [6] ok @ctx",
+		"TxCommit ok @ctx",
+	)
+
+	tx, err = db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	rec.check(t,
+		"ConnReuse @ctx",
+		"TxBegin ok @ctx",
+		"TxRollback ok @ctx",
+	)
+
+	// Holding one connection forces the next operation to open another,
+	// which is closed when it exceeds the idle limit.
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.check(t, "ConnReuse @ctx")
+	if _, err := db.ExecContext(ctx, "WIPE"); err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	rec.check(t,
+		"ConnOpen ok @ctx",
+		"ExecStart WIPE [] @ctx",
+		"ExecDone WIPE [] ok @ctx",
+		"ConnClose ok",
+	)
+
+	db.SetHooks(nil)
+	exec(t, db, "WIPE")
+	rec.check(t)
+}
+
+func TestHooksWait(t *testing.T) {
+	db := newTestDB(t, "people")
+	defer closeDB(t, db)
+	db.SetMaxOpenConns(1)
+
+	var rec hookRecorder
+	db.SetHooks(rec.hooks())
+	ctx := context.WithValue(context.Background(), hookCtxKey{}, "ctx")
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.check(t, "ConnReuse @ctx")
+
+	// The pool is exhausted, so this waits until the context expires.
+	waitCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := db.Conn(waitCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Conn with exhausted pool = %v, want DeadlineExceeded", err)
+	}
+	rec.check(t, "WaitStart @ctx", "WaitDone error @ctx")
+
+	// This waits until the held connection is returned.
+	done := make(chan error, 1)
+	go func() {
+		c, err := db.Conn(ctx)
+		if err == nil {
+			c.Close()
+		}
+		done <- err
+	}()
+	for {
+		db.mu.Lock()
+		n := len(db.connRequests)
+		db.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	conn.Close()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	rec.check(t, "WaitStart @ctx", "WaitDone ok @ctx", "ConnReuse @ctx")
+}
@@ -0,0 +1,217 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sql
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// ScanStruct copies the columns in the current row into the fields of
+// the struct pointed at by dest. Each column is matched to a field by
+// name, so the order of the columns does not matter.
+//
+// By default a column matches the exported field with the same name,
+// compared case-insensitively. A field's name can be overridden with
+// a "sql" key in the field's tag, which is matched exactly before
+// falling back to a case-insensitive match:
+//
+//	type Person struct {
+//		ID   int64  `sql:"person_id"`
+//		Name string // matches the column "name"
+//		Note string `sql:"-"` // never scanned
+//	}
+//
+// The fields of an embedded struct without a tag are treated as if they
+// were in the outer struct. If several fields at the same depth of
+// embedding have the same name, none of them matches. Likewise, a
+// case-insensitive match is made at the shallowest depth with a
+// matching name, and fails if several names at that depth match.
+// Every column must match a field; fields without a matching column
+// are left unchanged.
+//
+// Values are converted to the field types as by Scan, so fields may be
+// of any type that Scan accepts as a destination, including types
+// implementing Scanner such as Null.
+func (rs *Rows) ScanStruct(dest any) error {
+	dests, err := rs.structDests(dest)
+	if err != nil {
+		return err
+	}
+	return rs.Scan(dests...)
+}
+
+// ScanStruct copies the columns from the matched row into the fields
+// of the struct pointed at by dest. See the documentation on
+// Rows.ScanStruct for details. If more than one row matches the query,
+// ScanStruct uses the first row and discards the rest. If no row
+// matches the query, ScanStruct returns ErrNoRows.
+func (r *Row) ScanStruct(dest any) error {
+	if r.err != nil {
+		return r.err
+	}
+	defer r.rows.Close()
+	if !r.rows.Next() {
+		if err := r.rows.Err(); err != nil {
+			return err
+		}
+		return ErrNoRows
+	}
+	dests, err := r.rows.structDests(dest)
+	if err != nil {
+		return err
+	}
+	// See Row.Scan for why RawBytes are not allowed.
+	for _, dp := range dests {
+		if _, ok := dp.(*RawBytes); ok {
+			return errors.New("sql: RawBytes isn't allowed on Row.ScanStruct")
+		}
+	}
+	if err := r.rows.Scan(dests...); err != nil {
+		return err
+	}
+	// Make sure the query can be processed to completion with no errors.
+	return r.rows.Close()
+}
+
+// structDests returns pointers to the fields of the struct pointed at
+// by dest for each of rs's columns, in column order.
+func (rs *Rows) structDests(dest any) ([]any, error) {
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("sql: ScanStruct destination must be a non-nil pointer to a struct, not %T", dest)
+	}
+	cols, err := rs.Columns()
+	if err != nil {
+		return nil, err
+	}
+	sv := rv.Elem()
+	fields := cachedStructFields(sv.Type())
+	dests := make([]any, len(cols))
+	for i, col := range cols {
+		index, ok := fields.exact[col]
+		if !ok {
+			index, ok = fields.folded[strings.ToLower(col)]
+		}
+		if !ok || index == nil {
+			return nil, fmt.Errorf("sql: no field in %v matches column %q", sv.Type(), col)
+		}
+		dests[i] = sv.FieldByIndex(index).Addr().Interface()
+	}
+	return dests, nil
+}
+
+// structFields maps column names to the index sequences of the
+// struct fields they are scanned into.
+type structFields struct {
+	exact  map[string][]int
+	folded map[string][]int // keyed by lower-cased name; nil if ambiguous
+}
+
+var structFieldsCache sync.Map // map[reflect.Type]*structFields
+
+func cachedStructFields(t reflect.Type) *structFields {
+	if f, ok := structFieldsCache.Load(t); ok {
+		return f.(*structFields)
+	}
+	f, _ := structFieldsCache.LoadOrStore(t, typeStructFields(t))
+	return f.(*structFields)
+}
+
+// typeStructFields returns the fields of t that columns may be scanned into.
+func typeStructFields(t reflect.Type) *structFields {
+	type field struct {
+		name  string
+		index []int
+		typ   reflect.Type
+	}
+	sf := &structFields{
+		exact:  make(map[string][]int),
+		folded: make(map[string][]int),
+	}
+
+	// Walk the struct breadth-first, so that names found at a shallower
+	// depth hide those of more deeply embedded fields.
+	hidden := map[string]bool{}
+	current := []field{}
+	next := []field{{typ: t}}
+
+	// Number of times each struct type is reached at the current depth
+	// and the next. The fields of a type reached more than once at the
+	// same depth are ambiguous.
+	var count map[reflect.Type]int
+	nextCount := map[reflect.Type]int{t: 1}
+
+	visited := map[reflect.Type]bool{}
+	for len(next) > 0 {
+		current, next = next, current[:0]
+		count, nextCount = nextCount, map[reflect.Type]int{}
+		seen := map[string]int{} // names found at this depth
+		var found []field
+		for _, f := range current {
+			if visited[f.typ] {
+				continue
+			}
+			visited[f.typ] = true
+			for i := 0; i < f.typ.NumField(); i++ {
+				sf := f.typ.Field(i)
+				tag := sf.Tag.Get("sql")
+				if tag == "-" {
+					continue
+				}
+				index := make([]int, len(f.index)+1)
+				copy(index, f.index)
+				index[len(f.index)] = i
+				if sf.Anonymous && tag == "" && sf.Type.Kind() == reflect.Struct {
+					if nextCount[sf.Type] == 0 {
+						next = append(next, field{index: index, typ: sf.Type})
+					}
+					nextCount[sf.Type] += count[f.typ]
+					continue
+				}
+				if !sf.IsExported() {
+					continue
+				}
+				name := tag
+				if name == "" {
+					name = sf.Name
+				}
+				seen[name] += count[f.typ]
+				found = append(found, field{name: name, index: index})
+			}
+		}
+		for _, f := range found {
+			if hidden[f.name] || seen[f.name] != 1 {
+				continue
+			}
+			sf.exact[f.name] = f.index
+		}
+		// A case-insensitive match is made at the shallowest depth
+		// with a matching name, and is ambiguous if several names
+		// at that depth match.
+		folded := map[string][]int{}
+		for _, f := range found {
+			lower := strings.ToLower(f.name)
+			if _, ok := sf.folded[lower]; ok {
+				continue
+			}
+			if _, ok := folded[lower]; ok || seen[f.name] != 1 {
+				folded[lower] = nil
+			} else {
+				folded[lower] = f.index
+			}
+		}
+		for lower, index := range folded {
+			sf.folded[lower] = index
+		}
+		for name := range seen {
+			hidden[name] = true
+		}
+	}
+	return sf
+}
@@ -0,0 +1,191 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sql
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type scanPerson struct {
+	Name    string
+	Years   int    `sql:"age"`
+	Photo   []byte `sql:"photo"`
+	Ignored string `sql:"-"`
+	unused  int
+}
+
+func TestRowsScanStruct(t *testing.T) {
+	db := newTestDB(t, "people")
+	defer closeDB(t, db)
+
+	rows, err := db.Query("SELECT|people|age,name,photo|")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []scanPerson
+	for rows.Next() {
+		p := scanPerson{Ignored: "keep"}
+		if err := rows.ScanStruct(&p); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, p)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	want := []scanPerson{
+		{Name: "Alice", Years: 1, Photo: []byte("APHOTO"), Ignored: "keep"},
+		{Name: "Bob", Years: 2, Photo: []byte("BPHOTO"), Ignored: "keep"},
+		{Name: "Chris", Years: 3, Photo: []byte("CPHOTO"), Ignored: "keep"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ScanStruct:\n got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestRowScanStruct(t *testing.T) {
+	db := newTestDB(t, "people")
+	defer closeDB(t, db)
+
+	type base struct {
+		Name string
+		Age  int64
+	}
+	type person struct {
+		base
+		Age   Null[int32] // hides base.Age
+		BDate Null[time.Time]
+	}
+	var p person
+	if err := db.QueryRow("SELECT|people|name,age,bdate|age=?", 3).ScanStruct(&p); err != nil {
+		t.Fatal(err)
+	}
+	want := person{base{Name: "Chris"}, Null[int32]{3, true}, Null[time.Time]{chrisBirthday, true}}
+	if !reflect.DeepEqual(p, want) {
+		t.Errorf("ScanStruct = %+v, want %+v", p, want)
+	}
+
+	p = person{}
+	if err := db.QueryRow("SELECT|people|name,bdate|age=?", 1).ScanStruct(&p); err != nil {
+		t.Fatal(err)
+	}
+	if want := (person{base: base{Name: "Alice"}}); !reflect.DeepEqual(p, want) {
+		t.Errorf("ScanStruct = %+v, want %+v", p, want)
+	}
+
+	if err := db.QueryRow("SELECT|people|name|age=?", 99).ScanStruct(&p); err != ErrNoRows {
+		t.Errorf("ScanStruct with no rows = %v, want ErrNoRows", err)
+	}
+}
+
+func TestScanStructErrors(t *testing.T) {
+	db := newTestDB(t, "people")
+	defer closeDB(t, db)
+
+	type ambiguous struct {
+		Name1 string `sql:"name"`
+		Name2 string `sql:"NAME"`
+	}
+	type a struct{ Name string }
+	type b struct{ Name string }
+	type embedded struct {
+		a
+		b
+	}
+	var (
+		p   scanPerson
+		s   string
+		amb ambiguous
+		emb embedded
+		raw struct{ Name RawBytes }
+	)
+	tests := []struct {
+		query string
+		dest  any
+		want  string
+	}{
+		{"SELECT|people|name|", p, "must be a non-nil pointer to a struct"},
+		{"SELECT|people|name|", (*scanPerson)(nil), "must be a non-nil pointer to a struct"},
+		{"SELECT|people|name|", &s, "must be a non-nil pointer to a struct"},
+		{"SELECT|people|name,dead|", &p, `no field in sql.scanPerson matches column "dead"`},
+		{"SELECT|people|age|", &amb, `no field in sql.ambiguous matches column "age"`},
+		{"SELECT|people|name|", &emb, `no field in sql.embedded matches column "name"`},
+		{"SELECT|people|name|", &raw, "RawBytes isn't allowed"},
+	}
+	for _, tt := range tests {
+		err := db.QueryRow(tt.query).ScanStruct(tt.dest)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ScanStruct(%T) for %q = %v, want error containing %q", tt.dest, tt.query, err, tt.want)
+		}
+	}
+
+	// An exact tag match is preferred to case-insensitive ones.
+	if err := db.QueryRow("SELECT|people|name|").ScanStruct(&amb); err != nil {
+		t.Fatal(err)
+	}
+	if amb.Name1 == "" || amb.Name2 != "" {
+		t.Errorf("ScanStruct = %+v, want only Name1 set", amb)
+	}
+}
+
+type scanD struct{ X, Y int }
+type scanE struct{ Z int }
+type scanF struct {
+	scanD
+	scanE
+}
+type scanB struct{ scanF }
+type scanC struct{ scanF }
+
+func TestTypeStructFields(t *testing.T) {
+	type inner struct {
+		Name string
+		Note string `sql:"NOTE"`
+	}
+	tests := []struct {
+		v      any
+		exact  map[string][]int
+		folded map[string][]int
+	}{{
+		// A type embedded twice at the same depth makes its fields
+		// ambiguous, however deeply they are embedded in it.
+		v: struct {
+			scanB
+			scanC
+			Y int
+		}{},
+		exact:  map[string][]int{"Y": {2}},
+		folded: map[string][]int{"y": {2}, "x": nil, "z": nil},
+	}, {
+		// A case-insensitive match prefers the shallowest field.
+		v: struct {
+			NAME string
+			inner
+		}{},
+		exact:  map[string][]int{"NAME": {0}, "Name": {1, 0}, "NOTE": {1, 1}},
+		folded: map[string][]int{"name": {0}, "note": {1, 1}},
+	}, {
+		v: struct {
+			Name string
+			NAME string
+		}{},
+		exact:  map[string][]int{"Name": {0}, "NAME": {1}},
+		folded: map[string][]int{"name": nil},
+	}}
+	for _, tt := range tests {
+		typ := reflect.TypeOf(tt.v)
+		got := typeStructFields(typ)
+		if !reflect.DeepEqual(got.exact, tt.exact) {
+			t.Errorf("%v: exact = %v, want %v", typ, got.exact, tt.exact)
+		}
+		if !reflect.DeepEqual(got.folded, tt.folded) {
+			t.Errorf("%v: folded = %v, want %v", typ, got.folded, tt.folded)
+		}
+	}
+}
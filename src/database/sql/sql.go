@@ -392,6 +392,55 @@ func (n NullTime) Value() (driver.Value, error) {
 	return n.Time, nil
 }
 
+// Null represents a value that may be null.
+// Null implements the Scanner interface so
+// it can be used as a scan destination:
+//
+//	var s Null[string]
+//	err := db.QueryRow("SELECT name FROM foo WHERE id=?", id).Scan(&s)
+//	...
+//	if s.Valid {
+//	   // use s.V
+//	} else {
+//	   // NULL value
+//	}
+//
+// Scan converts the column value to T using the same rules as Rows.Scan.
+type Null[T any] struct {
+	V     T
+	Valid bool // Valid is true if V is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (n *Null[T]) Scan(value any) error {
+	if value == nil {
+		n.V, n.Valid = *new(T), false
+		return nil
+	}
+	n.Valid = true
+	return convertAssign(&n.V, value)
+}
+
+// Value implements the driver Valuer interface.
+func (n Null[T]) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	v := any(n.V)
+	// T may itself be a Valuer, such as a NullString or a type
+	// defined by the driver.
+	if vr, ok := v.(driver.Valuer); ok {
+		val, err := callValuerValue(vr)
+		if err != nil {
+			return val, err
+		}
+		v = val
+	}
+	// Convert values such as int32 or named string types, which are
+	// not themselves valid driver.Values.
+	return driver.DefaultParameterConverter.ConvertValue(v)
+}
+
 // Scanner is an interface used by Scan.
 type Scanner interface {
 	// Scan assigns a value from a database driver.
@@ -488,6 +537,8 @@ type DB struct {
 	maxIdleTimeClosed int64 // Total number of connections closed due to idle time.
 	maxLifetimeClosed int64 // Total number of connections closed due to max connection lifetime limit.
 
+	hooks atomic.Value // of *Hooks; set by SetHooks
+
 	stop func() // stop cancels the connection opener.
 }
 
@@ -575,7 +626,11 @@ func (dc *driverConn) validateConnection(needsReset bool) bool {
 // prepareLocked prepares the query on dc. When cg == nil the dc must keep track of
 // the prepared statements in a pool.
 func (dc *driverConn) prepareLocked(ctx context.Context, cg stmtConnGrabber, query string) (*driverStmt, error) {
+	h := dc.db.loadHooks()
+	h.prepareStart(ctx, query)
+	start := time.Now()
 	si, err := ctxDriverPrepare(ctx, dc.ci, query)
+	h.prepareDone(ctx, query, start, err)
 	if err != nil {
 		return nil, err
 	}
@@ -646,6 +701,7 @@ func (dc *driverConn) finalClose() error {
 		err = dc.ci.Close()
 		dc.ci = nil
 	})
+	dc.db.loadHooks().connClose(err)
 
 	dc.db.mu.Lock()
 	dc.db.numOpen--
@@ -1237,7 +1293,7 @@ func (db *DB) openNewConnection(ctx context.Context) {
 	// maybeOpenNewConnections has already executed db.numOpen++ before it sent
 	// on db.openerCh. This function must execute db.numOpen-- if the
 	// connection fails or is closed before returning.
-	ci, err := db.connector.Connect(ctx)
+	ci, err := db.connect(ctx)
 	db.mu.Lock()
 	defer db.mu.Unlock()
 	if db.closed {
@@ -1267,6 +1323,14 @@ func (db *DB) openNewConnection(ctx context.Context) {
 	}
 }
 
+// connect opens a new driver connection, running the ConnOpen hook.
+func (db *DB) connect(ctx context.Context) (driver.Conn, error) {
+	start := time.Now()
+	ci, err := db.connector.Connect(ctx)
+	db.loadHooks().connOpen(ctx, start, err)
+	return ci, err
+}
+
 // connRequest represents one request for a new connection
 // When there are no idle connections available, DB.conn will create
 // a new connRequest and put it on the db.connRequests list.
@@ -1315,6 +1379,7 @@ func (db *DB) conn(ctx context.Context, strategy connReuseStrategy) (*driverConn
 			conn.Close()
 			return nil, driver.ErrBadConn
 		}
+		returnedAt := conn.returnedAt
 		db.mu.Unlock()
 
 		// Reset the session if required.
@@ -1323,6 +1388,7 @@ func (db *DB) conn(ctx context.Context, strategy connReuseStrategy) (*driverConn
 			return nil, err
 		}
 
+		db.loadHooks().connReuse(ctx, returnedAt)
 		return conn, nil
 	}
 
@@ -1337,6 +1403,8 @@ func (db *DB) conn(ctx context.Context, strategy connReuseStrategy) (*driverConn
 		db.waitCount++
 		db.mu.Unlock()
 
+		hooks := db.loadHooks()
+		hooks.waitStart(ctx)
 		waitStart := nowFunc()
 
 		// Timeout the connection request with the context.
@@ -1349,6 +1417,7 @@ func (db *DB) conn(ctx context.Context, strategy connReuseStrategy) (*driverConn
 			db.mu.Unlock()
 
 			atomic.AddInt64(&db.waitDuration, int64(time.Since(waitStart)))
+			hooks.waitDone(ctx, waitStart, ctx.Err())
 
 			select {
 			default:
@@ -1362,8 +1431,10 @@ func (db *DB) conn(ctx context.Context, strategy connReuseStrategy) (*driverConn
 			atomic.AddInt64(&db.waitDuration, int64(time.Since(waitStart)))
 
 			if !ok {
+				hooks.waitDone(ctx, waitStart, errDBClosed)
 				return nil, errDBClosed
 			}
+			hooks.waitDone(ctx, waitStart, ret.err)
 			// Only check if the connection is expired if the strategy is cachedOrNewConns.
 			// If we require a new connection, just re-use the connection without looking
 			// at the expiry time. If it is expired, it will be checked when it is placed
@@ -1386,13 +1457,16 @@ func (db *DB) conn(ctx context.Context, strategy connReuseStrategy) (*driverConn
 				ret.conn.Close()
 				return nil, err
 			}
+			// The connection is owned by this request now,
+			// so returnedAt may be read without db.mu.
+			hooks.connReuse(ctx, ret.conn.returnedAt)
 			return ret.conn, ret.err
 		}
 	}
 
 	db.numOpen++ // optimistically
 	db.mu.Unlock()
-	ci, err := db.connector.Connect(ctx)
+	ci, err := db.connect(ctx)
 	if err != nil {
 		db.mu.Lock()
 		db.numOpen-- // correct for earlier optimism
@@ -1662,8 +1736,12 @@ func (db *DB) exec(ctx context.Context, query string, args []any, strategy connR
 }
 
 func (db *DB) execDC(ctx context.Context, dc *driverConn, release func(error), query string, args []any) (res Result, err error) {
+	hooks := db.loadHooks()
+	hooks.execStart(ctx, query, args)
+	start := time.Now()
 	defer func() {
 		release(err)
+		hooks.execDone(ctx, query, args, start, err)
 	}()
 	execerCtx, ok := dc.ci.(driver.ExecerContext)
 	var execer driver.Execer
@@ -1741,7 +1819,14 @@ func (db *DB) query(ctx context.Context, query string, args []any, strategy conn
 // The connection gets released by the releaseConn function.
 // The ctx context is from a query method and the txctx context is from an
 // optional transaction context.
-func (db *DB) queryDC(ctx, txctx context.Context, dc *driverConn, releaseConn func(error), query string, args []any) (*Rows, error) {
+func (db *DB) queryDC(ctx, txctx context.Context, dc *driverConn, releaseConn func(error), query string, args []any) (_ *Rows, err error) {
+	hooks := db.loadHooks()
+	hooks.queryStart(ctx, query, args)
+	start := time.Now()
+	defer func() {
+		hooks.queryDone(ctx, query, args, start, err)
+	}()
+
 	queryerCtx, ok := dc.ci.(driver.QueryerContext)
 	var queryer driver.Queryer
 	if !ok {
@@ -1750,7 +1835,6 @@ func (db *DB) queryDC(ctx, txctx context.Context, dc *driverConn, releaseConn fu
 	if ok {
 		var nvdargs []driver.NamedValue
 		var rowsi driver.Rows
-		var err error
 		withLock(dc, func() {
 			nvdargs, err = driverArgsConnLocked(dc.ci, nil, args)
 			if err != nil {
@@ -1776,7 +1860,6 @@ func (db *DB) queryDC(ctx, txctx context.Context, dc *driverConn, releaseConn fu
 	}
 
 	var si driver.Stmt
-	var err error
 	withLock(dc, func() {
 		si, err = ctxDriverPrepare(ctx, dc.ci, query)
 	})
@@ -1877,12 +1960,14 @@ func (db *DB) begin(ctx context.Context, opts *TxOptions, strategy connReuseStra
 func (db *DB) beginDC(ctx context.Context, dc *driverConn, release func(error), opts *TxOptions) (tx *Tx, err error) {
 	var txi driver.Tx
 	keepConnOnRollback := false
+	start := time.Now()
 	withLock(dc, func() {
 		_, hasSessionResetter := dc.ci.(driver.SessionResetter)
 		_, hasConnectionValidator := dc.ci.(driver.Validator)
 		keepConnOnRollback = hasSessionResetter && hasConnectionValidator
 		txi, err = ctxDriverBegin(ctx, opts, dc.ci)
 	})
+	db.loadHooks().txBegin(ctx, opts, start, err)
 	if err != nil {
 		release(err)
 		return nil, err
@@ -2286,9 +2371,11 @@ func (tx *Tx) Commit() error {
 	tx.closemu.Unlock()
 
 	var err error
+	start := time.Now()
 	withLock(tx.dc, func() {
 		err = tx.txi.Commit()
 	})
+	tx.db.loadHooks().txCommit(tx.ctx, start, err)
 	if !errors.Is(err, driver.ErrBadConn) {
 		tx.closePrepared()
 	}
@@ -2318,9 +2405,11 @@ func (tx *Tx) rollback(discardConn bool) error {
 	tx.closemu.Unlock()
 
 	var err error
+	start := time.Now()
 	withLock(tx.dc, func() {
 		err = tx.txi.Rollback()
 	})
+	tx.db.loadHooks().txRollback(tx.ctx, start, err)
 	if !errors.Is(err, driver.ErrBadConn) {
 		tx.closePrepared()
 	}
@@ -2620,6 +2709,7 @@ func (s *Stmt) ExecContext(ctx context.Context, args ...any) (Result, error) {
 	defer s.closemu.RUnlock()
 
 	var res Result
+	hooks := s.db.loadHooks()
 	strategy := cachedOrNewConn
 	for i := 0; i < maxBadConnRetries+1; i++ {
 		if i == maxBadConnRetries {
@@ -2633,8 +2723,11 @@ func (s *Stmt) ExecContext(ctx context.Context, args ...any) (Result, error) {
 			return nil, err
 		}
 
+		hooks.execStart(ctx, s.query, args)
+		start := time.Now()
 		res, err = resultFromStatement(ctx, dc.ci, ds, args...)
 		releaseConn(err)
+		hooks.execDone(ctx, s.query, args, start, err)
 		if !errors.Is(err, driver.ErrBadConn) {
 			return res, err
 		}
@@ -2768,6 +2861,7 @@ func (s *Stmt) QueryContext(ctx context.Context, args ...any) (*Rows, error) {
 	defer s.closemu.RUnlock()
 
 	var rowsi driver.Rows
+	hooks := s.db.loadHooks()
 	strategy := cachedOrNewConn
 	for i := 0; i < maxBadConnRetries+1; i++ {
 		if i == maxBadConnRetries {
@@ -2781,7 +2875,10 @@ func (s *Stmt) QueryContext(ctx context.Context, args ...any) (*Rows, error) {
 			return nil, err
 		}
 
+		hooks.queryStart(ctx, s.query, args)
+		start := time.Now()
 		rowsi, err = rowsiFromStatement(ctx, dc.ci, ds, args...)
+		hooks.queryDone(ctx, s.query, args, start, err)
 		if err == nil {
 			// Note: ownership of ci passes to the *Rows, to be freed
 			// with releaseConn.
@@ -1903,6 +1903,45 @@ func TestNullTimeParam(t *testing.T) {
 	nullTestRun(t, spec)
 }
 
+func TestGenericNullStringParam(t *testing.T) {
+	spec := nullTestSpec{"nullstring", "string", [6]nullTestRow{
+		{Null[string]{"aqua", true}, "", Null[string]{"aqua", true}},
+		{Null[string]{"brown", false}, "", Null[string]{"", false}},
+		{"chartreuse", "", Null[string]{"chartreuse", true}},
+		{Null[string]{"darkred", true}, "", Null[string]{"darkred", true}},
+		{Null[string]{"eel", false}, "", Null[string]{"", false}},
+		{"foo", Null[string]{"black", false}, nil},
+	}}
+	nullTestRun(t, spec)
+}
+
+func TestGenericNullInt32Param(t *testing.T) {
+	spec := nullTestSpec{"nullint32", "int32", [6]nullTestRow{
+		{Null[int32]{31, true}, 1, Null[int32]{31, true}},
+		{Null[int32]{-22, false}, 1, Null[int32]{0, false}},
+		{22, 1, Null[int32]{22, true}},
+		{Null[int32]{33, true}, 1, Null[int32]{33, true}},
+		{Null[int32]{222, false}, 1, Null[int32]{0, false}},
+		{0, Null[int32]{31, false}, nil},
+	}}
+	nullTestRun(t, spec)
+}
+
+func TestGenericNullValuer(t *testing.T) {
+	// A Valuer inside a Null is used to produce the value.
+	v, err := Null[NullString]{NullString{"hello", true}, true}.Value()
+	if err != nil || v != "hello" {
+		t.Errorf("Value() = %#v, %v; want \"hello\", nil", v, err)
+	}
+	v, err = Null[NullString]{NullString{"hello", false}, true}.Value()
+	if err != nil || v != nil {
+		t.Errorf("Value() = %#v, %v; want nil, nil", v, err)
+	}
+	if _, err := (Null[struct{}]{Valid: true}).Value(); err == nil {
+		t.Error("Value() of unsupported type succeeded")
+	}
+}
+
 func nullTestRun(t *testing.T, spec nullTestSpec) {
 	db := newTestDB(t, "")
 	defer closeDB(t, db)
@@ -42,9 +42,12 @@ import (
 //
 // To unmarshal JSON into a struct, Unmarshal matches incoming object
 // keys to the keys used by Marshal (either the struct field name or its tag),
-// preferring an exact match but also accepting a case-insensitive match. By
-// default, object keys which don't have a corresponding struct field are
-// ignored (see Decoder.DisallowUnknownFields for an alternative).
+// preferring an exact match but also accepting a case-insensitive match
+// (see Options.CaseSensitive for an alternative). By default, object keys
+// which don't have a corresponding struct field are ignored, or stored
+// in the struct's inlined map field, if any (see Decoder.DisallowUnknownFields
+// for an alternative). If an object has the same key more than once, the
+// last value wins (see Options.RejectDuplicateNames for an alternative).
 //
 // To unmarshal JSON into an interface value,
 // Unmarshal stores one of these in the interface value:
@@ -216,6 +219,8 @@ type decodeState struct {
 	savedError            error
 	useNumber             bool
 	disallowUnknownFields bool
+	caseSensitive         bool
+	rejectDuplicateNames  bool
 }
 
 // readIndex returns the position of the last byte read.
@@ -660,6 +665,7 @@ func (d *decodeState) object(v reflect.Value) error {
 	}
 
 	var mapElem reflect.Value
+	var seen map[string]struct{} // for rejectDuplicateNames
 	var origErrorContext errorContext
 	if d.errorContext != nil {
 		origErrorContext = *d.errorContext
@@ -687,7 +693,10 @@ func (d *decodeState) object(v reflect.Value) error {
 
 		// Figure out field corresponding to key.
 		var subv reflect.Value
-		destring := false // whether the value is wrapped in a string to be decoded first
+		var inlineMap reflect.Value // the inlined map field that subv is stored in
+		destring := false           // whether the value is wrapped in a string to be decoded first
+		format := ""                // the "format:" option of the field
+		name := string(key)         // the name that must be unique
 
 		if v.Kind() == reflect.Map {
 			elemType := t.Elem()
@@ -707,46 +716,46 @@ func (d *decodeState) object(v reflect.Value) error {
 				// linear search.
 				for i := range fields.list {
 					ff := &fields.list[i]
-					if ff.equalFold(ff.nameBytes, key) {
+					if (ff.caseIgnore || !d.caseSensitive && !ff.caseStrict) && ff.equalFold(ff.nameBytes, key) {
 						f = ff
 						break
 					}
 				}
 			}
 			if f != nil {
-				subv = v
-				destring = f.quoted
-				for _, i := range f.index {
-					if subv.Kind() == reflect.Pointer {
-						if subv.IsNil() {
-							// If a struct embeds a pointer to an unexported type,
-							// it is not possible to set a newly allocated value
-							// since the field is unexported.
-							//
-							// See https://golang.org/issue/21357
-							if !subv.CanSet() {
-								d.saveError(fmt.Errorf("json: cannot set embedded pointer to unexported struct: %v", subv.Type().Elem()))
-								// Invalidate subv to ensure d.value(subv) skips over
-								// the JSON value without assigning it to subv.
-								subv = reflect.Value{}
-								destring = false
-								break
-							}
-							subv.Set(reflect.New(subv.Type().Elem()))
-						}
-						subv = subv.Elem()
-					}
-					subv = subv.Field(i)
+				subv = d.fieldByIndex(v, f.index)
+				if subv.IsValid() {
+					destring = f.quoted
+					format = f.format
 				}
 				if d.errorContext == nil {
 					d.errorContext = new(errorContext)
 				}
 				d.errorContext.FieldStack = append(d.errorContext.FieldStack, f.name)
 				d.errorContext.Struct = t
+				name = f.name
+			} else if fields.inline != nil {
+				inlineMap = d.fieldByIndex(v, fields.inline.index)
+				if inlineMap.IsValid() {
+					if inlineMap.IsNil() {
+						inlineMap.Set(reflect.MakeMap(inlineMap.Type()))
+					}
+					subv = reflect.New(inlineMap.Type().Elem()).Elem()
+				}
 			} else if d.disallowUnknownFields {
 				d.saveError(fmt.Errorf("json: unknown field %q", key))
 			}
 		}
+		if d.rejectDuplicateNames {
+			if _, ok := seen[name]; ok {
+				d.saveError(fmt.Errorf("json: duplicate object name %q", key))
+			} else {
+				if seen == nil {
+					seen = make(map[string]struct{})
+				}
+				seen[name] = struct{}{}
+			}
+		}
 
 		// Read : before value.
 		if d.opcode == scanSkipSpace {
@@ -757,7 +766,11 @@ func (d *decodeState) object(v reflect.Value) error {
 		}
 		d.scanWhile(scanSkipSpace)
 
-		if destring {
+		if format != "" {
+			if err := d.formatStore(subv, format); err != nil {
+				return err
+			}
+		} else if destring {
 			switch qv := d.valueQuoted().(type) {
 			case nil:
 				if err := d.literalStore(nullLiteral, subv, false); err != nil {
@@ -778,7 +791,9 @@ func (d *decodeState) object(v reflect.Value) error {
 
 		// Write value back to map;
 		// if using struct, subv points into struct already.
-		if v.Kind() == reflect.Map {
+		if inlineMap.IsValid() {
+			inlineMap.SetMapIndex(reflect.ValueOf(string(key)).Convert(inlineMap.Type().Key()), subv)
+		} else if v.Kind() == reflect.Map {
 			kt := t.Key()
 			var kv reflect.Value
 			switch {
@@ -838,6 +853,34 @@ func (d *decodeState) object(v reflect.Value) error {
 	return nil
 }
 
+// fieldByIndex returns the field of the struct v with the given index
+// sequence, allocating any nil embedded pointers along the way.
+// If a pointer cannot be allocated, it saves an error and returns
+// the zero Value.
+func (d *decodeState) fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for _, i := range index {
+		if v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				// If a struct embeds a pointer to an unexported type,
+				// it is not possible to set a newly allocated value
+				// since the field is unexported.
+				//
+				// See https://golang.org/issue/21357
+				if !v.CanSet() {
+					d.saveError(fmt.Errorf("json: cannot set embedded pointer to unexported struct: %v", v.Type().Elem()))
+					// Return the zero Value to ensure d.value skips over
+					// the JSON value without assigning it.
+					return reflect.Value{}
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v
+}
+
 // convertNumber converts the number literal s to a float64 or a Number
 // depending on the setting of d.useNumber.
 func (d *decodeState) convertNumber(s string) (any, error) {
@@ -1111,6 +1154,12 @@ func (d *decodeState) objectInterface() map[string]any {
 		}
 		d.scanWhile(scanSkipSpace)
 
+		if d.rejectDuplicateNames {
+			if _, ok := m[key]; ok {
+				d.saveError(fmt.Errorf("json: duplicate object name %q", key))
+			}
+		}
+
 		// Read value.
 		m[key] = d.valueInterface()
 
@@ -2572,3 +2572,50 @@ func TestUnmarshalMaxDepth(t *testing.T) {
 		}
 	}
 }
+
+func TestUnmarshalInline(t *testing.T) {
+	tests := []struct {
+		in   string
+		ptr  any
+		want any
+	}{
+		{
+			in:   `{"a":1,"c":2,"x":"y","n":null,"o":{"k":[1]}}`,
+			ptr:  new(Inlines),
+			want: &Inlines{C: 2, Extra: map[string]any{"a": 1.0, "x": "y", "n": nil, "o": map[string]any{"k": []any{1.0}}}},
+		},
+		{
+			in:   `{"a":1,"b":"2","C":"3"}`,
+			ptr:  new(InlinesPtr),
+			want: &InlinesPtr{Base: &InlineBase{1, "2"}, Rest: map[string]string{"C": "3"}},
+		},
+		{
+			in:   `{"x":"1"}`,
+			ptr:  &InlinesPtr{Rest: map[string]string{"y": "2"}},
+			want: &InlinesPtr{Rest: map[string]string{"x": "1", "y": "2"}},
+		},
+	}
+	for _, tt := range tests {
+		if err := Unmarshal([]byte(tt.in), tt.ptr); err != nil {
+			t.Errorf("Unmarshal(%#q): %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(tt.ptr, tt.want) {
+			t.Errorf("Unmarshal(%#q):\n got: %+v\nwant: %+v", tt.in, tt.ptr, tt.want)
+		}
+	}
+
+	// Inlined maps collect unknown fields, so none are disallowed.
+	d := NewDecoder(strings.NewReader(`{"a":1,"b":"x","z":"2"}`))
+	d.DisallowUnknownFields()
+	var v InlinesPtr
+	if err := d.Decode(&v); err != nil {
+		t.Errorf("Decode with DisallowUnknownFields: %v", err)
+	}
+
+	var bad InlinesPtr
+	err := Unmarshal([]byte(`{"z":2}`), &bad)
+	if _, ok := err.(*UnmarshalTypeError); !ok {
+		t.Errorf("Unmarshal of number into inlined map[string]string: error = %v, want UnmarshalTypeError", err)
+	}
+}
@@ -72,6 +72,14 @@ import (
 // false, 0, a nil pointer, a nil interface value, and any empty array,
 // slice, map, or string.
 //
+// The "omitzero" option specifies that the field should be omitted
+// from the encoding if the field has a zero value. If the field's type
+// has an "IsZero() bool" method, that method decides whether the value
+// is zero; otherwise the value is zero if it is the zero value of its
+// type. Unlike "omitempty", "omitzero" omits zero structs such as a
+// zero time.Time. If both options are given, the field is omitted if
+// its value is either empty or zero.
+//
 // As a special case, if the field tag is "-", the field is always omitted.
 // Note that a field with name "-" can still be generated using the tag "-,".
 //
@@ -103,6 +111,42 @@ import (
 //
 //    Int64String int64 `json:",string"`
 //
+// The "format:" option selects the encoding of a field of type time.Time
+// or []byte, or of a pointer to one. For time.Time, the value after the
+// colon is either the name of one of the layout constants of package time,
+// such as "RFC1123", a layout to pass to Time.Format, or one of "unix",
+// "unixmilli", "unixmicro" and "unixnano", which encode the time as an
+// integer number of seconds, milliseconds, microseconds or nanoseconds
+// since the Unix epoch. For []byte, the value is one of "base64" (the
+// default), "base64url", "base32", "base32hex" and "base16", which encode
+// the bytes as a string using the corresponding encoding of RFC 4648,
+// or "array", which encodes the bytes as a JSON array of numbers.
+// Marshal and Unmarshal return an error for a field of one of these types
+// whose option has any other value. The option is ignored for fields of
+// other types:
+//
+//    Created time.Time `json:",format:RFC1123"`
+//    Expires time.Time `json:",format:unix"`
+//    Digest  []byte    `json:",format:base16"`
+//
+// The "inline" option specifies that the fields of a field of struct type,
+// or of pointer to struct type, are marshaled as if they were fields of the
+// outer struct, as for an anonymous struct field. The field's name is ignored.
+// The "inline" option may also be given for a field of map type with string
+// keys, in which case the map's entries are marshaled as members of the outer
+// object after the struct's fields, except for those whose keys are also the
+// name of a struct field. When unmarshaling, such a field receives the object
+// members that do not match any struct field, so that no data is lost.
+// The "unknown" option is a synonym of "inline" for map fields that makes
+// this intent clearer:
+//
+//    Extra map[string]any `json:",unknown"`
+//
+// The "case:strict" and "case:ignore" options control how Unmarshal matches
+// object keys to the field's name, and take precedence over the CaseSensitive
+// setting of Options. With "case:strict", only a key equal to the name
+// matches; with "case:ignore", a case-insensitive match is also accepted.
+//
 // The key name will be used if it's a non-empty string consisting of
 // only Unicode letters, digits, and ASCII punctuation except quotation
 // marks, backslash, and comma.
@@ -356,6 +400,47 @@ func isEmptyValue(v reflect.Value) bool {
 	return false
 }
 
+type isZeroer interface {
+	IsZero() bool
+}
+
+var isZeroerType = reflect.TypeOf((*isZeroer)(nil)).Elem()
+
+// zeroFunc returns a function that reports whether a value of type t
+// is zero, for the "omitzero" option.
+func zeroFunc(t reflect.Type) func(reflect.Value) bool {
+	switch {
+	case t.Kind() == reflect.Interface && t.Implements(isZeroerType):
+		return func(v reflect.Value) bool {
+			// Avoid calling IsZero on a nil interface or nil pointer.
+			if v.IsNil() || v.Elem().Kind() == reflect.Pointer && v.Elem().IsNil() {
+				return true
+			}
+			return v.Interface().(isZeroer).IsZero()
+		}
+	case t.Kind() == reflect.Pointer && t.Implements(isZeroerType):
+		return func(v reflect.Value) bool {
+			// Avoid calling IsZero on a nil pointer.
+			return v.IsNil() || v.Interface().(isZeroer).IsZero()
+		}
+	case t.Implements(isZeroerType):
+		return func(v reflect.Value) bool {
+			return v.Interface().(isZeroer).IsZero()
+		}
+	case reflect.PointerTo(t).Implements(isZeroerType):
+		return func(v reflect.Value) bool {
+			if !v.CanAddr() {
+				// Temporarily box v so we can take the address.
+				v2 := reflect.New(v.Type()).Elem()
+				v2.Set(v)
+				v = v2
+			}
+			return v.Addr().Interface().(isZeroer).IsZero()
+		}
+	}
+	return reflect.Value.IsZero
+}
+
 func (e *encodeState) reflectValue(v reflect.Value, opts encOpts) {
 	valueEncoder(v)(e, v, opts)
 }
@@ -365,6 +450,9 @@ type encOpts struct {
 	quoted bool
 	// escapeHTML causes '<', '>', and '&' to be escaped in JSON strings.
 	escapeHTML bool
+	// rejectDuplicateNames causes an error if an inlined map has a key
+	// that is also the name of a struct field.
+	rejectDuplicateNames bool
 }
 
 type encoderFunc func(e *encodeState, v reflect.Value, opts encOpts)
@@ -727,6 +815,10 @@ type structEncoder struct {
 type structFields struct {
 	list      []field
 	nameIndex map[string]int
+
+	// inline is the map field, if any, given the "inline" or "unknown"
+	// option. Its encoder encodes the map's elements.
+	inline *field
 }
 
 func (se structEncoder) encode(e *encodeState, v reflect.Value, opts encOpts) {
@@ -750,6 +842,9 @@ FieldLoop:
 		if f.omitEmpty && isEmptyValue(fv) {
 			continue
 		}
+		if f.omitZero && f.isZero(fv) {
+			continue
+		}
 		e.WriteByte(next)
 		next = ','
 		if opts.escapeHTML {
@@ -760,6 +855,9 @@ FieldLoop:
 		opts.quoted = f.quoted
 		f.encoder(e, fv, opts)
 	}
+	if f := se.fields.inline; f != nil {
+		next = se.encodeInline(e, v, f, next, opts)
+	}
 	if next == '{' {
 		e.WriteString("{}")
 	} else {
@@ -767,6 +865,44 @@ FieldLoop:
 	}
 }
 
+// encodeInline encodes the entries of the inlined map field f of v
+// as members of the object being written, and returns the byte that
+// comes before the next member.
+func (se structEncoder) encodeInline(e *encodeState, v reflect.Value, f *field, next byte, opts encOpts) byte {
+	for _, i := range f.index {
+		if v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return next
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	if v.Len() == 0 {
+		return next
+	}
+	keys := make([]string, 0, v.Len())
+	for _, k := range v.MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	opts.quoted = false
+	for _, k := range keys {
+		if _, ok := se.fields.nameIndex[k]; ok {
+			if opts.rejectDuplicateNames {
+				e.error(fmt.Errorf("json: duplicate name %q in inlined map field %s", k, v.Type()))
+			}
+			continue
+		}
+		e.WriteByte(next)
+		next = ','
+		e.string(k, opts.escapeHTML)
+		e.WriteByte(':')
+		f.encoder(e, v.MapIndex(reflect.ValueOf(k).Convert(v.Type().Key())), opts)
+	}
+	return next
+}
+
 func newStructEncoder(t reflect.Type) encoderFunc {
 	se := structEncoder{fields: cachedTypeFields(t)}
 	return se.encode
@@ -1180,12 +1316,17 @@ type field struct {
 	nameNonEsc  string // `"` + name + `":`
 	nameEscHTML string // `"` + HTMLEscape(name) + `":`
 
-	tag       bool
-	index     []int
-	typ       reflect.Type
-	omitEmpty bool
-	quoted    bool
-
+	tag        bool
+	index      []int
+	typ        reflect.Type
+	omitEmpty  bool
+	omitZero   bool
+	quoted     bool
+	caseStrict bool   // "case:strict": match the name exactly
+	caseIgnore bool   // "case:ignore": always match case-insensitively
+	format     string // value of the "format:" option, if typ supports it
+
+	isZero  func(reflect.Value) bool // for omitZero
 	encoder encoderFunc
 }
 
@@ -1225,6 +1366,9 @@ func typeFields(t reflect.Type) structFields {
 	// Fields found.
 	var fields []field
 
+	// Map fields given the "inline" or "unknown" option.
+	var inlines []field
+
 	// Buffer to run HTMLEscape on field names.
 	var nameEscBuf bytes.Buffer
 
@@ -1287,19 +1431,38 @@ func typeFields(t reflect.Type) structFields {
 					}
 				}
 
+				inline := opts.Contains("inline")
+				if (inline || opts.Contains("unknown")) && sf.Type.Kind() == reflect.Map && sf.Type.Key().Kind() == reflect.String {
+					// Record inlined map field.
+					inlines = append(inlines, field{name: sf.Name, index: index, typ: sf.Type})
+					if count[f.typ] > 1 {
+						inlines = append(inlines, inlines[len(inlines)-1])
+					}
+					continue
+				}
+
 				// Record found field and index sequence.
-				if name != "" || !sf.Anonymous || ft.Kind() != reflect.Struct {
+				if !(inline || sf.Anonymous && name == "") || ft.Kind() != reflect.Struct {
 					tagged := name != ""
 					if name == "" {
 						name = sf.Name
 					}
 					field := field{
-						name:      name,
-						tag:       tagged,
-						index:     index,
-						typ:       ft,
-						omitEmpty: opts.Contains("omitempty"),
-						quoted:    quoted,
+						name:       name,
+						tag:        tagged,
+						index:      index,
+						typ:        ft,
+						omitEmpty:  opts.Contains("omitempty"),
+						omitZero:   opts.Contains("omitzero"),
+						quoted:     quoted,
+						caseStrict: opts.Contains("case:strict"),
+						caseIgnore: opts.Contains("case:ignore"),
+					}
+					if field.omitZero {
+						field.isZero = zeroFunc(sf.Type)
+					}
+					if format, ok := opts.Get("format"); ok && formatType(ft) {
+						field.format = format
 					}
 					field.nameBytes = []byte(field.name)
 					field.equalFold = foldFunc(field.nameBytes)
@@ -1382,13 +1545,28 @@ func typeFields(t reflect.Type) structFields {
 
 	for i := range fields {
 		f := &fields[i]
-		f.encoder = typeEncoder(typeByIndex(t, f.index))
+		if f.format != "" {
+			f.encoder = newFormatEncoder(typeByIndex(t, f.index), f.format)
+		} else {
+			f.encoder = typeEncoder(typeByIndex(t, f.index))
+		}
 	}
 	nameIndex := make(map[string]int, len(fields))
 	for i, field := range fields {
 		nameIndex[field.name] = i
 	}
-	return structFields{fields, nameIndex}
+
+	// As for other fields, the least nested inlined map is used,
+	// and none if there are several at that depth.
+	var inline *field
+	sort.Slice(inlines, func(i, j int) bool {
+		return len(inlines[i].index) < len(inlines[j].index)
+	})
+	if len(inlines) == 1 || len(inlines) > 1 && len(inlines[0].index) < len(inlines[1].index) {
+		inline = &inlines[0]
+		inline.encoder = typeEncoder(inline.typ.Elem())
+	}
+	return structFields{fields, nameIndex, inline}
 }
 
 // dominantField looks through the fields, all of which are known to
@@ -13,7 +13,9 @@ import (
 	"reflect"
 	"regexp"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 	"unicode"
 )
 
@@ -71,6 +73,122 @@ func TestOmitEmpty(t *testing.T) {
 	}
 }
 
+// zeroer is zero if it is at most zero, to check that
+// "omitzero" calls the IsZero method.
+type zeroer int
+
+func (z zeroer) IsZero() bool { return z <= 0 }
+
+// ptrZeroer has an IsZero method with a pointer receiver.
+type ptrZeroer struct{ N int }
+
+func (z *ptrZeroer) IsZero() bool { return z.N == 42 }
+
+type OmitZeros struct {
+	Ir  int             `json:"ir"`
+	Iz  int             `json:"iz,omitzero"`
+	Tz  time.Time       `json:"tz,omitzero"`
+	Te  time.Time       `json:"te,omitempty"`
+	Sz  struct{ A int } `json:"sz,omitzero"`
+	Slz []int           `json:"slz,omitzero"`
+	Sle []int           `json:"sle,omitzero,omitempty"`
+	Pz  *int            `json:"pz,omitzero"`
+	Zz  zeroer          `json:"zz,omitzero"`
+	PZz ptrZeroer       `json:"pzz,omitzero"`
+	IZz isZeroer        `json:"izz,omitzero"`
+}
+
+func TestOmitZero(t *testing.T) {
+	o := OmitZeros{
+		Slz: []int{},
+		Sle: []int{},
+		Zz:  -1,
+		PZz: ptrZeroer{42},
+		IZz: (*time.Time)(nil),
+	}
+	const want = `{"ir":0,"te":"0001-01-01T00:00:00Z","slz":[]}`
+	// Check both addressable and unaddressable values.
+	for _, v := range []any{o, &o} {
+		got, err := Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("Marshal(%T):\n got: %s\nwant: %s", v, got, want)
+		}
+	}
+
+	one := 1
+	o = OmitZeros{
+		Iz:  1,
+		Tz:  time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+		Slz: []int{},
+		Pz:  &one,
+		Zz:  1,
+		PZz: ptrZeroer{1},
+		IZz: zeroer(1),
+	}
+	const want2 = `{"ir":0,"iz":1,"tz":"2000-01-01T00:00:00Z","te":"0001-01-01T00:00:00Z","slz":[],"pz":1,"zz":1,"pzz":{"N":1},"izz":1}`
+	got, err := Marshal(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want2 {
+		t.Errorf("Marshal:\n got: %s\nwant: %s", got, want2)
+	}
+}
+
+type InlineBase struct {
+	A int    `json:"a"`
+	B string `json:"b,omitempty"`
+}
+
+type Inlines struct {
+	Base  InlineBase     `json:"base,inline"`
+	PBase *InlineBase    `json:",inline"` // conflicts with Base, so ignored
+	C     int            `json:"c"`
+	Extra map[string]any `json:",unknown"`
+}
+
+type InlinesPtr struct {
+	*InlineBase `json:"-"`
+	Base        *InlineBase       `json:",inline"`
+	Rest        map[string]string `json:",inline"`
+}
+
+func TestMarshalInline(t *testing.T) {
+	tests := []struct {
+		in   any
+		want string
+	}{
+		{Inlines{}, `{"c":0}`},
+		{Inlines{Base: InlineBase{1, "x"}, C: 2}, `{"c":2}`},
+		{InlinesPtr{}, `{}`},
+		{InlinesPtr{Base: &InlineBase{A: 1}}, `{"a":1}`},
+		{InlinesPtr{Rest: map[string]string{"z": "<", "a": "dup", "y": "2"}}, `{"y":"2","z":"\u003c"}`},
+		{InlinesPtr{Base: &InlineBase{A: 1}, Rest: map[string]string{"b": "3"}}, `{"a":1}`},
+		{&struct {
+			InlineBase
+			M map[string]int `json:",inline"`
+		}{InlineBase{A: 1}, map[string]int{"m": 2}}, `{"a":1,"m":2}`},
+	}
+	for _, tt := range tests {
+		got, err := Marshal(tt.in)
+		if err != nil {
+			t.Errorf("Marshal(%#v): %v", tt.in, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("Marshal(%#v):\n got: %s\nwant: %s", tt.in, got, tt.want)
+		}
+	}
+
+	_, err := Options{RejectDuplicateNames: true}.Marshal(InlinesPtr{Rest: map[string]string{"a": "1"}})
+	if err == nil || !strings.Contains(err.Error(), `duplicate name "a"`) {
+		t.Errorf("Marshal with RejectDuplicateNames: error = %v, want duplicate name error", err)
+	}
+}
+
 type StringTag struct {
 	BoolStr    bool    `json:",string"`
 	IntStr     int64   `json:",string"`
@@ -0,0 +1,331 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package json
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// This file implements the "format:" struct tag option.
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// timeLayouts maps the names accepted by the "format:" option
+// to the layout constants of package time.
+var timeLayouts = map[string]string{
+	"ANSIC":       time.ANSIC,
+	"UnixDate":    time.UnixDate,
+	"RubyDate":    time.RubyDate,
+	"RFC822":      time.RFC822,
+	"RFC822Z":     time.RFC822Z,
+	"RFC850":      time.RFC850,
+	"RFC1123":     time.RFC1123,
+	"RFC1123Z":    time.RFC1123Z,
+	"RFC3339":     time.RFC3339,
+	"RFC3339Nano": time.RFC3339Nano,
+	"Kitchen":     time.Kitchen,
+	"Stamp":       time.Stamp,
+	"StampMilli":  time.StampMilli,
+	"StampMicro":  time.StampMicro,
+	"StampNano":   time.StampNano,
+}
+
+// timeLayout returns the layout for a time.Time format
+// that does not encode the time as a number.
+func timeLayout(format string) string {
+	if layout, ok := timeLayouts[format]; ok {
+		return layout
+	}
+	return format
+}
+
+// A byteCodec is a binary-to-text encoding for the "format:" option
+// of []byte fields. It is implemented by *base64.Encoding and
+// *base32.Encoding.
+type byteCodec interface {
+	EncodedLen(n int) int
+	Encode(dst, src []byte)
+	DecodedLen(n int) int
+	Decode(dst, src []byte) (int, error)
+}
+
+var byteCodecs = map[string]byteCodec{
+	"base64":    base64.StdEncoding,
+	"base64url": base64.URLEncoding,
+	"base32":    base32.StdEncoding,
+	"base32hex": base32.HexEncoding,
+	"base16":    base16Encoding{},
+}
+
+// formatType reports whether the "format:" option applies to a field
+// of type t, with one level of pointer removed.
+func formatType(t reflect.Type) bool {
+	return t == timeType || t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8
+}
+
+// validFormat reports whether format is a valid "format:" option
+// for a field of type t, with one level of pointer removed.
+func validFormat(t reflect.Type, format string) bool {
+	switch {
+	case t == timeType:
+		return format != ""
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		_, ok := byteCodecs[format]
+		return ok || format == "array"
+	}
+	return false
+}
+
+// formatError returns the error for a "format:" option
+// that is not valid for a field of type t.
+func formatError(t reflect.Type, format string) error {
+	return fmt.Errorf("json: invalid format %q in struct tag for field of type %v", format, t)
+}
+
+// newFormatEncoder returns an encoder for a field of type t,
+// which is time.Time, []byte or a pointer to one of them,
+// that encodes it as specified by format.
+func newFormatEncoder(t reflect.Type, format string) encoderFunc {
+	var enc encoderFunc
+	et := t
+	if et.Kind() == reflect.Pointer {
+		et = et.Elem()
+	}
+	if !validFormat(et, format) {
+		err := formatError(t, format)
+		return func(e *encodeState, _ reflect.Value, _ encOpts) {
+			e.error(err)
+		}
+	}
+	if et == timeType {
+		enc = timeFormatEncoder(format)
+	} else {
+		enc = bytesFormatEncoder(format)
+	}
+	if t.Kind() != reflect.Pointer {
+		return enc
+	}
+	return func(e *encodeState, v reflect.Value, opts encOpts) {
+		if v.IsNil() {
+			e.WriteString("null")
+			return
+		}
+		enc(e, v.Elem(), opts)
+	}
+}
+
+func timeFormatEncoder(format string) encoderFunc {
+	var unix func(time.Time) int64
+	switch format {
+	case "unix":
+		unix = time.Time.Unix
+	case "unixmilli":
+		unix = time.Time.UnixMilli
+	case "unixmicro":
+		unix = time.Time.UnixMicro
+	case "unixnano":
+		unix = time.Time.UnixNano
+	}
+	if unix != nil {
+		return func(e *encodeState, v reflect.Value, _ encOpts) {
+			t := v.Interface().(time.Time)
+			e.Write(strconv.AppendInt(e.scratch[:0], unix(t), 10))
+		}
+	}
+	layout := timeLayout(format)
+	return func(e *encodeState, v reflect.Value, opts encOpts) {
+		t := v.Interface().(time.Time)
+		e.string(t.Format(layout), opts.escapeHTML)
+	}
+}
+
+func bytesFormatEncoder(format string) encoderFunc {
+	if format == "array" {
+		return func(e *encodeState, v reflect.Value, _ encOpts) {
+			if v.IsNil() {
+				e.WriteString("null")
+				return
+			}
+			e.WriteByte('[')
+			for i, b := range v.Bytes() {
+				if i > 0 {
+					e.WriteByte(',')
+				}
+				e.Write(strconv.AppendUint(e.scratch[:0], uint64(b), 10))
+			}
+			e.WriteByte(']')
+		}
+	}
+	codec := byteCodecs[format]
+	return func(e *encodeState, v reflect.Value, _ encOpts) {
+		if v.IsNil() {
+			e.WriteString("null")
+			return
+		}
+		s := v.Bytes()
+		dst := make([]byte, codec.EncodedLen(len(s))+2)
+		dst[0] = '"'
+		codec.Encode(dst[1:], s)
+		dst[len(dst)-1] = '"'
+		e.Write(dst)
+	}
+}
+
+// formatStore consumes the next value from d.data and stores it in v,
+// a field whose type is time.Time, []byte or a pointer to one of them,
+// decoding it as specified by format.
+func (d *decodeState) formatStore(v reflect.Value, format string) error {
+	et := v.Type()
+	if et.Kind() == reflect.Pointer {
+		et = et.Elem()
+	}
+	if !validFormat(et, format) {
+		d.saveError(formatError(v.Type(), format))
+		return d.value(reflect.Value{})
+	}
+	if format == "array" {
+		// Unmarshal decodes arrays of numbers into []byte already.
+		return d.value(v)
+	}
+	start := d.readIndex()
+	if err := d.value(reflect.Value{}); err != nil {
+		return err
+	}
+	item := d.data[start:d.readIndex()]
+
+	if item[0] == 'n' { // null
+		switch v.Kind() {
+		case reflect.Pointer, reflect.Slice:
+			v.Set(reflect.Zero(v.Type()))
+		}
+		return nil
+	}
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+
+	if v.Type() == timeType {
+		var t time.Time
+		switch format {
+		case "unix", "unixmilli", "unixmicro", "unixnano":
+			n, err := strconv.ParseInt(string(item), 10, 64)
+			if err != nil {
+				d.saveError(&UnmarshalTypeError{Value: describeLiteral(item), Type: v.Type(), Offset: int64(start)})
+				return nil
+			}
+			switch format {
+			case "unix":
+				t = time.Unix(n, 0)
+			case "unixmilli":
+				t = time.UnixMilli(n)
+			case "unixmicro":
+				t = time.UnixMicro(n)
+			case "unixnano":
+				t = time.Unix(0, n)
+			}
+			t = t.UTC()
+		default:
+			s, ok := unquote(item)
+			if !ok {
+				d.saveError(&UnmarshalTypeError{Value: describeLiteral(item), Type: v.Type(), Offset: int64(start)})
+				return nil
+			}
+			var err error
+			t, err = time.Parse(timeLayout(format), s)
+			if err != nil {
+				return err
+			}
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	s, ok := unquoteBytes(item)
+	if !ok {
+		d.saveError(&UnmarshalTypeError{Value: describeLiteral(item), Type: v.Type(), Offset: int64(start)})
+		return nil
+	}
+	codec := byteCodecs[format]
+	b := make([]byte, codec.DecodedLen(len(s)))
+	n, err := codec.Decode(b, s)
+	if err != nil {
+		d.saveError(err)
+		return nil
+	}
+	v.SetBytes(b[:n])
+	return nil
+}
+
+// describeLiteral describes the JSON value item for an UnmarshalTypeError.
+func describeLiteral(item []byte) string {
+	switch item[0] {
+	case '"':
+		return "string"
+	case 't', 'f':
+		return "bool"
+	case '[':
+		return "array"
+	case '{':
+		return "object"
+	}
+	return "number " + string(item)
+}
+
+// base16Encoding is the base 16 (hexadecimal) encoding of RFC 4648,
+// written with lower-case letters. Decoding accepts either case.
+type base16Encoding struct{}
+
+func (base16Encoding) EncodedLen(n int) int { return 2 * n }
+
+func (base16Encoding) Encode(dst, src []byte) {
+	for i, b := range src {
+		dst[2*i] = hex[b>>4]
+		dst[2*i+1] = hex[b&0xF]
+	}
+}
+
+func (base16Encoding) DecodedLen(n int) int { return n / 2 }
+
+func (base16Encoding) Decode(dst, src []byte) (int, error) {
+	for i := 0; i < len(src); i += 2 {
+		if i+1 == len(src) {
+			return i / 2, errCorruptBase16(i)
+		}
+		hi, ok := unhex(src[i])
+		if !ok {
+			return i / 2, errCorruptBase16(i)
+		}
+		lo, ok := unhex(src[i+1])
+		if !ok {
+			return i / 2, errCorruptBase16(i + 1)
+		}
+		dst[i/2] = hi<<4 | lo
+	}
+	return len(src) / 2, nil
+}
+
+func errCorruptBase16(offset int) error {
+	return fmt.Errorf("json: illegal base16 data at input byte %d", offset)
+}
+
+func unhex(c byte) (byte, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0', true
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10, true
+	case 'A' <= c && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
@@ -0,0 +1,155 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package json
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type Formats struct {
+	T3339  time.Time  `json:"t3339,format:RFC3339"`
+	T1123  time.Time  `json:"t1123,format:RFC1123"`
+	TDate  time.Time  `json:"tdate,format:2006-01-02"`
+	TUnix  time.Time  `json:"tunix,format:unix"`
+	TMilli time.Time  `json:"tmilli,format:unixmilli"`
+	TMicro time.Time  `json:"tmicro,format:unixmicro"`
+	TNano  *time.Time `json:"tnano,format:unixnano"`
+	TNil   *time.Time `json:"tnil,format:unix"`
+
+	B64    []byte  `json:"b64,format:base64"`
+	B64URL []byte  `json:"b64url,format:base64url"`
+	B32    []byte  `json:"b32,format:base32"`
+	B32Hex []byte  `json:"b32hex,format:base32hex"`
+	B16    *[]byte `json:"b16,format:base16"`
+	BArray []byte  `json:"barray,format:array"`
+	BNil   []byte  `json:"bnil,format:base16"`
+
+	Ignored int `json:"ignored,format:unix"`
+}
+
+func TestFormat(t *testing.T) {
+	tm := time.Date(2009, 11, 10, 23, 4, 5, 123456789, time.UTC)
+	b := []byte{0xfb, 0xff, 0x01}
+	in := Formats{
+		T3339:   tm,
+		T1123:   tm.Truncate(time.Second),
+		TDate:   tm.Truncate(24 * time.Hour),
+		TUnix:   tm.Truncate(time.Second),
+		TMilli:  tm.Truncate(time.Millisecond),
+		TMicro:  tm.Truncate(time.Microsecond),
+		TNano:   &tm,
+		B64:     b,
+		B64URL:  b,
+		B32:     b,
+		B32Hex:  b,
+		B16:     &b,
+		BArray:  b,
+		Ignored: 1,
+	}
+	const want = `{` +
+		`"t3339":"2009-11-10T23:04:05Z",` +
+		`"t1123":"Tue, 10 Nov 2009 23:04:05 UTC",` +
+		`"tdate":"2009-11-10",` +
+		`"tunix":1257894245,` +
+		`"tmilli":1257894245123,` +
+		`"tmicro":1257894245123456,` +
+		`"tnano":1257894245123456789,` +
+		`"tnil":null,` +
+		`"b64":"+/8B",` +
+		`"b64url":"-_8B",` +
+		`"b32":"7P7QC===",` +
+		`"b32hex":"VFVG2===",` +
+		`"b16":"fbff01",` +
+		`"barray":[251,255,1],` +
+		`"bnil":null,` +
+		`"ignored":1}`
+	got, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Fatalf("Marshal:\n got: %s\nwant: %s", got, want)
+	}
+
+	var out Formats
+	out.TNil = &tm
+	out.BNil = b
+	if err := Unmarshal(got, &out); err != nil {
+		t.Fatal(err)
+	}
+	// RFC3339 has no fractional seconds.
+	in.T3339 = in.T3339.Truncate(time.Second)
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("Unmarshal:\n got: %+v\nwant: %+v", out, in)
+	}
+}
+
+func TestFormatUnmarshalErrors(t *testing.T) {
+	tests := []struct {
+		in  string
+		err string
+	}{
+		{`{"tunix":"1"}`, "json: cannot unmarshal string into Go struct field Formats.tunix of type time.Time"},
+		{`{"tunix":1.5}`, "json: cannot unmarshal number 1.5 into Go struct field Formats.tunix of type time.Time"},
+		{`{"tdate":1}`, "json: cannot unmarshal number 1 into Go struct field Formats.tdate of type time.Time"},
+		{`{"tdate":"2009-11"}`, `parsing time "2009-11" as "2006-01-02": cannot parse "" as "-"`},
+		{`{"b16":true}`, "json: cannot unmarshal bool into Go struct field Formats.b16 of type []uint8"},
+		{`{"b16":"fbf"}`, "json: illegal base16 data at input byte 2"},
+		{`{"b16":"fg"}`, "json: illegal base16 data at input byte 1"},
+		{`{"b32":"7P7QC"}`, "illegal base32 data at input byte 0"},
+	}
+	for _, tt := range tests {
+		var v Formats
+		err := Unmarshal([]byte(tt.in), &v)
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("Unmarshal(%#q): error = %v, want %s", tt.in, err, tt.err)
+		}
+	}
+}
+
+func TestInvalidFormat(t *testing.T) {
+	tests := []struct {
+		v   any
+		in  string
+		err string
+	}{
+		{new(struct {
+			B []byte `json:"b,format:hex"`
+		}), `{"b":"0102"}`, `json: invalid format "hex" in struct tag for field of type []uint8`},
+		{new(struct {
+			B *[]byte `json:"b,format:base58"`
+		}), `{"b":"0102"}`, `json: invalid format "base58" in struct tag for field of type *[]uint8`},
+	}
+	for _, tt := range tests {
+		if _, err := Marshal(tt.v); err == nil || err.Error() != tt.err {
+			t.Errorf("Marshal(%T): error = %v, want %s", tt.v, err, tt.err)
+		}
+		if err := Unmarshal([]byte(tt.in), tt.v); err == nil || err.Error() != tt.err {
+			t.Errorf("Unmarshal(%#q, %T): error = %v, want %s", tt.in, tt.v, err, tt.err)
+		}
+	}
+}
+
+func TestBase16Encoding(t *testing.T) {
+	var enc base16Encoding
+	src := []byte("\x00\x01\xab\xcd\xef\xff")
+	dst := make([]byte, enc.EncodedLen(len(src)))
+	enc.Encode(dst, src)
+	if string(dst) != "0001abcdefff" {
+		t.Errorf("Encode = %q", dst)
+	}
+	got := make([]byte, enc.DecodedLen(len(dst)))
+	n, err := enc.Decode(got, bytes.ToUpper(dst))
+	if err != nil || !bytes.Equal(got[:n], src) {
+		t.Errorf("Decode = %q, %v, want %q", got[:n], err, src)
+	}
+	if _, err := enc.Decode(got, []byte("0x")); err == nil || !strings.Contains(err.Error(), "byte 1") {
+		t.Errorf("Decode(0x): error = %v", err)
+	}
+}
@@ -0,0 +1,389 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsontext
+
+import (
+	"errors"
+	"io"
+)
+
+// A Decoder reads a stream of JSON tokens from an input stream.
+// The stream may contain any number of top-level JSON values,
+// separated by optional whitespace.
+//
+// The Decoder reads its input incrementally, buffering only the token
+// or value currently being read. The Tokens and Values it returns
+// refer to that buffer and are only valid until the next call to a
+// Decoder method.
+type Decoder struct {
+	r    io.Reader
+	rerr error // error returned by r; no more data follows buf
+
+	// buf holds input that has been read from r.
+	// buf[start:] must be retained, while buf[:start] may be discarded
+	// to make room for more input. buf[pos:] has not yet been consumed.
+	buf   []byte
+	start int
+	pos   int
+	base  int64 // input offset of buf[0]
+
+	ready  bool  // the whitespace and separator before the next token have been consumed
+	pinned bool  // a Value is being read; keep buf[start:] as the read proceeds
+	err    error // sticky syntax error
+
+	state state
+}
+
+// minRead is the minimum amount of space for each read from a Decoder's reader.
+const minRead = 512
+
+// NewDecoder returns a new Decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	d := new(Decoder)
+	d.Reset(r)
+	return d
+}
+
+// newBytesDecoder returns a Decoder that reads the JSON text in b.
+// The Values it returns refer to b itself.
+func newBytesDecoder(b []byte) *Decoder {
+	return &Decoder{buf: b, rerr: io.EOF}
+}
+
+// Reset discards the Decoder's state and makes it read from r,
+// reusing its buffers.
+func (d *Decoder) Reset(r io.Reader) {
+	*d = Decoder{
+		r:     r,
+		buf:   d.buf[:0],
+		state: d.state,
+	}
+	d.state.reset()
+}
+
+// InputOffset returns the offset in the input stream of the byte
+// following the most recently read token or value.
+func (d *Decoder) InputOffset() int64 {
+	return d.base + int64(d.pos)
+}
+
+// StackDepth returns the number of objects and arrays that enclose
+// the Decoder's current position in the JSON stream.
+func (d *Decoder) StackDepth() int {
+	return len(d.state.stack)
+}
+
+// StackIndex returns information about the i'th enclosing object or
+// array, counting from the outermost at 1 up to StackDepth. It returns
+// the kind of the object or array, '{' or '[', and the number of tokens
+// read within it so far. For an object, names and values are counted
+// separately, so an even length means the next token is a name or the
+// end of the object.
+//
+// StackIndex(0) describes the top level of the stream,
+// reporting a kind of 0 and the number of top-level values read.
+// StackIndex panics if i is not between 0 and StackDepth.
+func (d *Decoder) StackIndex(i int) (Kind, int64) {
+	return d.state.index(i)
+}
+
+// StackPointer returns a JSON Pointer to the most recently read value,
+// or to the object member whose name was most recently read.
+func (d *Decoder) StackPointer() Pointer {
+	return d.state.pointer()
+}
+
+// PeekKind returns the kind of the next token without consuming it.
+// It returns 0 at the end of the input or if an error occurs;
+// the error is reported by the next call to ReadToken or ReadValue.
+func (d *Decoder) PeekKind() Kind {
+	d.start = d.pos
+	if err := d.prepare(); err != nil {
+		return invalidKind
+	}
+	return kindOf(d.buf[d.pos])
+}
+
+// ReadToken reads the next Token, advancing the read position.
+// The ',' and ':' separators are consumed implicitly.
+// At the end of the input between top-level values it returns io.EOF;
+// if the input ends part way through a value, it returns a
+// SyntacticError wrapping io.ErrUnexpectedEOF.
+func (d *Decoder) ReadToken() (Token, error) {
+	d.start = d.pos
+	if err := d.prepare(); err != nil {
+		return Token{}, err
+	}
+	return d.readToken()
+}
+
+// ReadValue reads the next complete JSON value, advancing the read
+// position. If the value is an object or array, ReadValue reads up to
+// and including its end, so the Decoder buffers the entire value.
+// If the Decoder is positioned at an object name, ReadValue reads the
+// name as a JSON string.
+//
+// The returned Value is the raw JSON text of the value, without
+// leading or trailing whitespace. It refers to the Decoder's buffer
+// and is only valid until the next call to a Decoder method.
+//
+// It is an error to call ReadValue when the next token is the end
+// of an object or array.
+func (d *Decoder) ReadValue() (Value, error) {
+	d.start = d.pos
+	if err := d.prepare(); err != nil {
+		return nil, err
+	}
+	if err := d.checkValueNext(); err != nil {
+		return nil, err
+	}
+	d.start = d.pos
+	d.pinned = true
+	err := d.readValue()
+	d.pinned = false
+	if err != nil {
+		return nil, err
+	}
+	return Value(d.buf[d.start:d.pos:d.pos]), nil
+}
+
+// SkipValue is like ReadValue, but discards the value.
+// It buffers only one token at a time, so it can skip
+// arbitrarily large values.
+func (d *Decoder) SkipValue() error {
+	d.start = d.pos
+	if err := d.prepare(); err != nil {
+		return err
+	}
+	if err := d.checkValueNext(); err != nil {
+		return err
+	}
+	return d.readValue()
+}
+
+var errEndNotValue = errors.New("jsontext: next token is the end of an object or array, not a value")
+
+func (d *Decoder) checkValueNext() error {
+	if k := kindOf(d.buf[d.pos]); k == kindObjEnd || k == kindArrEnd {
+		if len(d.state.stack) > 0 {
+			return errEndNotValue
+		}
+	}
+	return nil
+}
+
+// readValue reads the tokens of the next value. The whitespace
+// and separator before the value must already have been consumed.
+func (d *Decoder) readValue() error {
+	depth := len(d.state.stack)
+	for {
+		if _, err := d.readToken(); err != nil {
+			return err
+		}
+		if len(d.state.stack) <= depth {
+			return nil
+		}
+		if !d.pinned {
+			d.start = d.pos
+		}
+		if err := d.prepare(); err != nil {
+			return err
+		}
+	}
+}
+
+// syntaxError records and returns a SyntacticError at buf[off].
+func (d *Decoder) syntaxError(off int, err error) error {
+	d.err = &SyntacticError{
+		ByteOffset:  d.base + int64(off),
+		JSONPointer: d.state.pointer(),
+		Err:         err,
+	}
+	return d.err
+}
+
+// fill reads more input into buf. Once the reader has returned an
+// error, fill returns that error without reading more.
+func (d *Decoder) fill() error {
+	if d.rerr != nil {
+		return d.rerr
+	}
+	if d.start > 0 {
+		n := copy(d.buf, d.buf[d.start:])
+		d.buf = d.buf[:n]
+		d.pos -= d.start
+		d.base += int64(d.start)
+		d.start = 0
+	}
+	if cap(d.buf)-len(d.buf) < minRead {
+		buf := make([]byte, len(d.buf), 2*cap(d.buf)+minRead)
+		copy(buf, d.buf)
+		d.buf = buf
+	}
+	for i := 0; i < 100; i++ {
+		n, err := d.r.Read(d.buf[len(d.buf):cap(d.buf)])
+		d.buf = d.buf[:len(d.buf)+n]
+		if err != nil {
+			d.rerr = err
+		}
+		if n > 0 {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	d.rerr = io.ErrNoProgress
+	return d.rerr
+}
+
+// skipSpace consumes whitespace, reading more input as needed.
+// It returns the reader's error, such as io.EOF, if the input
+// ends before a non-space byte is found.
+func (d *Decoder) skipSpace() error {
+	for {
+		d.pos += consumeWhitespace(d.buf[d.pos:])
+		if !d.pinned {
+			d.start = d.pos
+		}
+		if d.pos < len(d.buf) {
+			return nil
+		}
+		if err := d.fill(); err != nil {
+			return err
+		}
+	}
+}
+
+// skipSpaceInValue is like skipSpace, but the input must not end.
+func (d *Decoder) skipSpaceInValue() error {
+	err := d.skipSpace()
+	if err == io.EOF {
+		return d.syntaxError(d.pos, io.ErrUnexpectedEOF)
+	}
+	return err
+}
+
+// prepare consumes the whitespace and any separator before the next
+// token, checking that the separator is the one expected. It leaves
+// buf[pos] as the first byte of the next token.
+func (d *Decoder) prepare() error {
+	if d.err != nil {
+		return d.err
+	}
+	if d.ready {
+		return nil
+	}
+	s := &d.state
+	if len(s.stack) == 0 {
+		// io.EOF here is the clean end of the stream.
+		if err := d.skipSpace(); err != nil {
+			return err
+		}
+		d.ready = true
+		return nil
+	}
+	if err := d.skipSpaceInValue(); err != nil {
+		return err
+	}
+	top := s.top()
+	switch c := d.buf[d.pos]; {
+	case s.afterName():
+		if c != ':' {
+			return d.syntaxError(d.pos, newInvalidCharError(c, "after object name (expecting ':')"))
+		}
+	case s.needComma():
+		if Kind(c) == top.kind+2 { // end of object or array
+			d.ready = true
+			return nil
+		}
+		if c != ',' {
+			what := "after array element (expecting ',' or ']')"
+			if top.kind == kindObject {
+				what = "after object value (expecting ',' or '}')"
+			}
+			return d.syntaxError(d.pos, newInvalidCharError(c, what))
+		}
+	default:
+		// At the start of an object or array.
+		d.ready = true
+		return nil
+	}
+	d.pos++
+	if err := d.skipSpaceInValue(); err != nil {
+		return err
+	}
+	if c := d.buf[d.pos]; c == '}' || c == ']' {
+		// A trailing comma, or an end after ':'.
+		return d.syntaxError(d.pos, d.badStart(c))
+	}
+	d.ready = true
+	return nil
+}
+
+// badStart returns the error for c beginning a token
+// where it is not allowed.
+func (d *Decoder) badStart(c byte) error {
+	if d.state.needName() {
+		return newInvalidCharError(c, "looking for beginning of object name")
+	}
+	return newInvalidCharError(c, "looking for beginning of value")
+}
+
+// readToken reads the token at buf[pos]. The whitespace and separator
+// before the token must already have been consumed.
+func (d *Decoder) readToken() (Token, error) {
+	d.ready = false
+	s := &d.state
+	c := d.buf[d.pos]
+	k := kindOf(c)
+	switch {
+	case k == invalidKind,
+		s.needName() && k != kindString && k != kindObjEnd,
+		(k == kindObjEnd || k == kindArrEnd) && s.check(k) != nil:
+		return Token{}, d.syntaxError(d.pos, d.badStart(c))
+	}
+
+	n, from := 1, 0
+	var err error
+	for {
+		b := d.buf[d.pos:]
+		atEOF := d.rerr != nil
+		switch k {
+		case kindNull:
+			n, err = consumeLiteral(b, "null", atEOF)
+		case kindFalse:
+			n, err = consumeLiteral(b, "false", atEOF)
+		case kindTrue:
+			n, err = consumeLiteral(b, "true", atEOF)
+		case kindString:
+			n, err = consumeString(b, from, atEOF)
+		case kindNumber:
+			n, err = consumeNumber(b, atEOF)
+		}
+		if err != errIncomplete {
+			break
+		}
+		from = n
+		d.fill()
+	}
+	if err != nil {
+		if err == io.ErrUnexpectedEOF && d.rerr != io.EOF {
+			return Token{}, d.rerr
+		}
+		return Token{}, d.syntaxError(d.pos+n, err)
+	}
+
+	t := Token{kind: k}
+	if k == kindString || k == kindNumber {
+		t.raw = d.buf[d.pos : d.pos+n : d.pos+n]
+		if k == kindString && s.needName() {
+			s.setName(t.raw)
+		}
+	}
+	s.push(k)
+	d.pos += n
+	return t, nil
+}
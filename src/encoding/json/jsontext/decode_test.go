@@ -0,0 +1,405 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsontext
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// tokenString describes a token for comparison in tests.
+func tokenString(t Token) string {
+	if t.Kind() == kindString {
+		return `"` + t.String() + `"`
+	}
+	return t.String()
+}
+
+var decodeTests = []struct {
+	in       string
+	tokens   []string
+	pointers []Pointer
+}{
+	{
+		in:       `null true false`,
+		tokens:   []string{"null", "true", "false"},
+		pointers: []Pointer{"", "", ""},
+	},
+	{
+		in:       ` -1.5e+3 0 "a\tbé😀" `,
+		tokens:   []string{"-1.5e+3", "0", "\"a\tbé😀\""},
+		pointers: []Pointer{"", "", ""},
+	},
+	{
+		in:       `{}[]`,
+		tokens:   []string{"{", "}", "[", "]"},
+		pointers: []Pointer{"", "", "", ""},
+	},
+	{
+		in:     `{"a": [1, {"b/c": null, "~": []}], "d" : "e"}`,
+		tokens: []string{"{", `"a"`, "[", "1", "{", `"b/c"`, "null", `"~"`, "[", "]", "}", "]", `"d"`, `"e"`, "}"},
+		pointers: []Pointer{
+			"", "/a", "/a", "/a/0", "/a/1", "/a/1/b~1c", "/a/1/b~1c", "/a/1/~0",
+			"/a/1/~0", "/a/1/~0", "/a/1", "/a", "/d", "/d", "",
+		},
+	},
+	{
+		in:       "[[[]],\n[\"\\\"\"]]",
+		tokens:   []string{"[", "[", "[", "]", "]", "[", `"""`, "]", "]"},
+		pointers: []Pointer{"", "/0", "/0/0", "/0/0", "/0", "/1", "/1/0", "/1", ""},
+	},
+}
+
+func TestDecoderTokens(t *testing.T) {
+	readers := map[string]func(string) io.Reader{
+		"whole": func(s string) io.Reader { return strings.NewReader(s) },
+		"bytes": func(s string) io.Reader { return iotest.OneByteReader(strings.NewReader(s)) },
+		"half":  func(s string) io.Reader { return iotest.HalfReader(strings.NewReader(s)) },
+	}
+	for name, newReader := range readers {
+		for _, tt := range decodeTests {
+			d := NewDecoder(newReader(tt.in))
+			var tokens []string
+			var pointers []Pointer
+			for {
+				tok, err := d.ReadToken()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("%s: %#q: ReadToken: %v", name, tt.in, err)
+				}
+				tokens = append(tokens, tokenString(tok))
+				pointers = append(pointers, d.StackPointer())
+			}
+			if !reflect.DeepEqual(tokens, tt.tokens) {
+				t.Errorf("%s: %#q: tokens:\n got %q\nwant %q", name, tt.in, tokens, tt.tokens)
+			}
+			if !reflect.DeepEqual(pointers, tt.pointers) {
+				t.Errorf("%s: %#q: pointers:\n got %q\nwant %q", name, tt.in, pointers, tt.pointers)
+			}
+			if d.InputOffset() != int64(len(tt.in)) {
+				t.Errorf("%s: %#q: InputOffset = %d, want %d", name, tt.in, d.InputOffset(), len(tt.in))
+			}
+		}
+	}
+}
+
+func TestDecoderErrors(t *testing.T) {
+	tests := []struct {
+		in      string
+		err     string
+		offset  int64
+		pointer Pointer
+	}{
+		{`[1 2]`, `invalid character '2' after array element (expecting ',' or ']')`, 3, "/0"},
+		{`{"a" 1}`, `invalid character '1' after object name (expecting ':')`, 5, "/a"},
+		{`{"a":1 "b":2}`, `invalid character '"' after object value (expecting ',' or '}')`, 7, "/a"},
+		{`{1:2}`, `invalid character '1' looking for beginning of object name`, 1, ""},
+		{`[1,]`, `invalid character ']' looking for beginning of value`, 3, "/0"},
+		{`{"a":1,}`, `invalid character '}' looking for beginning of object name`, 7, "/a"},
+		{`{"a":}`, `invalid character '}' looking for beginning of value`, 5, "/a"},
+		{`[}`, `invalid character '}' looking for beginning of value`, 1, ""},
+		{`]`, `invalid character ']' looking for beginning of value`, 0, ""},
+		{`nul`, `unexpected EOF`, 3, ""},
+		{`nulx`, `invalid character 'x' in literal null (expecting 'l')`, 3, ""},
+		{`truefalse`, `invalid character 'f' after literal true`, 4, ""},
+		{`01`, `invalid character '1' after numeric literal`, 1, ""},
+		{`-`, `unexpected EOF`, 1, ""},
+		{`1.e5`, `invalid character 'e' in numeric literal`, 2, ""},
+		{`["a`, `unexpected EOF`, 3, ""},
+		{`["\x"]`, `invalid character 'x' in string escape code`, 3, ""},
+		{`"\u12g4"`, `invalid character 'g' in \u hexadecimal character escape`, 5, ""},
+		{"\"\n\"", `invalid character '\n' in string literal`, 1, ""},
+		{`[1`, `unexpected EOF`, 2, "/0"},
+		{`{"a":{"b":[`, `unexpected EOF`, 11, "/a/b"},
+	}
+	for _, tt := range tests {
+		d := NewDecoder(iotest.OneByteReader(strings.NewReader(tt.in)))
+		var err error
+		for err == nil {
+			_, err = d.ReadToken()
+		}
+		serr, ok := err.(*SyntacticError)
+		if !ok {
+			t.Errorf("%#q: error = %v, want SyntacticError", tt.in, err)
+			continue
+		}
+		if serr.Err.Error() != tt.err || serr.ByteOffset != tt.offset || serr.JSONPointer != tt.pointer {
+			t.Errorf("%#q: error = %q at %d in %q, want %q at %d in %q",
+				tt.in, serr.Err, serr.ByteOffset, serr.JSONPointer, tt.err, tt.offset, tt.pointer)
+		}
+		if tt.err == "unexpected EOF" && !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("%#q: error %v is not io.ErrUnexpectedEOF", tt.in, err)
+		}
+		// Errors are sticky.
+		if _, err2 := d.ReadToken(); err2 != err {
+			t.Errorf("%#q: second ReadToken = %v, want %v", tt.in, err2, err)
+		}
+	}
+}
+
+func TestDecoderReadError(t *testing.T) {
+	readErr := errors.New("read error")
+	d := NewDecoder(io.MultiReader(strings.NewReader(`["abc`), iotest.ErrReader(readErr)))
+	if _, err := d.ReadToken(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.ReadToken(); err != readErr {
+		t.Fatalf("ReadToken = %v, want %v", err, readErr)
+	}
+}
+
+func TestDecoderReadValue(t *testing.T) {
+	in := `{"a": [1, {"b": 2}], "c": "d"} [ ] 3`
+	d := NewDecoder(iotest.OneByteReader(strings.NewReader(in)))
+	readToken := func(want string) {
+		t.Helper()
+		tok, err := d.ReadToken()
+		if err != nil || tokenString(tok) != want {
+			t.Fatalf("ReadToken = %v, %v; want %v", tokenString(tok), err, want)
+		}
+	}
+	readValue := func(want string) {
+		t.Helper()
+		v, err := d.ReadValue()
+		if err != nil || string(v) != want {
+			t.Fatalf("ReadValue = %#q, %v; want %#q", v, err, want)
+		}
+	}
+
+	readToken("{")
+	readValue(`"a"`)
+	if k := d.PeekKind(); k != '[' {
+		t.Errorf("PeekKind = %v, want [", k)
+	}
+	readValue(`[1, {"b": 2}]`)
+	if p := d.StackPointer(); p != "/a" {
+		t.Errorf("StackPointer = %q, want /a", p)
+	}
+	readToken(`"c"`)
+	if err := d.SkipValue(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.ReadValue(); err != errEndNotValue {
+		t.Errorf("ReadValue at end of object = %v, want %v", err, errEndNotValue)
+	}
+	readToken("}")
+	readValue("[ ]")
+	readValue("3")
+	if _, err := d.ReadValue(); err != io.EOF {
+		t.Errorf("ReadValue at end = %v, want io.EOF", err)
+	}
+	if k := d.PeekKind(); k != 0 {
+		t.Errorf("PeekKind at end = %v, want 0", k)
+	}
+}
+
+// repeatReader returns a reader for prefix, then n copies of s,
+// then suffix.
+func repeatReader(prefix, s string, n int, suffix string) io.Reader {
+	rs := []io.Reader{strings.NewReader(prefix)}
+	chunk := strings.Repeat(s, 1000)
+	for ; n >= 1000; n -= 1000 {
+		rs = append(rs, strings.NewReader(chunk))
+	}
+	rs = append(rs, strings.NewReader(strings.Repeat(s, n)), strings.NewReader(suffix))
+	return io.MultiReader(rs...)
+}
+
+func TestDecoderConstantMemory(t *testing.T) {
+	const n = 200000
+	d := NewDecoder(repeatReader(`{"a":[`, `{"b":123456789},`, n, `{}]}`))
+	tokens := 0
+	for {
+		_, err := d.ReadToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens++
+		if cap(d.buf) > 4*minRead {
+			t.Fatalf("buffer grew to %d bytes after %d tokens", cap(d.buf), tokens)
+		}
+	}
+	if want := 3 + 4*n + 4; tokens != want {
+		t.Errorf("read %d tokens, want %d", tokens, want)
+	}
+
+	// Skipping a large value uses constant memory too.
+	d.Reset(repeatReader(`[`, `"abc",`, n, `"def"] 1`))
+	if err := d.SkipValue(); err != nil {
+		t.Fatal(err)
+	}
+	if cap(d.buf) > 4*minRead {
+		t.Errorf("buffer grew to %d bytes skipping value", cap(d.buf))
+	}
+	if tok, err := d.ReadToken(); err != nil || tok.Int() != 1 {
+		t.Errorf("ReadToken after SkipValue = %v, %v; want 1", tok, err)
+	}
+}
+
+func TestDecoderLongString(t *testing.T) {
+	const n = 1 << 20
+	d := NewDecoder(repeatReader(`"`, `\"x`, n, `"`))
+	tok, err := d.ReadToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := tok.String(); len(s) != 2*n || s[:4] != `"x"x` {
+		t.Errorf("ReadToken returned string of length %d beginning %q", len(s), s[:4])
+	}
+}
+
+func TestDecoderAllocs(t *testing.T) {
+	in := []byte(`{"name": "value", "list": [1, 2.5, -3e10, true, false, null, "a\nb"], "obj": {"x": {}}}`)
+	r := bytes.NewReader(in)
+	d := NewDecoder(r)
+	allocs := testing.AllocsPerRun(100, func() {
+		r.Reset(in)
+		d.Reset(r)
+		for {
+			tok, err := d.ReadToken()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tok.Kind() == kindNumber {
+				tok.Float()
+			}
+		}
+	})
+	if allocs > 0 {
+		t.Errorf("ReadToken allocated %v times per document, want 0", allocs)
+	}
+}
+
+func TestTokenAccessors(t *testing.T) {
+	d := NewDecoder(strings.NewReader(`[1.5, -2, 18446744073709551615, 1e400, "x"]`))
+	d.ReadToken()
+	var toks []Token
+	for d.PeekKind() != ']' {
+		tok, err := d.ReadToken()
+		if err != nil {
+			t.Fatal(err)
+		}
+		toks = append(toks, tok.Clone())
+	}
+
+	tests := []struct {
+		tok  Token
+		f    float64
+		i    int64
+		u    uint64
+		text string
+	}{
+		{toks[0], 1.5, 1, 1, "1.5"},
+		{toks[1], -2, -2, 0, "-2"},
+		{toks[2], 18446744073709551615, math.MaxInt64, math.MaxUint64, "18446744073709551615"},
+		{toks[3], math.Inf(1), math.MaxInt64, math.MaxUint64, "1e400"},
+		{Float(-0.000000125), -0.000000125, 0, 0, "-1.25e-7"},
+		{Float(1e21), 1e21, math.MaxInt64, math.MaxUint64, "1e+21"},
+		{Int(-7), -7, -7, 0, "-7"},
+		{Uint(math.MaxUint64), math.MaxUint64, math.MaxInt64, math.MaxUint64, "18446744073709551615"},
+	}
+	for _, tt := range tests {
+		if f, i, u, s := tt.tok.Float(), tt.tok.Int(), tt.tok.Uint(), tt.tok.String(); f != tt.f || i != tt.i || u != tt.u || s != tt.text {
+			t.Errorf("token %v: Float, Int, Uint, String = %v, %v, %v, %q; want %v, %v, %v, %q",
+				tt.tok, f, i, u, s, tt.f, tt.i, tt.u, tt.text)
+		}
+	}
+	if s := toks[4].String(); s != "x" {
+		t.Errorf("String() = %q, want x", s)
+	}
+	if !True.Bool() || False.Bool() || Bool(true).Kind() != 't' {
+		t.Errorf("Bool tokens are wrong")
+	}
+	if s := (Token{}).String(); s != "<invalid jsontext.Token>" {
+		t.Errorf("String of zero Token = %q", s)
+	}
+}
+
+func TestValue(t *testing.T) {
+	tests := []struct {
+		in    string
+		valid bool
+		kind  Kind
+	}{
+		{` {"a": [1, 2]} `, true, '{'},
+		{`"abc"`, true, '"'},
+		{`-1`, true, '0'},
+		{`null`, true, 'n'},
+		{``, false, 0},
+		{`  `, false, 0},
+		{`1 2`, false, '0'},
+		{`[1,]`, false, '['},
+		{`{"a":1}x`, false, '{'},
+		{`]`, false, 0},
+	}
+	for _, tt := range tests {
+		v := Value(tt.in)
+		if got := v.IsValid(); got != tt.valid {
+			t.Errorf("Value(%#q).IsValid() = %v, want %v", tt.in, got, tt.valid)
+		}
+		if got := v.Kind(); got != tt.kind {
+			t.Errorf("Value(%#q).Kind() = %v, want %v", tt.in, got, tt.kind)
+		}
+	}
+}
+
+func TestPointer(t *testing.T) {
+	p := Pointer("").AppendToken("a/b").AppendToken("~c").AppendToken("0")
+	if p != "/a~1b/~0c/0" {
+		t.Errorf("AppendToken built %q", p)
+	}
+	if !p.IsValid() {
+		t.Errorf("%q is not valid", p)
+	}
+	if got, want := p.Tokens(), []string{"a/b", "~c", "0"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Tokens() = %q, want %q", got, want)
+	}
+	if got := p.LastToken(); got != "0" {
+		t.Errorf("LastToken() = %q, want 0", got)
+	}
+	if got := p.Parent(); got != "/a~1b/~0c" {
+		t.Errorf("Parent() = %q", got)
+	}
+	if got := Pointer("/~01").LastToken(); got != "~1" {
+		t.Errorf(`LastToken of "/~01" = %q, want "~1"`, got)
+	}
+	for _, bad := range []Pointer{"a", "/~", "/~2", "/\xff"} {
+		if bad.IsValid() {
+			t.Errorf("%q is valid", bad)
+		}
+	}
+}
+
+func TestTokenFloat(t *testing.T) {
+	for _, s := range []string{
+		"0", "-0", "1", "0.1", "-0.3", "123.456e-7", "9007199254740993", "1e22", "1e23",
+		"4.9e-324", "1.7976931348623157e308", "1e-400", "12345678901234567890", "0.1e+2",
+		"9999999999999999999", "3.14159265358979323846264338327950288",
+	} {
+		want, _ := strconv.ParseFloat(s, 64)
+		tok, err := NewDecoder(strings.NewReader(s)).ReadToken()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := tok.Float(); got != want || math.Signbit(got) != math.Signbit(want) {
+			t.Errorf("Float() of %s = %v, want %v", s, got, want)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package jsontext implements syntactic processing of JSON as specified
+// in RFC 8259, as a stream of tokens.
+//
+// Unlike package encoding/json, which converts between JSON and Go
+// values, package jsontext deals only with the JSON text itself.
+// A Decoder reads a stream of JSON tokens from an io.Reader and an
+// Encoder writes a stream of JSON tokens to an io.Writer. Both check
+// that the stream is well-formed JSON, track their position within it
+// as a JSON Pointer (RFC 6901), and use memory proportional only to the
+// nesting depth of the JSON and the size of the largest token, so that
+// arbitrarily large documents can be processed a piece at a time.
+//
+// A Token represents a single JSON literal, string, number, or object
+// or array delimiter. A Value is the raw text of a complete JSON value.
+// Tokens and Values returned by a Decoder refer to the Decoder's
+// internal buffer and are only valid until the next call to one of the
+// Decoder's methods, which lets callers read JSON without allocating.
+// Use Token.Clone or Value.Clone to retain them for longer.
+//
+// Unlike the Decoder.Token method of package encoding/json, the ',' and
+// ':' separators between values are never reported as tokens, and an
+// object name is reported as an ordinary string token.
+package jsontext
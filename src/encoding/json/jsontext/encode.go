@@ -0,0 +1,229 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsontext
+
+import (
+	"io"
+	"math"
+)
+
+// An Encoder writes a stream of JSON tokens to an output stream.
+// It inserts the ',' and ':' separators between values and checks
+// that the tokens form well-formed JSON. Each top-level value is
+// followed by a newline.
+//
+// The Encoder buffers its output, writing it whenever a top-level value
+// is complete or enough output has accumulated, so that arbitrarily
+// large values may be written a token at a time.
+type Encoder struct {
+	w    io.Writer
+	buf  []byte
+	base int64 // number of bytes written to w
+	err  error // sticky write error
+
+	state state
+
+	prefix    string
+	indent    string
+	indenting bool
+}
+
+// flushSize is the amount of buffered output at which an Encoder
+// writes to its writer before the current top-level value is complete.
+const flushSize = 4096
+
+// NewEncoder returns a new Encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Reset discards the Encoder's state and any unwritten output and
+// makes it write to w, reusing its buffers. The indentation set by
+// SetIndent is kept.
+func (e *Encoder) Reset(w io.Writer) {
+	e.w = w
+	e.buf = e.buf[:0]
+	e.base = 0
+	e.err = nil
+	e.state.reset()
+}
+
+// SetIndent instructs the Encoder to format each subsequent value as if
+// indented by the package-level function Indent(dst, src, prefix, indent)
+// of package encoding/json. Calling SetIndent("", "") disables indentation.
+func (e *Encoder) SetIndent(prefix, indent string) {
+	e.prefix = prefix
+	e.indent = indent
+	e.indenting = prefix != "" || indent != ""
+}
+
+// OutputOffset returns the number of bytes of output produced so far,
+// including output not yet written to the underlying writer.
+func (e *Encoder) OutputOffset() int64 {
+	return e.base + int64(len(e.buf))
+}
+
+// StackDepth returns the number of objects and arrays that enclose
+// the Encoder's current position in the JSON stream.
+func (e *Encoder) StackDepth() int {
+	return len(e.state.stack)
+}
+
+// StackIndex returns information about the i'th enclosing object or
+// array. See Decoder.StackIndex for details.
+func (e *Encoder) StackIndex(i int) (Kind, int64) {
+	return e.state.index(i)
+}
+
+// StackPointer returns a JSON Pointer to the most recently written value,
+// or to the object member whose name was most recently written.
+func (e *Encoder) StackPointer() Pointer {
+	return e.state.pointer()
+}
+
+// WriteToken writes the next token. Any string token written where an
+// object name is expected is used as the name. If the token cannot
+// come next in well-formed JSON, WriteToken returns a SyntacticError
+// and writes nothing.
+func (e *Encoder) WriteToken(t Token) error {
+	if e.err != nil {
+		return e.err
+	}
+	k := t.kind
+	if err := e.state.check(k); err != nil {
+		return e.syntaxError(err)
+	}
+	if k == kindNumber && t.raw == nil && t.nk == 'f' {
+		if f := math.Float64frombits(t.num); math.IsNaN(f) || math.IsInf(f, 0) {
+			return e.syntaxError(errNonFiniteFloat)
+		}
+	}
+	e.writeSeparator(k)
+	switch k {
+	case kindNull:
+		e.buf = append(e.buf, "null"...)
+	case kindFalse:
+		e.buf = append(e.buf, "false"...)
+	case kindTrue:
+		e.buf = append(e.buf, "true"...)
+	case kindString:
+		if e.state.needName() {
+			if t.raw != nil {
+				e.state.setName(t.raw)
+			} else {
+				e.state.setNameString(t.str)
+			}
+		}
+		if t.raw != nil {
+			e.buf = append(e.buf, t.raw...)
+		} else {
+			e.buf = appendQuote(e.buf, t.str)
+		}
+	case kindNumber:
+		if t.raw != nil {
+			e.buf = append(e.buf, t.raw...)
+		} else {
+			e.buf = t.appendNumber(e.buf)
+		}
+	default:
+		e.buf = append(e.buf, byte(k))
+	}
+	e.state.push(k)
+	return e.flush()
+}
+
+// WriteValue writes the next complete JSON value, which must be valid.
+// An object or array is written compactly, or indented as set by
+// SetIndent, regardless of how v itself is formatted. If the Encoder
+// is positioned at an object name, v must be a JSON string.
+func (e *Encoder) WriteValue(v Value) error {
+	if e.err != nil {
+		return e.err
+	}
+	d := newBytesDecoder(v)
+	if _, err := d.ReadValue(); err != nil {
+		if serr, ok := err.(*SyntacticError); ok {
+			err = serr.Err
+		}
+		return e.syntaxError(err)
+	}
+	if err := d.prepare(); err != io.EOF {
+		return e.syntaxError(newInvalidCharError(v[d.pos], "after top-level value"))
+	}
+	if err := e.state.check(v.Kind()); err != nil {
+		return e.syntaxError(err)
+	}
+	d = newBytesDecoder(v)
+	for {
+		t, err := d.ReadToken()
+		if err == io.EOF {
+			return nil
+		}
+		// v is valid, so the only possible errors are from writing.
+		if err := e.WriteToken(t); err != nil {
+			return err
+		}
+	}
+}
+
+func (e *Encoder) syntaxError(err error) error {
+	return &SyntacticError{
+		ByteOffset:  e.OutputOffset(),
+		JSONPointer: e.state.pointer(),
+		Err:         err,
+	}
+}
+
+// writeSeparator writes the separator and indentation
+// that come before a token of kind k.
+func (e *Encoder) writeSeparator(k Kind) {
+	s := &e.state
+	top := s.top()
+	switch {
+	case top == nil:
+	case s.afterName():
+		e.buf = append(e.buf, ':')
+		if e.indenting {
+			e.buf = append(e.buf, ' ')
+		}
+	case k == kindObjEnd || k == kindArrEnd:
+		if top.len > 0 && e.indenting {
+			e.newline(len(s.stack) - 1)
+		}
+	default:
+		if top.len > 0 {
+			e.buf = append(e.buf, ',')
+		}
+		if e.indenting {
+			e.newline(len(s.stack))
+		}
+	}
+}
+
+func (e *Encoder) newline(depth int) {
+	e.buf = append(e.buf, '\n')
+	e.buf = append(e.buf, e.prefix...)
+	for i := 0; i < depth; i++ {
+		e.buf = append(e.buf, e.indent...)
+	}
+}
+
+// flush ends a completed top-level value with a newline and writes the
+// buffered output if the value is complete or the buffer is full.
+func (e *Encoder) flush() error {
+	done := len(e.state.stack) == 0
+	if done {
+		e.buf = append(e.buf, '\n')
+	} else if len(e.buf) < flushSize {
+		return nil
+	}
+	n, err := e.w.Write(e.buf)
+	e.base += int64(n)
+	e.buf = e.buf[:0]
+	if err != nil {
+		e.err = err
+	}
+	return err
+}
@@ -0,0 +1,213 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsontext
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestEncoder(t *testing.T) {
+	tokens := []Token{
+		BeginObject,
+		String("a"), BeginArray, Int(1), Float(-2.5), Uint(3), Float(1e-7), EndArray,
+		String("b"), BeginObject, EndObject,
+		String("c\"\n<"), String("\xff\u2028é"),
+		String("d"), BeginArray, Null, True, False, BeginArray, EndArray, EndArray,
+		EndObject,
+		Int(2),
+	}
+	tests := []struct {
+		prefix, indent string
+		want           string
+	}{
+		{"", "", `{"a":[1,-2.5,3,1e-7],"b":{},"c\"\n<":"\ufffd\u2028é","d":[null,true,false,[]]}` + "\n2\n"},
+		{">", "\t", `{
+>	"a": [
+>		1,
+>		-2.5,
+>		3,
+>		1e-7
+>	],
+>	"b": {},
+>	"c\"\n<": "\ufffd\u2028é",
+>	"d": [
+>		null,
+>		true,
+>		false,
+>		[]
+>	]
+>}
+2
+`},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		e := NewEncoder(&buf)
+		e.SetIndent(tt.prefix, tt.indent)
+		for _, tok := range tokens {
+			if err := e.WriteToken(tok); err != nil {
+				t.Fatalf("WriteToken(%v): %v", tok, err)
+			}
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("SetIndent(%q, %q): output:\n%s\nwant:\n%s", tt.prefix, tt.indent, got, tt.want)
+		}
+		if e.OutputOffset() != int64(buf.Len()) {
+			t.Errorf("OutputOffset = %d, want %d", e.OutputOffset(), buf.Len())
+		}
+
+		// The output matches encoding/json's formatting.
+		var want bytes.Buffer
+		if tt.prefix == "" && tt.indent == "" {
+			json.Compact(&want, []byte(tests[0].want))
+		} else {
+			json.Indent(&want, []byte(tests[0].want), tt.prefix, tt.indent)
+		}
+		if !strings.HasPrefix(buf.String(), want.String()) {
+			t.Errorf("output does not match json.Indent:\n%s\nwant:\n%s", buf.String(), want.String())
+		}
+	}
+}
+
+func TestEncoderErrors(t *testing.T) {
+	tests := []struct {
+		tokens  []Token
+		err     error
+		pointer Pointer
+	}{
+		{[]Token{EndObject}, errUnmatchedEnd, ""},
+		{[]Token{BeginArray, EndObject}, errMismatchedEnd, ""},
+		{[]Token{BeginObject, Int(1)}, errNonStringName, ""},
+		{[]Token{BeginObject, String("a"), EndObject}, errMissingValue, "/a"},
+		{[]Token{BeginArray, Float(math.NaN())}, errNonFiniteFloat, ""},
+		{[]Token{BeginArray, True, Float(math.Inf(-1))}, errNonFiniteFloat, "/0"},
+		{[]Token{{}}, errInvalidToken, ""},
+	}
+	for _, tt := range tests {
+		e := NewEncoder(io.Discard)
+		var err error
+		var offset int64
+		var depth int
+		for _, tok := range tt.tokens {
+			offset, depth = e.OutputOffset(), e.StackDepth()
+			if err = e.WriteToken(tok); err != nil {
+				break
+			}
+		}
+		serr, ok := err.(*SyntacticError)
+		if !ok || serr.Err != tt.err || serr.JSONPointer != tt.pointer {
+			t.Errorf("%v: error = %v, want %v within %q", tt.tokens, err, tt.err, tt.pointer)
+		}
+		// Nothing was written for the failed token.
+		if e.OutputOffset() != offset || e.StackDepth() != depth {
+			t.Errorf("%v: failed WriteToken changed offset from %d to %d, depth from %d to %d",
+				tt.tokens, offset, e.OutputOffset(), depth, e.StackDepth())
+		}
+	}
+}
+
+func TestEncoderWriteValue(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	e.WriteToken(BeginObject)
+	if err := e.WriteValue(Value(` "a" `)); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.WriteValue(Value(`{ "b" : [ 1 , 2 ] }`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.WriteValue(Value(`1`)); err == nil {
+		t.Error("WriteValue of a number as an object name succeeded")
+	}
+	for _, bad := range []string{`[1,]`, `1 2`, ``, `}`} {
+		if err := e.WriteValue(Value(bad)); err == nil {
+			t.Errorf("WriteValue(%#q) succeeded", bad)
+		}
+	}
+	e.WriteToken(EndObject)
+	if got, want := buf.String(), `{"a":{"b":[1,2]}}`+"\n"; got != want {
+		t.Errorf("output = %#q, want %#q", got, want)
+	}
+}
+
+type errWriter struct{ n int }
+
+func (w *errWriter) Write(p []byte) (int, error) {
+	if w.n -= len(p); w.n < 0 {
+		return 0, errors.New("write error")
+	}
+	return len(p), nil
+}
+
+func TestEncoderWriteError(t *testing.T) {
+	e := NewEncoder(&errWriter{n: 2 * flushSize})
+	e.WriteToken(BeginArray)
+	var err error
+	for i := 0; err == nil && i < 10*flushSize; i++ {
+		err = e.WriteToken(Int(int64(i)))
+	}
+	if err == nil || err.Error() != "write error" {
+		t.Fatalf("WriteToken = %v, want write error", err)
+	}
+	if err2 := e.WriteToken(Null); err2 != err {
+		t.Errorf("WriteToken after error = %v, want %v", err2, err)
+	}
+}
+
+// TestRoundTrip copies a large document token by token and checks
+// that the result matches encoding/json's compact form.
+func TestRoundTrip(t *testing.T) {
+	f, err := os.Open("../testdata/code.json.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	d := NewDecoder(iotest.HalfReader(bytes.NewReader(data)))
+	e := NewEncoder(&out)
+	for {
+		tok, err := d.ReadToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := e.WriteToken(tok); err != nil {
+			t.Fatal(err)
+		}
+		if d.StackDepth() != e.StackDepth() || d.StackPointer() != e.StackPointer() {
+			t.Fatalf("decoder at %q (depth %d), encoder at %q (depth %d)",
+				d.StackPointer(), d.StackDepth(), e.StackPointer(), e.StackDepth())
+		}
+	}
+
+	var want bytes.Buffer
+	if err := json.Compact(&want, data); err != nil {
+		t.Fatal(err)
+	}
+	want.WriteByte('\n')
+	if !bytes.Equal(out.Bytes(), want.Bytes()) {
+		t.Errorf("round trip of code.json differs from json.Compact")
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsontext
+
+import (
+	"errors"
+	"strconv"
+)
+
+// A SyntacticError describes JSON text that is not well-formed,
+// or a sequence of tokens passed to an Encoder that does not form
+// well-formed JSON.
+type SyntacticError struct {
+	// ByteOffset is the offset in the input or output
+	// at which the error occurred.
+	ByteOffset int64
+
+	// JSONPointer identifies the value in which the error occurred.
+	JSONPointer Pointer
+
+	// Err is the underlying error. It is io.ErrUnexpectedEOF
+	// if the input ended part way through a JSON value.
+	Err error
+}
+
+func (e *SyntacticError) Error() string {
+	s := "jsontext: " + e.Err.Error()
+	if e.JSONPointer != "" {
+		s += " within " + strconv.Quote(string(e.JSONPointer))
+	}
+	return s + " after offset " + strconv.FormatInt(e.ByteOffset, 10)
+}
+
+func (e *SyntacticError) Unwrap() error {
+	return e.Err
+}
+
+func newInvalidCharError(c byte, context string) error {
+	return errors.New("invalid character " + quoteChar(c) + " " + context)
+}
+
+// quoteChar formats c as a quoted character,
+// as in encoding/json's SyntaxError messages.
+func quoteChar(c byte) string {
+	if c == '\'' {
+		return `'\''`
+	}
+	if c == '"' {
+		return `'"'`
+	}
+	s := strconv.Quote(string(rune(c)))
+	return "'" + s[1:len(s)-1] + "'"
+}
@@ -0,0 +1,82 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsontext_test
+
+import (
+	"encoding/json/jsontext"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+// This example reads a stream of JSON tokens, reporting the location
+// of every number and skipping the values of any "ignore" members.
+func ExampleDecoder() {
+	const input = `{
+		"id": 1,
+		"items": [{"price": 2.5, "tags": ["a", "b"]}, {"price": 10}],
+		"ignore": {"price": 99}
+	}`
+	d := jsontext.NewDecoder(strings.NewReader(input))
+	for {
+		tok, err := d.ReadToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		switch tok.Kind() {
+		case '0':
+			fmt.Printf("%s = %v\n", d.StackPointer(), tok.Float())
+		case '"':
+			if _, n := d.StackIndex(d.StackDepth()); n%2 == 1 && tok.String() == "ignore" {
+				// tok is an object name.
+				if err := d.SkipValue(); err != nil {
+					log.Fatal(err)
+				}
+			}
+		}
+	}
+	// Output:
+	// /id = 1
+	// /items/0/price = 2.5
+	// /items/1/price = 10
+}
+
+// This example writes a JSON array one element at a time.
+func ExampleEncoder() {
+	e := jsontext.NewEncoder(os.Stdout)
+	e.SetIndent("", "  ")
+	e.WriteToken(jsontext.BeginArray)
+	for i := 1; i <= 3; i++ {
+		e.WriteToken(jsontext.BeginObject)
+		e.WriteToken(jsontext.String("n"))
+		e.WriteToken(jsontext.Int(int64(i)))
+		e.WriteToken(jsontext.String("square"))
+		e.WriteValue(jsontext.Value(fmt.Sprint(i * i)))
+		e.WriteToken(jsontext.EndObject)
+	}
+	if err := e.WriteToken(jsontext.EndArray); err != nil {
+		log.Fatal(err)
+	}
+	// Output:
+	// [
+	//   {
+	//     "n": 1,
+	//     "square": 1
+	//   },
+	//   {
+	//     "n": 2,
+	//     "square": 4
+	//   },
+	//   {
+	//     "n": 3,
+	//     "square": 9
+	//   }
+	// ]
+}
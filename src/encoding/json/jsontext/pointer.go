@@ -0,0 +1,92 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsontext
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// A Pointer is a JSON Pointer (RFC 6901) that identifies a value
+// within a JSON document. It is either empty, referring to the whole
+// document, or a sequence of reference tokens each preceded by '/'.
+// Within a reference token, '~' is escaped as "~0" and '/' as "~1".
+//
+// For example, in {"a":[{"b/c":1}]}, the Pointer "/a/0/b~1c"
+// refers to the number 1.
+type Pointer string
+
+// IsValid reports whether p is a syntactically valid JSON Pointer.
+func (p Pointer) IsValid() bool {
+	if p != "" && p[0] != '/' {
+		return false
+	}
+	for i := 0; i < len(p); i++ {
+		if p[i] == '~' && (i+1 == len(p) || (p[i+1] != '0' && p[i+1] != '1')) {
+			return false
+		}
+	}
+	return utf8.ValidString(string(p))
+}
+
+// AppendToken returns p with the unescaped reference token tok added
+// to the end.
+func (p Pointer) AppendToken(tok string) Pointer {
+	return Pointer(appendPointerEscape([]byte(p+"/"), []byte(tok)))
+}
+
+// LastToken returns the last unescaped reference token in p,
+// or "" if p is empty.
+func (p Pointer) LastToken() string {
+	i := strings.LastIndexByte(string(p), '/')
+	if i < 0 {
+		return ""
+	}
+	return pointerUnescape(string(p[i+1:]))
+}
+
+// Parent returns p with the last reference token removed.
+// The parent of the empty Pointer is itself.
+func (p Pointer) Parent() Pointer {
+	i := strings.LastIndexByte(string(p), '/')
+	if i < 0 {
+		return ""
+	}
+	return p[:i]
+}
+
+// Tokens returns the unescaped reference tokens of p.
+func (p Pointer) Tokens() []string {
+	if p == "" {
+		return nil
+	}
+	toks := strings.Split(string(p[1:]), "/")
+	for i, tok := range toks {
+		toks[i] = pointerUnescape(tok)
+	}
+	return toks
+}
+
+func appendPointerEscape(b, tok []byte) []byte {
+	for _, c := range tok {
+		switch c {
+		case '~':
+			b = append(b, '~', '0')
+		case '/':
+			b = append(b, '~', '1')
+		default:
+			b = append(b, c)
+		}
+	}
+	return b
+}
+
+func pointerUnescape(tok string) string {
+	if strings.IndexByte(tok, '~') < 0 {
+		return tok
+	}
+	// "~01" is "~1", not "/", so replace "~1" first.
+	return strings.ReplaceAll(strings.ReplaceAll(tok, "~1", "/"), "~0", "~")
+}
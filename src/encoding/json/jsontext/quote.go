@@ -0,0 +1,290 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsontext
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// errIncomplete is returned by the consume functions when the input
+// ends part way through a token and more input may follow.
+var errIncomplete = errors.New("incomplete token")
+
+// The consume functions below report how many bytes at the start of b
+// make up a token of the given kind. On a syntax error, they return
+// the offset of the offending byte along with the error. If b ends
+// before the token does, they return io.ErrUnexpectedEOF if atEOF is
+// set and errIncomplete otherwise.
+
+func isSpace(c byte) bool {
+	return c <= ' ' && (c == ' ' || c == '\t' || c == '\r' || c == '\n')
+}
+
+func consumeWhitespace(b []byte) int {
+	n := 0
+	for n < len(b) && isSpace(b[n]) {
+		n++
+	}
+	return n
+}
+
+// consumeDelim checks that the byte at b[n], following a literal or
+// number of length n, cannot continue it.
+func consumeDelim(b []byte, n int, atEOF bool, what string) (int, error) {
+	if n == len(b) {
+		if atEOF {
+			return n, nil
+		}
+		return 0, errIncomplete
+	}
+	switch c := b[n]; {
+	case isSpace(c), c == ',', c == ':', c == ']', c == '}':
+		return n, nil
+	default:
+		return n, newInvalidCharError(c, "after "+what)
+	}
+}
+
+func consumeLiteral(b []byte, lit string, atEOF bool) (int, error) {
+	for i := 0; i < len(lit); i++ {
+		if i == len(b) {
+			if atEOF {
+				return i, io.ErrUnexpectedEOF
+			}
+			return 0, errIncomplete
+		}
+		if b[i] != lit[i] {
+			return i, newInvalidCharError(b[i], "in literal "+lit+" (expecting "+strconv.QuoteRune(rune(lit[i]))+")")
+		}
+	}
+	return consumeDelim(b, len(lit), atEOF, "literal "+lit)
+}
+
+// consumeString consumes a JSON string, which must begin at b[0].
+// Scanning starts at offset from, which must have been returned by
+// an earlier call that reported errIncomplete, or be 0; this keeps
+// the cost of reading a long string in many pieces linear.
+func consumeString(b []byte, from int, atEOF bool) (int, error) {
+	i := from
+	if i == 0 {
+		i = 1
+	}
+	for {
+		if i >= len(b) {
+			break
+		}
+		switch c := b[i]; {
+		case c == '"':
+			return i + 1, nil
+		case c < ' ':
+			return i, newInvalidCharError(c, "in string literal")
+		case c != '\\':
+			i++
+			continue
+		}
+		if i+1 >= len(b) {
+			break
+		}
+		switch b[i+1] {
+		case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+			i += 2
+			continue
+		case 'u':
+			for j := i + 2; j < i+6; j++ {
+				if j >= len(b) {
+					if atEOF {
+						return j, io.ErrUnexpectedEOF
+					}
+					return i, errIncomplete
+				}
+				if unhex(b[j]) < 0 {
+					return j, newInvalidCharError(b[j], "in \\u hexadecimal character escape")
+				}
+			}
+			i += 6
+			continue
+		default:
+			return i + 1, newInvalidCharError(b[i+1], "in string escape code")
+		}
+	}
+	if atEOF {
+		return len(b), io.ErrUnexpectedEOF
+	}
+	return i, errIncomplete
+}
+
+// consumeNumber consumes a JSON number, which must begin at b[0].
+func consumeNumber(b []byte, atEOF bool) (int, error) {
+	i := 0
+	digits := func() (int, error) {
+		if i == len(b) {
+			if atEOF {
+				return i, io.ErrUnexpectedEOF
+			}
+			return 0, errIncomplete
+		}
+		if b[i] < '0' || b[i] > '9' {
+			return i, newInvalidCharError(b[i], "in numeric literal")
+		}
+		for i < len(b) && '0' <= b[i] && b[i] <= '9' {
+			i++
+		}
+		return i, nil
+	}
+
+	if b[i] == '-' {
+		i++
+	}
+	if i < len(b) && b[i] == '0' {
+		i++
+	} else if n, err := digits(); err != nil {
+		return n, err
+	}
+	if i < len(b) && b[i] == '.' {
+		i++
+		if n, err := digits(); err != nil {
+			return n, err
+		}
+	}
+	if i < len(b) && (b[i] == 'e' || b[i] == 'E') {
+		i++
+		if i < len(b) && (b[i] == '+' || b[i] == '-') {
+			i++
+		}
+		if n, err := digits(); err != nil {
+			return n, err
+		}
+	}
+	return consumeDelim(b, i, atEOF, "numeric literal")
+}
+
+func unhex(c byte) rune {
+	switch {
+	case '0' <= c && c <= '9':
+		return rune(c - '0')
+	case 'a' <= c && c <= 'f':
+		return rune(c - 'a' + 10)
+	case 'A' <= c && c <= 'F':
+		return rune(c - 'A' + 10)
+	}
+	return -1
+}
+
+func getu4(b []byte) rune {
+	return unhex(b[0])<<12 | unhex(b[1])<<8 | unhex(b[2])<<4 | unhex(b[3])
+}
+
+// appendUnquote appends the contents of the JSON string src,
+// which must be syntactically valid, to dst. As in package
+// encoding/json, invalid UTF-8 and unpaired surrogates are
+// replaced by utf8.RuneError.
+func appendUnquote(dst, src []byte) []byte {
+	src = src[1 : len(src)-1]
+	for len(src) > 0 {
+		c := src[0]
+		switch {
+		case c == '\\':
+			switch src[1] {
+			case 'b':
+				dst = append(dst, '\b')
+			case 'f':
+				dst = append(dst, '\f')
+			case 'n':
+				dst = append(dst, '\n')
+			case 'r':
+				dst = append(dst, '\r')
+			case 't':
+				dst = append(dst, '\t')
+			case 'u':
+				r := getu4(src[2:])
+				src = src[6:]
+				if utf16.IsSurrogate(r) {
+					r2 := utf8.RuneError
+					if len(src) >= 6 && src[0] == '\\' && src[1] == 'u' {
+						r2 = utf16.DecodeRune(r, getu4(src[2:]))
+					}
+					if r2 != utf8.RuneError {
+						src = src[6:]
+					}
+					r = r2
+				}
+				dst = utf8.AppendRune(dst, r)
+				continue
+			default: // '"', '\\' or '/'
+				dst = append(dst, src[1])
+			}
+			src = src[2:]
+		case c < utf8.RuneSelf:
+			dst = append(dst, c)
+			src = src[1:]
+		default:
+			r, size := utf8.DecodeRune(src)
+			if r == utf8.RuneError && size == 1 {
+				dst = utf8.AppendRune(dst, r)
+			} else {
+				dst = append(dst, src[:size]...)
+			}
+			src = src[size:]
+		}
+	}
+	return dst
+}
+
+const hex = "0123456789abcdef"
+
+// appendQuote appends s to dst as a JSON string. As in package
+// encoding/json, invalid UTF-8 is replaced by utf8.RuneError and
+// U+2028 and U+2029 are escaped, but unlike encoding/json's default,
+// HTML characters are not.
+func appendQuote(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			if c >= ' ' && c != '"' && c != '\\' {
+				i++
+				continue
+			}
+			dst = append(dst, s[start:i]...)
+			switch c {
+			case '"', '\\':
+				dst = append(dst, '\\', c)
+			case '\n':
+				dst = append(dst, '\\', 'n')
+			case '\r':
+				dst = append(dst, '\\', 'r')
+			case '\t':
+				dst = append(dst, '\\', 't')
+			default:
+				dst = append(dst, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, `\ufffd`...)
+			i += size
+			start = i
+			continue
+		}
+		if r == '\u2028' || r == '\u2029' {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, '\\', 'u', '2', '0', '2', hex[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	dst = append(dst, s[start:]...)
+	return append(dst, '"')
+}
@@ -0,0 +1,158 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsontext
+
+import (
+	"errors"
+	"strconv"
+)
+
+// state tracks the position of a Decoder or Encoder within the
+// nested objects and arrays of a JSON stream.
+type state struct {
+	stack  []stackEntry
+	topLen int64 // number of top-level values read
+
+	// names holds the most recent name read in each open object,
+	// so that the current position can be reported as a Pointer.
+	names []byte
+}
+
+type stackEntry struct {
+	kind Kind  // '{' or '['
+	len  int64 // number of names and values read in an object, or values in an array
+
+	// names[nameStart:nameEnd] is the most recent name read
+	// in an object.
+	nameStart, nameEnd int
+}
+
+func (s *state) reset() {
+	s.stack = s.stack[:0]
+	s.topLen = 0
+	s.names = s.names[:0]
+}
+
+func (s *state) top() *stackEntry {
+	if len(s.stack) == 0 {
+		return nil
+	}
+	return &s.stack[len(s.stack)-1]
+}
+
+// needName reports whether the next token must be an object name
+// or the end of an object.
+func (s *state) needName() bool {
+	e := s.top()
+	return e != nil && e.kind == kindObject && e.len%2 == 0
+}
+
+// afterName reports whether the next token is an object member value,
+// which must be preceded by a ':'.
+func (s *state) afterName() bool {
+	e := s.top()
+	return e != nil && e.kind == kindObject && e.len%2 == 1
+}
+
+// needComma reports whether the next value or name, if any,
+// must be preceded by a ','.
+func (s *state) needComma() bool {
+	e := s.top()
+	return e != nil && e.len > 0 && !(e.kind == kindObject && e.len%2 == 1)
+}
+
+var (
+	errUnmatchedEnd   = errors.New("unmatched end of object or array")
+	errMismatchedEnd  = errors.New("mismatched end of object or array")
+	errMissingValue   = errors.New("missing value after object name")
+	errNonStringName  = errors.New("object name must be a string")
+	errInvalidToken   = errors.New("invalid token")
+	errNonFiniteFloat = errors.New("number must be finite")
+)
+
+// check reports whether a token of kind k may come next.
+func (s *state) check(k Kind) error {
+	switch k {
+	case kindObjEnd, kindArrEnd:
+		e := s.top()
+		switch {
+		case e == nil:
+			return errUnmatchedEnd
+		case e.kind+2 != k: // '{'+2 == '}' and '['+2 == ']'
+			return errMismatchedEnd
+		case s.afterName():
+			return errMissingValue
+		}
+	case invalidKind:
+		return errInvalidToken
+	default:
+		if k != kindString && s.needName() {
+			return errNonStringName
+		}
+	}
+	return nil
+}
+
+// push records that a token of kind k has been read.
+func (s *state) push(k Kind) {
+	switch k {
+	case kindObjEnd, kindArrEnd:
+		e := s.top()
+		s.names = s.names[:e.nameStart]
+		s.stack = s.stack[:len(s.stack)-1]
+		return
+	}
+	if e := s.top(); e != nil {
+		e.len++
+	} else {
+		s.topLen++
+	}
+	if k == kindObject || k == kindArray {
+		n := len(s.names)
+		s.stack = append(s.stack, stackEntry{kind: k, nameStart: n, nameEnd: n})
+	}
+}
+
+// setName records the quoted JSON string raw as the most recent
+// name in the current object.
+func (s *state) setName(raw []byte) {
+	e := s.top()
+	s.names = appendUnquote(s.names[:e.nameStart], raw)
+	e.nameEnd = len(s.names)
+}
+
+// setNameString is like setName, but name is unescaped.
+func (s *state) setNameString(name string) {
+	e := s.top()
+	s.names = append(s.names[:e.nameStart], name...)
+	e.nameEnd = len(s.names)
+}
+
+func (s *state) index(i int) (Kind, int64) {
+	if i == 0 {
+		return invalidKind, s.topLen
+	}
+	e := &s.stack[i-1]
+	return e.kind, e.len
+}
+
+// pointer returns a Pointer to the most recently read value,
+// or to the member whose name was most recently read.
+func (s *state) pointer() Pointer {
+	var b []byte
+	for i := range s.stack {
+		e := &s.stack[i]
+		if e.len == 0 {
+			break
+		}
+		b = append(b, '/')
+		if e.kind == kindArray {
+			b = strconv.AppendInt(b, e.len-1, 10)
+		} else {
+			b = appendPointerEscape(b, s.names[e.nameStart:e.nameEnd])
+		}
+	}
+	return Pointer(b)
+}
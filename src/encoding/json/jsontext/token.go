@@ -0,0 +1,367 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsontext
+
+import (
+	"math"
+	"strconv"
+)
+
+// A Kind is the kind of a JSON token or value.
+// It is represented by the first byte of the token's JSON text,
+// except that all numbers have the kind '0'.
+type Kind byte
+
+const (
+	invalidKind Kind = 0
+	kindNull    Kind = 'n'
+	kindFalse   Kind = 'f'
+	kindTrue    Kind = 't'
+	kindString  Kind = '"'
+	kindNumber  Kind = '0'
+	kindObject  Kind = '{'
+	kindObjEnd  Kind = '}'
+	kindArray   Kind = '['
+	kindArrEnd  Kind = ']'
+)
+
+// String returns a description of the kind, such as "string" or "{".
+func (k Kind) String() string {
+	switch k {
+	case kindNull:
+		return "null"
+	case kindFalse:
+		return "false"
+	case kindTrue:
+		return "true"
+	case kindString:
+		return "string"
+	case kindNumber:
+		return "number"
+	case kindObject:
+		return "{"
+	case kindObjEnd:
+		return "}"
+	case kindArray:
+		return "["
+	case kindArrEnd:
+		return "]"
+	}
+	return "<invalid jsontext.Kind: " + strconv.QuoteRune(rune(k)) + ">"
+}
+
+// kindOf returns the kind of the JSON value beginning with c.
+func kindOf(c byte) Kind {
+	switch c {
+	case 'n', 'f', 't', '"', '{', '}', '[', ']':
+		return Kind(c)
+	case '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		return kindNumber
+	}
+	return invalidKind
+}
+
+// A Token is a single JSON token: a literal, string, number,
+// or the start or end of an object or array.
+// The zero Token is invalid.
+//
+// A Token read by a Decoder refers to the Decoder's buffer and is only
+// valid until the next call to one of the Decoder's methods.
+type Token struct {
+	// raw is the JSON text of a string or number read by a Decoder.
+	// If raw is nil, the token was created by one of the functions
+	// below and its value is held in str or num.
+	raw  []byte
+	str  string
+	num  uint64 // bits of a float64, int64 or uint64, according to nk
+	nk   byte   // 'f', 'i' or 'u'
+	kind Kind
+}
+
+// Tokens for the JSON literals and for the delimiters
+// at the start and end of objects and arrays.
+var (
+	Null        = Token{kind: kindNull}
+	False       = Token{kind: kindFalse}
+	True        = Token{kind: kindTrue}
+	BeginObject = Token{kind: kindObject}
+	EndObject   = Token{kind: kindObjEnd}
+	BeginArray  = Token{kind: kindArray}
+	EndArray    = Token{kind: kindArrEnd}
+)
+
+// Bool returns True if b is true and False otherwise.
+func Bool(b bool) Token {
+	if b {
+		return True
+	}
+	return False
+}
+
+// String returns a Token for the JSON string s.
+func String(s string) Token {
+	return Token{kind: kindString, str: s}
+}
+
+// Float returns a Token for the JSON number f.
+// Encoding the token fails if f is NaN or infinite.
+func Float(f float64) Token {
+	return Token{kind: kindNumber, nk: 'f', num: math.Float64bits(f)}
+}
+
+// Int returns a Token for the JSON number n.
+func Int(n int64) Token {
+	return Token{kind: kindNumber, nk: 'i', num: uint64(n)}
+}
+
+// Uint returns a Token for the JSON number n.
+func Uint(n uint64) Token {
+	return Token{kind: kindNumber, nk: 'u', num: n}
+}
+
+// Kind returns the kind of the token, or 0 if the token is invalid.
+func (t Token) Kind() Kind {
+	return t.kind
+}
+
+// Clone returns a copy of t that does not refer to the buffer
+// of the Decoder t was read from.
+func (t Token) Clone() Token {
+	if t.raw != nil {
+		t.raw = append([]byte(nil), t.raw...)
+	}
+	return t
+}
+
+// Bool returns the value of a JSON true or false token.
+// It panics if the token is not a boolean.
+func (t Token) Bool() bool {
+	switch t.kind {
+	case kindTrue:
+		return true
+	case kindFalse:
+		return false
+	}
+	panic("jsontext: Token.Bool called on " + t.kind.String() + " token")
+}
+
+// String returns the unescaped contents of a JSON string token.
+// For other tokens, it returns the token's JSON text, or
+// "<invalid jsontext.Token>" for the zero Token.
+//
+// Unlike other Token methods, String does not panic,
+// so that Token implements fmt.Stringer.
+func (t Token) String() string {
+	switch t.kind {
+	case kindString:
+		if t.raw == nil {
+			return t.str
+		}
+		return string(appendUnquote(nil, t.raw))
+	case kindNumber:
+		if t.raw == nil {
+			return string(t.appendNumber(nil))
+		}
+		return string(t.raw)
+	case invalidKind:
+		return "<invalid jsontext.Token>"
+	}
+	return t.kind.String()
+}
+
+// Float returns the value of a JSON number token as a float64.
+// A number too large to be represented is rounded to ±Inf.
+// It panics if the token is not a number.
+func (t Token) Float() float64 {
+	t.mustBeNumber("Float")
+	if t.raw != nil {
+		if mant, exp, neg, ok := parseSimple(t.raw); ok && mant < 1<<53 && -22 <= exp && exp <= 22 {
+			// Both mant and 10^|exp| are exact float64s,
+			// so a single operation rounds correctly.
+			f := float64(mant)
+			if exp > 0 {
+				f *= float64pow10[exp]
+			} else if exp < 0 {
+				f /= float64pow10[-exp]
+			}
+			if neg {
+				f = -f
+			}
+			return f
+		}
+		// A syntactically valid number only fails to parse if
+		// it is out of range, for which ParseFloat returns ±Inf.
+		f, _ := strconv.ParseFloat(string(t.raw), 64)
+		return f
+	}
+	switch t.nk {
+	case 'i':
+		return float64(int64(t.num))
+	case 'u':
+		return float64(t.num)
+	}
+	return math.Float64frombits(t.num)
+}
+
+// Int returns the value of a JSON number token as an int64.
+// A fractional number is truncated toward zero, and a number
+// out of range is clamped to the minimum or maximum int64.
+// It panics if the token is not a number.
+func (t Token) Int() int64 {
+	t.mustBeNumber("Int")
+	if t.raw != nil {
+		if mant, exp, neg, ok := parseSimple(t.raw); ok && exp == 0 && mant <= math.MaxInt64 {
+			if neg {
+				return -int64(mant)
+			}
+			return int64(mant)
+		}
+	} else {
+		switch t.nk {
+		case 'i':
+			return int64(t.num)
+		case 'u':
+			if t.num > math.MaxInt64 {
+				return math.MaxInt64
+			}
+			return int64(t.num)
+		}
+	}
+	switch f := t.Float(); {
+	case f >= math.MaxInt64:
+		return math.MaxInt64
+	case f <= math.MinInt64:
+		return math.MinInt64
+	case f != f: // NaN
+		return 0
+	default:
+		return int64(f)
+	}
+}
+
+// Uint returns the value of a JSON number token as a uint64.
+// A fractional number is truncated toward zero, and a number
+// out of range is clamped to zero or the maximum uint64.
+// It panics if the token is not a number.
+func (t Token) Uint() uint64 {
+	t.mustBeNumber("Uint")
+	if t.raw != nil {
+		if mant, exp, neg, ok := parseSimple(t.raw); ok && exp == 0 {
+			if neg {
+				return 0
+			}
+			return mant
+		}
+	} else {
+		switch t.nk {
+		case 'i':
+			if int64(t.num) < 0 {
+				return 0
+			}
+			return t.num
+		case 'u':
+			return t.num
+		}
+	}
+	switch f := t.Float(); {
+	case f >= math.MaxUint64:
+		return math.MaxUint64
+	case f <= 0 || f != f:
+		return 0
+	default:
+		return uint64(f)
+	}
+}
+
+func (t Token) mustBeNumber(method string) {
+	if t.kind != kindNumber {
+		panic("jsontext: Token." + method + " called on " + t.kind.String() + " token")
+	}
+}
+
+var float64pow10 = [...]float64{
+	1e0, 1e1, 1e2, 1e3, 1e4, 1e5, 1e6, 1e7, 1e8, 1e9, 1e10,
+	1e11, 1e12, 1e13, 1e14, 1e15, 1e16, 1e17, 1e18, 1e19, 1e20, 1e21, 1e22,
+}
+
+// parseSimple parses the valid JSON number b as ±mant×10^exp, without
+// allocating. It reports !ok if b has more than 19 significant digits
+// or a large exponent, leaving such numbers to package strconv.
+func parseSimple(b []byte) (mant uint64, exp int, neg, ok bool) {
+	i := 0
+	if b[0] == '-' {
+		neg = true
+		i++
+	}
+	digits := 0
+	for ; i < len(b) && '0' <= b[i] && b[i] <= '9'; i++ {
+		if digits++; digits > 19 {
+			return 0, 0, false, false
+		}
+		mant = mant*10 + uint64(b[i]-'0')
+	}
+	if i < len(b) && b[i] == '.' {
+		for i++; i < len(b) && '0' <= b[i] && b[i] <= '9'; i++ {
+			if digits++; digits > 19 {
+				return 0, 0, false, false
+			}
+			mant = mant*10 + uint64(b[i]-'0')
+			exp--
+		}
+	}
+	if i < len(b) {
+		// An exponent.
+		i++
+		esign := 1
+		if b[i] == '+' || b[i] == '-' {
+			if b[i] == '-' {
+				esign = -1
+			}
+			i++
+		}
+		e := 0
+		for ; i < len(b); i++ {
+			if e = e*10 + int(b[i]-'0'); e > 1000 {
+				return 0, 0, false, false
+			}
+		}
+		exp += esign * e
+	}
+	return mant, exp, neg, true
+}
+
+// appendNumber appends the JSON text of a number token created by
+// Float, Int or Uint to b. The number must not be NaN or infinite.
+func (t Token) appendNumber(b []byte) []byte {
+	switch t.nk {
+	case 'i':
+		return strconv.AppendInt(b, int64(t.num), 10)
+	case 'u':
+		return strconv.AppendUint(b, t.num, 10)
+	}
+	return appendFloat(b, math.Float64frombits(t.num))
+}
+
+// appendFloat appends the JSON text of f to b,
+// formatted as by package encoding/json.
+func appendFloat(b []byte, f float64) []byte {
+	// Convert as if by ES6 number to string conversion.
+	// See encoding/json's floatEncoder for details.
+	abs := math.Abs(f)
+	fmt := byte('f')
+	if abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		fmt = 'e'
+	}
+	b = strconv.AppendFloat(b, f, fmt, -1, 64)
+	if fmt == 'e' {
+		// clean up e-09 to e-9
+		n := len(b)
+		if n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	return b
+}
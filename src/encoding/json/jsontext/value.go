@@ -0,0 +1,51 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsontext
+
+import "io"
+
+// A Value is the raw JSON text of a single JSON value.
+//
+// A Value read by a Decoder refers to the Decoder's buffer and is only
+// valid until the next call to one of the Decoder's methods.
+type Value []byte
+
+// Clone returns a copy of v.
+func (v Value) Clone() Value {
+	if v == nil {
+		return nil
+	}
+	return append(Value{}, v...)
+}
+
+// String returns the JSON text of v.
+func (v Value) String() string {
+	return string(v)
+}
+
+// IsValid reports whether v is a single well-formed JSON value,
+// optionally surrounded by whitespace.
+func (v Value) IsValid() bool {
+	d := newBytesDecoder(v)
+	if _, err := d.ReadValue(); err != nil {
+		return false
+	}
+	return d.prepare() == io.EOF
+}
+
+// Kind returns the kind of the value, or 0 if v does not begin
+// with a JSON value. It does not check that v is valid.
+func (v Value) Kind() Kind {
+	v = v[consumeWhitespace(v):]
+	if len(v) == 0 {
+		return invalidKind
+	}
+	switch k := kindOf(v[0]); k {
+	case kindObjEnd, kindArrEnd:
+		return invalidKind
+	default:
+		return k
+	}
+}
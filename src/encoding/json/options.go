@@ -0,0 +1,67 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package json
+
+// Options configure a single call to Marshal or Unmarshal.
+// The zero Options behave exactly like the package-level
+// Marshal and Unmarshal functions.
+type Options struct {
+	// CaseSensitive makes Unmarshal match object keys to struct fields
+	// only if they are equal to the field's name, instead of also
+	// accepting a case-insensitive match. Fields with the "case:ignore"
+	// tag option still accept a case-insensitive match.
+	CaseSensitive bool
+
+	// RejectDuplicateNames makes Unmarshal report an error for an object
+	// that has the same key more than once, or that has several keys
+	// matching the same struct field. It also makes Marshal report an
+	// error if a struct's inlined map has a key that is also the name
+	// of one of the struct's fields, instead of omitting that entry.
+	RejectDuplicateNames bool
+
+	// DisallowUnknownFields makes Unmarshal report an error when the
+	// destination is a struct and the input contains object keys which
+	// do not match any non-ignored, exported field in the destination,
+	// and the struct has no inlined map field, as for
+	// Decoder.DisallowUnknownFields.
+	DisallowUnknownFields bool
+
+	// UseNumber makes Unmarshal unmarshal a number into an interface{}
+	// as a Number instead of as a float64, as for Decoder.UseNumber.
+	UseNumber bool
+}
+
+// Marshal is like the package-level Marshal function,
+// but configured by o.
+func (o Options) Marshal(v any) ([]byte, error) {
+	e := newEncodeState()
+
+	err := e.marshal(v, encOpts{escapeHTML: true, rejectDuplicateNames: o.RejectDuplicateNames})
+	if err != nil {
+		return nil, err
+	}
+	buf := append([]byte(nil), e.Bytes()...)
+
+	encodeStatePool.Put(e)
+
+	return buf, nil
+}
+
+// Unmarshal is like the package-level Unmarshal function,
+// but configured by o.
+func (o Options) Unmarshal(data []byte, v any) error {
+	var d decodeState
+	err := checkValid(data, &d.scan)
+	if err != nil {
+		return err
+	}
+
+	d.init(data)
+	d.caseSensitive = o.CaseSensitive
+	d.rejectDuplicateNames = o.RejectDuplicateNames
+	d.disallowUnknownFields = o.DisallowUnknownFields
+	d.useNumber = o.UseNumber
+	return d.unmarshal(v)
+}
@@ -0,0 +1,98 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package json
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type caseFields struct {
+	Name   string
+	Strict string `json:"strict,case:strict"`
+	Ignore string `json:"ignore,case:ignore"`
+}
+
+func TestOptionsCaseSensitive(t *testing.T) {
+	const in = `{"NAME":"a","STRICT":"b","IGNORE":"c"}`
+	tests := []struct {
+		opts Options
+		want caseFields
+	}{
+		{Options{}, caseFields{Name: "a", Ignore: "c"}},
+		{Options{CaseSensitive: true}, caseFields{Ignore: "c"}},
+	}
+	for _, tt := range tests {
+		var got caseFields
+		if err := tt.opts.Unmarshal([]byte(in), &got); err != nil {
+			t.Fatalf("%+v: Unmarshal: %v", tt.opts, err)
+		}
+		if got != tt.want {
+			t.Errorf("%+v: Unmarshal = %+v, want %+v", tt.opts, got, tt.want)
+		}
+	}
+
+	// Exact matches work regardless.
+	var got caseFields
+	opts := Options{CaseSensitive: true}
+	if err := opts.Unmarshal([]byte(`{"Name":"a","strict":"b","ignore":"c"}`), &got); err != nil {
+		t.Fatal(err)
+	}
+	if want := (caseFields{"a", "b", "c"}); got != want {
+		t.Errorf("Unmarshal = %+v, want %+v", got, want)
+	}
+}
+
+func TestOptionsRejectDuplicateNames(t *testing.T) {
+	type S struct {
+		A int
+		M map[string]int `json:",inline"`
+	}
+	tests := []struct {
+		in  string
+		ptr any
+		dup string // duplicate name reported, if any
+	}{
+		{`{"A":1,"B":2}`, new(S), ""},
+		{`{"A":1,"A":2}`, new(S), "A"},
+		{`{"A":1,"a":2}`, new(S), "a"},
+		{`{"B":1,"B":2}`, new(S), "B"},
+		{`{"B":1,"b":2}`, new(S), ""},
+		{`{"a":1,"b":{"a":1}}`, new(map[string]any), ""},
+		{`{"a":1,"b":{"a":1,"a":2}}`, new(map[string]any), "a"},
+		{`{"a":1,"a":2}`, new(map[string]int), "a"},
+		{`[{"a":1},{"a":1}]`, new(any), ""},
+		{`[{"a":1},{"a":1,"a":1}]`, new(any), "a"},
+	}
+	for _, tt := range tests {
+		// Without the option, duplicates are accepted.
+		v := reflect.New(reflect.TypeOf(tt.ptr).Elem()).Interface()
+		if err := Unmarshal([]byte(tt.in), v); err != nil {
+			t.Errorf("Unmarshal(%#q): %v", tt.in, err)
+		}
+
+		err := Options{RejectDuplicateNames: true}.Unmarshal([]byte(tt.in), tt.ptr)
+		switch {
+		case tt.dup == "" && err != nil:
+			t.Errorf("Unmarshal(%#q): %v", tt.in, err)
+		case tt.dup != "" && (err == nil || !strings.Contains(err.Error(), `duplicate object name "`+tt.dup+`"`)):
+			t.Errorf("Unmarshal(%#q): error = %v, want duplicate object name %q", tt.in, err, tt.dup)
+		}
+	}
+}
+
+func TestOptionsUnmarshal(t *testing.T) {
+	var v struct {
+		N any
+	}
+	err := Options{UseNumber: true, DisallowUnknownFields: true}.Unmarshal([]byte(`{"N":1.5,"X":1}`), &v)
+	if err == nil || err.Error() != `json: unknown field "X"` {
+		t.Errorf("Unmarshal: error = %v, want unknown field error", err)
+	}
+	if v.N != Number("1.5") {
+		t.Errorf("N = %#v, want Number(\"1.5\")", v.N)
+	}
+}
@@ -178,6 +178,8 @@ func nonSpace(b []byte) bool {
 }
 
 // An Encoder writes JSON values to an output stream.
+// To write a value a token at a time, use the Encoder
+// in package encoding/json/jsontext.
 type Encoder struct {
 	w          io.Writer
 	err        error
@@ -367,6 +369,10 @@ func (d Delim) String() string {
 // number, and null—along with delimiters [ ] { } of type Delim
 // to mark the start and end of arrays and objects.
 // Commas and colons are elided.
+//
+// Token allocates for most tokens and does not report the location of
+// the token within the input. Package encoding/json/jsontext provides
+// a lower-level tokenizer that does neither.
 func (dec *Decoder) Token() (Token, error) {
 	for {
 		c, err := dec.peek()
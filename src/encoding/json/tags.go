@@ -36,3 +36,17 @@ func (o tagOptions) Contains(optionName string) bool {
 	}
 	return false
 }
+
+// Get returns the value of the first option of the form name:value,
+// and whether there is such an option.
+func (o tagOptions) Get(name string) (string, bool) {
+	s := string(o)
+	for s != "" {
+		var opt string
+		opt, s, _ = strings.Cut(s, ",")
+		if k, v, ok := strings.Cut(opt, ":"); ok && k == name {
+			return v, true
+		}
+	}
+	return "", false
+}
@@ -26,3 +26,21 @@ func TestTagParsing(t *testing.T) {
 		}
 	}
 }
+
+func TestTagGet(t *testing.T) {
+	_, opts := parseTag("field,omitempty,format:2006-01-02,case:strict,format:unix")
+	for _, tt := range []struct {
+		name  string
+		value string
+		ok    bool
+	}{
+		{"format", "2006-01-02", true},
+		{"case", "strict", true},
+		{"omitempty", "", false},
+		{"field", "", false},
+	} {
+		if v, ok := opts.Get(tt.name); v != tt.value || ok != tt.ok {
+			t.Errorf("Get(%q) = %q, %v, want %q, %v", tt.name, v, ok, tt.value, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,345 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package coverage defines the format of the coverage data files
+// written by programs built with "go build -cover" and read by
+// "go tool covdata".
+//
+// A program writes two kinds of files to the directory named by
+// $GOCOVERDIR. A meta-data file, named covmeta.<hash>, describes the
+// instrumented source files and their basic blocks. It depends only on
+// the program, so all runs of a program share one meta-data file.
+// A counter data file, named covcounters.<hash>.<pid>.<nanotime>,
+// holds the execution counts of the blocks for a single run.
+// In both names, <hash> is the hash of the meta-data file's contents,
+// which ties counter data files to the meta-data file describing them.
+//
+// Both kinds of file begin with a four-byte magic number and a version
+// number. The remaining fields are unsigned varints; a string is
+// encoded as its length followed by its bytes.
+package coverage
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// File name prefixes of meta-data and counter data files.
+const (
+	MetaFilePref    = "covmeta"
+	CounterFilePref = "covcounters"
+)
+
+const version = 1
+
+var (
+	metaMagic    = [4]byte{0x00, 'c', 'v', 'm'}
+	counterMagic = [4]byte{0x00, 'c', 'v', 'c'}
+)
+
+// A Block is a basic block of an instrumented source file.
+type Block struct {
+	StartLine, StartCol uint32
+	EndLine, EndCol     uint32
+	NumStmt             uint16
+}
+
+// A FileMeta describes the basic blocks of an instrumented source file.
+type FileMeta struct {
+	PkgPath string // import path of the file's package
+	Name    string // file name as it appears in coverage profiles
+	Blocks  []Block
+}
+
+// Meta is the contents of a meta-data file.
+type Meta struct {
+	Mode  string // "set", "count", or "atomic"
+	Files []FileMeta
+}
+
+// Counters is the contents of a counter data file.
+// Files[i][j] is the count of block j of the i'th file in the
+// meta-data file with hash MetaHash.
+type Counters struct {
+	MetaHash Hash
+	Files    [][]uint32
+}
+
+// A Hash identifies a meta-data file.
+type Hash [8]byte
+
+func (h Hash) String() string {
+	return fmt.Sprintf("%x", h[:])
+}
+
+// MetaFileName returns the name of the meta-data file with hash h.
+func MetaFileName(h Hash) string {
+	return MetaFilePref + "." + h.String()
+}
+
+// CounterFileName returns the name of the counter data file for a run
+// of the program whose meta-data file has hash h.
+func CounterFileName(h Hash, pid int, nanotime int64) string {
+	return fmt.Sprintf("%s.%s.%d.%d", CounterFilePref, h, pid, nanotime)
+}
+
+// Encode returns the encoded form of m.
+func (m *Meta) Encode() []byte {
+	var e encoder
+	e.header(metaMagic)
+	e.string(m.Mode)
+	e.uvarint(uint64(len(m.Files)))
+	for _, f := range m.Files {
+		e.string(f.PkgPath)
+		e.string(f.Name)
+		e.uvarint(uint64(len(f.Blocks)))
+		for _, b := range f.Blocks {
+			e.uvarint(uint64(b.StartLine))
+			e.uvarint(uint64(b.StartCol))
+			e.uvarint(uint64(b.EndLine))
+			e.uvarint(uint64(b.EndCol))
+			e.uvarint(uint64(b.NumStmt))
+		}
+	}
+	return e.buf
+}
+
+// Hash returns the hash of the encoded form of m.
+func (m *Meta) Hash() Hash {
+	return hashOf(m.Encode())
+}
+
+func hashOf(data []byte) Hash {
+	var h Hash
+	f := fnv.New64a()
+	f.Write(data)
+	copy(h[:], f.Sum(nil))
+	return h
+}
+
+// DecodeMeta decodes the contents of a meta-data file.
+func DecodeMeta(data []byte) (*Meta, error) {
+	d := decoder{buf: data}
+	d.header(metaMagic)
+	m := &Meta{Mode: d.string()}
+	n := d.count()
+	for i := 0; i < n && d.err == nil; i++ {
+		f := FileMeta{PkgPath: d.string(), Name: d.string()}
+		nb := d.count()
+		for j := 0; j < nb && d.err == nil; j++ {
+			f.Blocks = append(f.Blocks, Block{
+				StartLine: d.uint32(),
+				StartCol:  d.uint32(),
+				EndLine:   d.uint32(),
+				EndCol:    d.uint32(),
+				NumStmt:   uint16(d.uint32()),
+			})
+		}
+		m.Files = append(m.Files, f)
+	}
+	if err := d.finish(); err != nil {
+		return nil, fmt.Errorf("decoding meta-data: %v", err)
+	}
+	return m, nil
+}
+
+// Encode returns the encoded form of c.
+func (c *Counters) Encode() []byte {
+	var e encoder
+	e.header(counterMagic)
+	e.buf = append(e.buf, c.MetaHash[:]...)
+	e.uvarint(uint64(len(c.Files)))
+	for _, f := range c.Files {
+		e.uvarint(uint64(len(f)))
+		for _, v := range f {
+			e.uvarint(uint64(v))
+		}
+	}
+	return e.buf
+}
+
+// DecodeCounters decodes the contents of a counter data file.
+func DecodeCounters(data []byte) (*Counters, error) {
+	d := decoder{buf: data}
+	d.header(counterMagic)
+	c := new(Counters)
+	if d.err == nil {
+		if len(d.buf) < len(c.MetaHash) {
+			d.err = errors.New("truncated data")
+		} else {
+			copy(c.MetaHash[:], d.buf)
+			d.buf = d.buf[len(c.MetaHash):]
+		}
+	}
+	n := d.count()
+	for i := 0; i < n && d.err == nil; i++ {
+		f := make([]uint32, d.count())
+		for j := range f {
+			f[j] = d.uint32()
+		}
+		c.Files = append(c.Files, f)
+	}
+	if err := d.finish(); err != nil {
+		return nil, fmt.Errorf("decoding counter data: %v", err)
+	}
+	return c, nil
+}
+
+// Check reports an error if c cannot hold the counters of m.
+func (c *Counters) Check(m *Meta) error {
+	if len(c.Files) != len(m.Files) {
+		return fmt.Errorf("counter data has %d files, meta-data has %d", len(c.Files), len(m.Files))
+	}
+	for i, f := range c.Files {
+		if len(f) != len(m.Files[i].Blocks) {
+			return fmt.Errorf("counter data has %d blocks for %s, meta-data has %d", len(f), m.Files[i].Name, len(m.Files[i].Blocks))
+		}
+	}
+	return nil
+}
+
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) header(magic [4]byte) {
+	e.buf = append(e.buf, magic[:]...)
+	e.uvarint(version)
+}
+
+func (e *encoder) uvarint(v uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	e.buf = append(e.buf, tmp[:n]...)
+}
+
+func (e *encoder) string(s string) {
+	e.uvarint(uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) header(magic [4]byte) {
+	if len(d.buf) < len(magic) || string(d.buf[:len(magic)]) != string(magic[:]) {
+		d.err = errors.New("bad magic number")
+		return
+	}
+	d.buf = d.buf[len(magic):]
+	if v := d.uvarint(); d.err == nil && v != version {
+		d.err = fmt.Errorf("unsupported version %d", v)
+	}
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.err = errors.New("truncated data")
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *decoder) uint32() uint32 {
+	v := d.uvarint()
+	if v > 1<<32-1 && d.err == nil {
+		d.err = errors.New("value out of range")
+	}
+	return uint32(v)
+}
+
+// count decodes a length, which must not exceed the amount of data left.
+func (d *decoder) count() int {
+	v := d.uvarint()
+	if v > uint64(len(d.buf)) && d.err == nil {
+		d.err = errors.New("truncated data")
+		return 0
+	}
+	return int(v)
+}
+
+func (d *decoder) string() string {
+	n := d.count()
+	if d.err != nil {
+		return ""
+	}
+	s := string(d.buf[:n])
+	d.buf = d.buf[n:]
+	return s
+}
+
+func (d *decoder) finish() error {
+	if d.err == nil && len(d.buf) != 0 {
+		d.err = errors.New("trailing data")
+	}
+	return d.err
+}
+
+// A Pod is a meta-data file together with the counter data files
+// that refer to it.
+type Pod struct {
+	MetaFile     string
+	CounterFiles []string
+}
+
+// CollectPods reads the given directories and groups the coverage
+// data files found there into pods, sorted by meta-data file name.
+// Identical meta-data files in different directories are treated as
+// one; any of their paths may be used for the pod. Counter data files
+// without a matching meta-data file are reported as an error.
+func CollectPods(dirs []string) ([]Pod, error) {
+	metas := make(map[string]string)      // hash -> meta-data file
+	counters := make(map[string][]string) // hash -> counter data files
+	for _, dir := range dirs {
+		ents, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, ent := range ents {
+			if ent.IsDir() {
+				continue
+			}
+			name := ent.Name()
+			path := filepath.Join(dir, name)
+			switch {
+			case strings.HasPrefix(name, MetaFilePref+"."):
+				h := strings.TrimPrefix(name, MetaFilePref+".")
+				if _, seen := metas[h]; !seen {
+					metas[h] = path
+				}
+			case strings.HasPrefix(name, CounterFilePref+"."):
+				h, _, _ := strings.Cut(strings.TrimPrefix(name, CounterFilePref+"."), ".")
+				counters[h] = append(counters[h], path)
+			}
+		}
+	}
+	for h, files := range counters {
+		if _, ok := metas[h]; !ok {
+			return nil, fmt.Errorf("no meta-data file for counter data file %s", files[0])
+		}
+	}
+	var pods []Pod
+	for h, meta := range metas {
+		files := counters[h]
+		sort.Strings(files)
+		pods = append(pods, Pod{MetaFile: meta, CounterFiles: files})
+	}
+	sort.Slice(pods, func(i, j int) bool {
+		return filepath.Base(pods[i].MetaFile) < filepath.Base(pods[j].MetaFile)
+	})
+	return pods, nil
+}
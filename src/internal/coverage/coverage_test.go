@@ -0,0 +1,120 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package coverage
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var testMeta = &Meta{
+	Mode: "count",
+	Files: []FileMeta{
+		{
+			PkgPath: "example.com/p",
+			Name:    "example.com/p/p.go",
+			Blocks: []Block{
+				{3, 19, 4, 11, 1},
+				{4, 11, 6, 3, 2},
+				{1 << 20, 1, 1<<20 + 1, 200, 1<<16 - 1},
+			},
+		},
+		{
+			PkgPath: "example.com/q",
+			Name:    "/abs/path/q.go",
+		},
+	},
+}
+
+func TestMetaRoundTrip(t *testing.T) {
+	data := testMeta.Encode()
+	m, err := DecodeMeta(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m, testMeta) {
+		t.Errorf("DecodeMeta(Encode(m)) = %+v, want %+v", m, testMeta)
+	}
+	if m.Hash() != testMeta.Hash() {
+		t.Errorf("hash changed after round trip")
+	}
+
+	for i := range data {
+		if _, err := DecodeMeta(data[:i]); err == nil {
+			t.Errorf("DecodeMeta of %d of %d bytes succeeded", i, len(data))
+		}
+	}
+	if _, err := DecodeMeta(append(data, 0)); err == nil {
+		t.Errorf("DecodeMeta with trailing data succeeded")
+	}
+}
+
+func TestCountersRoundTrip(t *testing.T) {
+	c := &Counters{
+		MetaHash: testMeta.Hash(),
+		Files:    [][]uint32{{1, 0, 1<<32 - 1}, {}},
+	}
+	data := c.Encode()
+	c2, err := DecodeCounters(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c2, c) {
+		t.Errorf("DecodeCounters(Encode(c)) = %+v, want %+v", c2, c)
+	}
+	if err := c2.Check(testMeta); err != nil {
+		t.Errorf("Check: %v", err)
+	}
+	c2.Files[0] = c2.Files[0][:2]
+	if err := c2.Check(testMeta); err == nil {
+		t.Errorf("Check succeeded with missing counter")
+	}
+
+	for i := range data {
+		if _, err := DecodeCounters(data[:i]); err == nil {
+			t.Errorf("DecodeCounters of %d of %d bytes succeeded", i, len(data))
+		}
+	}
+	if _, err := DecodeMeta(data); err == nil {
+		t.Errorf("DecodeMeta of counter data succeeded")
+	}
+}
+
+func TestCollectPods(t *testing.T) {
+	h1, h2 := Hash{1}, Hash{2}
+	dir1, dir2 := t.TempDir(), t.TempDir()
+	write := func(dir, name string) string {
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, nil, 0666); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+	m1 := write(dir1, MetaFileName(h1))
+	write(dir2, MetaFileName(h1))
+	m2 := write(dir2, MetaFileName(h2))
+	c1 := write(dir1, CounterFileName(h1, 1, 10))
+	c2 := write(dir2, CounterFileName(h1, 2, 20))
+	write(dir1, "unrelated")
+
+	pods, err := CollectPods([]string{dir1, dir2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Pod{
+		{MetaFile: m1, CounterFiles: []string{c1, c2}},
+		{MetaFile: m2},
+	}
+	if !reflect.DeepEqual(pods, want) {
+		t.Errorf("CollectPods = %v, want %v", pods, want)
+	}
+
+	write(dir2, CounterFileName(Hash{3}, 3, 30))
+	if _, err := CollectPods([]string{dir1, dir2}); err == nil {
+		t.Errorf("CollectPods succeeded with orphaned counter data file")
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package rtcov holds the coverage counters of a program built with
+// "go build -cover". The go command generates code in package main
+// that registers the counters of each instrumented source file here,
+// and package runtime/coverage reads them to write coverage data files.
+//
+// Package rtcov must not import any other package, so that it can be
+// linked into any program.
+package rtcov
+
+// A File describes the coverage counters of one instrumented source
+// file. Counters, Pos and NumStmt are the Count, Pos and NumStmt
+// arrays of the variable that cmd/cover declares for the file.
+type File struct {
+	PkgPath  string   // import path of the file's package
+	Name     string   // file name as it appears in coverage profiles
+	Counters []uint32 // one counter per basic block
+	Pos      []uint32 // start line, end line and packed columns of each block
+	NumStmt  []uint16 // number of statements in each block
+}
+
+// Mode is the coverage mode the program was built with:
+// "set", "count", or "atomic".
+var Mode string
+
+// Files lists the registered files, in registration order.
+var Files []File
+
+// AddFile registers the counters of an instrumented source file.
+func AddFile(pkgPath, name string, counters, pos []uint32, numStmt []uint16) {
+	Files = append(Files, File{
+		PkgPath:  pkgPath,
+		Name:     name,
+		Counters: counters,
+		Pos:      pos,
+		NumStmt:  numStmt,
+	})
+}
@@ -61,6 +61,7 @@ var ARM64 struct {
 	HasPMULL     bool
 	HasSHA1      bool
 	HasSHA2      bool
+	HasSHA3      bool
 	HasCRC32     bool
 	HasATOMICS   bool
 	HasCPUID     bool
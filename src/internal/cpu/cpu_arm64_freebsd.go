@@ -38,6 +38,11 @@ func osInit() {
 	case 2:
 		ARM64.HasATOMICS = true
 	}
+
+	switch extractBits(isar0, 32, 35) {
+	case 1:
+		ARM64.HasSHA3 = true
+	}
 }
 
 func extractBits(data uint64, start, end uint) uint {
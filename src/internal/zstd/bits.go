@@ -0,0 +1,173 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import "math/bits"
+
+// A forwardBitReader reads bits from a byte slice, starting with the
+// least significant bits of the first byte. It is used for the headers
+// of FSE tables.
+type forwardBitReader struct {
+	data []byte
+	off  int    // next byte to read into bits
+	bits uint32 // unread bits, in the low cnt bits
+	cnt  uint32 // number of unread bits
+}
+
+// val returns the next n bits, for n <= 24, reading zeros past the
+// end of the data. The caller checks for overruns with overrun.
+func (r *forwardBitReader) val(n uint32) uint32 {
+	for r.cnt < n {
+		var b uint32
+		if r.off < len(r.data) {
+			b = uint32(r.data[r.off])
+		}
+		r.off++
+		r.bits |= b << r.cnt
+		r.cnt += 8
+	}
+	return r.bits & (1<<n - 1)
+}
+
+// skip discards n bits, which must have been made available by val.
+func (r *forwardBitReader) skip(n uint32) {
+	r.bits >>= n
+	r.cnt -= n
+}
+
+// overrun reports whether more bits were consumed than the data holds.
+func (r *forwardBitReader) overrun() bool {
+	return r.off-int(r.cnt/8) > len(r.data)
+}
+
+// bytesUsed returns the number of bytes that hold the consumed bits,
+// rounding up to a whole byte.
+func (r *forwardBitReader) bytesUsed() int {
+	return r.off - int(r.cnt/8)
+}
+
+// A backwardBitReader reads bits from a byte slice, starting with
+// the most significant bits of the last byte. This is the order in
+// which Huffman and FSE bit streams are read. The stream ends with a
+// 1 bit marking where the data starts, followed by zero padding.
+type backwardBitReader struct {
+	data []byte
+	off  int    // next byte to read into bits, counting down
+	bits uint64 // unread bits, in the low cnt bits
+	cnt  uint32 // number of unread bits
+}
+
+func (r *backwardBitReader) init(data []byte) error {
+	if len(data) == 0 {
+		return errCorrupt("empty bit stream")
+	}
+	last := data[len(data)-1]
+	if last == 0 {
+		return errCorrupt("bit stream missing end marker")
+	}
+	r.data = data
+	r.off = len(data) - 1
+	r.bits = uint64(last)
+	r.cnt = uint32(7 - bits.LeadingZeros8(last))
+	r.bits &= 1<<r.cnt - 1
+	return nil
+}
+
+// fill ensures that at least 56 bits are available,
+// or all remaining bits if there are fewer.
+func (r *backwardBitReader) fill() {
+	for r.cnt <= 56 && r.off > 0 {
+		r.off--
+		r.bits = r.bits<<8 | uint64(r.data[r.off])
+		r.cnt += 8
+	}
+}
+
+// val reads and returns the next n bits, for n <= 32.
+// It reads zeros past the start of the data;
+// the caller checks for that with overrun.
+func (r *backwardBitReader) val(n uint32) uint32 {
+	if n == 0 {
+		return 0
+	}
+	if r.cnt < n {
+		r.fill()
+		if r.cnt < n {
+			// Past the start of the stream.
+			r.bits <<= n - r.cnt
+			r.cnt = n
+			r.off = -1
+		}
+	}
+	r.cnt -= n
+	v := uint32(r.bits >> r.cnt & (1<<n - 1))
+	return v
+}
+
+// peek returns the next n bits without consuming them, for n <= 16.
+// Bits past the start of the data read as zero.
+func (r *backwardBitReader) peek(n uint32) uint32 {
+	if r.cnt < n {
+		r.fill()
+		if r.cnt < n {
+			return uint32(r.bits<<(n-r.cnt)) & (1<<n - 1)
+		}
+	}
+	return uint32(r.bits>>(r.cnt-n)) & (1<<n - 1)
+}
+
+// skip consumes n bits previously returned by peek.
+func (r *backwardBitReader) skip(n uint32) {
+	if r.cnt < n {
+		// Consumed bits past the start of the stream.
+		r.off = -1
+		r.cnt = 0
+		return
+	}
+	r.cnt -= n
+}
+
+// remaining returns the number of unread bits.
+func (r *backwardBitReader) remaining() int {
+	if r.off < 0 {
+		return -1
+	}
+	return r.off*8 + int(r.cnt)
+}
+
+// overrun reports whether more bits were read than the stream holds.
+func (r *backwardBitReader) overrun() bool {
+	return r.off < 0
+}
+
+// A backwardBitWriter writes bits in the order that a backwardBitReader
+// reads them back last to first.
+type backwardBitWriter struct {
+	out  []byte
+	bits uint64
+	cnt  uint32
+}
+
+// write writes the low n bits of v, for n <= 32.
+func (w *backwardBitWriter) write(v uint32, n uint32) {
+	w.bits |= uint64(v&(1<<n-1)) << w.cnt
+	w.cnt += n
+	for w.cnt >= 8 {
+		w.out = append(w.out, byte(w.bits))
+		w.bits >>= 8
+		w.cnt -= 8
+	}
+}
+
+// close writes the end marker and returns the stream.
+func (w *backwardBitWriter) close() []byte {
+	w.write(1, 1)
+	if w.cnt > 0 {
+		w.out = append(w.out, byte(w.bits))
+		w.bits = 0
+		w.cnt = 0
+	}
+	return w.out
+}
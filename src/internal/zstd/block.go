@@ -0,0 +1,334 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import "encoding/binary"
+
+// maxBlockSize is the largest amount of data a block may decompress to.
+const maxBlockSize = 128 << 10
+
+// Literals block types.
+const (
+	literalsRaw        = 0
+	literalsRLE        = 1
+	literalsCompressed = 2
+	literalsTreeless   = 3
+)
+
+// Sequence table compression modes.
+const (
+	modePredefined = 0
+	modeRLE        = 1
+	modeFSE        = 2
+	modeRepeat     = 3
+)
+
+// compressedBlock decompresses the compressed block data,
+// appending the result to r.hist.
+func (r *Reader) compressedBlock(data []byte) error {
+	lits, n, err := r.readLiterals(data)
+	if err != nil {
+		return err
+	}
+	return r.execSequences(data[n:], lits)
+}
+
+// readLiterals reads the literals section at the start of data
+// (RFC 8878 section 3.1.1.3.1). It returns the literals and the size
+// of the section.
+func (r *Reader) readLiterals(data []byte) ([]byte, int, error) {
+	if len(data) == 0 {
+		return nil, 0, errCorrupt("missing literals section")
+	}
+	typ := data[0] & 3
+	sizeFormat := data[0] >> 2 & 3
+
+	if typ == literalsRaw || typ == literalsRLE {
+		var size, hdr int
+		switch sizeFormat {
+		case 0, 2:
+			size, hdr = int(data[0]>>3), 1
+		case 1:
+			if len(data) < 2 {
+				return nil, 0, errCorrupt("literals header truncated")
+			}
+			size, hdr = int(data[0]>>4)|int(data[1])<<4, 2
+		case 3:
+			if len(data) < 3 {
+				return nil, 0, errCorrupt("literals header truncated")
+			}
+			size, hdr = int(data[0]>>4)|int(data[1])<<4|int(data[2])<<12, 3
+		}
+		if size > maxBlockSize {
+			return nil, 0, errCorrupt("literals too large")
+		}
+		if typ == literalsRaw {
+			if hdr+size > len(data) {
+				return nil, 0, errCorrupt("raw literals truncated")
+			}
+			return data[hdr : hdr+size], hdr + size, nil
+		}
+		if hdr >= len(data) {
+			return nil, 0, errCorrupt("RLE literals truncated")
+		}
+		lits := r.lits[:0]
+		for i := 0; i < size; i++ {
+			lits = append(lits, data[hdr])
+		}
+		r.lits = lits
+		return lits, hdr + 1, nil
+	}
+
+	// Huffman-compressed literals.
+	var regen, comp, hdr int
+	streams := 4
+	switch sizeFormat {
+	case 0, 1:
+		if len(data) < 3 {
+			return nil, 0, errCorrupt("literals header truncated")
+		}
+		v := uint32(data[0]) | uint32(data[1])<<8 | uint32(data[2])<<16
+		regen, comp, hdr = int(v>>4&0x3ff), int(v>>14&0x3ff), 3
+		if sizeFormat == 0 {
+			streams = 1
+		}
+	case 2:
+		if len(data) < 4 {
+			return nil, 0, errCorrupt("literals header truncated")
+		}
+		v := binary.LittleEndian.Uint32(data)
+		regen, comp, hdr = int(v>>4&0x3fff), int(v>>18), 4
+	case 3:
+		if len(data) < 5 {
+			return nil, 0, errCorrupt("literals header truncated")
+		}
+		v := uint64(binary.LittleEndian.Uint32(data)) | uint64(data[4])<<32
+		regen, comp, hdr = int(v>>4&0x3ffff), int(v>>22&0x3ffff), 5
+	}
+	if regen > maxBlockSize {
+		return nil, 0, errCorrupt("literals too large")
+	}
+	if hdr+comp > len(data) {
+		return nil, 0, errCorrupt("compressed literals truncated")
+	}
+	body := data[hdr : hdr+comp]
+	if typ == literalsCompressed {
+		if r.huff == nil {
+			r.huff = new(huffTable)
+		}
+		n, err := readHuffman(body, r.huff)
+		if err != nil {
+			r.huffValid = false
+			return nil, 0, err
+		}
+		r.huffValid = true
+		body = body[n:]
+	} else if !r.huffValid {
+		return nil, 0, errCorrupt("treeless literals without a previous Huffman table")
+	}
+	var lits []byte
+	var err error
+	if streams == 1 {
+		lits, err = decodeHuffman(r.lits[:0], body, regen, r.huff)
+	} else {
+		lits, err = decodeHuffman4(r.lits[:0], body, regen, r.huff)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	r.lits = lits
+	return lits, hdr + comp, nil
+}
+
+// A seqTable is the decoding table for one kind of sequence code.
+type seqTable struct {
+	table []fseEntry
+	log   uint32
+	buf   []fseEntry // storage for tables read from the input
+}
+
+// readSeqTable sets t as specified by mode, reading any table
+// description from data. It returns the number of bytes read.
+func readSeqTable(t *seqTable, mode byte, data []byte, maxSym, maxLog int, predef []fseEntry, predefLog uint32) (int, error) {
+	switch mode {
+	case modePredefined:
+		t.table, t.log = predef, predefLog
+		return 0, nil
+	case modeRLE:
+		if len(data) == 0 {
+			return 0, errCorrupt("missing RLE sequence code")
+		}
+		if int(data[0]) > maxSym {
+			return 0, errCorrupt("invalid RLE sequence code")
+		}
+		if t.buf == nil {
+			t.buf = make([]fseEntry, 1<<maxLog)
+		}
+		t.buf[0] = fseEntry{sym: data[0]}
+		t.table, t.log = t.buf[:1], 0
+		return 1, nil
+	case modeFSE:
+		if t.buf == nil {
+			t.buf = make([]fseEntry, 1<<maxLog)
+		}
+		log, n, err := readFSE(data, maxSym, maxLog, t.buf)
+		if err != nil {
+			return 0, err
+		}
+		t.table, t.log = t.buf[:1<<log], uint32(log)
+		return n, nil
+	default: // modeRepeat
+		if t.table == nil {
+			return 0, errCorrupt("repeated sequence table without a previous table")
+		}
+		return 0, nil
+	}
+}
+
+// execSequences reads the sequences section data
+// (RFC 8878 section 3.1.1.3.2) and executes the sequences,
+// appending the result to r.hist.
+func (r *Reader) execSequences(data []byte, lits []byte) error {
+	if len(data) == 0 {
+		return errCorrupt("missing sequences section")
+	}
+	var nseq, hdr int
+	switch b := int(data[0]); {
+	case b < 128:
+		nseq, hdr = b, 1
+	case b < 255:
+		if len(data) < 2 {
+			return errCorrupt("sequences header truncated")
+		}
+		nseq, hdr = (b-128)<<8|int(data[1]), 2
+	default:
+		if len(data) < 3 {
+			return errCorrupt("sequences header truncated")
+		}
+		nseq, hdr = int(data[1])|int(data[2])<<8+0x7f00, 3
+	}
+	if nseq == 0 {
+		if hdr != len(data) {
+			return errCorrupt("extra data after sequences")
+		}
+		r.hist = append(r.hist, lits...)
+		return nil
+	}
+
+	if hdr >= len(data) {
+		return errCorrupt("sequences header truncated")
+	}
+	modes := data[hdr]
+	if modes&3 != 0 {
+		return errCorrupt("reserved sequence modes bits set")
+	}
+	off := hdr + 1
+	n, err := readSeqTable(&r.llTable, modes>>6, data[off:], maxLiteralLengthCode, maxLiteralLengthLog,
+		predefinedLiteralLengthTable[:], predefinedLiteralLengthLog)
+	if err != nil {
+		return err
+	}
+	off += n
+	n, err = readSeqTable(&r.ofTable, modes>>4&3, data[off:], maxOffsetCode, maxOffsetLog,
+		predefinedOffsetTable[:], predefinedOffsetLog)
+	if err != nil {
+		return err
+	}
+	off += n
+	n, err = readSeqTable(&r.mlTable, modes>>2&3, data[off:], maxMatchLengthCode, maxMatchLengthLog,
+		predefinedMatchLengthTable[:], predefinedMatchLengthLog)
+	if err != nil {
+		return err
+	}
+	off += n
+
+	var br backwardBitReader
+	if err := br.init(data[off:]); err != nil {
+		return err
+	}
+	ll := r.llTable.table[br.val(r.llTable.log)]
+	of := r.ofTable.table[br.val(r.ofTable.log)]
+	ml := r.mlTable.table[br.val(r.mlTable.log)]
+
+	start := len(r.hist)
+	for i := 0; i < nseq; i++ {
+		if of.sym > maxOffsetCode || ml.sym > maxMatchLengthCode || ll.sym > maxLiteralLengthCode {
+			return errCorrupt("invalid sequence code")
+		}
+		offset := uint32(1)<<of.sym + br.val(uint32(of.sym))
+		mlb := matchLengthBase[ml.sym]
+		matchLen := mlb.base + br.val(uint32(mlb.bits))
+		llb := literalLengthBase[ll.sym]
+		litLen := llb.base + br.val(uint32(llb.bits))
+
+		// Resolve repeated offsets (RFC 8878 section 3.1.1.5).
+		if offset > 3 {
+			offset -= 3
+			r.reps[2], r.reps[1], r.reps[0] = r.reps[1], r.reps[0], offset
+		} else {
+			if litLen == 0 {
+				offset++
+			}
+			switch offset {
+			case 1:
+				offset = r.reps[0]
+			case 2:
+				offset = r.reps[1]
+				r.reps[1], r.reps[0] = r.reps[0], offset
+			case 3:
+				offset = r.reps[2]
+				r.reps[2], r.reps[1], r.reps[0] = r.reps[1], r.reps[0], offset
+			case 4:
+				offset = r.reps[0] - 1
+				if offset == 0 {
+					return errCorrupt("invalid repeated offset")
+				}
+				r.reps[2], r.reps[1], r.reps[0] = r.reps[1], r.reps[0], offset
+			}
+		}
+
+		if uint32(len(lits)) < litLen {
+			return errCorrupt("literal length exceeds literals")
+		}
+		r.hist = append(r.hist, lits[:litLen]...)
+		lits = lits[litLen:]
+
+		// Matches may not reach before the start of the frame.
+		if uint64(offset) > r.produced+uint64(len(r.hist)-start) || offset > r.window {
+			return errCorrupt("match offset too large")
+		}
+		if len(r.hist)-start+int(matchLen) > maxBlockSize {
+			return errCorrupt("block too large")
+		}
+		// Copy the match, which may overlap the output.
+		from := len(r.hist) - int(offset)
+		for matchLen > 0 {
+			chunk := r.hist[from:]
+			if uint32(len(chunk)) > matchLen {
+				chunk = chunk[:matchLen]
+			}
+			r.hist = append(r.hist, chunk...)
+			from += len(chunk)
+			matchLen -= uint32(len(chunk))
+		}
+
+		if i < nseq-1 {
+			ll = r.llTable.table[uint32(ll.base)+br.val(uint32(ll.bits))]
+			ml = r.mlTable.table[uint32(ml.base)+br.val(uint32(ml.bits))]
+			of = r.ofTable.table[uint32(of.base)+br.val(uint32(of.bits))]
+		}
+		if br.overrun() {
+			return errCorrupt("sequences bit stream truncated")
+		}
+	}
+	if br.remaining() != 0 {
+		return errCorrupt("extra bits after sequences")
+	}
+	r.hist = append(r.hist, lits...)
+	if len(r.hist)-start > maxBlockSize {
+		return errCorrupt("block too large")
+	}
+	return nil
+}
@@ -0,0 +1,588 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/bits"
+)
+
+// The Writer finds matches with a single hash table of recent positions,
+// encodes the sequences with the predefined FSE tables, and compresses
+// literals with a Huffman code when all of them are below 128, as in
+// text. Blocks that do not shrink are stored raw.
+
+const (
+	windowLog  = 20
+	windowSize = 1 << windowLog
+	hashLog    = 16
+	minMatch   = 4
+)
+
+var errWriterClosed = errors.New("zstd: write to closed Writer")
+
+// A Writer compresses the data written to it into a single Zstandard
+// frame. It does not use the full window that the format allows, nor
+// entropy tables other than the predefined ones, so it compresses
+// quickly but less than other implementations.
+type Writer struct {
+	w           io.Writer
+	err         error
+	closed      bool
+	wroteHeader bool
+
+	// buf holds up to windowSize bytes of history that matches may
+	// refer to, followed by the input not yet compressed, from pos on.
+	buf   []byte
+	pos   int
+	table [1 << hashLog]int32 // 1 + position in buf of recent input, by hash of its first bytes
+	hash  xxhash
+
+	seqs []sequence
+	lits []byte
+	out  []byte // encoded block
+	huff []byte // Huffman-compressed literals
+}
+
+// A sequence is a run of literals followed by a match.
+type sequence struct {
+	litLen   uint32
+	matchLen uint32
+	offset   uint32
+}
+
+// NewWriter returns a new Writer writing a Zstandard stream to w.
+// The caller must call Close to complete the stream.
+func NewWriter(w io.Writer) *Writer {
+	z := new(Writer)
+	z.Reset(w)
+	return z
+}
+
+// Reset discards the Writer's state and makes it equivalent to the
+// result of NewWriter(w), reusing its buffers.
+func (z *Writer) Reset(w io.Writer) {
+	z.w = w
+	z.err = nil
+	z.closed = false
+	z.wroteHeader = false
+	z.buf = z.buf[:0]
+	z.pos = 0
+	z.table = [1 << hashLog]int32{}
+	z.hash.reset()
+}
+
+// Write compresses p, buffering up to a block of data.
+func (z *Writer) Write(p []byte) (int, error) {
+	if z.err != nil {
+		return 0, z.err
+	}
+	if z.closed {
+		return 0, errWriterClosed
+	}
+	n := len(p)
+	for len(p) > 0 {
+		// Keep a full block buffered until more data arrives,
+		// so that Close can mark the last block.
+		if len(z.buf)-z.pos == maxBlockSize {
+			if z.err = z.writeBlock(false); z.err != nil {
+				return 0, z.err
+			}
+		}
+		m := maxBlockSize - (len(z.buf) - z.pos)
+		if m > len(p) {
+			m = len(p)
+		}
+		z.buf = append(z.buf, p[:m]...)
+		z.hash.write(p[:m])
+		p = p[m:]
+	}
+	return n, nil
+}
+
+// Close writes any buffered data and the end of the frame.
+// It does not close the underlying writer.
+func (z *Writer) Close() error {
+	if z.err != nil {
+		return z.err
+	}
+	if z.closed {
+		return nil
+	}
+	z.closed = true
+	if z.err = z.writeBlock(true); z.err != nil {
+		return z.err
+	}
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], uint32(z.hash.sum64()))
+	_, z.err = z.w.Write(b[:])
+	return z.err
+}
+
+// writeBlock compresses the buffered input as a single block.
+func (z *Writer) writeBlock(last bool) error {
+	out := z.out[:0]
+	if !z.wroteHeader {
+		z.wroteHeader = true
+		// No dictionary or content size; a content checksum;
+		// and a window descriptor for windowSize.
+		out = binary.LittleEndian.AppendUint32(out, frameMagic)
+		out = append(out, 0x04, (windowLog-10)<<3)
+	}
+	hdr := len(out)
+	out = append(out, 0, 0, 0)
+	out = z.compressBlock(out)
+	typ := blockCompressed
+	size := len(out) - hdr - 3
+	if size >= len(z.buf)-z.pos {
+		out = append(out[:hdr+3], z.buf[z.pos:]...)
+		typ, size = blockRaw, len(z.buf)-z.pos
+	}
+	v := uint32(size<<3 | typ<<1)
+	if last {
+		v |= 1
+	}
+	out[hdr], out[hdr+1], out[hdr+2] = byte(v), byte(v>>8), byte(v>>16)
+	z.out = out
+	z.pos = len(z.buf)
+	if _, err := z.w.Write(out); err != nil {
+		return err
+	}
+
+	// Discard the history beyond the window. Do so only once
+	// there is a fair amount of it, to limit copying.
+	if drop := z.pos - windowSize; drop >= windowSize/4 {
+		z.buf = z.buf[:copy(z.buf, z.buf[drop:])]
+		z.pos -= drop
+		for i, v := range z.table {
+			if int(v) <= drop {
+				z.table[i] = 0
+			} else {
+				z.table[i] = v - int32(drop)
+			}
+		}
+	}
+	return nil
+}
+
+func hash4(u uint32) uint32 {
+	return u * 0x9E3779B1 >> (32 - hashLog)
+}
+
+// compressBlock appends the literals and sequences sections for the
+// buffered input to out. The result may be larger than the input.
+func (z *Writer) compressBlock(out []byte) []byte {
+	buf := z.buf
+	end := len(buf)
+	seqs := z.seqs[:0]
+	lits := z.lits[:0]
+	litStart := z.pos
+	for i := z.pos; i+minMatch <= end; {
+		cur := binary.LittleEndian.Uint32(buf[i:])
+		h := hash4(cur)
+		cand := int(z.table[h]) - 1
+		z.table[h] = int32(i + 1)
+		if cand < 0 || i-cand > windowSize || binary.LittleEndian.Uint32(buf[cand:]) != cur {
+			// Skip ahead faster in data that does not compress.
+			i += 1 + (i-litStart)>>5
+			continue
+		}
+		n := minMatch
+		for i+n < end && buf[cand+n] == buf[i+n] {
+			n++
+		}
+		for i > litStart && cand > 0 && buf[i-1] == buf[cand-1] {
+			i--
+			cand--
+			n++
+		}
+		lits = append(lits, buf[litStart:i]...)
+		seqs = append(seqs, sequence{
+			litLen:   uint32(i - litStart),
+			matchLen: uint32(n),
+			offset:   uint32(i - cand),
+		})
+		i += n
+		litStart = i
+		if i+minMatch <= end {
+			z.table[hash4(binary.LittleEndian.Uint32(buf[i-2:]))] = int32(i - 2 + 1)
+		}
+	}
+	lits = append(lits, buf[litStart:end]...)
+	z.seqs, z.lits = seqs, lits
+
+	out = z.appendLiterals(out, lits)
+	return appendSequences(out, seqs)
+}
+
+// appendLiterals appends a literals section (RFC 8878 section 3.1.1.3.1)
+// holding lits to out.
+func (z *Writer) appendLiterals(out []byte, lits []byte) []byte {
+	var freq [256]uint32
+	maxSym := 0
+	distinct := 0
+	for _, c := range lits {
+		if freq[c] == 0 {
+			distinct++
+		}
+		freq[c]++
+		if int(c) > maxSym {
+			maxSym = int(c)
+		}
+	}
+	if distinct == 1 && len(lits) > 1 {
+		out = appendLiteralsHeader(out, literalsRLE, len(lits))
+		return append(out, lits[0])
+	}
+	// Huffman weights are stored directly only for up to 128 symbols.
+	if distinct > 1 && maxSym <= 128 && len(lits) >= 32 {
+		if c := z.huffmanLiterals(lits, &freq, maxSym); c != nil && len(c)+5 < len(lits) {
+			return appendCompressedLiteralsHeader(out, len(lits), c)
+		}
+	}
+	out = appendLiteralsHeader(out, literalsRaw, len(lits))
+	return append(out, lits...)
+}
+
+// appendLiteralsHeader appends the header of a raw or RLE literals section.
+func appendLiteralsHeader(out []byte, typ byte, size int) []byte {
+	switch {
+	case size < 1<<5:
+		return append(out, typ|byte(size)<<3)
+	case size < 1<<12:
+		return append(out, typ|1<<2|byte(size)<<4, byte(size>>4))
+	default:
+		return append(out, typ|3<<2|byte(size)<<4, byte(size>>4), byte(size>>12))
+	}
+}
+
+// appendCompressedLiteralsHeader appends a compressed literals section
+// holding size literals, whose Huffman tree and streams are c.
+func appendCompressedLiteralsHeader(out []byte, size int, c []byte) []byte {
+	n := uint64(len(c))
+	switch {
+	case size < 1<<10 && n < 1<<10:
+		// A single stream, as huffmanLiterals makes for short input.
+		v := literalsCompressed | uint64(size)<<4 | n<<14
+		out = append(out, byte(v), byte(v>>8), byte(v>>16))
+	case size < 1<<14 && n < 1<<14:
+		v := literalsCompressed | 2<<2 | uint64(size)<<4 | n<<18
+		out = binary.LittleEndian.AppendUint32(out, uint32(v))
+	default:
+		v := literalsCompressed | 3<<2 | uint64(size)<<4 | n<<22
+		out = binary.LittleEndian.AppendUint32(out, uint32(v))
+		out = append(out, byte(v>>32))
+	}
+	return append(out, c...)
+}
+
+// huffmanLiterals returns the Huffman tree description and the
+// compressed streams for lits, whose symbol frequencies are freq.
+// It uses a single stream for fewer than 1024 literals and four
+// streams otherwise, matching appendCompressedLiteralsHeader.
+func (z *Writer) huffmanLiterals(lits []byte, freq *[256]uint32, maxSym int) []byte {
+	var lengths [256]uint8
+	maxBits := huffmanLengths(freq[:maxSym+1], lengths[:maxSym+1])
+
+	// Assign the codes as the decoder does: in order of increasing
+	// weight, and then of increasing symbol.
+	var start [maxHuffmanBits + 2]uint32
+	for _, l := range lengths[:maxSym+1] {
+		if l > 0 {
+			w := maxBits + 1 - l
+			start[w] += 1 << (w - 1)
+		}
+	}
+	pos := uint32(0)
+	for w := 1; w <= maxHuffmanBits+1; w++ {
+		c := start[w]
+		start[w] = pos
+		pos += c
+	}
+	var codes [256]uint16
+	for s, l := range lengths[:maxSym+1] {
+		if l > 0 {
+			w := maxBits + 1 - l
+			codes[s] = uint16(start[w] >> (w - 1))
+			start[w] += 1 << (w - 1)
+		}
+	}
+
+	// The tree description gives the weights of all symbols but the
+	// last, four bits each.
+	c := z.huff[:0]
+	c = append(c, byte(127+maxSym))
+	for s := 0; s < maxSym; s += 2 {
+		b := byte(0)
+		if l := lengths[s]; l > 0 {
+			b = (maxBits + 1 - l) << 4
+		}
+		if l := lengths[s+1]; s+1 < maxSym && l > 0 {
+			b |= maxBits + 1 - l
+		}
+		c = append(c, b)
+	}
+
+	appendStream := func(c, lits []byte) []byte {
+		w := backwardBitWriter{out: c}
+		for i := len(lits) - 1; i >= 0; i-- {
+			s := lits[i]
+			w.write(uint32(codes[s]), uint32(lengths[s]))
+		}
+		return w.close()
+	}
+	if len(lits) < 1<<10 {
+		c = appendStream(c, lits)
+	} else {
+		jump := len(c)
+		c = append(c, 0, 0, 0, 0, 0, 0)
+		per := (len(lits) + 3) / 4
+		for i := 0; i < 4; i++ {
+			s := lits[i*per:]
+			if i < 3 {
+				s = s[:per]
+			}
+			before := len(c)
+			c = appendStream(c, s)
+			if i < 3 {
+				binary.LittleEndian.PutUint16(c[jump+2*i:], uint16(len(c)-before))
+			}
+		}
+	}
+	z.huff = c
+	return c
+}
+
+// huffmanLengths sets lengths to the code lengths of a Huffman code
+// for the symbol frequencies freq, which include at least two nonzero
+// ones, and returns the longest length, which is at most maxHuffmanBits.
+func huffmanLengths(freq []uint32, lengths []uint8) uint8 {
+	var f [256]uint32
+	copy(f[:], freq)
+	for {
+		// Build the tree by repeatedly joining the two lightest
+		// nodes. There are few enough symbols for this to be quick.
+		var weight [512]uint32
+		var parent [512]int
+		var sym [256]int
+		n := 0
+		for s, w := range f[:len(freq)] {
+			if w > 0 {
+				weight[n] = w
+				sym[n] = s
+				n++
+			}
+		}
+		leaves := n
+		active := make([]bool, 2*leaves)
+		for i := 0; i < leaves; i++ {
+			active[i] = true
+		}
+		for k := 0; k < leaves-1; k++ {
+			a, b := -1, -1
+			for i := 0; i < n; i++ {
+				if !active[i] {
+					continue
+				}
+				if a < 0 || weight[i] < weight[a] {
+					a, b = i, a
+				} else if b < 0 || weight[i] < weight[b] {
+					b = i
+				}
+			}
+			active[a], active[b] = false, false
+			weight[n] = weight[a] + weight[b]
+			parent[a], parent[b] = n, n
+			active[n] = true
+			n++
+		}
+		root := n - 1
+		maxLen := uint8(0)
+		for i := 0; i < leaves; i++ {
+			l := uint8(0)
+			for j := i; j != root; j = parent[j] {
+				l++
+			}
+			lengths[sym[i]] = l
+			if l > maxLen {
+				maxLen = l
+			}
+		}
+		if maxLen <= maxHuffmanBits {
+			return maxLen
+		}
+		// Flatten the distribution and try again.
+		for i, w := range f[:len(freq)] {
+			if w > 0 {
+				f[i] = w>>1 | 1
+			}
+		}
+	}
+}
+
+// An fseEncoder encodes symbols with an FSE table.
+type fseEncoder struct {
+	log        uint32
+	stateTable []uint16
+	symbols    []fseSymbolTransform
+}
+
+type fseSymbolTransform struct {
+	deltaNbBits    uint32
+	deltaFindState int32
+}
+
+// newFSEEncoder returns the encoder for the FSE table with the
+// normalized counts norm.
+func newFSEEncoder(norm []int16, log int) *fseEncoder {
+	size := 1 << log
+	syms := make([]uint8, size)
+	if _, err := spreadFSE(norm, log, syms); err != nil {
+		panic(err)
+	}
+	e := &fseEncoder{
+		log:        uint32(log),
+		stateTable: make([]uint16, size),
+		symbols:    make([]fseSymbolTransform, len(norm)),
+	}
+	cumul := make([]int, len(norm)+1)
+	for s, c := range norm {
+		if c == -1 {
+			c = 1
+		}
+		cumul[s+1] = cumul[s] + int(c)
+	}
+	for u, s := range syms {
+		e.stateTable[cumul[s]] = uint16(size + u)
+		cumul[s]++
+	}
+	total := int32(0)
+	for s, c := range norm {
+		switch c {
+		case 0:
+		case -1, 1:
+			e.symbols[s] = fseSymbolTransform{
+				deltaNbBits:    uint32(log)<<16 - uint32(size),
+				deltaFindState: total - 1,
+			}
+			total++
+		default:
+			maxBitsOut := uint32(log - (bits.Len16(uint16(c-1)) - 1))
+			minStatePlus := uint32(c) << maxBitsOut
+			e.symbols[s] = fseSymbolTransform{
+				deltaNbBits:    maxBitsOut<<16 - minStatePlus,
+				deltaFindState: total - int32(c),
+			}
+			total += int32(c)
+		}
+	}
+	return e
+}
+
+// init returns the initial state for encoding sym last.
+func (e *fseEncoder) init(sym uint8) uint32 {
+	t := e.symbols[sym]
+	nbBits := (t.deltaNbBits + 1<<15) >> 16
+	v := nbBits<<16 - t.deltaNbBits
+	return uint32(e.stateTable[int32(v>>nbBits)+t.deltaFindState])
+}
+
+// encode writes the bits that lead to state from the state for sym,
+// and returns that state.
+func (e *fseEncoder) encode(w *backwardBitWriter, state uint32, sym uint8) uint32 {
+	t := e.symbols[sym]
+	nbBits := (state + t.deltaNbBits) >> 16
+	w.write(state, nbBits)
+	return uint32(e.stateTable[int32(state>>nbBits)+t.deltaFindState])
+}
+
+var (
+	literalLengthEncoder = newFSEEncoder(predefinedLiteralLengths, predefinedLiteralLengthLog)
+	matchLengthEncoder   = newFSEEncoder(predefinedMatchLengths, predefinedMatchLengthLog)
+	offsetEncoder        = newFSEEncoder(predefinedOffsets, predefinedOffsetLog)
+)
+
+// Codes of the short literal and match lengths, indexed by the
+// length and the length less 3.
+var (
+	literalLengthCodes [64]uint8
+	matchLengthCodes   [128]uint8
+)
+
+func init() {
+	for c, b := range literalLengthBase[:25] {
+		for v := b.base; v < b.base+1<<b.bits; v++ {
+			literalLengthCodes[v] = uint8(c)
+		}
+	}
+	for c, b := range matchLengthBase[:43] {
+		for v := b.base; v < b.base+1<<b.bits; v++ {
+			matchLengthCodes[v-3] = uint8(c)
+		}
+	}
+}
+
+func literalLengthCode(n uint32) uint8 {
+	if n < 64 {
+		return literalLengthCodes[n]
+	}
+	return uint8(bits.Len32(n) - 1 + 19)
+}
+
+func matchLengthCode(n uint32) uint8 {
+	n -= 3
+	if n < 128 {
+		return matchLengthCodes[n]
+	}
+	return uint8(bits.Len32(n) - 1 + 36)
+}
+
+// appendSequences appends a sequences section
+// (RFC 8878 section 3.1.1.3.2) holding seqs to out.
+func appendSequences(out []byte, seqs []sequence) []byte {
+	switch n := len(seqs); {
+	case n < 128:
+		out = append(out, byte(n))
+	case n < 0x7f00:
+		out = append(out, byte(n>>8)+128, byte(n))
+	default:
+		out = append(out, 255, byte(n-0x7f00), byte((n-0x7f00)>>8))
+	}
+	if len(seqs) == 0 {
+		return out
+	}
+	out = append(out, 0) // predefined tables
+
+	// The decoder reads the bit stream backward,
+	// so write the sequences last to first.
+	w := backwardBitWriter{out: out}
+	var llState, mlState, ofState uint32
+	for i := len(seqs) - 1; i >= 0; i-- {
+		s := seqs[i]
+		ll := literalLengthCode(s.litLen)
+		ml := matchLengthCode(s.matchLen)
+		ofv := s.offset + 3 // never a repeated offset
+		of := uint8(bits.Len32(ofv) - 1)
+		if i == len(seqs)-1 {
+			mlState = matchLengthEncoder.init(ml)
+			ofState = offsetEncoder.init(of)
+			llState = literalLengthEncoder.init(ll)
+		} else {
+			ofState = offsetEncoder.encode(&w, ofState, of)
+			mlState = matchLengthEncoder.encode(&w, mlState, ml)
+			llState = literalLengthEncoder.encode(&w, llState, ll)
+		}
+		llb, mlb := literalLengthBase[ll], matchLengthBase[ml]
+		w.write(s.litLen-llb.base, uint32(llb.bits))
+		w.write(s.matchLen-mlb.base, uint32(mlb.bits))
+		w.write(ofv-1<<of, uint32(of))
+	}
+	w.write(mlState, matchLengthEncoder.log)
+	w.write(ofState, offsetEncoder.log)
+	w.write(llState, literalLengthEncoder.log)
+	return w.close()
+}
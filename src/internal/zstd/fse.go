@@ -0,0 +1,243 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import "math/bits"
+
+// An fseEntry is an entry in an FSE decoding table: the symbol for
+// a state, and how to compute the next state from it.
+type fseEntry struct {
+	sym  uint8  // symbol
+	bits uint8  // number of bits to read for the next state
+	base uint16 // base of the next state
+}
+
+// readFSE reads an FSE table description from data (RFC 8878 section
+// 4.1.1) for symbols up to maxSym and with an accuracy log of at most
+// maxLog. It builds the decoding table into table, which must have
+// room for 1<<maxLog entries, and returns the accuracy log and the
+// number of bytes read.
+func readFSE(data []byte, maxSym, maxLog int, table []fseEntry) (log int, n int, err error) {
+	r := forwardBitReader{data: data}
+	log = int(r.val(4)) + 5
+	r.skip(4)
+	if log > maxLog {
+		return 0, 0, errCorrupt("FSE accuracy log too large")
+	}
+
+	var norm [256]int16
+	remaining := int32(1<<log + 1)
+	threshold := int32(1 << log)
+	nbits := uint32(log + 1)
+	sym := 0
+	prev0 := false
+	for remaining > 1 && sym <= maxSym {
+		if prev0 {
+			// Repeat flags give runs of zero probabilities.
+			n0 := sym
+			for r.val(2) == 3 {
+				r.skip(2)
+				n0 += 3
+				if r.overrun() || n0 > maxSym+1 {
+					return 0, 0, errCorrupt("FSE table has too many zeros")
+				}
+			}
+			n0 += int(r.val(2))
+			r.skip(2)
+			if n0 > maxSym+1 {
+				return 0, 0, errCorrupt("FSE table has too many zeros")
+			}
+			for sym < n0 {
+				norm[sym] = 0
+				sym++
+			}
+			if sym > maxSym {
+				break
+			}
+		}
+
+		max := 2*threshold - 1 - remaining
+		var count int32
+		v := int32(r.val(nbits))
+		if v&(threshold-1) < max {
+			count = v & (threshold - 1)
+			r.skip(nbits - 1)
+		} else {
+			count = v & (2*threshold - 1)
+			if count >= threshold {
+				count -= max
+			}
+			r.skip(nbits)
+		}
+		count-- // a count of -1 is a "less than 1" probability
+		if count < 0 {
+			remaining += count
+		} else {
+			remaining -= count
+		}
+		norm[sym] = int16(count)
+		sym++
+		prev0 = count == 0
+		for remaining < threshold && threshold > 1 {
+			nbits--
+			threshold >>= 1
+		}
+		if r.overrun() {
+			return 0, 0, errCorrupt("FSE table description truncated")
+		}
+	}
+	if remaining != 1 || r.overrun() {
+		return 0, 0, errCorrupt("invalid FSE table description")
+	}
+	if err := buildFSE(norm[:sym], log, table); err != nil {
+		return 0, 0, err
+	}
+	return log, r.bytesUsed(), nil
+}
+
+// spreadFSE distributes the symbols with the normalized counts norm
+// over a table of size 1<<log, as the encoder and decoder must agree
+// on, setting sym for each entry. It returns the number of entries
+// set by the normal spreading, which excludes the entries at the high
+// end of the table for "less than 1" probability symbols.
+func spreadFSE(norm []int16, log int, sym []uint8) (highThreshold int, err error) {
+	size := 1 << log
+	high := size - 1
+	for s, c := range norm {
+		if c == -1 {
+			if high < 0 {
+				return 0, errCorrupt("invalid FSE table")
+			}
+			sym[high] = uint8(s)
+			high--
+		}
+	}
+	step := size>>1 + size>>3 + 3
+	mask := size - 1
+	pos := 0
+	for s, c := range norm {
+		for i := 0; i < int(c); i++ {
+			sym[pos] = uint8(s)
+			pos = (pos + step) & mask
+			for pos > high {
+				pos = (pos + step) & mask
+			}
+		}
+	}
+	if pos != 0 {
+		return 0, errCorrupt("invalid FSE table")
+	}
+	return high, nil
+}
+
+// buildFSE builds the decoding table for the normalized counts norm.
+func buildFSE(norm []int16, log int, table []fseEntry) error {
+	size := 1 << log
+	var syms [1 << maxFSELog]uint8
+	if _, err := spreadFSE(norm, log, syms[:size]); err != nil {
+		return err
+	}
+	var next [256]uint16
+	for s, c := range norm {
+		if c == -1 {
+			next[s] = 1
+		} else {
+			next[s] = uint16(c)
+		}
+	}
+	for i := 0; i < size; i++ {
+		s := syms[i]
+		n := next[s]
+		next[s]++
+		nb := log - (bits.Len16(n) - 1)
+		table[i] = fseEntry{
+			sym:  s,
+			bits: uint8(nb),
+			base: uint16(int(n)<<nb - size),
+		}
+	}
+	return nil
+}
+
+const maxFSELog = 9 // largest accuracy log of any FSE table
+
+// Maximum symbols and accuracy logs of the sequence code tables.
+const (
+	maxLiteralLengthCode = 35
+	maxMatchLengthCode   = 52
+	maxOffsetCode        = 31
+
+	maxLiteralLengthLog = 9
+	maxMatchLengthLog   = 9
+	maxOffsetLog        = 8
+)
+
+// Predefined distributions of the sequence codes (RFC 8878 section 3.1.1.3.2.2).
+var (
+	predefinedLiteralLengths = []int16{
+		4, 3, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1,
+		2, 2, 2, 2, 2, 2, 2, 2, 2, 3, 2, 1, 1, 1, 1, 1,
+		-1, -1, -1, -1,
+	}
+	predefinedMatchLengths = []int16{
+		1, 4, 3, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1,
+		-1, -1, -1, -1, -1,
+	}
+	predefinedOffsets = []int16{
+		1, 1, 1, 1, 1, 1, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1,
+	}
+)
+
+const (
+	predefinedLiteralLengthLog = 6
+	predefinedMatchLengthLog   = 6
+	predefinedOffsetLog        = 5
+)
+
+var (
+	predefinedLiteralLengthTable [1 << predefinedLiteralLengthLog]fseEntry
+	predefinedMatchLengthTable   [1 << predefinedMatchLengthLog]fseEntry
+	predefinedOffsetTable        [1 << predefinedOffsetLog]fseEntry
+)
+
+func init() {
+	if buildFSE(predefinedLiteralLengths, predefinedLiteralLengthLog, predefinedLiteralLengthTable[:]) != nil ||
+		buildFSE(predefinedMatchLengths, predefinedMatchLengthLog, predefinedMatchLengthTable[:]) != nil ||
+		buildFSE(predefinedOffsets, predefinedOffsetLog, predefinedOffsetTable[:]) != nil {
+		panic("zstd: invalid predefined FSE table")
+	}
+}
+
+// A codeBase gives the value of a literal length or match length code:
+// the baseline plus the value of the given number of extra bits.
+type codeBase struct {
+	base uint32
+	bits uint8
+}
+
+// literalLengthBase is the value of each literal length code
+// (RFC 8878 section 3.1.1.3.2.1.1).
+var literalLengthBase = [maxLiteralLengthCode + 1]codeBase{
+	{0, 0}, {1, 0}, {2, 0}, {3, 0}, {4, 0}, {5, 0}, {6, 0}, {7, 0},
+	{8, 0}, {9, 0}, {10, 0}, {11, 0}, {12, 0}, {13, 0}, {14, 0}, {15, 0},
+	{16, 1}, {18, 1}, {20, 1}, {22, 1}, {24, 2}, {28, 2}, {32, 3}, {40, 3},
+	{48, 4}, {64, 6}, {128, 7}, {256, 8}, {512, 9}, {1024, 10}, {2048, 11}, {4096, 12},
+	{8192, 13}, {16384, 14}, {32768, 15}, {65536, 16},
+}
+
+// matchLengthBase is the value of each match length code
+// (RFC 8878 section 3.1.1.3.2.1.1).
+var matchLengthBase = [maxMatchLengthCode + 1]codeBase{
+	{3, 0}, {4, 0}, {5, 0}, {6, 0}, {7, 0}, {8, 0}, {9, 0}, {10, 0},
+	{11, 0}, {12, 0}, {13, 0}, {14, 0}, {15, 0}, {16, 0}, {17, 0}, {18, 0},
+	{19, 0}, {20, 0}, {21, 0}, {22, 0}, {23, 0}, {24, 0}, {25, 0}, {26, 0},
+	{27, 0}, {28, 0}, {29, 0}, {30, 0}, {31, 0}, {32, 0}, {33, 0}, {34, 0},
+	{35, 1}, {37, 1}, {39, 1}, {41, 1}, {43, 2}, {47, 2}, {51, 3}, {59, 3},
+	{67, 4}, {83, 4}, {99, 5}, {131, 7}, {259, 8}, {515, 9}, {1027, 10}, {2051, 11},
+	{4099, 12}, {8195, 13}, {16387, 14}, {32771, 15}, {65539, 16},
+}
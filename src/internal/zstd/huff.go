@@ -0,0 +1,216 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+const maxHuffmanBits = 11 // longest Huffman code
+
+// A huffEntry is an entry in a Huffman decoding table, which is indexed
+// by the next maxBits bits of the stream.
+type huffEntry struct {
+	sym  uint8
+	bits uint8 // length of the code
+}
+
+// A huffTable is a Huffman decoding table. A nil table means that
+// no table has been read yet.
+type huffTable struct {
+	entries [1 << maxHuffmanBits]huffEntry
+	maxBits uint32
+}
+
+// readHuffman reads a Huffman tree description (RFC 8878 section 4.2.1)
+// from data into t and returns the number of bytes read.
+func readHuffman(data []byte, t *huffTable) (int, error) {
+	if len(data) == 0 {
+		return 0, errCorrupt("missing Huffman tree description")
+	}
+	var weights [256]uint8
+	nw := 0
+	hdr := int(data[0])
+	n := 0
+	if hdr < 128 {
+		// The weights are FSE-compressed.
+		n = 1 + hdr
+		if n > len(data) {
+			return 0, errCorrupt("Huffman weights truncated")
+		}
+		var table [1 << 6]fseEntry
+		log, used, err := readFSE(data[1:n], 11, 6, table[:])
+		if err != nil {
+			return 0, err
+		}
+		var r backwardBitReader
+		if err := r.init(data[1+used : n]); err != nil {
+			return 0, err
+		}
+		// Two interleaved states decode the weights until
+		// the bit stream is exhausted.
+		s1 := table[r.val(uint32(log))]
+		s2 := table[r.val(uint32(log))]
+		for {
+			if nw >= 255 {
+				return 0, errCorrupt("too many Huffman weights")
+			}
+			weights[nw] = s1.sym
+			nw++
+			if r.remaining() < int(s1.bits) {
+				weights[nw] = s2.sym
+				nw++
+				break
+			}
+			s1 = table[int(s1.base)+int(r.val(uint32(s1.bits)))]
+
+			if nw >= 255 {
+				return 0, errCorrupt("too many Huffman weights")
+			}
+			weights[nw] = s2.sym
+			nw++
+			if r.remaining() < int(s2.bits) {
+				if nw >= 255 {
+					return 0, errCorrupt("too many Huffman weights")
+				}
+				weights[nw] = s1.sym
+				nw++
+				break
+			}
+			s2 = table[int(s2.base)+int(r.val(uint32(s2.bits)))]
+		}
+	} else {
+		// The weights are stored directly, 4 bits each.
+		nw = hdr - 127
+		n = 1 + (nw+1)/2
+		if n > len(data) {
+			return 0, errCorrupt("Huffman weights truncated")
+		}
+		for i := 0; i < nw; i++ {
+			b := data[1+i/2]
+			if i%2 == 0 {
+				weights[i] = b >> 4
+			} else {
+				weights[i] = b & 0xf
+			}
+		}
+	}
+	if err := buildHuffman(weights[:nw], t); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// buildHuffman builds the decoding table for the given weights of all
+// but the last symbol, whose weight is implied.
+func buildHuffman(weights []uint8, t *huffTable) error {
+	var total uint32
+	for _, w := range weights {
+		if w > maxHuffmanBits {
+			return errCorrupt("invalid Huffman weight")
+		}
+		if w > 0 {
+			total += 1 << (w - 1)
+		}
+	}
+	if total == 0 {
+		return errCorrupt("invalid Huffman weights")
+	}
+	maxBits := uint32(bits.Len32(total))
+	if maxBits > maxHuffmanBits {
+		return errCorrupt("Huffman table too large")
+	}
+	// The last weight brings the total up to a power of two.
+	left := uint32(1)<<maxBits - total
+	if left&(left-1) != 0 {
+		return errCorrupt("invalid Huffman weights")
+	}
+	lastWeight := uint8(bits.Len32(left))
+	if len(weights) >= 256 {
+		return errCorrupt("too many Huffman weights")
+	}
+	var all [256]uint8
+	copy(all[:], weights)
+	all[len(weights)] = lastWeight
+	nsym := len(weights) + 1
+
+	// Codes are assigned in order of increasing weight,
+	// and then of increasing symbol.
+	var start [maxHuffmanBits + 2]uint32
+	for _, w := range all[:nsym] {
+		if w > 0 {
+			start[w] += 1 << (w - 1)
+		}
+	}
+	pos := uint32(0)
+	for w := 1; w <= maxHuffmanBits+1; w++ {
+		c := start[w]
+		start[w] = pos
+		pos += c
+	}
+	for s, w := range all[:nsym] {
+		if w == 0 {
+			continue
+		}
+		n := uint32(1) << (w - 1)
+		e := huffEntry{sym: uint8(s), bits: uint8(maxBits + 1 - uint32(w))}
+		for i := start[w]; i < start[w]+n; i++ {
+			t.entries[i] = e
+		}
+		start[w] += n
+	}
+	t.maxBits = maxBits
+	return nil
+}
+
+// decodeHuffman appends the n symbols encoded in the Huffman stream
+// data to out.
+func decodeHuffman(out []byte, data []byte, n int, t *huffTable) ([]byte, error) {
+	var r backwardBitReader
+	if err := r.init(data); err != nil {
+		return nil, err
+	}
+	for i := 0; i < n; i++ {
+		e := t.entries[r.peek(t.maxBits)]
+		r.skip(uint32(e.bits))
+		out = append(out, e.sym)
+	}
+	if r.remaining() != 0 {
+		return nil, errCorrupt("bad Huffman stream length")
+	}
+	return out, nil
+}
+
+// decodeHuffman4 is like decodeHuffman for data holding four streams
+// preceded by a jump table.
+func decodeHuffman4(out []byte, data []byte, n int, t *huffTable) ([]byte, error) {
+	if len(data) < 6 {
+		return nil, errCorrupt("missing Huffman jump table")
+	}
+	s1 := int(binary.LittleEndian.Uint16(data))
+	s2 := s1 + int(binary.LittleEndian.Uint16(data[2:]))
+	s3 := s2 + int(binary.LittleEndian.Uint16(data[4:]))
+	data = data[6:]
+	if s3 > len(data) {
+		return nil, errCorrupt("bad Huffman jump table")
+	}
+	per := (n + 3) / 4
+	if 3*per > n {
+		return nil, errCorrupt("bad Huffman stream sizes")
+	}
+	var err error
+	streams := [4][]byte{data[:s1], data[s1:s2], data[s2:s3], data[s3:]}
+	for i, s := range streams {
+		m := per
+		if i == 3 {
+			m = n - 3*per
+		}
+		if out, err = decodeHuffman(out, s, m, t); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
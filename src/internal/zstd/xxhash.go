@@ -0,0 +1,113 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// This file implements the 64-bit xxHash algorithm with a seed of 0,
+// the low 32 bits of which are the zstd content checksum.
+// See https://github.com/Cyan4973/xxHash/blob/dev/doc/xxhash_spec.md.
+
+const (
+	xxhPrime1 uint64 = 0x9E3779B185EBCA87
+	xxhPrime2 uint64 = 0xC2B2AE3D27D4EB4F
+	xxhPrime3 uint64 = 0x165667B19E3779F9
+	xxhPrime4 uint64 = 0x85EBCA77C2B2AE63
+	xxhPrime5 uint64 = 0x27D4EB2F165667C5
+)
+
+// An xxhash computes an xxHash64 checksum incrementally.
+type xxhash struct {
+	v     [4]uint64
+	buf   [32]byte
+	nbuf  int
+	total uint64
+}
+
+func (h *xxhash) reset() {
+	p1, p2 := xxhPrime1, xxhPrime2 // variables, as the sums overflow
+	h.v[0] = p1 + p2
+	h.v[1] = p2
+	h.v[2] = 0
+	h.v[3] = -p1
+	h.nbuf = 0
+	h.total = 0
+}
+
+func xxhRound(acc, lane uint64) uint64 {
+	acc += lane * xxhPrime2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * xxhPrime1
+}
+
+func xxhMerge(acc, v uint64) uint64 {
+	acc ^= xxhRound(0, v)
+	return acc*xxhPrime1 + xxhPrime4
+}
+
+func (h *xxhash) write(p []byte) {
+	h.total += uint64(len(p))
+	if h.nbuf > 0 {
+		n := copy(h.buf[h.nbuf:], p)
+		h.nbuf += n
+		p = p[n:]
+		if h.nbuf < len(h.buf) {
+			return
+		}
+		h.stripe(h.buf[:])
+		h.nbuf = 0
+	}
+	for len(p) >= 32 {
+		h.stripe(p[:32])
+		p = p[32:]
+	}
+	h.nbuf = copy(h.buf[:], p)
+}
+
+func (h *xxhash) stripe(p []byte) {
+	h.v[0] = xxhRound(h.v[0], binary.LittleEndian.Uint64(p))
+	h.v[1] = xxhRound(h.v[1], binary.LittleEndian.Uint64(p[8:]))
+	h.v[2] = xxhRound(h.v[2], binary.LittleEndian.Uint64(p[16:]))
+	h.v[3] = xxhRound(h.v[3], binary.LittleEndian.Uint64(p[24:]))
+}
+
+func (h *xxhash) sum64() uint64 {
+	var acc uint64
+	if h.total >= 32 {
+		acc = bits.RotateLeft64(h.v[0], 1) + bits.RotateLeft64(h.v[1], 7) +
+			bits.RotateLeft64(h.v[2], 12) + bits.RotateLeft64(h.v[3], 18)
+		for _, v := range h.v {
+			acc = xxhMerge(acc, v)
+		}
+	} else {
+		acc = h.v[2] + xxhPrime5
+	}
+	acc += h.total
+
+	p := h.buf[:h.nbuf]
+	for ; len(p) >= 8; p = p[8:] {
+		acc ^= xxhRound(0, binary.LittleEndian.Uint64(p))
+		acc = bits.RotateLeft64(acc, 27)*xxhPrime1 + xxhPrime4
+	}
+	if len(p) >= 4 {
+		acc ^= uint64(binary.LittleEndian.Uint32(p)) * xxhPrime1
+		acc = bits.RotateLeft64(acc, 23)*xxhPrime2 + xxhPrime3
+		p = p[4:]
+	}
+	for _, b := range p {
+		acc ^= uint64(b) * xxhPrime5
+		acc = bits.RotateLeft64(acc, 11) * xxhPrime1
+	}
+
+	acc ^= acc >> 33
+	acc *= xxhPrime2
+	acc ^= acc >> 29
+	acc *= xxhPrime3
+	acc ^= acc >> 32
+	return acc
+}
@@ -0,0 +1,350 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package zstd implements the Zstandard compressed data format
+// described in RFC 8878.
+//
+// The Reader decompresses any Zstandard stream that does not use
+// a dictionary. The Writer produces valid but simple Zstandard streams,
+// trading compression ratio for speed and a small implementation.
+package zstd
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const (
+	frameMagic         = 0xFD2FB528
+	skippableMagicMask = 0xFFFFFFF0
+	skippableMagic     = 0x184D2A50
+)
+
+// maxWindowSize is the largest window size the Reader accepts,
+// which bounds the memory it uses.
+const maxWindowSize = 1 << 27
+
+// Block types.
+const (
+	blockRaw        = 0
+	blockRLE        = 1
+	blockCompressed = 2
+)
+
+// ErrChecksum is returned when reading a frame whose content
+// checksum does not match the decompressed data.
+var ErrChecksum = errors.New("zstd: checksum error")
+
+type corruptError string
+
+func (e corruptError) Error() string {
+	return "zstd: corrupt input: " + string(e)
+}
+
+func errCorrupt(msg string) error {
+	return corruptError(msg)
+}
+
+// A Reader decompresses a Zstandard stream, which is a sequence
+// of frames.
+type Reader struct {
+	r   io.Reader
+	err error // sticky error, io.EOF at the end of the stream
+
+	// hist holds the decompressed data, starting with the window of
+	// earlier output that matches may refer to. The bytes from out
+	// onward have not been returned by Read yet.
+	hist []byte
+	out  int
+
+	sawFrame  bool   // whether a frame has been read
+	inFrame   bool   // whether the next block belongs to the current frame
+	window    uint32 // window size of the current frame
+	blockMax  int    // maximum decompressed size of a block
+	checksum  bool   // whether the frame ends with a content checksum
+	size      uint64 // frame content size, if known
+	sizeKnown bool
+	produced  uint64 // bytes produced in the current frame
+	hash      xxhash
+
+	// State that carries over between the blocks of a frame.
+	reps      [3]uint32
+	huff      *huffTable
+	huffValid bool
+	llTable   seqTable
+	ofTable   seqTable
+	mlTable   seqTable
+
+	block   []byte // compressed block data
+	lits    []byte // decoded literals
+	scratch [18]byte
+}
+
+// NewReader returns a new Reader decompressing data read from r.
+func NewReader(r io.Reader) *Reader {
+	z := new(Reader)
+	z.Reset(r)
+	return z
+}
+
+// Reset discards the Reader's state and makes it equivalent to the
+// result of NewReader(r), reusing its buffers.
+func (z *Reader) Reset(r io.Reader) {
+	z.r = r
+	z.err = nil
+	z.hist = z.hist[:0]
+	z.out = 0
+	z.sawFrame = false
+	z.inFrame = false
+}
+
+// Read reads decompressed data into p.
+func (z *Reader) Read(p []byte) (int, error) {
+	for {
+		if z.out < len(z.hist) {
+			n := copy(p, z.hist[z.out:])
+			z.out += n
+			return n, nil
+		}
+		if z.err != nil {
+			return 0, z.err
+		}
+		if len(p) == 0 {
+			return 0, nil
+		}
+		if !z.inFrame {
+			z.err = z.readFrameHeader()
+		} else {
+			z.err = z.readBlock()
+		}
+	}
+}
+
+// Close implements io.Closer. It does not close the underlying reader.
+func (z *Reader) Close() error {
+	return nil
+}
+
+// readFull is io.ReadFull, except that it reports a truncated
+// stream as io.ErrUnexpectedEOF even if nothing was read.
+func (z *Reader) readFull(b []byte) error {
+	_, err := io.ReadFull(z.r, b)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// readFrameHeader reads the next frame header (RFC 8878 section 3.1.1.1),
+// skipping any skippable frames. It returns io.EOF at the end of the stream.
+func (z *Reader) readFrameHeader() error {
+	var magic uint32
+	for {
+		b := z.scratch[:4]
+		if _, err := io.ReadFull(z.r, b); err != nil {
+			if err == io.EOF && !z.sawFrame {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		magic = binary.LittleEndian.Uint32(b)
+		if magic&skippableMagicMask != skippableMagic {
+			break
+		}
+		if err := z.readFull(b); err != nil {
+			return err
+		}
+		size := int64(binary.LittleEndian.Uint32(b))
+		if n, err := io.CopyN(io.Discard, z.r, size); err != nil {
+			if n < size && err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		z.sawFrame = true
+	}
+	if magic != frameMagic {
+		return errCorrupt("bad magic number")
+	}
+
+	b := z.scratch[:1]
+	if err := z.readFull(b); err != nil {
+		return err
+	}
+	desc := b[0]
+	fcsFlag := desc >> 6
+	single := desc&0x20 != 0
+	if desc&0x08 != 0 {
+		return errCorrupt("reserved frame header bit set")
+	}
+	z.checksum = desc&0x04 != 0
+	dictIDSize := [4]int{0, 1, 2, 4}[desc&3]
+	fcsSize := [4]int{0, 2, 4, 8}[fcsFlag]
+	if fcsFlag == 0 && single {
+		fcsSize = 1
+	}
+	windowSize := 0
+	if !single {
+		windowSize = 1
+	}
+
+	b = z.scratch[:windowSize+dictIDSize+fcsSize]
+	if err := z.readFull(b); err != nil {
+		return err
+	}
+	var window uint64
+	if !single {
+		exp := b[0] >> 3
+		mantissa := uint64(b[0] & 7)
+		base := uint64(1) << (10 + exp)
+		window = base + base/8*mantissa
+		b = b[1:]
+	}
+	var dictID uint32
+	switch dictIDSize {
+	case 1:
+		dictID = uint32(b[0])
+	case 2:
+		dictID = uint32(binary.LittleEndian.Uint16(b))
+	case 4:
+		dictID = binary.LittleEndian.Uint32(b)
+	}
+	if dictID != 0 {
+		return errors.New("zstd: dictionaries are not supported")
+	}
+	b = b[dictIDSize:]
+	z.sizeKnown = fcsSize > 0
+	switch fcsSize {
+	case 1:
+		z.size = uint64(b[0])
+	case 2:
+		z.size = uint64(binary.LittleEndian.Uint16(b)) + 256
+	case 4:
+		z.size = uint64(binary.LittleEndian.Uint32(b))
+	case 8:
+		z.size = binary.LittleEndian.Uint64(b)
+	}
+	if single {
+		window = z.size
+	}
+	if window > maxWindowSize {
+		return errors.New("zstd: window size too large")
+	}
+	z.window = uint32(window)
+	z.blockMax = maxBlockSize
+	if int(window) < z.blockMax {
+		z.blockMax = int(window)
+	}
+
+	// Start a new frame, keeping only unread output.
+	z.hist = z.hist[:copy(z.hist, z.hist[z.out:])]
+	z.out = 0
+	z.produced = 0
+	z.hash.reset()
+	z.reps = [3]uint32{1, 4, 8}
+	z.huffValid = false
+	z.llTable.table = nil
+	z.ofTable.table = nil
+	z.mlTable.table = nil
+	z.sawFrame = true
+	z.inFrame = true
+	return nil
+}
+
+// readBlock reads and decompresses the next block of the current frame
+// (RFC 8878 section 3.1.1.2), appending its content to z.hist.
+func (z *Reader) readBlock() error {
+	// All output has been read, so only the window is needed.
+	// Discard older data once it would at least double the
+	// buffer, to copy each byte at most once.
+	if keep := int(z.window); len(z.hist)-keep >= keep && len(z.hist)-keep >= maxBlockSize {
+		z.hist = z.hist[:copy(z.hist, z.hist[len(z.hist)-keep:])]
+		z.out = len(z.hist)
+	}
+
+	b := z.scratch[:3]
+	if err := z.readFull(b); err != nil {
+		return err
+	}
+	hdr := uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+	last := hdr&1 != 0
+	typ := hdr >> 1 & 3
+	size := int(hdr >> 3)
+
+	start := len(z.hist)
+	switch typ {
+	case blockRaw:
+		if size > z.blockMax {
+			return errCorrupt("block too large")
+		}
+		z.hist = grow(z.hist, size)
+		if err := z.readFull(z.hist[start:]); err != nil {
+			return err
+		}
+	case blockRLE:
+		if size > z.blockMax {
+			return errCorrupt("block too large")
+		}
+		if err := z.readFull(b[:1]); err != nil {
+			return err
+		}
+		for i := 0; i < size; i++ {
+			z.hist = append(z.hist, b[0])
+		}
+	case blockCompressed:
+		if size > z.blockMax {
+			return errCorrupt("block too large")
+		}
+		if cap(z.block) < size {
+			z.block = make([]byte, size, maxBlockSize)
+		}
+		z.block = z.block[:size]
+		if err := z.readFull(z.block); err != nil {
+			return err
+		}
+		if err := z.compressedBlock(z.block); err != nil {
+			return err
+		}
+		if len(z.hist)-start > z.blockMax {
+			return errCorrupt("block too large")
+		}
+	default:
+		return errCorrupt("reserved block type")
+	}
+
+	z.produced += uint64(len(z.hist) - start)
+	if z.sizeKnown && z.produced > z.size {
+		return errCorrupt("frame content size mismatch")
+	}
+	if z.checksum {
+		z.hash.write(z.hist[start:])
+	}
+	if !last {
+		return nil
+	}
+
+	z.inFrame = false
+	if z.sizeKnown && z.produced != z.size {
+		return errCorrupt("frame content size mismatch")
+	}
+	if z.checksum {
+		b := z.scratch[:4]
+		if err := z.readFull(b); err != nil {
+			return err
+		}
+		if binary.LittleEndian.Uint32(b) != uint32(z.hash.sum64()) {
+			return ErrChecksum
+		}
+	}
+	return nil
+}
+
+// grow returns b extended by n bytes.
+func grow(b []byte, n int) []byte {
+	if len(b)+n <= cap(b) {
+		return b[:len(b)+n]
+	}
+	return append(b, make([]byte, n)...)
+}
@@ -0,0 +1,208 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package zstd
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func readFile(t *testing.T, name string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestReaderFiles(t *testing.T) {
+	gettysburg := readFile(t, "../../compress/testdata/gettysburg.txt")
+	tests := []struct {
+		file string
+		want []byte
+	}{
+		{"gettysburg.txt.zst", gettysburg},
+		{"e.txt.zst", readFile(t, "../../compress/testdata/e.txt")},
+		{"pi.txt.zst", readFile(t, "../../compress/testdata/pi.txt")},
+		{"empty.zst", nil},
+		{"multi.zst", append(append([]byte{}, gettysburg...), gettysburg...)},
+	}
+	for _, tt := range tests {
+		data := readFile(t, "testdata/"+tt.file)
+		got, err := io.ReadAll(NewReader(bytes.NewReader(data)))
+		if err != nil {
+			t.Errorf("%s: %v", tt.file, err)
+			continue
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: decompressed %d bytes, want %d", tt.file, len(got), len(tt.want))
+		}
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	data := readFile(t, "testdata/gettysburg.txt.zst")
+	// Flip a byte of the checksum.
+	bad := append([]byte{}, data...)
+	bad[len(bad)-1] ^= 1
+	if _, err := io.ReadAll(NewReader(bytes.NewReader(bad))); err != ErrChecksum {
+		t.Errorf("bad checksum: got %v, want %v", err, ErrChecksum)
+	}
+
+	for _, n := range []int{0, 3, 6, len(data) / 2, len(data) - 1} {
+		_, err := io.ReadAll(NewReader(bytes.NewReader(data[:n])))
+		if err != io.ErrUnexpectedEOF {
+			t.Errorf("truncated to %d bytes: got %v, want %v", n, err, io.ErrUnexpectedEOF)
+		}
+	}
+
+	_, err := io.ReadAll(NewReader(strings.NewReader("not zstd data")))
+	if err == nil || !strings.Contains(err.Error(), "bad magic number") {
+		t.Errorf("bad magic: got %v", err)
+	}
+}
+
+// TestReaderCorrupt checks that the Reader does not panic on damaged input.
+func TestReaderCorrupt(t *testing.T) {
+	data := readFile(t, "testdata/pi.txt.zst")
+	bad := make([]byte, len(data))
+	for i := 0; i < len(data); i += 97 {
+		copy(bad, data)
+		bad[i] ^= 0x5a
+		io.Copy(io.Discard, NewReader(bytes.NewReader(bad)))
+	}
+}
+
+func TestReaderReset(t *testing.T) {
+	data := readFile(t, "testdata/gettysburg.txt.zst")
+	want := readFile(t, "../../compress/testdata/gettysburg.txt")
+	r := NewReader(bytes.NewReader(data[:10]))
+	if _, err := io.ReadAll(r); err == nil {
+		t.Fatal("truncated input: no error")
+	}
+	r.Reset(bytes.NewReader(data))
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("decompressed %d bytes after Reset, want %d", len(got), len(want))
+	}
+}
+
+// testData returns inputs for round-trip tests.
+func testData(t *testing.T) map[string][]byte {
+	rnd := make([]byte, 300000)
+	x := uint32(1)
+	for i := range rnd {
+		x = x*1664525 + 1013904223
+		rnd[i] = byte(x >> 24)
+	}
+	return map[string][]byte{
+		"empty":      nil,
+		"byte":       {'x'},
+		"zeros":      make([]byte, 1<<20),
+		"random":     rnd,
+		"gettysburg": readFile(t, "../../compress/testdata/gettysburg.txt"),
+		"e":          readFile(t, "../../compress/testdata/e.txt"),
+		"opticks":    readFile(t, "../../testdata/Isaac.Newton-Opticks.txt"),
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for name, data := range testData(t) {
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		// Write in uneven pieces to exercise buffering.
+		for rest := data; len(rest) > 0; {
+			n := 1 + len(rest)/3
+			if _, err := w.Write(rest[:n]); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			rest = rest[n:]
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(data) > 1000 && name != "random" && buf.Len() >= len(data) {
+			t.Errorf("%s: compressed %d bytes to %d", name, len(data), buf.Len())
+		}
+		got, err := io.ReadAll(NewReader(&buf))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("%s: round trip of %d bytes returned %d different bytes", name, len(data), len(got))
+		}
+	}
+}
+
+func TestWriterReset(t *testing.T) {
+	data := readFile(t, "../../compress/testdata/gettysburg.txt")
+	var buf1, buf2 bytes.Buffer
+	w := NewWriter(&buf1)
+	w.Write(data)
+	w.Close()
+	w.Reset(&buf2)
+	w.Write(data)
+	w.Close()
+	if !bytes.Equal(buf1.Bytes(), buf2.Bytes()) {
+		t.Error("output differs after Reset")
+	}
+	if _, err := w.Write(data); err == nil {
+		t.Error("Write after Close succeeded")
+	}
+}
+
+// TestCLI checks the Reader and Writer against the zstd command,
+// if it is installed.
+func TestCLI(t *testing.T) {
+	zstd, err := exec.LookPath("zstd")
+	if err != nil {
+		t.Skip("zstd command not found")
+	}
+	if testing.Short() {
+		t.Skip("skipping in short mode")
+	}
+	for name, data := range testData(t) {
+		for _, level := range []string{"-1", "-19", "--long=24"} {
+			cmd := exec.Command(zstd, "-c", "-q", level)
+			cmd.Stdin = bytes.NewReader(data)
+			compressed, err := cmd.Output()
+			if err != nil {
+				t.Fatalf("%s %s: %v", zstd, level, err)
+			}
+			got, err := io.ReadAll(NewReader(bytes.NewReader(compressed)))
+			if err != nil {
+				t.Errorf("%s, zstd %s: %v", name, level, err)
+			} else if !bytes.Equal(got, data) {
+				t.Errorf("%s, zstd %s: decompressed data differs", name, level)
+			}
+		}
+
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		w.Write(data)
+		w.Close()
+		cmd := exec.Command(zstd, "-d", "-c", "-q")
+		cmd.Stdin = &buf
+		got, err := cmd.Output()
+		var ee *exec.ExitError
+		if errors.As(err, &ee) {
+			err = errors.New(string(ee.Stderr))
+		}
+		if err != nil {
+			t.Errorf("%s: zstd -d: %v", name, err)
+		} else if !bytes.Equal(got, data) {
+			t.Errorf("%s: zstd -d: decompressed data differs", name)
+		}
+	}
+}
@@ -0,0 +1,848 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http/internal/quic"
+	"net/http/internal/quic/quicwire"
+	"net/textproto"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// Stream types.
+//
+// For unidirectional streams, the value is the stream type sent over the wire.
+//
+// For bidirectional streams (which are always request streams),
+// the value is arbitrary and never sent on the wire.
+type http3streamType int64
+
+const (
+	// Bidirectional request stream.
+	// All bidirectional streams are request streams.
+	// This stream type is never sent over the wire.
+	//
+	// https://www.rfc-editor.org/rfc/rfc9114.html#section-6.1
+	http3streamTypeRequest = http3streamType(-1)
+
+	// https://www.rfc-editor.org/rfc/rfc9114.html#section-6.2
+	http3streamTypeControl = http3streamType(0x00)
+	http3streamTypePush    = http3streamType(0x01)
+
+	// https://www.rfc-editor.org/rfc/rfc9204.html#section-4.2
+	http3streamTypeEncoder = http3streamType(0x02)
+	http3streamTypeDecoder = http3streamType(0x03)
+)
+
+// canceledCtx is a canceled Context.
+// Used for performing non-blocking QUIC operations.
+var http3canceledCtx = func() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}()
+
+func (stype http3streamType) String() string {
+	switch stype {
+	case http3streamTypeRequest:
+		return "request"
+	case http3streamTypeControl:
+		return "control"
+	case http3streamTypePush:
+		return "push"
+	case http3streamTypeEncoder:
+		return "encoder"
+	case http3streamTypeDecoder:
+		return "decoder"
+	default:
+		return "unknown"
+	}
+}
+
+// Frame types.
+type http3frameType int64
+
+const (
+	// https://www.rfc-editor.org/rfc/rfc9114.html#section-7.2
+	http3frameTypeData        = http3frameType(0x00)
+	http3frameTypeHeaders     = http3frameType(0x01)
+	http3frameTypeCancelPush  = http3frameType(0x03)
+	http3frameTypeSettings    = http3frameType(0x04)
+	http3frameTypePushPromise = http3frameType(0x05)
+	http3frameTypeGoaway      = http3frameType(0x07)
+	http3frameTypeMaxPushID   = http3frameType(0x0d)
+)
+
+func (ftype http3frameType) String() string {
+	switch ftype {
+	case http3frameTypeData:
+		return "DATA"
+	case http3frameTypeHeaders:
+		return "HEADERS"
+	case http3frameTypeCancelPush:
+		return "CANCEL_PUSH"
+	case http3frameTypeSettings:
+		return "SETTINGS"
+	case http3frameTypePushPromise:
+		return "PUSH_PROMISE"
+	case http3frameTypeGoaway:
+		return "GOAWAY"
+	case http3frameTypeMaxPushID:
+		return "MAX_PUSH_ID"
+	default:
+		return fmt.Sprintf("UNKNOWN_%d", int64(ftype))
+	}
+}
+
+// http3Error is an HTTP/3 error code.
+type http3Error int
+
+const (
+	// https://www.rfc-editor.org/rfc/rfc9114.html#section-8.1
+	http3errH3NoError              = http3Error(0x0100)
+	http3errH3GeneralProtocolError = http3Error(0x0101)
+	http3errH3InternalError        = http3Error(0x0102)
+	http3errH3StreamCreationError  = http3Error(0x0103)
+	http3errH3ClosedCriticalStream = http3Error(0x0104)
+	http3errH3FrameUnexpected      = http3Error(0x0105)
+	http3errH3FrameError           = http3Error(0x0106)
+	http3errH3ExcessiveLoad        = http3Error(0x0107)
+	http3errH3IDError              = http3Error(0x0108)
+	http3errH3SettingsError        = http3Error(0x0109)
+	http3errH3MissingSettings      = http3Error(0x010a)
+	http3errH3RequestRejected      = http3Error(0x010b)
+	http3errH3RequestCancelled     = http3Error(0x010c)
+	http3errH3RequestIncomplete    = http3Error(0x010d)
+	http3errH3MessageError         = http3Error(0x010e)
+	http3errH3ConnectError         = http3Error(0x010f)
+	http3errH3VersionFallback      = http3Error(0x0110)
+
+	// https://www.rfc-editor.org/rfc/rfc9204.html#section-8.3
+	http3errQPACKDecompressionFailed = http3Error(0x0200)
+	http3errQPACKEncoderStreamError  = http3Error(0x0201)
+	http3errQPACKDecoderStreamError  = http3Error(0x0202)
+)
+
+func (e http3Error) Error() string {
+	switch e {
+	case http3errH3NoError:
+		return "H3_NO_ERROR"
+	case http3errH3GeneralProtocolError:
+		return "H3_GENERAL_PROTOCOL_ERROR"
+	case http3errH3InternalError:
+		return "H3_INTERNAL_ERROR"
+	case http3errH3StreamCreationError:
+		return "H3_STREAM_CREATION_ERROR"
+	case http3errH3ClosedCriticalStream:
+		return "H3_CLOSED_CRITICAL_STREAM"
+	case http3errH3FrameUnexpected:
+		return "H3_FRAME_UNEXPECTED"
+	case http3errH3FrameError:
+		return "H3_FRAME_ERROR"
+	case http3errH3ExcessiveLoad:
+		return "H3_EXCESSIVE_LOAD"
+	case http3errH3IDError:
+		return "H3_ID_ERROR"
+	case http3errH3SettingsError:
+		return "H3_SETTINGS_ERROR"
+	case http3errH3MissingSettings:
+		return "H3_MISSING_SETTINGS"
+	case http3errH3RequestRejected:
+		return "H3_REQUEST_REJECTED"
+	case http3errH3RequestCancelled:
+		return "H3_REQUEST_CANCELLED"
+	case http3errH3RequestIncomplete:
+		return "H3_REQUEST_INCOMPLETE"
+	case http3errH3MessageError:
+		return "H3_MESSAGE_ERROR"
+	case http3errH3ConnectError:
+		return "H3_CONNECT_ERROR"
+	case http3errH3VersionFallback:
+		return "H3_VERSION_FALLBACK"
+	case http3errQPACKDecompressionFailed:
+		return "QPACK_DECOMPRESSION_FAILED"
+	case http3errQPACKEncoderStreamError:
+		return "QPACK_ENCODER_STREAM_ERROR"
+	case http3errQPACKDecoderStreamError:
+		return "QPACK_DECODER_STREAM_ERROR"
+	}
+	return fmt.Sprintf("H3_ERROR_%v", int(e))
+}
+
+// A streamError is an error which terminates a stream, but not the connection.
+// https://www.rfc-editor.org/rfc/rfc9114.html#section-8-1
+type http3streamError struct {
+	code    http3Error
+	message string
+}
+
+func (e *http3streamError) Error() string { return e.message }
+func (e *http3streamError) Unwrap() error { return e.code }
+
+// A connectionError is an error which results in the entire connection closing.
+// https://www.rfc-editor.org/rfc/rfc9114.html#section-8-2
+type http3connectionError struct {
+	code    http3Error
+	message string
+}
+
+func (e *http3connectionError) Error() string { return e.message }
+func (e *http3connectionError) Unwrap() error { return e.code }
+
+const (
+	// https://www.rfc-editor.org/rfc/rfc9114.html#section-7.2.4.1
+	http3settingsMaxFieldSectionSize = 0x06
+
+	// https://www.rfc-editor.org/rfc/rfc9204.html#section-5
+	http3settingsQPACKMaxTableCapacity = 0x01
+	http3settingsQPACKBlockedStreams   = 0x07
+)
+
+// fieldLineSize returns the size of a field line, as limited by
+// SETTINGS_MAX_FIELD_SECTION_SIZE.
+//
+// https://www.rfc-editor.org/rfc/rfc9114.html#section-4.2.2-2
+func http3fieldLineSize(name, value string) int64 {
+	return int64(len(name) + len(value) + 32)
+}
+
+// writeSettings writes a complete SETTINGS frame.
+// Its parameter is a list of alternating setting types and values.
+func (st *http3stream) writeSettings(settings ...int64) {
+	var size int64
+	for _, s := range settings {
+		// Settings values that don't fit in a QUIC varint ([0,2^62)) will panic here.
+		size += int64(quicwire.SizeVarint(uint64(s)))
+	}
+	st.writeVarint(int64(http3frameTypeSettings))
+	st.writeVarint(size)
+	for _, s := range settings {
+		st.writeVarint(s)
+	}
+}
+
+// readSettings reads a complete SETTINGS frame, including the frame header.
+func (st *http3stream) readSettings(f func(settingType, value int64) error) error {
+	frameType, err := st.readFrameHeader()
+	if err != nil || frameType != http3frameTypeSettings {
+		return &http3connectionError{
+			code:    http3errH3MissingSettings,
+			message: "settings not sent on control stream",
+		}
+	}
+	for st.lim > 0 {
+		settingsType, err := st.readVarint()
+		if err != nil {
+			return err
+		}
+		settingsValue, err := st.readVarint()
+		if err != nil {
+			return err
+		}
+
+		// Use of HTTP/2 settings where there is no corresponding HTTP/3 setting
+		// is an error.
+		// https://www.rfc-editor.org/rfc/rfc9114.html#section-7.2.4.1-5
+		switch settingsType {
+		case 0x02, 0x03, 0x04, 0x05:
+			return &http3connectionError{
+				code:    http3errH3SettingsError,
+				message: "use of reserved setting",
+			}
+		}
+
+		if err := f(settingsType, settingsValue); err != nil {
+			return err
+		}
+	}
+	return st.endFrame()
+}
+
+// A stream wraps a QUIC stream, providing methods to read/write various values.
+type http3stream struct {
+	stream *quic.Stream
+
+	// lim is the current read limit.
+	// Reading a frame header sets the limit to the end of the frame.
+	// Reading past the limit or reading less than the limit and ending the frame
+	// results in an error.
+	// -1 indicates no limit.
+	lim int64
+}
+
+// newConnStream creates a new stream on a connection.
+// It writes the stream header for unidirectional streams.
+//
+// The stream returned by newStream is not flushed,
+// and will not be sent to the peer until the caller calls
+// Flush or writes enough data to the stream.
+func http3newConnStream(ctx context.Context, qconn *quic.Conn, stype http3streamType) (*http3stream, error) {
+	var qs *quic.Stream
+	var err error
+	if stype == http3streamTypeRequest {
+		// Request streams are bidirectional.
+		qs, err = qconn.NewStream(ctx)
+	} else {
+		// All other streams are unidirectional.
+		qs, err = qconn.NewSendOnlyStream(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	st := &http3stream{
+		stream: qs,
+		lim:    -1, // no limit
+	}
+	if stype != http3streamTypeRequest {
+		// Unidirectional stream header.
+		st.writeVarint(int64(stype))
+	}
+	return st, err
+}
+
+func http3newStream(qs *quic.Stream) *http3stream {
+	return &http3stream{
+		stream: qs,
+		lim:    -1, // no limit
+	}
+}
+
+// readFrameHeader reads the type and length fields of an HTTP/3 frame.
+// It sets the read limit to the end of the frame.
+//
+// https://www.rfc-editor.org/rfc/rfc9114.html#section-7.1
+func (st *http3stream) readFrameHeader() (ftype http3frameType, err error) {
+	if st.lim >= 0 {
+		// We shouldn't call readFrameHeader before ending the previous frame.
+		return 0, http3errH3FrameError
+	}
+	ftype, err = http3readVarint[http3frameType](st)
+	if err != nil {
+		return 0, err
+	}
+	size, err := st.readVarint()
+	if err != nil {
+		return 0, err
+	}
+	st.lim = size
+	return ftype, nil
+}
+
+// endFrame is called after reading a frame to reset the read limit.
+// It returns an error if the entire contents of a frame have not been read.
+func (st *http3stream) endFrame() error {
+	if st.lim != 0 {
+		return &http3connectionError{
+			code:    http3errH3FrameError,
+			message: "invalid HTTP/3 frame",
+		}
+	}
+	st.lim = -1
+	return nil
+}
+
+// readFrameData returns the remaining data in the current frame.
+func (st *http3stream) readFrameData() ([]byte, error) {
+	if st.lim < 0 {
+		return nil, http3errH3FrameError
+	}
+	// TODO: Pool buffers to avoid allocation here.
+	b := make([]byte, st.lim)
+	_, err := io.ReadFull(st, b)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// ReadByte reads one byte from the stream.
+func (st *http3stream) ReadByte() (b byte, err error) {
+	if err := st.recordBytesRead(1); err != nil {
+		return 0, err
+	}
+	b, err = st.stream.ReadByte()
+	if err != nil {
+		if err == io.EOF && st.lim < 0 {
+			return 0, io.EOF
+		}
+		return 0, http3errH3FrameError
+	}
+	return b, nil
+}
+
+// Read reads from the stream.
+func (st *http3stream) Read(b []byte) (int, error) {
+	n, err := st.stream.Read(b)
+	if e2 := st.recordBytesRead(n); e2 != nil {
+		return 0, e2
+	}
+	if err == io.EOF {
+		if st.lim == 0 {
+			// EOF at end of frame, ignore.
+			return n, nil
+		} else if st.lim > 0 {
+			// EOF inside frame, error.
+			return 0, http3errH3FrameError
+		} else {
+			// EOF outside of frame, surface to caller.
+			return n, io.EOF
+		}
+	}
+	if err != nil {
+		return 0, http3errH3FrameError
+	}
+	return n, nil
+}
+
+// discardUnknownFrame discards an unknown frame.
+//
+// HTTP/3 requires that unknown frames be ignored on all streams.
+// However, a known frame appearing in an unexpected place is a fatal error,
+// so this returns an error if the frame is one we know.
+func (st *http3stream) discardUnknownFrame(ftype http3frameType) error {
+	switch ftype {
+	case http3frameTypeData,
+		http3frameTypeHeaders,
+		http3frameTypeCancelPush,
+		http3frameTypeSettings,
+		http3frameTypePushPromise,
+		http3frameTypeGoaway,
+		http3frameTypeMaxPushID:
+		return &http3connectionError{
+			code:    http3errH3FrameUnexpected,
+			message: "unexpected " + ftype.String() + " frame",
+		}
+	}
+	return st.discardFrame()
+}
+
+// discardFrame discards any remaining data in the current frame and resets the read limit.
+func (st *http3stream) discardFrame() error {
+	// TODO: Consider adding a *quic.Stream method to discard some amount of data.
+	for i := int64(0); i < st.lim; i++ {
+		_, err := st.stream.ReadByte()
+		if err != nil {
+			return &http3streamError{http3errH3FrameError, err.Error()}
+		}
+	}
+	st.lim = -1
+	return nil
+}
+
+// Write writes to the stream.
+func (st *http3stream) Write(b []byte) (int, error) { return st.stream.Write(b) }
+
+// Flush commits data written to the stream.
+func (st *http3stream) Flush() error { return st.stream.Flush() }
+
+// readVarint reads a QUIC variable-length integer from the stream.
+func (st *http3stream) readVarint() (v int64, err error) {
+	b, err := st.stream.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	v = int64(b & 0x3f)
+	n := 1 << (b >> 6)
+	for i := 1; i < n; i++ {
+		b, err := st.stream.ReadByte()
+		if err != nil {
+			return 0, http3errH3FrameError
+		}
+		v = (v << 8) | int64(b)
+	}
+	if err := st.recordBytesRead(n); err != nil {
+		return 0, err
+	}
+	return v, nil
+}
+
+// readVarint reads a varint of a particular type.
+func http3readVarint[T ~int64 | ~uint64](st *http3stream) (T, error) {
+	v, err := st.readVarint()
+	return T(v), err
+}
+
+// writeVarint writes a QUIC variable-length integer to the stream.
+func (st *http3stream) writeVarint(v int64) {
+	switch {
+	case v <= (1<<6)-1:
+		st.stream.WriteByte(byte(v))
+	case v <= (1<<14)-1:
+		st.stream.WriteByte((1 << 6) | byte(v>>8))
+		st.stream.WriteByte(byte(v))
+	case v <= (1<<30)-1:
+		st.stream.WriteByte((2 << 6) | byte(v>>24))
+		st.stream.WriteByte(byte(v >> 16))
+		st.stream.WriteByte(byte(v >> 8))
+		st.stream.WriteByte(byte(v))
+	case v <= (1<<62)-1:
+		st.stream.WriteByte((3 << 6) | byte(v>>56))
+		st.stream.WriteByte(byte(v >> 48))
+		st.stream.WriteByte(byte(v >> 40))
+		st.stream.WriteByte(byte(v >> 32))
+		st.stream.WriteByte(byte(v >> 24))
+		st.stream.WriteByte(byte(v >> 16))
+		st.stream.WriteByte(byte(v >> 8))
+		st.stream.WriteByte(byte(v))
+	default:
+		panic("varint too large")
+	}
+}
+
+// recordBytesRead records that n bytes have been read.
+// It returns an error if the read passes the current limit.
+func (st *http3stream) recordBytesRead(n int) error {
+	if st.lim < 0 {
+		return nil
+	}
+	st.lim -= int64(n)
+	if st.lim < 0 {
+		st.stream = nil // panic if we try to read again
+		return &http3connectionError{
+			code:    http3errH3FrameError,
+			message: "invalid HTTP/3 frame",
+		}
+	}
+	return nil
+}
+
+type http3streamHandler interface {
+	handleControlStream(*http3stream) error
+	handlePushStream(*http3stream) error
+	handleEncoderStream(*http3stream) error
+	handleDecoderStream(*http3stream) error
+	handleRequestStream(*http3stream) error
+	abort(error)
+}
+
+type http3genericConn struct {
+	mu sync.Mutex
+
+	// The peer may create exactly one control, encoder, and decoder stream.
+	// streamsCreated is a bitset of streams created so far.
+	// Bits are 1 << streamType.
+	streamsCreated uint8
+}
+
+func (c *http3genericConn) acceptStreams(qconn *quic.Conn, h http3streamHandler) {
+	for {
+		// Use context.Background: This blocks until a stream is accepted
+		// or the connection closes.
+		st, err := qconn.AcceptStream(context.Background())
+		if err != nil {
+			return // connection closed
+		}
+		if st.IsReadOnly() {
+			go c.handleUnidirectionalStream(http3newStream(st), h)
+		} else {
+			go c.handleRequestStream(http3newStream(st), h)
+		}
+	}
+}
+
+func (c *http3genericConn) handleUnidirectionalStream(st *http3stream, h http3streamHandler) {
+	// Unidirectional stream header: One varint with the stream type.
+	v, err := st.readVarint()
+	if err != nil {
+		h.abort(&http3connectionError{
+			code:    http3errH3StreamCreationError,
+			message: "error reading unidirectional stream header",
+		})
+		return
+	}
+	stype := http3streamType(v)
+	if err := c.checkStreamCreation(stype); err != nil {
+		h.abort(err)
+		return
+	}
+	switch stype {
+	case http3streamTypeControl:
+		err = h.handleControlStream(st)
+	case http3streamTypePush:
+		err = h.handlePushStream(st)
+	case http3streamTypeEncoder:
+		err = h.handleEncoderStream(st)
+	case http3streamTypeDecoder:
+		err = h.handleDecoderStream(st)
+	default:
+		// "Recipients of unknown stream types MUST either abort reading
+		// of the stream or discard incoming data without further processing."
+		// https://www.rfc-editor.org/rfc/rfc9114.html#section-6.2-7
+		//
+		// We should send the H3_STREAM_CREATION_ERROR error code,
+		// but the quic package currently doesn't allow setting error codes
+		// for STOP_SENDING frames.
+		// TODO: Should CloseRead take an error code?
+		err = nil
+	}
+	if err == io.EOF {
+		err = &http3connectionError{
+			code:    http3errH3ClosedCriticalStream,
+			message: http3streamType(stype).String() + " stream closed",
+		}
+	}
+	c.handleStreamError(st, h, err)
+}
+
+func (c *http3genericConn) handleRequestStream(st *http3stream, h http3streamHandler) {
+	c.handleStreamError(st, h, h.handleRequestStream(st))
+}
+
+func (c *http3genericConn) handleStreamError(st *http3stream, h http3streamHandler, err error) {
+	switch err := err.(type) {
+	case *http3connectionError:
+		h.abort(err)
+	case nil:
+		st.stream.CloseRead()
+		st.stream.CloseWrite()
+	case *http3streamError:
+		st.stream.CloseRead()
+		st.stream.Reset(uint64(err.code))
+	default:
+		st.stream.CloseRead()
+		st.stream.Reset(uint64(http3errH3InternalError))
+	}
+}
+
+func (c *http3genericConn) checkStreamCreation(stype http3streamType) error {
+	switch stype {
+	case http3streamTypeControl, http3streamTypeEncoder, http3streamTypeDecoder:
+		// The peer may create exactly one control, encoder, and decoder stream.
+	default:
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	bit := uint8(1) << stype
+	if c.streamsCreated&bit != 0 {
+		return &http3connectionError{
+			code:    http3errH3StreamCreationError,
+			message: "multiple " + stype.String() + " streams created",
+		}
+	}
+	c.streamsCreated |= bit
+	return nil
+}
+
+// extractTrailerFromHeader extracts the "Trailer" header values from a header
+// map, and populates a trailer map with those values as keys. The extracted
+// header values will be canonicalized.
+func http3extractTrailerFromHeader(header, trailer Header) {
+	for _, names := range header["Trailer"] {
+		names = textproto.TrimString(names)
+		for _, name := range strings.Split(names, ",") {
+			name = textproto.CanonicalMIMEHeaderKey(textproto.TrimString(name))
+			if !httpguts.ValidTrailerHeader(name) {
+				continue
+			}
+			trailer[name] = nil
+		}
+	}
+}
+
+// A bodyWriter writes a request or response body to a stream
+// as a series of DATA frames.
+type http3bodyWriter struct {
+	st      *http3stream
+	remain  int64              // -1 when content-length is not known
+	flush   bool               // flush the stream after every write
+	name    string             // "request" or "response"
+	trailer Header             // trailer headers that will be written once bodyWriter is closed.
+	enc     *http3qpackEncoder // QPACK encoder used by the connection.
+}
+
+func (w *http3bodyWriter) write(ps ...[]byte) (n int, err error) {
+	var size int64
+	for _, p := range ps {
+		size += int64(len(p))
+	}
+	// If write is called with empty byte slices, just return instead of
+	// sending out a DATA frame containing nothing.
+	if size == 0 {
+		return 0, nil
+	}
+	if w.remain >= 0 && size > w.remain {
+		return 0, &http3streamError{
+			code:    http3errH3InternalError,
+			message: w.name + " body longer than specified content length",
+		}
+	}
+	w.st.writeVarint(int64(http3frameTypeData))
+	w.st.writeVarint(size)
+	for _, p := range ps {
+		var n2 int
+		n2, err = w.st.Write(p)
+		n += n2
+		if w.remain >= 0 {
+			w.remain -= int64(n)
+		}
+		if err != nil {
+			break
+		}
+	}
+	if w.flush && err == nil {
+		err = w.st.Flush()
+	}
+	if err != nil {
+		err = fmt.Errorf("writing %v body: %w", w.name, err)
+	}
+	return n, err
+}
+
+func (w *http3bodyWriter) Write(p []byte) (n int, err error) {
+	return w.write(p)
+}
+
+func (w *http3bodyWriter) Close() error {
+	if w.remain > 0 {
+		return errors.New(w.name + " body shorter than specified content length")
+	}
+	if len(w.trailer) > 0 {
+		encTrailer := w.enc.encode(func(f func(itype http3indexType, name, value string)) {
+			for name, values := range w.trailer {
+				if !httpguts.ValidHeaderFieldName(name) {
+					continue
+				}
+				for _, val := range values {
+					if !httpguts.ValidHeaderFieldValue(val) {
+						continue
+					}
+					f(http3mayIndex, name, val)
+				}
+			}
+		})
+		w.st.writeVarint(int64(http3frameTypeHeaders))
+		w.st.writeVarint(int64(len(encTrailer)))
+		w.st.Write(encTrailer)
+	}
+	if w.st != nil && w.st.stream != nil {
+		w.st.stream.CloseWrite()
+	}
+	return nil
+}
+
+// A bodyReader reads a request or response body from a stream.
+type http3bodyReader struct {
+	st *http3stream
+
+	mu     sync.Mutex
+	remain int64
+	err    error
+	// If not nil, the body contains an "Expect: 100-continue" header, and
+	// send100Continue should be called when Read is invoked for the first
+	// time.
+	send100Continue func()
+	// A map where the key represents the trailer header names we expect. If
+	// there is a HEADERS frame after reading DATA frames to EOF, the value of
+	// the headers will be written here, provided that the name of the header
+	// exists in the map already.
+	trailer Header
+}
+
+func (r *http3bodyReader) Read(p []byte) (n int, err error) {
+	// The HTTP/1 and HTTP/2 implementations both permit concurrent reads from a body,
+	// in the sense that the race detector won't complain.
+	// Use a mutex here to provide the same behavior.
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.send100Continue != nil {
+		r.send100Continue()
+		r.send100Continue = nil
+	}
+	if r.err != nil {
+		return 0, r.err
+	}
+	defer func() {
+		if err != nil {
+			r.err = err
+		}
+	}()
+	if r.st.lim == 0 {
+		// We've finished reading the previous DATA frame, so end it.
+		if err := r.st.endFrame(); err != nil {
+			return 0, err
+		}
+	}
+	// Read the next DATA frame header,
+	// if we aren't already in the middle of one.
+	for r.st.lim < 0 {
+		ftype, err := r.st.readFrameHeader()
+		if err == io.EOF && r.remain > 0 {
+			return 0, &http3streamError{
+				code:    http3errH3MessageError,
+				message: "body shorter than content-length",
+			}
+		}
+		if err != nil {
+			return 0, err
+		}
+		switch ftype {
+		case http3frameTypeData:
+			if r.remain >= 0 && r.st.lim > r.remain {
+				return 0, &http3streamError{
+					code:    http3errH3MessageError,
+					message: "body longer than content-length",
+				}
+			}
+			// Fall out of the loop and process the frame body below.
+		case http3frameTypeHeaders:
+			// This HEADERS frame contains the message trailers.
+			if r.remain > 0 {
+				return 0, &http3streamError{
+					code:    http3errH3MessageError,
+					message: "body shorter than content-length",
+				}
+			}
+			var dec http3qpackDecoder
+			if err := dec.decode(r.st, func(_ http3indexType, name, value string) error {
+				name = textproto.CanonicalMIMEHeaderKey(textproto.TrimString(name))
+				if _, ok := r.trailer[name]; ok {
+					r.trailer.Add(name, value)
+				}
+				return nil
+			}); err != nil {
+				return 0, err
+			}
+			if err := r.st.discardFrame(); err != nil {
+				return 0, err
+			}
+			return 0, io.EOF
+		default:
+			if err := r.st.discardUnknownFrame(ftype); err != nil {
+				return 0, err
+			}
+		}
+	}
+	// We are now reading the content of a DATA frame.
+	// Fill the read buffer or read to the end of the frame,
+	// whichever comes first.
+	if int64(len(p)) > r.st.lim {
+		p = p[:r.st.lim]
+	}
+	n, err = r.st.Read(p)
+	if r.remain > 0 {
+		r.remain -= int64(n)
+	}
+	return n, err
+}
+
+func (r *http3bodyReader) Close() error {
+	// Unlike the HTTP/1 and HTTP/2 body readers (at the time of this comment being written),
+	// calling Close concurrently with Read will interrupt the read.
+	r.st.stream.CloseRead()
+	// Make sure that any data that has already been written to bodyReader
+	// cannot be read after it has been closed.
+	r.err = net.ErrClosed
+	r.remain = 0
+	return nil
+}
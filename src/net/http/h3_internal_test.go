@@ -0,0 +1,289 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net/http/internal/quic"
+	"net/http/internal/testcert"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTTP3ParseAltSvc(t *testing.T) {
+	tests := []struct {
+		vv     []string
+		addr   string
+		maxAge time.Duration
+		clear  bool
+	}{
+		{vv: []string{`h3=":443"`}, addr: "example.com:443", maxAge: http3altSvcDefaultMaxAge},
+		{vv: []string{`h3="alt.example.com:8443"; ma=60`}, addr: "alt.example.com:8443", maxAge: time.Minute},
+		{vv: []string{`h2=":443", h3-29=":443"`, `h3=":9443";ma=3600;persist=1`}, addr: "example.com:9443", maxAge: time.Hour},
+		{vv: []string{`h3="[::1]:443"`}, addr: "[::1]:443", maxAge: http3altSvcDefaultMaxAge},
+		{vv: []string{`clear`}, clear: true},
+		{vv: []string{`h3=:443`}},
+		{vv: []string{`h3="example.com"`}},
+		{vv: []string{`h2=":443"`}},
+		{vv: []string{``}},
+	}
+	for _, tt := range tests {
+		now := time.Now()
+		alt, ok, clear := http3parseAltSvc("example.com:443", tt.vv)
+		if ok != (tt.addr != "") || clear != tt.clear {
+			t.Errorf("http3parseAltSvc(%q) = ok %v, clear %v; want %v, %v", tt.vv, ok, clear, tt.addr != "", tt.clear)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if alt.addr != tt.addr {
+			t.Errorf("http3parseAltSvc(%q) addr = %q, want %q", tt.vv, alt.addr, tt.addr)
+		}
+		if d := alt.expires.Sub(now); d < tt.maxAge-time.Minute || d > tt.maxAge+time.Minute {
+			t.Errorf("http3parseAltSvc(%q) max age = %v, want %v", tt.vv, d, tt.maxAge)
+		}
+	}
+}
+
+func TestHTTP3AltSvcCache(t *testing.T) {
+	var c http3altSvcCache
+	const origin = "example.com:443"
+	if _, ok := c.lookup(origin); ok {
+		t.Fatal("lookup in empty cache succeeded")
+	}
+	c.record(origin, Header{"Alt-Svc": {`h3=":8443"`}})
+	if alt, ok := c.lookup(origin); !ok || alt != "example.com:8443" {
+		t.Fatalf("lookup = %q, %v; want example.com:8443", alt, ok)
+	}
+
+	c.markBroken(origin, "example.com:8443")
+	if _, ok := c.lookup(origin); ok {
+		t.Error("lookup of broken alternative succeeded")
+	}
+	c.record(origin, Header{"Alt-Svc": {`h3=":8443"`}})
+	if _, ok := c.lookup(origin); ok {
+		t.Error("readvertising a broken alternative cleared its broken state")
+	}
+	c.record(origin, Header{"Alt-Svc": {`h3=":9443"`}})
+	if alt, ok := c.lookup(origin); !ok || alt != "example.com:9443" {
+		t.Errorf("lookup = %q, %v; want example.com:9443", alt, ok)
+	}
+
+	c.record(origin, Header{})
+	if _, ok := c.lookup(origin); !ok {
+		t.Error("response without Alt-Svc removed the alternative")
+	}
+	c.record(origin, Header{"Alt-Svc": {"clear"}})
+	if _, ok := c.lookup(origin); ok {
+		t.Error("Alt-Svc: clear did not remove the alternative")
+	}
+
+	c.record(origin, Header{"Alt-Svc": {`h3=":443"; ma=0`}})
+	time.Sleep(time.Millisecond)
+	if _, ok := c.lookup(origin); ok {
+		t.Error("lookup of expired alternative succeeded")
+	}
+}
+
+func TestHTTP3EncodeHeaders(t *testing.T) {
+	req, err := NewRequest("POST", "https://example.com/path?q=1", strings.NewReader("body"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header = Header{
+		"Connection": {"keep-alive"},
+		"Cookie":     {"a=1; b=2"},
+		"X-Foo":      {"bar"},
+	}
+	req.Trailer = Header{"X-Trailer": nil}
+	if err := http3checkRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	var got [][2]string
+	http3encodeHeaders(req, func(name, value string) {
+		got = append(got, [2]string{name, value})
+	})
+	want := [][2]string{
+		{":authority", "example.com"},
+		{":method", "POST"},
+		{":path", "/path?q=1"},
+		{":scheme", "https"},
+		{"trailer", "X-Trailer"},
+		{"cookie", "a=1"},
+		{"cookie", "b=2"},
+		{"x-foo", "bar"},
+		{"content-length", "4"},
+		{"user-agent", "Go-http-client/3"},
+	}
+	// Header fields are sent in map order.
+	if len(got) > 7 {
+		fields := got[5 : len(got)-2]
+		sort.SliceStable(fields, func(i, j int) bool { return fields[i][0] < fields[j][0] })
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("encoded fields:\n%q\nwant:\n%q", got, want)
+	}
+
+	for _, h := range []Header{
+		{"Upgrade": {"websocket"}},
+		{"Transfer-Encoding": {"gzip"}},
+		{"Connection": {"upgrade"}},
+		{"X-Bad": {"a\nb"}},
+	} {
+		req.Header = h
+		if err := http3checkRequest(req); err == nil {
+			t.Errorf("http3checkRequest accepted header %q", h)
+		}
+	}
+}
+
+// newHTTP3ConnPair returns a client and a server QUIC connection
+// connected over loopback UDP, using the "h3" protocol.
+func newHTTP3ConnPair(t *testing.T) (client, server *quic.Conn) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cert, err := tls.X509KeyPair(testcert.LocalhostCert, testcert.LocalhostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(testcert.LocalhostCert) {
+		t.Fatal("failed to parse test certificate")
+	}
+	se, err := quic.Listen("udp", "127.0.0.1:0", &quic.Config{
+		TLSConfig: &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS13,
+			NextProtos:   []string{"h3"},
+		},
+	})
+	if err != nil {
+		t.Skipf("cannot listen on UDP: %v", err)
+	}
+	t.Cleanup(func() { se.Close(http3canceledCtx) })
+	ce, err := quic.Listen("udp", "127.0.0.1:0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ce.Close(http3canceledCtx) })
+	client, err = ce.Dial(ctx, "udp", se.LocalAddr().String(), &quic.Config{
+		TLSConfig: &tls.Config{
+			RootCAs:    roots,
+			ServerName: "example.com",
+			MinVersion: tls.VersionTLS13,
+			NextProtos: []string{"h3"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err = se.Accept(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, server
+}
+
+func TestHTTP3QPACKStreams(t *testing.T) {
+	client, server := newHTTP3ConnPair(t)
+	for _, test := range []struct {
+		name    string
+		stream  string // "encoder" or "decoder"
+		data    []byte
+		wantErr http3Error // 0 for a clean end of stream
+	}{{
+		name:   "set capacity zero",
+		stream: "encoder",
+		data:   []byte{0x20},
+	}, {
+		name:    "set capacity",
+		stream:  "encoder",
+		data:    []byte{0x3f, 0x01}, // capacity 32
+		wantErr: http3errQPACKEncoderStreamError,
+	}, {
+		name:    "insert with literal name",
+		stream:  "encoder",
+		data:    []byte{0x41, 'a', 0x01, 'b'},
+		wantErr: http3errQPACKEncoderStreamError,
+	}, {
+		name:    "duplicate",
+		stream:  "encoder",
+		data:    []byte{0x00},
+		wantErr: http3errQPACKEncoderStreamError,
+	}, {
+		name:   "stream cancellation",
+		stream: "decoder",
+		data:   []byte{0x40, 0x7f, 0x01}, // streams 0 and 64
+	}, {
+		name:    "section acknowledgment",
+		stream:  "decoder",
+		data:    []byte{0x80},
+		wantErr: http3errQPACKDecoderStreamError,
+	}, {
+		name:    "insert count increment",
+		stream:  "decoder",
+		data:    []byte{0x01},
+		wantErr: http3errQPACKDecoderStreamError,
+	}} {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			cs, err := client.NewSendOnlyStream(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cs.Write(test.data)
+			cs.CloseWrite()
+			ss, err := server.AcceptStream(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			st := http3newStream(ss)
+			var enc http3qpackEncoder
+			var dec http3qpackDecoder
+			if test.stream == "encoder" {
+				err = dec.readEncoderStream(st)
+			} else {
+				err = enc.readDecoderStream(st)
+			}
+			if test.wantErr == 0 {
+				if err != io.EOF {
+					t.Errorf("read %v stream = %v, want io.EOF", test.stream, err)
+				}
+				return
+			}
+			var cerr *http3connectionError
+			if !errors.As(err, &cerr) || cerr.code != test.wantErr {
+				t.Errorf("read %v stream = %v, want connection error %v", test.stream, err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestHTTP3MaxFieldSectionSize(t *testing.T) {
+	client, _ := newHTTP3ConnPair(t)
+	tr := newHTTP3Transport(&Transport{})
+	cc, err := tr.newClientConn(context.Background(), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc.peerMaxFieldSectionSize = 200
+	req, err := NewRequest("GET", "https://example.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Large", strings.Repeat("a", 200))
+	if _, err := cc.RoundTrip(req); err != http3errRequestHeaderTooLarge {
+		t.Errorf("RoundTrip with header over the limit = %v, want %v", err, http3errRequestHeaderTooLarge)
+	}
+}
@@ -0,0 +1,868 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http/internal/quic"
+	"net/http/internal/quic/quicwire"
+	"net/textproto"
+	"net/url"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// http3server serves HTTP/3 requests for a Server on a single QUIC endpoint.
+type http3server struct {
+	srv      *Server
+	endpoint *quic.Endpoint
+
+	serveCtx       context.Context
+	serveCtxCancel context.CancelFunc
+
+	// connClosed is used to signal that a connection has been unregistered
+	// from activeConns. That way, when shutting down gracefully, the server
+	// can avoid busy-waiting for activeConns to be empty.
+	connClosed  chan struct{}
+	mu          sync.Mutex // guards activeConns
+	activeConns map[*http3serverConn]struct{}
+}
+
+// newHTTP3Server returns an HTTP/3 server for srv
+// on a QUIC endpoint using pc.
+func newHTTP3Server(srv *Server, pc net.PacketConn, tlsConfig *tls.Config) (*http3server, error) {
+	tlsConfig.NextProtos = []string{"h3"}
+	if tlsConfig.MinVersion < tls.VersionTLS13 {
+		tlsConfig.MinVersion = tls.VersionTLS13
+	}
+	e, err := quic.NewEndpoint(pc, &quic.Config{
+		TLSConfig:      tlsConfig,
+		MaxIdleTimeout: srv.idleTimeout(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.WithValue(context.Background(), ServerContextKey, srv)
+	ctx, cancel := context.WithCancel(ctx)
+	return &http3server{
+		srv:            srv,
+		endpoint:       e,
+		serveCtx:       ctx,
+		serveCtxCancel: cancel,
+		connClosed:     make(chan struct{}, 1),
+		activeConns:    make(map[*http3serverConn]struct{}),
+	}, nil
+}
+
+// port returns the UDP port the server is listening on.
+func (s *http3server) port() int {
+	return int(s.endpoint.LocalAddr().Port())
+}
+
+// serve accepts incoming connections on the QUIC endpoint
+// and handles requests from those connections.
+// It returns when the endpoint is closed or the server is shut down.
+func (s *http3server) serve() error {
+	defer s.endpoint.Close(http3canceledCtx)
+	for {
+		qconn, err := s.endpoint.Accept(s.serveCtx)
+		if err != nil {
+			return err
+		}
+		go s.newServerConn(qconn)
+	}
+}
+
+// close immediately closes the endpoint and all of its connections.
+func (s *http3server) close() {
+	s.serveCtxCancel()
+	s.endpoint.Close(http3canceledCtx)
+}
+
+// shutdown attempts a graceful shutdown for the server.
+func (s *http3server) shutdown(ctx context.Context) {
+	// Send GOAWAY frames to all active connections to give a chance for them
+	// to gracefully terminate.
+	s.mu.Lock()
+	for sc := range s.activeConns {
+		go sc.sendGoaway()
+	}
+	s.mu.Unlock()
+
+	// Complete shutdown as soon as there are no more active connections or ctx
+	// is done, whichever comes first.
+	defer func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.serveCtxCancel()
+		for sc := range s.activeConns {
+			sc.abort(&http3connectionError{
+				code:    http3errH3NoError,
+				message: "server is shutting down",
+			})
+		}
+	}()
+	noMoreConns := func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return len(s.activeConns) == 0
+	}
+	for {
+		if noMoreConns() {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.connClosed:
+		}
+	}
+}
+
+func (s *http3server) registerConn(sc *http3serverConn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.activeConns[sc] = struct{}{}
+}
+
+func (s *http3server) unregisterConn(sc *http3serverConn) {
+	s.mu.Lock()
+	delete(s.activeConns, sc)
+	s.mu.Unlock()
+	select {
+	case s.connClosed <- struct{}{}:
+	default:
+		// Channel already full. No need to send more values since we are just
+		// using this channel as a simpler sync.Cond.
+	}
+}
+
+type http3serverConn struct {
+	qconn *quic.Conn
+	srv   *Server
+	ctx   context.Context // base context for requests on this connection
+
+	http3genericConn // for handleUnidirectionalStream
+	enc              http3qpackEncoder
+	dec              http3qpackDecoder
+
+	// For handling shutdown.
+	controlStream      *http3stream
+	mu                 sync.Mutex // Guards everything below.
+	maxRequestStreamID int64
+	goawaySent         bool
+}
+
+func (s *http3server) newServerConn(qconn *quic.Conn) {
+	ctx := context.WithValue(s.serveCtx, LocalAddrContextKey, net.UDPAddrFromAddrPort(qconn.LocalAddr()))
+	sc := &http3serverConn{
+		qconn: qconn,
+		srv:   s.srv,
+		ctx:   ctx,
+	}
+	s.registerConn(sc)
+	defer s.unregisterConn(sc)
+	sc.enc.init()
+
+	// Create control stream and send SETTINGS frame.
+	//
+	// This needs no timeout: the handshake is complete, so the client's
+	// stream limits are known, and they must allow at least three
+	// unidirectional streams. With a client that does not comply,
+	// this waits until the connection is closed, by the idle timeout
+	// or by Close or Shutdown.
+	// https://www.rfc-editor.org/rfc/rfc9114.html#section-6.2-3
+	var err error
+	sc.controlStream, err = http3newConnStream(context.Background(), sc.qconn, http3streamTypeControl)
+	if err != nil {
+		return
+	}
+	sc.controlStream.writeSettings()
+	sc.controlStream.Flush()
+
+	sc.acceptStreams(sc.qconn, sc)
+}
+
+func (sc *http3serverConn) handleControlStream(st *http3stream) error {
+	// "A SETTINGS frame MUST be sent as the first frame of each control stream [...]"
+	// https://www.rfc-editor.org/rfc/rfc9114.html#section-7.2.4-2
+	if err := st.readSettings(func(settingsType, settingsValue int64) error {
+		switch settingsType {
+		case http3settingsMaxFieldSectionSize:
+			// The response header is written by the Handler, which
+			// has no way to learn of the limit or to handle an error
+			// enforcing it. As in the HTTP/2 server, send the header
+			// regardless and leave it to the client to reject it.
+		case http3settingsQPACKMaxTableCapacity, http3settingsQPACKBlockedStreams:
+			// These limit our use of the client's dynamic table,
+			// which the encoder never uses.
+		default:
+			// Unknown settings types are ignored.
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	for {
+		ftype, err := st.readFrameHeader()
+		if err != nil {
+			return err
+		}
+		switch ftype {
+		case http3frameTypeCancelPush:
+			// "If a server receives a CANCEL_PUSH frame for a push ID
+			// that has not yet been mentioned by a PUSH_PROMISE frame,
+			// this MUST be treated as a connection error of type H3_ID_ERROR."
+			// https://www.rfc-editor.org/rfc/rfc9114.html#section-7.2.3-8
+			return &http3connectionError{
+				code:    http3errH3IDError,
+				message: "CANCEL_PUSH for unsent push ID",
+			}
+		case http3frameTypeGoaway:
+			return http3errH3NoError
+		default:
+			// Unknown frames are ignored.
+			if err := st.discardUnknownFrame(ftype); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (sc *http3serverConn) handleEncoderStream(st *http3stream) error {
+	return sc.dec.readEncoderStream(st)
+}
+
+func (sc *http3serverConn) handleDecoderStream(st *http3stream) error {
+	return sc.enc.readDecoderStream(st)
+}
+
+func (sc *http3serverConn) handlePushStream(*http3stream) error {
+	// "[...] if a server receives a client-initiated push stream,
+	// this MUST be treated as a connection error of type H3_STREAM_CREATION_ERROR."
+	// https://www.rfc-editor.org/rfc/rfc9114.html#section-6.2.2-3
+	return &http3connectionError{
+		code:    http3errH3StreamCreationError,
+		message: "client created push stream",
+	}
+}
+
+// hasDisallowedConnectionHeader reports whether h contains connnection headers
+// that are not allowed in HTTP/3:
+//
+// "An endpoint MUST NOT generate an HTTP/3 field section containing
+// connection-specific fields; any message containing connection-specific
+// fields MUST be treated as malformed."
+//
+// "The only exception to this is the TE header field, which MAY be present in
+// an HTTP/3 request header; when it is, it MUST NOT contain any value other
+// than "trailers"."
+func http3hasDisallowedConnectionHeader(h Header) bool {
+	neverAllowed := []string{
+		"Connection",
+		"Keep-Alive",
+		"Proxy-Connection",
+		"Transfer-Encoding",
+		"Upgrade",
+	}
+	for _, k := range neverAllowed {
+		if _, ok := h[k]; ok {
+			return true
+		}
+	}
+	if te, ok := h["Te"]; ok && (len(te) != 1 || te[0] != "trailers") {
+		return true
+	}
+	return false
+}
+
+type http3pseudoHeader struct {
+	method    string
+	scheme    string
+	path      string
+	authority string
+}
+
+func (sc *http3serverConn) parseHeader(st *http3stream) (Header, http3pseudoHeader, error) {
+	ftype, err := st.readFrameHeader()
+	if err != nil {
+		return nil, http3pseudoHeader{}, err
+	}
+	if ftype != http3frameTypeHeaders {
+		return nil, http3pseudoHeader{}, &http3streamError{http3errH3MessageError, "received other frames when expecting HEADERS"}
+	}
+	header := make(Header)
+	var pHeader http3pseudoHeader
+	var dec http3qpackDecoder
+	var hasMethod, hasScheme, hasPath, hasAuthority bool
+	if err := dec.decode(st, func(_ http3indexType, name, value string) error {
+		if !httpguts.ValidHeaderFieldValue(value) {
+			return &http3streamError{http3errH3MessageError, "invalid field value"}
+		}
+		switch name {
+		case ":method":
+			if hasMethod {
+				return &http3streamError{http3errH3MessageError, "duplicate :method"}
+			}
+			hasMethod = true
+			pHeader.method = value
+		case ":scheme":
+			if hasScheme {
+				return &http3streamError{http3errH3MessageError, "duplicate :scheme"}
+			}
+			hasScheme = true
+			pHeader.scheme = value
+		case ":path":
+			if hasPath {
+				return &http3streamError{http3errH3MessageError, "duplicate :path"}
+			}
+			hasPath = true
+			pHeader.path = value
+		case ":authority":
+			if hasAuthority {
+				return &http3streamError{http3errH3MessageError, "duplicate :authority"}
+			}
+			hasAuthority = true
+			pHeader.authority = value
+		default:
+			if !http3validWireHeaderFieldName(name) {
+				return &http3streamError{http3errH3MessageError, "invalid field name"}
+			}
+			header.Add(name, value)
+		}
+		return nil
+	}); err != nil {
+		return nil, http3pseudoHeader{}, err
+	}
+	if err := st.endFrame(); err != nil {
+		return nil, http3pseudoHeader{}, err
+	}
+	if http3hasDisallowedConnectionHeader(header) {
+		return nil, http3pseudoHeader{}, &http3streamError{http3errH3MessageError, "invalid connection-related header"}
+	}
+
+	// "All HTTP/3 requests MUST include exactly one value for the :method,
+	// :scheme, and :path pseudo-header fields, unless the request is a CONNECT
+	// request"
+	//
+	// "A CONNECT request MUST be constructed as follows:
+	// - The :method pseudo-header field is set to "CONNECT"
+	// - The :scheme and :path pseudo-header fields are omitted
+	// - The :authority pseudo-header field contains the host and port to connect to"
+	if !hasMethod {
+		return nil, http3pseudoHeader{}, &http3streamError{http3errH3MessageError, "missing :method"}
+	}
+	if pHeader.method != "CONNECT" && (!hasScheme || !hasPath) {
+		return nil, http3pseudoHeader{}, &http3streamError{http3errH3MessageError, "missing :scheme or :path for non-CONNECT requests"}
+	}
+	if pHeader.method == "CONNECT" && (hasScheme || hasPath || !hasAuthority) {
+		return nil, http3pseudoHeader{}, &http3streamError{
+			http3errH3MessageError, "CONNECT request must only have :method and :authority pseudo-headers",
+		}
+	}
+	return header, pHeader, nil
+}
+
+func (sc *http3serverConn) sendGoaway() {
+	sc.mu.Lock()
+	if sc.goawaySent || sc.controlStream == nil {
+		sc.mu.Unlock()
+		return
+	}
+	sc.goawaySent = true
+	sc.mu.Unlock()
+
+	// No lock in this section in case writing to stream blocks. This is safe
+	// since sc.maxRequestStreamID is only updated when sc.goawaySent is false.
+	sc.controlStream.writeVarint(int64(http3frameTypeGoaway))
+	sc.controlStream.writeVarint(int64(quicwire.SizeVarint(uint64(sc.maxRequestStreamID))))
+	sc.controlStream.writeVarint(sc.maxRequestStreamID)
+	sc.controlStream.Flush()
+}
+
+// requestShouldGoAway returns true if st has a stream ID that is equal or
+// greater than the ID we have sent in a GOAWAY frame, if any.
+func (sc *http3serverConn) requestShouldGoaway(st *http3stream) bool {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.goawaySent {
+		return st.stream.ID() >= sc.maxRequestStreamID
+	}
+	if id := st.stream.ID(); id > sc.maxRequestStreamID {
+		sc.maxRequestStreamID = id
+	}
+	return false
+}
+
+func (sc *http3serverConn) handleRequestStream(st *http3stream) error {
+	if sc.requestShouldGoaway(st) {
+		return &http3streamError{
+			code:    http3errH3RequestRejected,
+			message: "GOAWAY request with equal or lower ID than the stream has been sent",
+		}
+	}
+	header, pHeader, err := sc.parseHeader(st)
+	if err != nil {
+		return err
+	}
+
+	url, requestURI, trailer, err := http3newServerRequest(pHeader, header)
+	if err != nil {
+		return err
+	}
+	needsContinue := httpguts.HeaderValuesContainsToken(header["Expect"], "100-continue")
+	if needsContinue {
+		delete(header, "Expect")
+	}
+
+	var body io.ReadCloser
+	contentLength := int64(-1)
+	if n, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64); err == nil && n >= 0 {
+		contentLength = n
+	}
+	if contentLength != 0 || len(trailer) != 0 {
+		body = &http3bodyReader{
+			st:      st,
+			remain:  contentLength,
+			trailer: trailer,
+		}
+	} else {
+		body = NoBody
+	}
+
+	tlsState := sc.qconn.ConnectionState()
+	ctx, cancel := context.WithCancel(sc.ctx)
+	defer cancel()
+	req := &Request{
+		Proto:         "HTTP/3.0",
+		ProtoMajor:    3,
+		Method:        pHeader.method,
+		Host:          pHeader.authority,
+		URL:           url,
+		RequestURI:    requestURI,
+		Trailer:       trailer,
+		RemoteAddr:    sc.qconn.RemoteAddr().String(),
+		Body:          body,
+		Header:        header,
+		ContentLength: contentLength,
+		TLS:           &tlsState,
+		ctx:           ctx,
+	}
+	defer req.Body.Close()
+
+	rw := &http3responseWriter{
+		st:             st,
+		headers:        make(Header),
+		trailer:        make(Header),
+		bb:             make(http3bodyBuffer, 0, http3defaultBodyBufferCap),
+		cannotHaveBody: req.Method == "HEAD",
+		bw: &http3bodyWriter{
+			st:     st,
+			remain: -1,
+			flush:  false,
+			name:   "response",
+			enc:    &sc.enc,
+		},
+	}
+	if needsContinue && body != NoBody {
+		body.(*http3bodyReader).send100Continue = func() {
+			rw.WriteHeader(100)
+		}
+	}
+
+	if err := sc.runHandler(rw, req); err != nil {
+		return err
+	}
+	rw.close()
+	return nil
+}
+
+var http3errHandlerPanicked = errors.New("http3: handler panicked")
+
+// runHandler calls the server's handler. If the handler panics,
+// the panic is logged (unless it is ErrAbortHandler) and
+// runHandler returns an error that causes the stream to be reset.
+func (sc *http3serverConn) runHandler(rw *http3responseWriter, req *Request) (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			if e != ErrAbortHandler {
+				const size = 64 << 10
+				buf := make([]byte, size)
+				buf = buf[:runtime.Stack(buf, false)]
+				sc.srv.logf("http3: panic serving %v: %v\n%s", req.RemoteAddr, e, buf)
+			}
+			err = http3errHandlerPanicked
+		}
+	}()
+	serverHandler{sc.srv}.ServeHTTP(rw, req)
+	return nil
+}
+
+// http3newServerRequest validates the pseudo-header fields of a request
+// and returns the request URL, RequestURI, and declared trailers.
+// It removes the Trailer field from header and merges Cookie fields.
+func http3newServerRequest(ph http3pseudoHeader, header Header) (*url.URL, string, Header, error) {
+	// Merge Cookie headers into one "; "-delimited value.
+	if cookies := header["Cookie"]; len(cookies) > 1 {
+		header["Cookie"] = []string{strings.Join(cookies, "; ")}
+	}
+
+	var trailer Header
+	for _, v := range header["Trailer"] {
+		for _, key := range strings.Split(v, ",") {
+			key = CanonicalHeaderKey(textproto.TrimString(key))
+			switch key {
+			case "Transfer-Encoding", "Trailer", "Content-Length":
+				// Bogus. (copy of http1 rules)
+				// Ignore.
+			default:
+				if trailer == nil {
+					trailer = make(Header)
+				}
+				trailer[key] = nil
+			}
+		}
+	}
+	delete(header, "Trailer")
+
+	// ":authority MUST NOT include the deprecated userinfo subcomponent
+	// for "http" or "https" schemed URIs."
+	// https://www.rfc-editor.org/rfc/rfc9114.html#section-4.3.1-2.4.1
+	if strings.IndexByte(ph.authority, '@') != -1 && (ph.scheme == "http" || ph.scheme == "https") {
+		return nil, "", nil, &http3streamError{http3errH3MessageError, "userinfo in :authority"}
+	}
+
+	if ph.method == "CONNECT" {
+		// Mimic HTTP/1 server behavior.
+		return &url.URL{Host: ph.authority}, ph.authority, trailer, nil
+	}
+	if ph.path == "" || (ph.path[0] != '/' && ph.path != "*") {
+		return nil, "", nil, &http3streamError{http3errH3MessageError, "invalid :path"}
+	}
+	u, err := url.ParseRequestURI(ph.path)
+	if err != nil {
+		return nil, "", nil, &http3streamError{http3errH3MessageError, "invalid :path"}
+	}
+	return u, ph.path, trailer, nil
+}
+
+// abort closes the connection with an error.
+func (sc *http3serverConn) abort(err error) {
+	if e, ok := err.(*http3connectionError); ok {
+		sc.qconn.Abort(&quic.ApplicationError{
+			Code:   uint64(e.code),
+			Reason: e.message,
+		})
+	} else {
+		sc.qconn.Abort(err)
+	}
+}
+
+type http3responseWriter struct {
+	st             *http3stream
+	bw             *http3bodyWriter
+	mu             sync.Mutex
+	headers        Header
+	trailer        Header
+	bb             http3bodyBuffer
+	wroteHeader    bool // Non-1xx header has been (logically) written.
+	statusCode     int  // Status of the response that will be sent in HEADERS frame.
+	statusCodeSet  bool // Status of the response has been set via a call to WriteHeader.
+	cannotHaveBody bool // Response should not have a body (e.g. response to a HEAD request).
+	bodyLenLeft    int  // How much of the content body is left to be sent, set via "Content-Length" header. -1 if unknown.
+}
+
+func (rw *http3responseWriter) Header() Header {
+	return rw.headers
+}
+
+// prepareTrailerForWriteLocked populates any pre-declared trailer header with
+// its value, and passes it to bodyWriter so it can be written after body EOF.
+// Caller must hold rw.mu.
+func (rw *http3responseWriter) prepareTrailerForWriteLocked() {
+	for name := range rw.trailer {
+		if val, ok := rw.headers[name]; ok {
+			rw.trailer[name] = val
+		} else {
+			delete(rw.trailer, name)
+		}
+	}
+	if len(rw.trailer) > 0 {
+		rw.bw.trailer = rw.trailer
+	}
+}
+
+// writeHeaderLockedOnce writes the final response header. If rw.wroteHeader is
+// true, calling this method is a no-op. Sending informational status headers
+// should be done using writeInfoHeaderLocked, rather than this method.
+// Caller must hold rw.mu.
+func (rw *http3responseWriter) writeHeaderLockedOnce() {
+	if rw.wroteHeader {
+		return
+	}
+	if !bodyAllowedForStatus(rw.statusCode) {
+		rw.cannotHaveBody = true
+	}
+	// If there is any Trailer declared in headers, save them so we know which
+	// trailers have been pre-declared. Also, write back the extracted value,
+	// which is canonicalized, to rw.Header for consistency.
+	if _, ok := rw.headers["Trailer"]; ok {
+		http3extractTrailerFromHeader(rw.headers, rw.trailer)
+		keys := make([]string, 0, len(rw.trailer))
+		for k := range rw.trailer {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		rw.headers.Set("Trailer", strings.Join(keys, ", "))
+	}
+
+	rw.bb.inferHeader(rw.headers, rw.statusCode)
+	encHeaders := rw.bw.enc.encode(func(f func(itype http3indexType, name, value string)) {
+		f(http3mayIndex, ":status", strconv.Itoa(rw.statusCode))
+		for name, values := range rw.headers {
+			if !httpguts.ValidHeaderFieldName(name) {
+				continue
+			}
+			for _, val := range values {
+				if !httpguts.ValidHeaderFieldValue(val) {
+					continue
+				}
+				// Issue #71374: Consider supporting never-indexed fields.
+				f(http3mayIndex, name, val)
+			}
+		}
+	})
+
+	rw.st.writeVarint(int64(http3frameTypeHeaders))
+	rw.st.writeVarint(int64(len(encHeaders)))
+	rw.st.Write(encHeaders)
+	rw.wroteHeader = true
+}
+
+// writeHeaderLocked writes informational status headers (i.e. status 1XX).
+// If a non-informational status header has been written via
+// writeHeaderLockedOnce, this method is a no-op.
+// Caller must hold rw.mu.
+func (rw *http3responseWriter) writeHeaderLocked(statusCode int) {
+	if rw.wroteHeader {
+		return
+	}
+	encHeaders := rw.bw.enc.encode(func(f func(itype http3indexType, name, value string)) {
+		f(http3mayIndex, ":status", strconv.Itoa(statusCode))
+		for name, values := range rw.headers {
+			if name == "Content-Length" || name == "Transfer-Encoding" {
+				continue
+			}
+			if !httpguts.ValidHeaderFieldName(name) {
+				continue
+			}
+			for _, val := range values {
+				if !httpguts.ValidHeaderFieldValue(val) {
+					continue
+				}
+				// Issue #71374: Consider supporting never-indexed fields.
+				f(http3mayIndex, name, val)
+			}
+		}
+	})
+	rw.st.writeVarint(int64(http3frameTypeHeaders))
+	rw.st.writeVarint(int64(len(encHeaders)))
+	rw.st.Write(encHeaders)
+}
+
+func http3isInfoStatus(status int) bool {
+	return status >= 100 && status < 200
+}
+
+func (rw *http3responseWriter) WriteHeader(statusCode int) {
+	// TODO: handle sending informational status headers (e.g. 103).
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if rw.statusCodeSet {
+		return
+	}
+	checkWriteHeaderCode(statusCode)
+
+	// Informational headers can be sent multiple times, and should be flushed
+	// immediately.
+	if http3isInfoStatus(statusCode) {
+		rw.writeHeaderLocked(statusCode)
+		rw.st.Flush()
+		return
+	}
+
+	// Non-informational headers should only be set once, and should be
+	// buffered.
+	rw.statusCodeSet = true
+	rw.statusCode = statusCode
+	if n, err := strconv.Atoi(rw.Header().Get("Content-Length")); err == nil {
+		rw.bodyLenLeft = n
+	} else {
+		rw.bodyLenLeft = -1 // Unknown.
+	}
+}
+
+// trimWriteLocked trims a byte slice, b, such that the length of b will not
+// exceed rw.bodyLenLeft. This method will update rw.bodyLenLeft when trimming
+// b, and will also return whether b was trimmed or not.
+// Caller must hold rw.mu.
+func (rw *http3responseWriter) trimWriteLocked(b []byte) ([]byte, bool) {
+	if rw.bodyLenLeft < 0 {
+		return b, false
+	}
+	n := len(b)
+	if n > rw.bodyLenLeft {
+		n = rw.bodyLenLeft
+	}
+	rw.bodyLenLeft -= n
+	return b[:n], n != len(b)
+}
+
+func (rw *http3responseWriter) Write(b []byte) (n int, err error) {
+	// Calling Write implicitly calls WriteHeader(200) if WriteHeader has not
+	// been called before.
+	rw.WriteHeader(StatusOK)
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	if rw.statusCode == StatusNotModified {
+		return 0, ErrBodyNotAllowed
+	}
+
+	b, trimmed := rw.trimWriteLocked(b)
+	if trimmed {
+		defer func() {
+			err = ErrContentLength
+		}()
+	}
+
+	// If b fits entirely in our body buffer, save it to the buffer and return
+	// early so we can coalesce small writes.
+	// As a special case, we always want to save b to the buffer even when b is
+	// big if we had yet to write our header, so we can infer headers like
+	// "Content-Type" with as much information as possible.
+	initialBLen := len(b)
+	initialBufLen := len(rw.bb)
+	if !rw.wroteHeader || len(b) <= cap(rw.bb)-len(rw.bb) {
+		b = rw.bb.write(b)
+		if len(b) == 0 {
+			return initialBLen, nil
+		}
+	}
+
+	// Reaching this point means that our buffer has been sufficiently filled.
+	// Therefore, we now want to:
+	// 1. Infer and write response headers based on our body buffer, if not
+	// done yet.
+	// 2. Write our body buffer and the rest of b (if any).
+	// 3. Reset the current body buffer so it can be used again.
+	rw.writeHeaderLockedOnce()
+	if rw.cannotHaveBody {
+		return initialBLen, nil
+	}
+	if n, err := rw.bw.write(rw.bb, b); err != nil {
+		if n < initialBufLen {
+			n = initialBufLen
+		}
+		return n - initialBufLen, err
+	}
+	rw.bb.discard()
+	return initialBLen, nil
+}
+
+func (rw *http3responseWriter) Flush() {
+	rw.FlushError()
+}
+
+func (rw *http3responseWriter) FlushError() error {
+	// Calling Flush implicitly calls WriteHeader(200) if WriteHeader has not
+	// been called before.
+	rw.WriteHeader(StatusOK)
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	rw.writeHeaderLockedOnce()
+	if !rw.cannotHaveBody {
+		if _, err := rw.bw.Write(rw.bb); err != nil {
+			return err
+		}
+		rw.bb.discard()
+	}
+	return rw.st.Flush()
+}
+
+func (rw *http3responseWriter) EnableFullDuplex() error {
+	// Request and response bodies are sent on independent halves
+	// of the stream, so this is a no-op.
+	return nil
+}
+
+func (rw *http3responseWriter) close() error {
+	rw.Flush()
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	rw.prepareTrailerForWriteLocked()
+	if err := rw.bw.Close(); err != nil {
+		return err
+	}
+	return rw.st.stream.Close()
+}
+
+// defaultBodyBufferCap is the default number of bytes of body that we are
+// willing to save in a buffer for the sake of inferring headers and coalescing
+// small writes. 512 was chosen to be consistent with how much
+// DetectContentType is willing to read.
+const http3defaultBodyBufferCap = 512
+
+// bodyBuffer is a buffer used to store body content of a response.
+type http3bodyBuffer []byte
+
+// write writes b to the buffer. It returns a new slice of b, which contains
+// any remaining data that could not be written to the buffer, if any.
+func (bb *http3bodyBuffer) write(b []byte) []byte {
+	n := cap(*bb) - len(*bb)
+	if n > len(b) {
+		n = len(b)
+	}
+	*bb = append(*bb, b[:n]...)
+	return b[n:]
+}
+
+// discard resets the buffer so it can be used again.
+func (bb *http3bodyBuffer) discard() {
+	*bb = (*bb)[:0]
+}
+
+// inferHeader populates h with the header values that we can infer from our
+// current buffer content, if not already explicitly set. This method should be
+// called only once with as much body content as possible in the buffer, before
+// a HEADERS frame is sent, and before discard has been called. Doing so
+// properly is the responsibility of the caller.
+func (bb *http3bodyBuffer) inferHeader(h Header, status int) {
+	if _, ok := h["Date"]; !ok {
+		h.Set("Date", time.Now().UTC().Format(TimeFormat))
+	}
+	// If the Content-Encoding is non-blank, we shouldn't
+	// sniff the body. See Issue golang.org/issue/31753.
+	_, hasCE := h["Content-Encoding"]
+	_, hasCT := h["Content-Type"]
+	if !hasCE && !hasCT && bodyAllowedForStatus(status) && len(*bb) > 0 {
+		h.Set("Content-Type", DetectContentType(*bb))
+	}
+	// We can technically infer Content-Length too here, as long as the entire
+	// response body fits within hi.buf and does not require flushing. However,
+	// we have chosen not to do so for now as Content-Length is not very
+	// important for HTTP/3, and such inconsistent behavior might be confusing.
+}
@@ -0,0 +1,353 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Tests of HTTP/3 over loopback UDP.
+
+package http_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	. "net/http"
+	"net/http/httptest"
+	"net/http/internal/testcert"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type h3Test struct {
+	t    *testing.T
+	ts   *httptest.Server
+	c    *Client
+	tr   *Transport
+	errc chan error // result of ServeQUIC
+	port int        // UDP port of the HTTP/3 endpoint
+}
+
+// newH3Test starts an HTTPS test server running h, which also serves
+// HTTP/3 on a loopback UDP socket, and a client with HTTP/3 enabled.
+func newH3Test(t *testing.T, h HandlerFunc) *h3Test {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, testcert.LocalhostCert, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, testcert.LocalhostKey, 0600); err != nil {
+		t.Fatal(err)
+	}
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen on UDP: %v", err)
+	}
+
+	ts := httptest.NewUnstartedServer(h)
+	ts.Config.ErrorLog = quietLog
+	ts.StartTLS()
+	st := &h3Test{
+		t:    t,
+		ts:   ts,
+		errc: make(chan error, 1),
+		port: pc.LocalAddr().(*net.UDPAddr).Port,
+	}
+	go func() {
+		st.errc <- ts.Config.ServeQUIC(pc, certFile, keyFile)
+	}()
+
+	st.tr = ts.Client().Transport.(*Transport).Clone()
+	st.tr.EnableHTTP3 = true
+	st.c = &Client{Transport: st.tr}
+	return st
+}
+
+func (st *h3Test) close() {
+	st.tr.CloseIdleConnections()
+	st.ts.Config.Close()
+	st.ts.Close()
+	select {
+	case err := <-st.errc:
+		if err != ErrServerClosed {
+			st.t.Errorf("ServeQUIC = %v, want ErrServerClosed", err)
+		}
+	case <-time.After(10 * time.Second):
+		st.t.Errorf("ServeQUIC did not return after Close")
+	}
+}
+
+// get sends a GET request and returns the response with its body read.
+func (st *h3Test) get(path string) (*Response, string) {
+	st.t.Helper()
+	res, err := st.c.Get(st.ts.URL + path)
+	if err != nil {
+		st.t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		st.t.Fatal(err)
+	}
+	return res, string(body)
+}
+
+// upgrade sends requests over TCP until the server advertises HTTP/3.
+func (st *h3Test) upgrade() {
+	st.t.Helper()
+	want := fmt.Sprintf(`h3=":%d"`, st.port)
+	for i := 0; ; i++ {
+		res, _ := st.get("/")
+		if res.ProtoMajor == 3 {
+			st.t.Fatalf("request %d unexpectedly used HTTP/3", i)
+		}
+		if got := res.Header.Get("Alt-Svc"); got == want {
+			return
+		} else if got != "" {
+			st.t.Fatalf("Alt-Svc = %q, want %q", got, want)
+		}
+		if i == 100 {
+			st.t.Fatal("server never advertised HTTP/3")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHTTP3AltSvcUpgrade(t *testing.T) {
+	st := newH3Test(t, func(w ResponseWriter, r *Request) {
+		if r.ProtoMajor == 3 && r.TLS == nil {
+			t.Error("HTTP/3 request has nil TLS")
+		}
+		if _, ok := r.Context().Value(ServerContextKey).(*Server); !ok {
+			t.Error("request context lacks ServerContextKey")
+		}
+		w.Header().Set("X-Proto", r.Proto)
+		io.WriteString(w, "hello "+r.Proto)
+	})
+	defer st.close()
+
+	st.upgrade()
+	for i := 0; i < 3; i++ {
+		res, body := st.get("/")
+		if res.Proto != "HTTP/3.0" || res.ProtoMajor != 3 {
+			t.Fatalf("request %d: Proto = %q, want HTTP/3.0", i, res.Proto)
+		}
+		if got := res.Header.Get("X-Proto"); got != "HTTP/3.0" {
+			t.Errorf("request %d: server saw Proto %q", i, got)
+		}
+		if body != "hello HTTP/3.0" {
+			t.Errorf("request %d: body = %q", i, body)
+		}
+		if res.Header.Get("Alt-Svc") != "" {
+			t.Errorf("request %d: HTTP/3 response has Alt-Svc header", i)
+		}
+		if res.TLS == nil || res.TLS.NegotiatedProtocol != "h3" {
+			t.Errorf("request %d: TLS = %+v, want h3", i, res.TLS)
+		}
+	}
+}
+
+func TestHTTP3RequestBodyAndTrailers(t *testing.T) {
+	st := newH3Test(t, func(w ResponseWriter, r *Request) {
+		if r.ProtoMajor != 3 {
+			return
+		}
+		w.Header().Set("Trailer", "X-Sum")
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("reading request body: %v", err)
+		}
+		if got := r.Trailer.Get("X-Client"); got != "done" {
+			t.Errorf("request trailer X-Client = %q, want done", got)
+		}
+		fmt.Fprintf(w, "%s %s %s", r.Method, r.URL.RequestURI(), strings.ToUpper(string(body)))
+		w.Header().Set("X-Sum", fmt.Sprint(len(body)))
+	})
+	defer st.close()
+
+	st.upgrade()
+	pr, pw := io.Pipe()
+	req, err := NewRequest("POST", st.ts.URL+"/echo?q=1", pr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Trailer = Header{"X-Client": nil}
+	go func() {
+		io.WriteString(pw, "some body")
+		// The trailer is sent after the body is read to EOF.
+		req.Trailer.Set("X-Client", "done")
+		pw.Close()
+	}()
+	res, err := st.c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ProtoMajor != 3 {
+		t.Fatalf("Proto = %q, want HTTP/3.0", res.Proto)
+	}
+	if got, want := string(body), "POST /echo?q=1 SOME BODY"; got != want {
+		t.Errorf("body = %q, want %q", got, want)
+	}
+	if got := res.Trailer.Get("X-Sum"); got != "9" {
+		t.Errorf("response trailer X-Sum = %q, want 9", got)
+	}
+}
+
+// readNotifier closes started on the first call to Read.
+type readNotifier struct {
+	io.Reader
+	once    sync.Once
+	started chan struct{}
+}
+
+func (r *readNotifier) Read(p []byte) (int, error) {
+	r.once.Do(func() { close(r.started) })
+	return r.Reader.Read(p)
+}
+
+func TestHTTP3ExpectContinueTimeout(t *testing.T) {
+	body := &readNotifier{
+		Reader:  strings.NewReader("some body"),
+		started: make(chan struct{}),
+	}
+	st := newH3Test(t, func(w ResponseWriter, r *Request) {
+		if r.ProtoMajor != 3 {
+			return
+		}
+		// Reading the body would send a 100 Continue response.
+		// Wait until the client gives up waiting for one instead.
+		select {
+		case <-body.started:
+		case <-r.Context().Done():
+			return
+		}
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("reading request body: %v", err)
+		}
+		w.Write(b)
+	})
+	defer st.close()
+
+	st.upgrade()
+	st.tr.ExpectContinueTimeout = 10 * time.Millisecond
+	req, err := NewRequest("POST", st.ts.URL, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Expect", "100-continue")
+	res, err := st.c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	got, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ProtoMajor != 3 {
+		t.Fatalf("Proto = %q, want HTTP/3.0", res.Proto)
+	}
+	if string(got) != "some body" {
+		t.Errorf("body = %q, want %q", got, "some body")
+	}
+}
+
+func TestHTTP3HandlerPanic(t *testing.T) {
+	st := newH3Test(t, func(w ResponseWriter, r *Request) {
+		if r.ProtoMajor == 3 && r.URL.Path == "/panic" {
+			panic(ErrAbortHandler)
+		}
+	})
+	defer st.close()
+
+	st.upgrade()
+	if res, err := st.c.Get(st.ts.URL + "/panic"); err == nil {
+		_, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err == nil {
+			t.Fatalf("request to panicking handler succeeded with status %v", res.Status)
+		}
+	}
+	// The connection is still usable.
+	if res, _ := st.get("/"); res.ProtoMajor != 3 {
+		t.Errorf("Proto = %q after handler panic, want HTTP/3.0", res.Proto)
+	}
+}
+
+func TestHTTP3Shutdown(t *testing.T) {
+	st := newH3Test(t, func(w ResponseWriter, r *Request) {})
+	st.upgrade()
+	if res, _ := st.get("/"); res.ProtoMajor != 3 {
+		t.Fatalf("Proto = %q, want HTTP/3.0", res.Proto)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := st.ts.Config.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if err := <-st.errc; err != ErrServerClosed {
+		t.Errorf("ServeQUIC = %v, want ErrServerClosed", err)
+	}
+	st.errc <- ErrServerClosed
+	st.close()
+}
+
+func TestHTTP3FallbackToTCP(t *testing.T) {
+	// Find a UDP port with nothing listening on it.
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen on UDP: %v", err)
+	}
+	deadPort := pc.LocalAddr().(*net.UDPAddr).Port
+	pc.Close()
+
+	ts := httptest.NewTLSServer(HandlerFunc(func(w ResponseWriter, r *Request) {
+		w.Header().Set("Alt-Svc", fmt.Sprintf(`h3=":%d"; ma=3600`, deadPort))
+		io.WriteString(w, r.Proto)
+	}))
+	defer ts.Close()
+	tr := ts.Client().Transport.(*Transport).Clone()
+	tr.EnableHTTP3 = true
+	tr.TLSHandshakeTimeout = 500 * time.Millisecond
+	defer tr.CloseIdleConnections()
+	c := &Client{Transport: tr}
+
+	for i := 0; i < 2; i++ {
+		res, err := c.Get(ts.URL)
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		body, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.ProtoMajor != 1 || string(body) != "HTTP/1.1" {
+			t.Errorf("request %d: Proto = %q, body = %q; want HTTP/1.1", i, res.Proto, body)
+		}
+	}
+}
+
+func TestServeQUICAfterClose(t *testing.T) {
+	srv := &Server{}
+	srv.Close()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen on UDP: %v", err)
+	}
+	if err := srv.ServeQUIC(pc, "", ""); !errors.Is(err, ErrServerClosed) {
+		t.Errorf("ServeQUIC = %v, want ErrServerClosed", err)
+	}
+}
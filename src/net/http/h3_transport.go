@@ -0,0 +1,1130 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http/httptrace"
+	"net/http/internal/ascii"
+	"net/http/internal/quic"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// http3transport is the HTTP/3 half of a Transport with EnableHTTP3 set.
+//
+// It keeps at most one QUIC connection per origin, all sharing a single
+// QUIC endpoint, and remembers which origins have advertised HTTP/3
+// support with an Alt-Svc header.
+type http3transport struct {
+	t *Transport
+
+	altSvc http3altSvcCache
+
+	mu sync.Mutex // Guards fields below.
+	// endpoint is the QUIC endpoint used by connections created by the
+	// transport. If CloseIdleConnections is called when activeConns is empty,
+	// endpoint will be unset. If unset, endpoint will be initialized by any
+	// call to dial.
+	endpoint      *quic.Endpoint
+	conns         map[string]*http3clientConn // keyed by origin address
+	activeConns   map[*http3clientConn]struct{}
+	inFlightDials int
+}
+
+func newHTTP3Transport(t *Transport) *http3transport {
+	return &http3transport{
+		t:           t,
+		conns:       make(map[string]*http3clientConn),
+		activeConns: make(map[*http3clientConn]struct{}),
+	}
+}
+
+// http3errNoConn is returned by http3transport.roundTrip when the request
+// was not sent because no HTTP/3 connection to the origin is available.
+// The request can safely be sent over TCP instead.
+var http3errNoConn = errors.New("http3: no connection available")
+
+// roundTrip sends req over HTTP/3 to the origin at addr ("host:port"),
+// if the origin has advertised an HTTP/3 endpoint.
+// It returns http3errNoConn if the request was not sent.
+func (tr *http3transport) roundTrip(req *Request, addr string) (*Response, error) {
+	cc, err := tr.getConn(req.Context(), addr)
+	if err != nil {
+		return nil, http3errNoConn
+	}
+	resp, err := cc.RoundTrip(req)
+	if err == http3errNoConn {
+		// The connection is no longer usable. Drop it, and let
+		// the next request dial a new one.
+		tr.removeConn(addr, cc)
+	}
+	return resp, err
+}
+
+// getConn returns a connection to the HTTP/3 alternative of the
+// origin at addr, dialing one if necessary.
+func (tr *http3transport) getConn(ctx context.Context, addr string) (*http3clientConn, error) {
+	tr.mu.Lock()
+	cc := tr.conns[addr]
+	tr.mu.Unlock()
+	if cc != nil {
+		return cc, nil
+	}
+
+	alt, ok := tr.altSvc.lookup(addr)
+	if !ok {
+		return nil, http3errNoConn
+	}
+	cc, err := tr.dial(ctx, addr, alt)
+	if err != nil {
+		tr.altSvc.markBroken(addr, alt)
+		return nil, err
+	}
+
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if prev := tr.conns[addr]; prev != nil {
+		// Another request dialed concurrently; use its connection.
+		go cc.Close()
+		return prev, nil
+	}
+	tr.conns[addr] = cc
+	cc.addr = addr
+	return cc, nil
+}
+
+func (tr *http3transport) removeConn(addr string, cc *http3clientConn) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if tr.conns[addr] == cc {
+		delete(tr.conns, addr)
+	}
+}
+
+func (tr *http3transport) incInFlightDials() {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.inFlightDials++
+}
+
+func (tr *http3transport) decInFlightDials() {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.inFlightDials--
+}
+
+func (tr *http3transport) initEndpoint() (err error) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if tr.endpoint == nil {
+		tr.endpoint, err = quic.Listen("udp", ":0", nil)
+	}
+	return err
+}
+
+// dial creates a new HTTP/3 client connection to target, which is the
+// HTTP/3 alternative for the origin at addr.
+func (tr *http3transport) dial(ctx context.Context, addr, target string) (*http3clientConn, error) {
+	tr.incInFlightDials()
+	defer tr.decInFlightDials()
+
+	if err := tr.initEndpoint(); err != nil {
+		return nil, err
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := cloneTLSConfig(tr.t.TLSClientConfig)
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = host
+	}
+	tlsConfig.NextProtos = []string{"h3"}
+	if tlsConfig.MinVersion < tls.VersionTLS13 {
+		tlsConfig.MinVersion = tls.VersionTLS13
+	}
+	config := &quic.Config{
+		TLSConfig:        tlsConfig,
+		HandshakeTimeout: tr.t.TLSHandshakeTimeout,
+		MaxIdleTimeout:   tr.t.IdleConnTimeout,
+	}
+
+	qconn, err := tr.endpoint.Dial(ctx, "udp", target, config)
+	if err != nil {
+		return nil, err
+	}
+	cc, err := tr.newClientConn(ctx, qconn)
+	if err != nil {
+		qconn.Abort(err)
+		return nil, err
+	}
+	return cc, nil
+}
+
+// closeIdleConnections closes all connections with no requests in flight.
+//
+// When the transport has no active connections anymore, it also closes
+// its QUIC endpoint. A new endpoint is created by the next dial.
+func (tr *http3transport) closeIdleConnections() {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	var idle []*http3clientConn
+	busy := tr.inFlightDials > 0
+	for cc := range tr.activeConns {
+		if cc.inFlight > 0 {
+			busy = true
+			continue
+		}
+		idle = append(idle, cc)
+		if tr.conns[cc.addr] == cc {
+			delete(tr.conns, cc.addr)
+		}
+	}
+	if tr.endpoint == nil {
+		return
+	}
+	if !busy {
+		// Closing the endpoint closes all of its connections.
+		tr.endpoint.Close(http3canceledCtx)
+		tr.endpoint = nil
+		return
+	}
+	for _, cc := range idle {
+		go cc.Close()
+	}
+}
+
+// http3altSvcCache records the HTTP/3 alternative services advertised
+// by origins in Alt-Svc response headers (RFC 7838).
+type http3altSvcCache struct {
+	mu sync.Mutex
+	m  map[string]http3altSvc // keyed by origin address
+}
+
+type http3altSvc struct {
+	addr        string // "host:port" of the HTTP/3 endpoint
+	expires     time.Time
+	brokenUntil time.Time // don't use before this time; zero if not broken
+}
+
+const (
+	// http3altSvcDefaultMaxAge is the freshness lifetime of an Alt-Svc
+	// entry with no "ma" parameter.
+	http3altSvcDefaultMaxAge = 24 * time.Hour
+
+	// http3altSvcBrokenPeriod is how long an alternative that could not
+	// be reached is ignored, even if the origin keeps advertising it.
+	http3altSvcBrokenPeriod = 5 * time.Minute
+)
+
+// record updates the alternatives for the origin at addr
+// from the Alt-Svc fields in h.
+func (c *http3altSvcCache) record(addr string, h Header) {
+	vv := h["Alt-Svc"]
+	if len(vv) == 0 {
+		return
+	}
+	alt, ok, clear := http3parseAltSvc(addr, vv)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case ok:
+		if c.m == nil {
+			c.m = make(map[string]http3altSvc)
+		}
+		if prev, found := c.m[addr]; found && prev.addr == alt.addr {
+			alt.brokenUntil = prev.brokenUntil
+		}
+		c.m[addr] = alt
+	case clear:
+		delete(c.m, addr)
+	}
+}
+
+// lookup returns the HTTP/3 endpoint for the origin at addr, if any.
+func (c *http3altSvcCache) lookup(addr string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	alt, ok := c.m[addr]
+	if !ok {
+		return "", false
+	}
+	now := time.Now()
+	if now.After(alt.expires) {
+		delete(c.m, addr)
+		return "", false
+	}
+	if now.Before(alt.brokenUntil) {
+		return "", false
+	}
+	return alt.addr, true
+}
+
+// markBroken records that the alternative altAddr for the origin
+// at addr could not be reached.
+func (c *http3altSvcCache) markBroken(addr, altAddr string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if alt, ok := c.m[addr]; ok && alt.addr == altAddr {
+		alt.brokenUntil = time.Now().Add(http3altSvcBrokenPeriod)
+		c.m[addr] = alt
+	}
+}
+
+// http3parseAltSvc returns the first "h3" alternative listed in the
+// Alt-Svc field values vv for the origin at addr.
+// It reports clear=true if the values are the special value "clear".
+func http3parseAltSvc(addr string, vv []string) (alt http3altSvc, ok, clear bool) {
+	originHost, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return alt, false, false
+	}
+	for _, v := range vv {
+		for _, entry := range strings.Split(v, ",") {
+			entry = textproto.TrimString(entry)
+			if entry == "clear" {
+				return alt, false, true
+			}
+			params := strings.Split(entry, ";")
+			proto, authority, found := strings.Cut(params[0], "=")
+			if !found || textproto.TrimString(proto) != "h3" {
+				continue
+			}
+			authority, err := strconv.Unquote(textproto.TrimString(authority))
+			if err != nil {
+				continue
+			}
+			host, port, err := net.SplitHostPort(authority)
+			if err != nil || port == "" {
+				continue
+			}
+			if host == "" {
+				host = originHost
+			}
+			maxAge := http3altSvcDefaultMaxAge
+			for _, p := range params[1:] {
+				k, v, _ := strings.Cut(textproto.TrimString(p), "=")
+				if ascii.EqualFold(k, "ma") {
+					if n, err := strconv.ParseInt(v, 10, 64); err == nil && n >= 0 {
+						maxAge = time.Duration(n) * time.Second
+					}
+				}
+			}
+			return http3altSvc{
+				addr:    net.JoinHostPort(host, port),
+				expires: time.Now().Add(maxAge),
+			}, true, false
+		}
+	}
+	return alt, false, false
+}
+
+// A clientConn is a client HTTP/3 connection.
+//
+// Multiple goroutines may invoke methods on a clientConn simultaneously.
+type http3clientConn struct {
+	qconn *quic.Conn
+	tr    *http3transport
+	addr  string // origin address; guarded by tr.mu
+
+	http3genericConn
+
+	enc http3qpackEncoder
+	dec http3qpackDecoder
+
+	inFlight  int  // number of requests in flight; guarded by tr.mu
+	goingAway bool // GOAWAY received; guarded by tr.mu
+
+	// peerMaxFieldSectionSize is the server's SETTINGS_MAX_FIELD_SECTION_SIZE,
+	// or -1 if it has not sent one; guarded by tr.mu
+	peerMaxFieldSectionSize int64
+}
+
+func (tr *http3transport) registerConn(cc *http3clientConn) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.activeConns[cc] = struct{}{}
+}
+
+func (tr *http3transport) unregisterConn(cc *http3clientConn) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	delete(tr.activeConns, cc)
+	if tr.conns[cc.addr] == cc {
+		delete(tr.conns, cc.addr)
+	}
+}
+
+func (tr *http3transport) newClientConn(ctx context.Context, qconn *quic.Conn) (*http3clientConn, error) {
+	cc := &http3clientConn{
+		qconn:                   qconn,
+		tr:                      tr,
+		peerMaxFieldSectionSize: -1, // unlimited
+	}
+	tr.registerConn(cc)
+	cc.enc.init()
+
+	// Create control stream and send SETTINGS frame.
+	controlStream, err := http3newConnStream(ctx, cc.qconn, http3streamTypeControl)
+	if err != nil {
+		tr.unregisterConn(cc)
+		return nil, fmt.Errorf("http3: cannot create control stream: %v", err)
+	}
+	controlStream.writeSettings()
+	controlStream.Flush()
+
+	go func() {
+		cc.acceptStreams(qconn, cc)
+		tr.unregisterConn(cc)
+	}()
+	return cc, nil
+}
+
+// Close closes the connection.
+func (cc *http3clientConn) Close() error {
+	// Use Close rather than Abort on the QUIC connection, so that
+	// closeIdleConnections can close the endpoint once the peer
+	// has acknowledged the close of every connection.
+	return cc.qconn.Close()
+}
+
+func (cc *http3clientConn) incInFlight() {
+	cc.tr.mu.Lock()
+	defer cc.tr.mu.Unlock()
+	cc.inFlight++
+}
+
+func (cc *http3clientConn) decInFlight() {
+	cc.tr.mu.Lock()
+	defer cc.tr.mu.Unlock()
+	cc.inFlight--
+	if cc.inFlight == 0 && cc.goingAway {
+		go cc.Close()
+	}
+}
+
+// maxFieldSectionSize returns the largest request header the server
+// accepts, or -1 if it has not set a limit.
+func (cc *http3clientConn) maxFieldSectionSize() int64 {
+	cc.tr.mu.Lock()
+	defer cc.tr.mu.Unlock()
+	return cc.peerMaxFieldSectionSize
+}
+
+// goAway handles receipt of a GOAWAY frame.
+func (cc *http3clientConn) goAway() {
+	cc.tr.mu.Lock()
+	defer cc.tr.mu.Unlock()
+	if cc.tr.conns[cc.addr] == cc {
+		delete(cc.tr.conns, cc.addr)
+	}
+	cc.goingAway = true
+	if cc.inFlight == 0 {
+		go cc.Close()
+	}
+}
+
+func (cc *http3clientConn) handleControlStream(st *http3stream) error {
+	// "A SETTINGS frame MUST be sent as the first frame of each control stream [...]"
+	// https://www.rfc-editor.org/rfc/rfc9114.html#section-7.2.4-2
+	if err := st.readSettings(func(settingsType, settingsValue int64) error {
+		switch settingsType {
+		case http3settingsMaxFieldSectionSize:
+			cc.tr.mu.Lock()
+			cc.peerMaxFieldSectionSize = settingsValue
+			cc.tr.mu.Unlock()
+		case http3settingsQPACKMaxTableCapacity, http3settingsQPACKBlockedStreams:
+			// These limit our use of the server's dynamic table,
+			// which the encoder never uses.
+		default:
+			// Unknown settings types are ignored.
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	for {
+		ftype, err := st.readFrameHeader()
+		if err != nil {
+			return err
+		}
+		switch ftype {
+		case http3frameTypeCancelPush:
+			// "If a CANCEL_PUSH frame is received that references a push ID
+			// greater than currently allowed on the connection,
+			// this MUST be treated as a connection error of type H3_ID_ERROR."
+			// https://www.rfc-editor.org/rfc/rfc9114.html#section-7.2.3-7
+			return &http3connectionError{
+				code:    http3errH3IDError,
+				message: "CANCEL_PUSH received when no MAX_PUSH_ID has been sent",
+			}
+		case http3frameTypeGoaway:
+			// The server is shutting down. Stop sending new requests
+			// on this connection, and close it once the requests
+			// in flight have completed.
+			if err := st.discardFrame(); err != nil {
+				return err
+			}
+			cc.goAway()
+		default:
+			// Unknown frames are ignored.
+			if err := st.discardUnknownFrame(ftype); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (cc *http3clientConn) handleEncoderStream(st *http3stream) error {
+	return cc.dec.readEncoderStream(st)
+}
+
+func (cc *http3clientConn) handleDecoderStream(st *http3stream) error {
+	return cc.enc.readDecoderStream(st)
+}
+
+func (cc *http3clientConn) handlePushStream(*http3stream) error {
+	// "A client MUST treat receipt of a push stream as a connection error
+	// of type H3_ID_ERROR when no MAX_PUSH_ID frame has been sent [...]"
+	// https://www.rfc-editor.org/rfc/rfc9114.html#section-4.6-3
+	return &http3connectionError{
+		code:    http3errH3IDError,
+		message: "push stream created when no MAX_PUSH_ID has been sent",
+	}
+}
+
+func (cc *http3clientConn) handleRequestStream(st *http3stream) error {
+	// "Clients MUST treat receipt of a server-initiated bidirectional
+	// stream as a connection error of type H3_STREAM_CREATION_ERROR [...]"
+	// https://www.rfc-editor.org/rfc/rfc9114.html#section-6.1-3
+	return &http3connectionError{
+		code:    http3errH3StreamCreationError,
+		message: "server created bidirectional stream",
+	}
+}
+
+// abort closes the connection with an error.
+func (cc *http3clientConn) abort(err error) {
+	if e, ok := err.(*http3connectionError); ok {
+		cc.qconn.Abort(&quic.ApplicationError{
+			Code:   uint64(e.code),
+			Reason: e.message,
+		})
+	} else {
+		cc.qconn.Abort(err)
+	}
+}
+
+type http3roundTripState struct {
+	cc *http3clientConn
+	st *http3stream
+
+	// Request body, provided by the caller.
+	onceCloseReqBody sync.Once
+	reqBody          io.ReadCloser
+
+	reqBodyWriter http3bodyWriter
+
+	// Response.Body, provided to the caller.
+	respBody io.ReadCloser
+
+	trace *httptrace.ClientTrace
+
+	errOnce sync.Once
+	err     error
+}
+
+// abort terminates the RoundTrip.
+// It returns the first fatal error encountered by the RoundTrip call.
+func (rt *http3roundTripState) abort(err error) error {
+	rt.errOnce.Do(func() {
+		rt.err = err
+		rt.cc.decInFlight()
+		switch e := err.(type) {
+		case *http3connectionError:
+			rt.cc.abort(e)
+		case *http3streamError:
+			rt.st.stream.CloseRead()
+			rt.st.stream.Reset(uint64(e.code))
+		default:
+			rt.st.stream.CloseRead()
+			rt.st.stream.Reset(uint64(http3errH3NoError))
+		}
+	})
+	return rt.err
+}
+
+// closeReqBody closes the Request.Body, at most once.
+func (rt *http3roundTripState) closeReqBody() {
+	if rt.reqBody != nil {
+		rt.onceCloseReqBody.Do(func() {
+			rt.reqBody.Close()
+		})
+	}
+}
+
+// TODO: Set up the rest of the hooks that might be in rt.trace.
+func (rt *http3roundTripState) maybeCallGot1xxResponse(status int, h Header) error {
+	if rt.trace == nil || rt.trace.Got1xxResponse == nil {
+		return nil
+	}
+	return rt.trace.Got1xxResponse(status, textproto.MIMEHeader(h))
+}
+
+func (rt *http3roundTripState) maybeCallGot100Continue() {
+	if rt.trace == nil || rt.trace.Got100Continue == nil {
+		return
+	}
+	rt.trace.Got100Continue()
+}
+
+func (rt *http3roundTripState) maybeCallWait100Continue() {
+	if rt.trace == nil || rt.trace.Wait100Continue == nil {
+		return
+	}
+	rt.trace.Wait100Continue()
+}
+
+// RoundTrip sends a request on the connection.
+func (cc *http3clientConn) RoundTrip(req *Request) (_ *Response, err error) {
+	// Each request gets its own QUIC stream.
+	st, err := http3newConnStream(req.Context(), cc.qconn, http3streamTypeRequest)
+	if err != nil {
+		if req.Context().Err() != nil {
+			return nil, err
+		}
+		// Nothing has been sent, so the request may be retried elsewhere.
+		return nil, http3errNoConn
+	}
+	cc.incInFlight()
+	rt := &http3roundTripState{
+		cc:      cc,
+		st:      st,
+		trace:   httptrace.ContextClientTrace(req.Context()),
+		reqBody: req.Body,
+	}
+	if rt.reqBody == nil {
+		rt.reqBody = NoBody
+	}
+	defer func() {
+		if err != nil {
+			err = rt.abort(err)
+		}
+	}()
+
+	// Cancel reads/writes on the stream when the request expires.
+	st.stream.SetReadContext(req.Context())
+	st.stream.SetWriteContext(req.Context())
+
+	if err := http3checkRequest(req); err != nil {
+		return nil, err
+	}
+	var fieldSectionSize int64
+	headers := cc.enc.encode(func(yield func(itype http3indexType, name, value string)) {
+		http3encodeHeaders(req, func(name, value string) {
+			// Issue #71374: Consider supporting never-indexed fields.
+			yield(http3mayIndex, name, value)
+			fieldSectionSize += http3fieldLineSize(name, value)
+		})
+	})
+	if max := cc.maxFieldSectionSize(); max >= 0 && fieldSectionSize > max {
+		return nil, http3errRequestHeaderTooLarge
+	}
+
+	// Write the HEADERS frame.
+	st.writeVarint(int64(http3frameTypeHeaders))
+	st.writeVarint(int64(len(headers)))
+	st.Write(headers)
+	if err := st.Flush(); err != nil {
+		return nil, err
+	}
+
+	// The body is written at most once: immediately, on receipt of a
+	// 100 Continue response, or when ExpectContinueTimeout expires.
+	var writeBodyOnce sync.Once
+	writeBody := func() {
+		writeBodyOnce.Do(func() {
+			go cc.writeBodyAndTrailer(rt, req)
+		})
+	}
+	is100ContinueReq := cc.tr.t.ExpectContinueTimeout != 0 &&
+		httpguts.HeaderValuesContainsToken(req.Header["Expect"], "100-continue")
+	if is100ContinueReq {
+		rt.maybeCallWait100Continue()
+		timer := time.AfterFunc(cc.tr.t.ExpectContinueTimeout, writeBody)
+		defer timer.Stop()
+	} else {
+		writeBody()
+	}
+
+	// Read the response headers.
+	for {
+		ftype, err := st.readFrameHeader()
+		if err != nil {
+			return nil, err
+		}
+		switch ftype {
+		case http3frameTypeHeaders:
+			statusCode, h, err := cc.handleHeaders(st)
+			if err != nil {
+				return nil, err
+			}
+
+			// Informational responses are reported to the trace hooks
+			// and otherwise skipped, except that 100 Continue starts
+			// sending the body of a request that was waiting for it.
+			if http3isInfoStatus(statusCode) {
+				if err := rt.maybeCallGot1xxResponse(statusCode, h); err != nil {
+					return nil, err
+				}
+				switch statusCode {
+				case 100:
+					rt.maybeCallGot100Continue()
+					if is100ContinueReq {
+						writeBody()
+						continue
+					}
+					// If we did not send "Expect: 100-continue" request but
+					// received status 100 anyways, just continue per usual and
+					// let the caller decide what to do with the response.
+				default:
+					continue
+				}
+			}
+
+			// We have the response headers.
+			// Set up the response and return it to the caller.
+			contentLength, err := http3parseResponseContentLength(req.Method, statusCode, h)
+			if err != nil {
+				return nil, err
+			}
+
+			trailer := make(Header)
+			http3extractTrailerFromHeader(h, trailer)
+			delete(h, "Trailer")
+
+			if (contentLength != 0 && req.Method != MethodHead) || len(trailer) > 0 {
+				rt.respBody = &http3bodyReader{
+					st:      st,
+					remain:  contentLength,
+					trailer: trailer,
+				}
+			} else {
+				rt.respBody = NoBody
+			}
+			tlsState := cc.qconn.ConnectionState()
+			resp := &Response{
+				Proto:         "HTTP/3.0",
+				ProtoMajor:    3,
+				Header:        h,
+				StatusCode:    statusCode,
+				Status:        strconv.Itoa(statusCode) + " " + StatusText(statusCode),
+				ContentLength: contentLength,
+				Trailer:       trailer,
+				Body:          (*http3transportResponseBody)(rt),
+				TLS:           &tlsState,
+			}
+			// TODO: Automatic Content-Type: gzip decoding.
+			return resp, nil
+		case http3frameTypePushPromise:
+			if err := cc.handlePushPromise(st); err != nil {
+				return nil, err
+			}
+		default:
+			if err := st.discardUnknownFrame(ftype); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// http3checkRequest reports an error if req cannot be sent over HTTP/3.
+func http3checkRequest(req *Request) error {
+	// Check for invalid connection-level headers.
+	// https://www.rfc-editor.org/rfc/rfc9114.html#section-4.2-3
+	//
+	// Certain headers are special-cased as okay but not transmitted later.
+	// For example, we allow "Transfer-Encoding: chunked", but drop the header when encoding.
+	h := req.Header
+	if vv := h["Upgrade"]; len(vv) > 0 && (vv[0] != "" && vv[0] != "chunked") {
+		return fmt.Errorf("http3: invalid Upgrade request header: %q", vv)
+	}
+	if vv := h["Transfer-Encoding"]; len(vv) > 0 && (len(vv) > 1 || vv[0] != "" && vv[0] != "chunked") {
+		return fmt.Errorf("http3: invalid Transfer-Encoding request header: %q", vv)
+	}
+	if vv := h["Connection"]; len(vv) > 0 && (len(vv) > 1 || vv[0] != "" && !ascii.EqualFold(vv[0], "close") && !ascii.EqualFold(vv[0], "keep-alive")) {
+		return fmt.Errorf("http3: invalid Connection request header: %q", vv)
+	}
+
+	host, err := httpguts.PunycodeHostPort(http3requestHost(req))
+	if err != nil {
+		return err
+	}
+	if !httpguts.ValidHostHeader(host) {
+		return errors.New("http3: invalid Host header")
+	}
+	if req.Method != "CONNECT" && !http3validPseudoPath(http3requestPath(req, host)) {
+		return fmt.Errorf("http3: invalid request :path %q", req.URL.RequestURI())
+	}
+
+	for _, hdrs := range []Header{req.Header, req.Trailer} {
+		for k, vv := range hdrs {
+			if !httpguts.ValidHeaderFieldName(k) {
+				return fmt.Errorf("http3: invalid HTTP header name %q", k)
+			}
+			for _, v := range vv {
+				if !httpguts.ValidHeaderFieldValue(v) {
+					// Don't include the value in the error,
+					// because it may be sensitive.
+					return fmt.Errorf("http3: invalid HTTP header value for header %q", k)
+				}
+			}
+		}
+	}
+	for k := range req.Trailer {
+		switch CanonicalHeaderKey(k) {
+		case "Transfer-Encoding", "Trailer", "Content-Length":
+			return fmt.Errorf("http3: invalid Trailer key %q", k)
+		}
+	}
+	return nil
+}
+
+// http3requestHost returns the :authority pseudo-header field for req,
+// before IDNA conversion.
+func http3requestHost(req *Request) string {
+	if req.Host != "" {
+		return req.Host
+	}
+	return req.URL.Host
+}
+
+// http3requestPath returns the :path pseudo-header field for req.
+func http3requestPath(req *Request, host string) string {
+	path := req.URL.RequestURI()
+	if !http3validPseudoPath(path) {
+		path = strings.TrimPrefix(path, req.URL.Scheme+"://"+host)
+	}
+	return path
+}
+
+// http3validPseudoPath reports whether v is a valid :path pseudo-header
+// value. It must be either a non-empty string starting with '/',
+// or the string '*', for OPTIONS requests.
+func http3validPseudoPath(v string) bool {
+	return (len(v) > 0 && v[0] == '/') || v == "*"
+}
+
+// http3encodeHeaders calls f with each field of the header section
+// for req, which must have been validated by http3checkRequest.
+func http3encodeHeaders(req *Request, f func(name, value string)) {
+	trace := httptrace.ContextClientTrace(req.Context())
+	writeField := func(name, value string) {
+		name, ok := ascii.ToLower(name)
+		if !ok {
+			// Field names are strings containing a subset of ASCII characters.
+			// https://www.rfc-editor.org/rfc/rfc9114.html#section-4.2
+			return
+		}
+		f(name, value)
+		if trace != nil && trace.WroteHeaderField != nil {
+			trace.WroteHeaderField(name, []string{value})
+		}
+	}
+
+	host, _ := httpguts.PunycodeHostPort(http3requestHost(req))
+	writeField(":authority", host)
+	m := req.Method
+	if m == "" {
+		m = "GET"
+	}
+	writeField(":method", m)
+	if m != "CONNECT" {
+		writeField(":path", http3requestPath(req, host))
+		writeField(":scheme", req.URL.Scheme)
+	}
+	if len(req.Trailer) > 0 {
+		keys := make([]string, 0, len(req.Trailer))
+		for k := range req.Trailer {
+			keys = append(keys, CanonicalHeaderKey(k))
+		}
+		sort.Strings(keys)
+		writeField("trailer", strings.Join(keys, ","))
+	}
+
+	var didUA bool
+	for k, vv := range req.Header {
+		switch {
+		case ascii.EqualFold(k, "host"), ascii.EqualFold(k, "content-length"):
+			// Host is :authority, already sent.
+			// Content-Length is automatic, set below.
+			continue
+		case ascii.EqualFold(k, "connection"),
+			ascii.EqualFold(k, "proxy-connection"),
+			ascii.EqualFold(k, "transfer-encoding"),
+			ascii.EqualFold(k, "upgrade"),
+			ascii.EqualFold(k, "keep-alive"):
+			// Don't send connection-specific fields. We have
+			// already checked if any are error-worthy so just
+			// ignore the rest.
+			continue
+		case ascii.EqualFold(k, "user-agent"):
+			// Match Go's http1 behavior: at most one
+			// User-Agent. If set to nil or empty string,
+			// then omit it. Otherwise if not mentioned,
+			// include the default (below).
+			didUA = true
+			if len(vv) < 1 {
+				continue
+			}
+			vv = vv[:1]
+			if vv[0] == "" {
+				continue
+			}
+		case ascii.EqualFold(k, "cookie"):
+			// To allow for better compression efficiency, the Cookie
+			// field MAY be split into separate field lines, each with
+			// one or more cookie-pairs.
+			// https://www.rfc-editor.org/rfc/rfc9114.html#section-4.2.1
+			for _, v := range vv {
+				for {
+					p := strings.IndexByte(v, ';')
+					if p < 0 {
+						break
+					}
+					writeField("cookie", v[:p])
+					p++
+					// strip space after semicolon if any.
+					for p+1 <= len(v) && v[p] == ' ' {
+						p++
+					}
+					v = v[p:]
+				}
+				if len(v) > 0 {
+					writeField("cookie", v)
+				}
+			}
+			continue
+		}
+		for _, v := range vv {
+			writeField(k, v)
+		}
+	}
+	if n := http3actualContentLength(req); n > 0 || n == 0 && (m == "POST" || m == "PUT" || m == "PATCH") {
+		writeField("content-length", strconv.FormatInt(n, 10))
+	}
+	if !didUA {
+		writeField("user-agent", "Go-http-client/3")
+	}
+}
+
+// actualContentLength returns a sanitized version of req.ContentLength,
+// where 0 actually means zero (not unknown) and -1 means unknown.
+func http3actualContentLength(req *Request) int64 {
+	if req.Body == nil || req.Body == NoBody {
+		return 0
+	}
+	if req.ContentLength != 0 {
+		return req.ContentLength
+	}
+	return -1
+}
+
+// writeBodyAndTrailer handles writing the body and trailer for a given
+// request, if any. This function will close the write direction of the stream.
+func (cc *http3clientConn) writeBodyAndTrailer(rt *http3roundTripState, req *Request) {
+	defer rt.closeReqBody()
+
+	declaredTrailer := req.Trailer.Clone()
+
+	rt.reqBodyWriter.st = rt.st
+	rt.reqBodyWriter.remain = http3actualContentLength(req)
+	rt.reqBodyWriter.flush = true
+	rt.reqBodyWriter.name = "request"
+	rt.reqBodyWriter.trailer = req.Trailer
+	rt.reqBodyWriter.enc = &cc.enc
+
+	if _, err := io.Copy(&rt.reqBodyWriter, rt.reqBody); err != nil {
+		rt.abort(err)
+	}
+	// Get rid of any trailer that was not declared beforehand, before we
+	// close the request body which will cause the trailer headers to be
+	// written.
+	for name := range req.Trailer {
+		if _, ok := declaredTrailer[name]; !ok {
+			delete(req.Trailer, name)
+		}
+	}
+	if err := rt.reqBodyWriter.Close(); err != nil {
+		rt.abort(err)
+	}
+}
+
+// transportResponseBody is the Response.Body returned by RoundTrip.
+type http3transportResponseBody http3roundTripState
+
+// Read is Response.Body.Read.
+func (b *http3transportResponseBody) Read(p []byte) (n int, err error) {
+	return b.respBody.Read(p)
+}
+
+var http3errRespBodyClosed = errors.New("response body closed")
+
+var http3errRequestHeaderTooLarge = errors.New("http3: request header larger than peer's advertised limit")
+
+// Close is Response.Body.Close.
+// Closing the response body is how the caller signals that they're done with a request.
+func (b *http3transportResponseBody) Close() error {
+	rt := (*http3roundTripState)(b)
+	// Close the request body, which should wake up copyRequestBody if it's
+	// currently blocked reading the body.
+	rt.closeReqBody()
+	// Close the request stream, since we're done with the request.
+	// Reset closes the sending half of the stream.
+	rt.st.stream.Reset(uint64(http3errH3NoError))
+	// respBody.Close is responsible for closing the receiving half.
+	err := rt.respBody.Close()
+	if err == nil {
+		err = http3errRespBodyClosed
+	}
+	err = rt.abort(err)
+	if err == http3errRespBodyClosed {
+		// No other errors occurred before closing Response.Body,
+		// so consider this a successful request.
+		return nil
+	}
+	return err
+}
+
+func http3parseResponseContentLength(method string, statusCode int, h Header) (int64, error) {
+	clens := h["Content-Length"]
+	if len(clens) == 0 {
+		return -1, nil
+	}
+
+	// We allow duplicate Content-Length headers,
+	// but only if they all have the same value.
+	for _, v := range clens[1:] {
+		if clens[0] != v {
+			return -1, &http3streamError{http3errH3MessageError, "mismatching Content-Length headers"}
+		}
+	}
+
+	// "A server MUST NOT send a Content-Length header field in any response
+	// with a status code of 1xx (Informational) or 204 (No Content).
+	// A server MUST NOT send a Content-Length header field in any 2xx (Successful)
+	// response to a CONNECT request [...]"
+	// https://www.rfc-editor.org/rfc/rfc9110#section-8.6-8
+	if (statusCode >= 100 && statusCode < 200) ||
+		statusCode == 204 ||
+		(method == "CONNECT" && statusCode >= 200 && statusCode < 300) {
+		// This is a protocol violation, but a fairly harmless one.
+		// Just ignore the header.
+		return -1, nil
+	}
+
+	contentLen, err := strconv.ParseUint(clens[0], 10, 63)
+	if err != nil {
+		return -1, &http3streamError{http3errH3MessageError, "invalid Content-Length header"}
+	}
+	return int64(contentLen), nil
+}
+
+func (cc *http3clientConn) handleHeaders(st *http3stream) (statusCode int, h Header, err error) {
+	haveStatus := false
+	cookie := ""
+	// Issue #71374: Consider tracking the never-indexed status of headers
+	// with the N bit set in their QPACK encoding.
+	err = cc.dec.decode(st, func(_ http3indexType, name, value string) error {
+		if !httpguts.ValidHeaderFieldValue(value) {
+			return &http3streamError{http3errH3MessageError, "invalid field value"}
+		}
+		switch {
+		case name == ":status":
+			if haveStatus {
+				return &http3streamError{http3errH3MessageError, "duplicate :status"}
+			}
+			haveStatus = true
+			statusCode, err = strconv.Atoi(value)
+			if err != nil {
+				return &http3streamError{http3errH3MessageError, "invalid :status"}
+			}
+		case name[0] == ':':
+			// "Endpoints MUST treat a request or response
+			// that contains undefined or invalid
+			// pseudo-header fields as malformed."
+			// https://www.rfc-editor.org/rfc/rfc9114.html#section-4.3-3
+			return &http3streamError{http3errH3MessageError, "undefined pseudo-header"}
+		case name == "cookie":
+			// "If a decompressed field section contains multiple cookie field lines,
+			// these MUST be concatenated into a single byte string [...]"
+			// using the two-byte delimiter of "; "''
+			// https://www.rfc-editor.org/rfc/rfc9114.html#section-4.2.1-2
+			if cookie == "" {
+				cookie = value
+			} else {
+				cookie += "; " + value
+			}
+		default:
+			if !http3validWireHeaderFieldName(name) {
+				return &http3streamError{http3errH3MessageError, "invalid field name"}
+			}
+			if h == nil {
+				h = make(Header)
+			}
+			// TODO: Use a per-connection canonicalization cache as we do in HTTP/2.
+			// Maybe we could put this in the QPACK decoder and have it deliver
+			// pre-canonicalized headers to us here?
+			cname := CanonicalHeaderKey(name)
+			// TODO: Consider using a single []string slice for all headers,
+			// as we do in the HTTP/1 and HTTP/2 cases.
+			// This is a bit tricky, since we don't know the number of headers
+			// at the start of decoding. Perhaps it's worth doing a two-pass decode,
+			// or perhaps we should just allocate header value slices in
+			// reasonably-sized chunks.
+			h[cname] = append(h[cname], value)
+		}
+		return nil
+	})
+	if !haveStatus {
+		// "[The :status] pseudo-header field MUST be included in all responses [...]"
+		// https://www.rfc-editor.org/rfc/rfc9114.html#section-4.3.2-1
+		err = http3errH3MessageError
+	}
+	if cookie != "" {
+		if h == nil {
+			h = make(Header)
+		}
+		h["Cookie"] = []string{cookie}
+	}
+	if err := st.endFrame(); err != nil {
+		return 0, nil, err
+	}
+	return statusCode, h, err
+}
+
+func (cc *http3clientConn) handlePushPromise(st *http3stream) error {
+	// "A client MUST treat receipt of a PUSH_PROMISE frame that contains a
+	// larger push ID than the client has advertised as a connection error of H3_ID_ERROR."
+	// https://www.rfc-editor.org/rfc/rfc9114.html#section-7.2.5-5
+	return &http3connectionError{
+		code:    http3errH3IDError,
+		message: "PUSH_PROMISE received when no MAX_PUSH_ID has been sent",
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
@@ -369,6 +370,314 @@ func TestXForwardedFor_Omit(t *testing.T) {
 	res.Body.Close()
 }
 
+func TestReverseProxyRewriteStripsForwarded(t *testing.T) {
+	headers := []string{
+		"Forwarded",
+		"X-Forwarded-For",
+		"X-Forwarded-Host",
+		"X-Forwarded-Proto",
+	}
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, h := range headers {
+			if v := r.Header.Get(h); v != "" {
+				t.Errorf("got %v header: %q", h, v)
+			}
+		}
+	}))
+	defer backend.Close()
+	backendURL, err := url.Parse(backend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxyHandler := &ReverseProxy{
+		Rewrite: func(r *ProxyRequest) {
+			r.SetURL(backendURL)
+		},
+	}
+	frontend := httptest.NewServer(proxyHandler)
+	defer frontend.Close()
+
+	getReq, _ := http.NewRequest("GET", frontend.URL, nil)
+	getReq.Host = "some-name"
+	getReq.Close = true
+	for _, h := range headers {
+		getReq.Header.Set(h, "x")
+	}
+	res, err := frontend.Client().Do(getReq)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	res.Body.Close()
+}
+
+func TestReverseProxyRewriteAfterHopHeaders(t *testing.T) {
+	const smuggled = "X-Smuggled"
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if v := r.Header.Get(smuggled); v != "" {
+			t.Errorf("%v header sent to backend: %q", smuggled, v)
+		}
+		if got, want := r.Header.Get("X-Set-By-Rewrite"), "yes"; got != want {
+			t.Errorf("X-Set-By-Rewrite = %q, want %q", got, want)
+		}
+	}))
+	defer backend.Close()
+	backendURL, err := url.Parse(backend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxyHandler := &ReverseProxy{
+		Rewrite: func(r *ProxyRequest) {
+			if v := r.Out.Header.Get(smuggled); v != "" {
+				t.Errorf("Rewrite saw %v header listed in Connection: %q", smuggled, v)
+			}
+			if got, want := r.In.Header.Get(smuggled), "value"; got != want {
+				t.Errorf("inbound %v header = %q, want %q", smuggled, got, want)
+			}
+			r.SetURL(backendURL)
+			r.Out.Header.Set("X-Set-By-Rewrite", "yes")
+		},
+	}
+	frontend := httptest.NewServer(proxyHandler)
+	defer frontend.Close()
+
+	getReq, _ := http.NewRequest("GET", frontend.URL, nil)
+	getReq.Header.Set("Connection", smuggled)
+	getReq.Header.Set(smuggled, "value")
+	res, err := frontend.Client().Do(getReq)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	res.Body.Close()
+}
+
+func TestReverseProxyRewriteReplacesOut(t *testing.T) {
+	const content = "response_content"
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(content))
+	}))
+	defer backend.Close()
+	proxyHandler := &ReverseProxy{
+		Rewrite: func(r *ProxyRequest) {
+			r.Out, _ = http.NewRequest("GET", backend.URL, nil)
+		},
+	}
+	frontend := httptest.NewServer(proxyHandler)
+	defer frontend.Close()
+
+	res, err := frontend.Client().Get(frontend.URL)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer res.Body.Close()
+	body, _ := io.ReadAll(res.Body)
+	if got, want := string(body), content; got != want {
+		t.Errorf("got response %q, want %q", got, want)
+	}
+}
+
+func TestReverseProxyDirectorAndRewrite(t *testing.T) {
+	for _, p := range []*ReverseProxy{
+		{},
+		{
+			Director: func(*http.Request) {},
+			Rewrite:  func(*ProxyRequest) {},
+		},
+	} {
+		rec := httptest.NewRecorder()
+		p.ErrorLog = log.New(io.Discard, "", 0)
+		p.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		if rec.Code != http.StatusBadGateway {
+			t.Errorf("ServeHTTP with Director %v and Rewrite %v: status = %v, want %v",
+				p.Director != nil, p.Rewrite != nil, rec.Code, http.StatusBadGateway)
+		}
+	}
+}
+
+func TestSetURL(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host))
+	}))
+	defer backend.Close()
+	backendURL, err := url.Parse(backend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxyHandler := &ReverseProxy{
+		Rewrite: func(r *ProxyRequest) {
+			r.SetURL(backendURL)
+		},
+	}
+	frontend := httptest.NewServer(proxyHandler)
+	defer frontend.Close()
+	frontendClient := frontend.Client()
+
+	res, err := frontendClient.Get(frontend.URL)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("Reading body: %v", err)
+	}
+
+	if got, want := string(body), backendURL.Host; got != want {
+		t.Errorf("backend got Host %q, want %q", got, want)
+	}
+}
+
+func TestSetXForwarded(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		prior   []string
+		tls     bool
+		wantFor string
+	}{{
+		name:    "no prior",
+		wantFor: "192.0.2.1",
+	}, {
+		name:    "prior",
+		prior:   []string{"198.51.100.1", "198.51.100.2"},
+		tls:     true,
+		wantFor: "198.51.100.1, 198.51.100.2, 192.0.2.1",
+	}} {
+		in := httptest.NewRequest("GET", "http://example.com/", nil)
+		in.RemoteAddr = "192.0.2.1:1234"
+		if tt.tls {
+			in.TLS = &tls.ConnectionState{}
+		}
+		out := in.Clone(context.Background())
+		out.Header["X-Forwarded-For"] = tt.prior
+		pr := &ProxyRequest{In: in, Out: out}
+		pr.SetXForwarded()
+		wantProto := "http"
+		if tt.tls {
+			wantProto = "https"
+		}
+		for h, want := range map[string]string{
+			"X-Forwarded-For":   tt.wantFor,
+			"X-Forwarded-Host":  "example.com",
+			"X-Forwarded-Proto": wantProto,
+		} {
+			if got := out.Header.Get(h); got != want {
+				t.Errorf("%v: %v = %q, want %q", tt.name, h, got, want)
+			}
+		}
+	}
+}
+
+func TestSetForwarded(t *testing.T) {
+	for _, tt := range []struct {
+		remoteAddr string
+		host       string
+		tls        bool
+		prior      []string
+		want       string
+	}{{
+		remoteAddr: "192.0.2.43:1234",
+		host:       "example.com",
+		want:       "for=192.0.2.43;host=example.com;proto=http",
+	}, {
+		remoteAddr: "[2001:db8:cafe::17]:4711",
+		host:       "example.com:8443",
+		tls:        true,
+		want:       `for="[2001:db8:cafe::17]";host="example.com:8443";proto=https`,
+	}, {
+		remoteAddr: "pipe",
+		host:       "example.com",
+		prior:      []string{"for=198.51.100.17", "for=198.51.100.18"},
+		want:       "for=198.51.100.17, for=198.51.100.18, for=unknown;host=example.com;proto=http",
+	}} {
+		in := httptest.NewRequest("GET", "/", nil)
+		in.RemoteAddr = tt.remoteAddr
+		in.Host = tt.host
+		if tt.tls {
+			in.TLS = &tls.ConnectionState{}
+		}
+		out := in.Clone(context.Background())
+		out.Header["Forwarded"] = tt.prior
+		pr := &ProxyRequest{In: in, Out: out}
+		pr.SetForwarded()
+		if got := out.Header.Get("Forwarded"); got != tt.want {
+			t.Errorf("RemoteAddr %q, Host %q: Forwarded = %q, want %q", tt.remoteAddr, tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestReverseProxyQueryParameterSmuggling(t *testing.T) {
+	// The servers log a warning about each query containing a semicolon.
+	quiet := log.New(io.Discard, "", 0)
+	backend := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.RawQuery))
+	}))
+	backend.Config.ErrorLog = quiet
+	backend.Start()
+	defer backend.Close()
+	backendURL, err := url.Parse(backend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name  string
+		proxy *ReverseProxy
+		query string
+		want  string
+	}{{
+		name: "Director without Form",
+		proxy: &ReverseProxy{
+			Director: func(r *http.Request) {
+				rewriteRequestURL(r, backendURL)
+			},
+		},
+		query: "a=1&a=2;b=3",
+		want:  "a=1&a=2;b=3",
+	}, {
+		name: "Director with Form",
+		proxy: &ReverseProxy{
+			Director: func(r *http.Request) {
+				rewriteRequestURL(r, backendURL)
+				r.ParseForm()
+			},
+		},
+		query: "a=1&a=2;b=3",
+		want:  "a=1",
+	}, {
+		name: "Rewrite",
+		proxy: &ReverseProxy{
+			Rewrite: func(r *ProxyRequest) {
+				r.SetURL(backendURL)
+			},
+		},
+		query: "a=1&a=2;b=3&c=%zz&d=4",
+		want:  "a=1&d=4",
+	}, {
+		name: "Rewrite preserving query",
+		proxy: &ReverseProxy{
+			Rewrite: func(r *ProxyRequest) {
+				r.SetURL(backendURL)
+				r.Out.URL.RawQuery = r.In.URL.RawQuery
+			},
+		},
+		query: "a=1&a=2;b=3",
+		want:  "a=1&a=2;b=3",
+	}} {
+		frontend := httptest.NewUnstartedServer(tt.proxy)
+		frontend.Config.ErrorLog = quiet
+		frontend.Start()
+		res, err := frontend.Client().Get(frontend.URL + "?" + tt.query)
+		if err != nil {
+			t.Fatalf("%v: Get: %v", tt.name, err)
+		}
+		body, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		frontend.Close()
+		if got := string(body); got != tt.want {
+			t.Errorf("%v: backend got query %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
 var proxyQueryTests = []struct {
 	baseSuffix string // suffix to add to backend URL
 	reqSuffix  string // suffix to add to frontend's request URL
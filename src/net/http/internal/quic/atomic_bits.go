@@ -0,0 +1,82 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// atomicBits is an atomic uint32 that supports setting individual bits.
+type atomicBits[T ~uint32] struct {
+	bits uint32
+}
+
+// set sets the bits in mask to the corresponding bits in v.
+// It returns the new value.
+func (a *atomicBits[T]) set(v, mask T) T {
+	if v&^mask != 0 {
+		panic("BUG: bits in v are not in mask")
+	}
+	for {
+		o := atomic.LoadUint32(&a.bits)
+		n := (o &^ uint32(mask)) | uint32(v)
+		if atomic.CompareAndSwapUint32(&a.bits, o, n) {
+			return T(n)
+		}
+	}
+}
+
+func (a *atomicBits[T]) load() T {
+	return T(atomic.LoadUint32(&a.bits))
+}
+
+// atomicBool is an atomic boolean.
+type atomicBool struct {
+	v uint32
+}
+
+func (b *atomicBool) Load() bool {
+	return atomic.LoadUint32(&b.v) != 0
+}
+
+func (b *atomicBool) Store(v bool) {
+	var n uint32
+	if v {
+		n = 1
+	}
+	atomic.StoreUint32(&b.v, n)
+}
+
+// atomicInt64 is an int64 which may be accessed concurrently.
+//
+// It is guarded by a mutex rather than using 64-bit atomic operations,
+// which require 8-byte alignment that is not guaranteed on 32-bit platforms
+// for fields embedded deep within other structs.
+type atomicInt64 struct {
+	mu sync.Mutex
+	v  int64
+}
+
+func (a *atomicInt64) Add(delta int64) int64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.v += delta
+	return a.v
+}
+
+func (a *atomicInt64) Load() int64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.v
+}
+
+func (a *atomicInt64) Swap(v int64) int64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	old := a.v
+	a.v = v
+	return old
+}
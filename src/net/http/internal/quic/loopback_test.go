@@ -0,0 +1,102 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net/http/internal/testcert"
+	"testing"
+	"time"
+)
+
+// newLoopbackConfigs returns server and client configurations
+// using the localhost test certificate.
+func newLoopbackConfigs(t *testing.T) (server, client *Config) {
+	cert, err := tls.X509KeyPair(testcert.LocalhostCert, testcert.LocalhostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(testcert.LocalhostCert) {
+		t.Fatal("failed to parse test certificate")
+	}
+	server = &Config{
+		TLSConfig: &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS13,
+			NextProtos:   []string{"test"},
+		},
+	}
+	client = &Config{
+		TLSConfig: &tls.Config{
+			RootCAs:    roots,
+			ServerName: "example.com",
+			MinVersion: tls.VersionTLS13,
+			NextProtos: []string{"test"},
+		},
+	}
+	return server, client
+}
+
+func TestLoopbackStreamEcho(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	serverConfig, clientConfig := newLoopbackConfigs(t)
+	server, err := Listen("udp", "127.0.0.1:0", serverConfig)
+	if err != nil {
+		t.Skipf("cannot listen on UDP: %v", err)
+	}
+	defer server.Close(ctx)
+	// The client endpoint is dual-stack, and dials an IPv4 address.
+	client, err := Listen("udp", ":0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close(ctx)
+
+	go func() {
+		c, err := server.Accept(ctx)
+		if err != nil {
+			return
+		}
+		s, err := c.AcceptStream(ctx)
+		if err != nil {
+			return
+		}
+		io.Copy(s, s)
+		s.Close()
+	}()
+
+	c, err := client.Dial(ctx, "udp", server.LocalAddr().String(), clientConfig)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	if got := c.ConnectionState().NegotiatedProtocol; got != "test" {
+		t.Errorf("NegotiatedProtocol = %q, want test", got)
+	}
+	s, err := c.NewStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := bytes.Repeat([]byte("hello, world\n"), 10000)
+	go func() {
+		s.Write(want)
+		s.CloseWrite()
+	}()
+	got, err := io.ReadAll(s)
+	if err != nil {
+		t.Fatalf("reading stream: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("echoed %d bytes, want %d", len(got), len(want))
+	}
+	s.Close()
+	c.Close()
+}
@@ -0,0 +1,36 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quic
+
+func abs[T ~int | ~int64](a T) T {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+type ordered interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64
+}
+
+func min[T ordered](a T, rest ...T) T {
+	for _, b := range rest {
+		if b < a {
+			a = b
+		}
+	}
+	return a
+}
+
+func max[T ordered](a T, rest ...T) T {
+	for _, b := range rest {
+		if b > a {
+			a = b
+		}
+	}
+	return a
+}
@@ -0,0 +1,52 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package quicwire
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestVarint(t *testing.T) {
+	// Examples from RFC 9000, Section A.1.
+	tests := []struct {
+		v uint64
+		b []byte
+	}{
+		{37, []byte{0x25}},
+		{15293, []byte{0x7b, 0xbd}},
+		{494878333, []byte{0x9d, 0x7f, 0x3e, 0x7d}},
+		{151288809941952652, []byte{0xc2, 0x19, 0x7c, 0x5e, 0xff, 0x14, 0xe8, 0x8c}},
+	}
+	for _, tt := range tests {
+		if got := AppendVarint(nil, tt.v); !bytes.Equal(got, tt.b) {
+			t.Errorf("AppendVarint(%v) = %x, want %x", tt.v, got, tt.b)
+		}
+		if got := SizeVarint(tt.v); got != len(tt.b) {
+			t.Errorf("SizeVarint(%v) = %v, want %v", tt.v, got, len(tt.b))
+		}
+		if v, n := ConsumeVarint(tt.b); v != tt.v || n != len(tt.b) {
+			t.Errorf("ConsumeVarint(%x) = %v, %v; want %v, %v", tt.b, v, n, tt.v, len(tt.b))
+		}
+		if _, n := ConsumeVarint(tt.b[:len(tt.b)-1]); n >= 0 {
+			t.Errorf("ConsumeVarint(%x) of truncated input = %v, want error", tt.b[:len(tt.b)-1], n)
+		}
+	}
+	// Non-minimal encodings are accepted.
+	if v, n := ConsumeVarint([]byte{0x40, 0x25}); v != 37 || n != 2 {
+		t.Errorf("ConsumeVarint(4025) = %v, %v; want 37, 2", v, n)
+	}
+}
+
+func TestVarintBytes(t *testing.T) {
+	b := AppendVarintBytes(nil, []byte("hello"))
+	got, n := ConsumeVarintBytes(b)
+	if string(got) != "hello" || n != len(b) {
+		t.Errorf("ConsumeVarintBytes(%x) = %q, %v", b, got, n)
+	}
+	if _, n := ConsumeVarintBytes(b[:len(b)-1]); n >= 0 {
+		t.Errorf("ConsumeVarintBytes of truncated input = %v, want error", n)
+	}
+}
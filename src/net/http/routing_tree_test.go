@@ -0,0 +1,116 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func getTestTree(t *testing.T) *routingNode {
+	root := &routingNode{}
+	for _, p := range []string{
+		"/a",
+		"/a/b",
+		"/a/{x}",
+		"/g/h/i",
+		"/g/{x}/j",
+		"/a/b/{x...}",
+		"/a/b/{y}",
+		"/a/b/{$}",
+		"GET /foo/bar",
+		"GET /foo/{$}",
+		"POST /foo/bar",
+		"HEAD /head",
+		"example.com/",
+		"example.com/a/{x...}",
+		"x.com/{y}",
+		"GET x.com/a/b",
+	} {
+		root.addPattern(mustParsePattern(t, p), nil)
+	}
+	return root
+}
+
+func TestRoutingMatch(t *testing.T) {
+	root := getTestTree(t)
+
+	for _, test := range []struct {
+		host, method, path string
+		wantPat            string // "" for nil (no match)
+		wantMatches        []string
+	}{
+		{"", "GET", "/a", "/a", nil},
+		{"", "GET", "/b", "", nil},
+		{"", "GET", "/a/b", "/a/b", nil},
+		{"", "POST", "/a/b", "/a/b", nil},
+		{"", "GET", "/a/c", "/a/{x}", []string{"c"}},
+		{"", "GET", "/a/b/", "/a/b/{$}", nil},
+		{"", "GET", "/a/b/c", "/a/b/{y}", []string{"c"}},
+		{"", "GET", "/a/b/c/d", "/a/b/{x...}", []string{"c/d"}},
+		{"", "GET", "/g/h/i", "/g/h/i", nil},
+		{"", "GET", "/g/h/j", "/g/{x}/j", []string{"h"}},
+		// Escaped segments are unescaped before matching.
+		{"", "GET", "/a/%41", "/a/{x}", []string{"A"}},
+		{"", "GET", "/a/b/c%2Fd", "/a/b/{y}", []string{"c/d"}},
+		// Methods.
+		{"", "GET", "/foo/bar", "GET /foo/bar", nil},
+		{"", "HEAD", "/foo/bar", "GET /foo/bar", nil},
+		{"", "POST", "/foo/bar", "POST /foo/bar", nil},
+		{"", "PUT", "/foo/bar", "", nil},
+		{"", "GET", "/foo/", "GET /foo/{$}", nil},
+		{"", "HEAD", "/head", "HEAD /head", nil},
+		{"", "GET", "/head", "", nil},
+		// Hosts.
+		{"example.com", "GET", "/", "example.com/", nil},
+		{"example.com", "GET", "/a", "example.com/", nil}, // host patterns win
+		{"example.com", "GET", "/b", "example.com/", nil},
+		{"example.com", "GET", "/a/b", "example.com/a/{x...}", []string{"b"}},
+		{"x.com", "GET", "/a/b", "GET x.com/a/b", nil},
+		{"x.com", "PUT", "/a/b", "/a/b", nil},
+		{"x.com", "GET", "/c", "x.com/{y}", []string{"c"}},
+		{"y.com", "GET", "/c", "", nil},
+	} {
+		n, gotMatches := root.match(test.host, test.method, test.path)
+		got := ""
+		if n != nil {
+			got = n.pattern.String()
+		}
+		if got != test.wantPat {
+			t.Errorf("%s %s%s: got pattern %q, want %q", test.method, test.host, test.path, got, test.wantPat)
+		}
+		if !reflect.DeepEqual(gotMatches, test.wantMatches) {
+			t.Errorf("%s %s%s: got matches %q, want %q", test.method, test.host, test.path, gotMatches, test.wantMatches)
+		}
+	}
+}
+
+func TestMatchingMethods(t *testing.T) {
+	root := getTestTree(t)
+	for _, test := range []struct {
+		host, path string
+		want       string
+	}{
+		{"", "/foo/bar", "GET,HEAD,POST"},
+		{"", "/foo/", "GET,HEAD"},
+		{"", "/head", "HEAD"},
+		{"", "/a", ""},
+		{"x.com", "/a/b", "GET,HEAD"},
+		{"y.com", "/a/b", ""},
+	} {
+		ms := map[string]bool{}
+		root.matchingMethods(test.host, test.path, ms)
+		var keys []string
+		for k := range ms {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		if got := strings.Join(keys, ","); got != test.want {
+			t.Errorf("%s%s: got %q, want %q", test.host, test.path, got, test.want)
+		}
+	}
+}
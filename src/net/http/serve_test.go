@@ -601,6 +601,134 @@ func TestServeWithSlashRedirectForHostPatterns(t *testing.T) {
 	}
 }
 
+func TestServeMuxMethodsAndWildcards(t *testing.T) {
+	setParallel(t)
+	mux := NewServeMux()
+	mux.HandleFunc("GET /items/{id}", func(w ResponseWriter, r *Request) {
+		fmt.Fprintf(w, "get %s", r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /items/{id}", func(w ResponseWriter, r *Request) {
+		fmt.Fprintf(w, "post %s", r.PathValue("id"))
+	})
+	mux.HandleFunc("/items/{id}/edit", func(w ResponseWriter, r *Request) {
+		fmt.Fprintf(w, "edit %s", r.PathValue("id"))
+	})
+	mux.HandleFunc("/files/{dir}/{rest...}", func(w ResponseWriter, r *Request) {
+		fmt.Fprintf(w, "files %s %s", r.PathValue("dir"), r.PathValue("rest"))
+	})
+	mux.HandleFunc("/{$}", func(w ResponseWriter, r *Request) {
+		fmt.Fprintf(w, "root")
+	})
+
+	for _, tt := range []struct {
+		method, path string
+		code         int
+		body         string
+		allow        string
+	}{
+		{"GET", "/items/42", 200, "get 42", ""},
+		{"HEAD", "/items/42", 200, "get 42", ""},
+		{"POST", "/items/42", 200, "post 42", ""},
+		{"DELETE", "/items/42", 405, "Method Not Allowed\n", "GET, HEAD, POST"},
+		{"DELETE", "/items/7/edit", 200, "edit 7", ""},
+		{"GET", "/items/a%2Fb", 200, "get a/b", ""},
+		{"GET", "/files/a/b/c", 200, "files a b/c", ""},
+		{"GET", "/files/a/", 200, "files a ", ""},
+		{"GET", "/", 200, "root", ""},
+		{"GET", "/other", 404, "404 page not found\n", ""},
+	} {
+		r := httptest.NewRequest(tt.method, tt.path, nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != tt.code || w.Body.String() != tt.body {
+			t.Errorf("%s %s = %d %q, want %d %q", tt.method, tt.path, w.Code, w.Body.String(), tt.code, tt.body)
+		}
+		if got := w.Header().Get("Allow"); got != tt.allow {
+			t.Errorf("%s %s: Allow = %q, want %q", tt.method, tt.path, got, tt.allow)
+		}
+	}
+}
+
+func TestServeMuxWildcardRedirect(t *testing.T) {
+	setParallel(t)
+	mux := NewServeMux()
+	mux.HandleFunc("GET /dirs/{name}/", func(w ResponseWriter, r *Request) {})
+
+	r := httptest.NewRequest("GET", "/dirs/x?q=1", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	if w.Code != StatusMovedPermanently {
+		t.Fatalf("got code %d, want %d", w.Code, StatusMovedPermanently)
+	}
+	if got, want := w.Header().Get("Location"), "/dirs/x/?q=1"; got != want {
+		t.Errorf("Location = %q, want %q", got, want)
+	}
+}
+
+func TestServeMuxRegistrationConflict(t *testing.T) {
+	for _, tt := range []struct {
+		p1, p2 string
+		want   string // substring of the panic message, or "" for no panic
+	}{
+		{"/a/{x}", "/{y}/b", "conflicts with pattern"},
+		{"GET /a", "GET /a", "multiple registrations for GET /a"},
+		{"/a/{x}", "/a/{y}", "matches the same requests"},
+		{"GET /a", "POST /a", ""},
+		{"/a/{x}", "/a/b", ""},
+		{"example.com/a/{x}", "/{y}/b", ""},
+	} {
+		mux := NewServeMux()
+		mux.Handle(tt.p1, NotFoundHandler())
+		got := ""
+		func() {
+			defer func() {
+				if err := recover(); err != nil {
+					got = fmt.Sprint(err)
+				}
+			}()
+			mux.Handle(tt.p2, NotFoundHandler())
+		}()
+		if tt.want == "" {
+			if got != "" {
+				t.Errorf("%q then %q: unexpected panic: %s", tt.p1, tt.p2, got)
+			}
+			continue
+		}
+		if !strings.Contains(got, tt.want) {
+			t.Errorf("%q then %q: panic %q, want it to contain %q", tt.p1, tt.p2, got, tt.want)
+		}
+		if !strings.Contains(got, "serve_test.go") {
+			t.Errorf("%q then %q: panic %q does not name the registering file", tt.p1, tt.p2, got)
+		}
+	}
+}
+
+func TestSetPathValue(t *testing.T) {
+	mux := NewServeMux()
+	mux.HandleFunc("/a/{b}/c/{d...}", func(_ ResponseWriter, r *Request) {
+		kvs := map[string]string{
+			"b": "X",
+			"d": "Y",
+			"a": "Z",
+		}
+		for k, v := range kvs {
+			r.SetPathValue(k, v)
+		}
+		for k, w := range kvs {
+			if g := r.PathValue(k); g != w {
+				t.Errorf("got %q, want %q", g, w)
+			}
+		}
+		r2 := r.Clone(r.Context())
+		r2.SetPathValue("b", "changed")
+		if g := r.PathValue("b"); g != "X" {
+			t.Errorf("after setting path value on clone, got %q, want %q", g, "X")
+		}
+	})
+	r := httptest.NewRequest("GET", "/a/b/c/d/e", nil)
+	mux.ServeHTTP(httptest.NewRecorder(), r)
+}
+
 func TestShouldRedirectConcurrency(t *testing.T) {
 	setParallel(t)
 	defer afterTest(t)
@@ -90,9 +90,44 @@ func BenchmarkServerMatch(b *testing.B) {
 		"/products/", "/products/3/image.jpg"}
 	b.StartTimer()
 	for i := 0; i < b.N; i++ {
-		if h, p := mux.match(paths[i%len(paths)]); h != nil && p == "" {
+		if n, _ := mux.tree.match("", "GET", paths[i%len(paths)]); n != nil && n.pattern == nil {
 			b.Error("impossible")
 		}
 	}
 	b.StopTimer()
 }
+
+func TestServeMuxEscapedPaths(t *testing.T) {
+	run := func(t *testing.T, set121 bool) {
+		defer func(u bool) { use121 = u }(use121)
+		use121 = set121
+
+		mux := NewServeMux()
+		mux.Handle("/a/b", NotFoundHandler())
+		mux.Handle("/{x}", NotFoundHandler())
+		for _, tt := range []struct {
+			path       string
+			pattern    string
+			pattern121 string
+		}{
+			{"/a/b", "/a/b", "/a/b"},
+			{"/a%2Fb", "/{x}", "/a/b"},
+			{"/%7Bx%7D", "/{x}", "/{x}"},
+			{"/y", "/{x}", ""},
+		} {
+			r, err := NewRequest("GET", "http://example.com"+tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := tt.pattern
+			if set121 {
+				want = tt.pattern121
+			}
+			if _, got := mux.Handler(r); got != want {
+				t.Errorf("%s: got pattern %q, want %q", tt.path, got, want)
+			}
+		}
+	}
+	t.Run("latest", func(t *testing.T) { run(t, false) })
+	t.Run("httpmuxgo121", func(t *testing.T) { run(t, true) })
+}
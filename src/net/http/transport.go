@@ -269,11 +269,12 @@ type Transport struct {
 	// If zero, a default (currently 4KB) is used.
 	ReadBufferSize int
 
-	// nextProtoOnce guards initialization of TLSNextProto and
-	// h2transport (via onceSetNextProtoDefaults)
+	// nextProtoOnce guards initialization of TLSNextProto,
+	// h2transport, and h3transport (via onceSetNextProtoDefaults)
 	nextProtoOnce      sync.Once
-	h2transport        h2Transport // non-nil if http2 wired up
-	tlsNextProtoWasNil bool        // whether TLSNextProto was nil when the Once fired
+	h2transport        h2Transport     // non-nil if http2 wired up
+	h3transport        *http3transport // non-nil if EnableHTTP3 is set
+	tlsNextProtoWasNil bool            // whether TLSNextProto was nil when the Once fired
 
 	// ForceAttemptHTTP2 controls whether HTTP/2 is enabled when a non-zero
 	// Dial, DialTLS, or DialContext func or TLSClientConfig is provided.
@@ -281,6 +282,18 @@ type Transport struct {
 	// To use a custom dialer or TLS config and still attempt HTTP/2
 	// upgrades, set this to true.
 	ForceAttemptHTTP2 bool
+
+	// EnableHTTP3 enables HTTP/3 over QUIC for https requests.
+	// When set, the Transport remembers servers that advertise an
+	// HTTP/3 endpoint with an Alt-Svc response header, and sends
+	// subsequent requests to those servers over HTTP/3.
+	// If the HTTP/3 endpoint cannot be reached, the Transport falls
+	// back to TCP until the server advertises HTTP/3 again.
+	// HTTP/3 is not used for requests sent through a proxy.
+	//
+	// HTTP/3 connections use TLSClientConfig, TLSHandshakeTimeout
+	// and IdleConnTimeout, but not the Dial functions.
+	EnableHTTP3 bool
 }
 
 // A cancelKey is the key of the reqCanceler map.
@@ -326,6 +339,7 @@ func (t *Transport) Clone() *Transport {
 		GetProxyConnectHeader:  t.GetProxyConnectHeader,
 		MaxResponseHeaderBytes: t.MaxResponseHeaderBytes,
 		ForceAttemptHTTP2:      t.ForceAttemptHTTP2,
+		EnableHTTP3:            t.EnableHTTP3,
 		WriteBufferSize:        t.WriteBufferSize,
 		ReadBufferSize:         t.ReadBufferSize,
 	}
@@ -360,6 +374,9 @@ func (t *Transport) hasCustomTLSDialer() bool {
 // It must be called via t.nextProtoOnce.Do.
 func (t *Transport) onceSetNextProtoDefaults() {
 	t.tlsNextProtoWasNil = (t.TLSNextProto == nil)
+	if t.EnableHTTP3 {
+		t.h3transport = newHTTP3Transport(t)
+	}
 	if godebug.Get("http2client") == "0" {
 		return
 	}
@@ -574,6 +591,19 @@ func (t *Transport) roundTrip(req *Request) (*Response, error) {
 			return nil, err
 		}
 
+		// Use HTTP/3 if the origin has advertised it.
+		useHTTP3 := t.h3transport != nil && cm.proxyURL == nil && cm.targetScheme == "https"
+		if useHTTP3 {
+			resp, err := t.h3transport.roundTrip(req, cm.targetAddr)
+			if err == nil {
+				resp.Request = origReq
+				return resp, nil
+			}
+			if err != http3errNoConn {
+				return nil, err
+			}
+		}
+
 		// Get the cached or newly-created connection to either the
 		// host (for http or https), the http proxy, or the http proxy
 		// pre-CONNECTed to https server. In any case, we'll be ready
@@ -594,6 +624,9 @@ func (t *Transport) roundTrip(req *Request) (*Response, error) {
 			resp, err = pconn.roundTrip(treq)
 		}
 		if err == nil {
+			if useHTTP3 {
+				t.h3transport.altSvc.record(cm.targetAddr, resp.Header)
+			}
 			resp.Request = origReq
 			return resp, nil
 		}
@@ -776,6 +809,9 @@ func (t *Transport) CloseIdleConnections() {
 	if t2 := t.h2transport; t2 != nil {
 		t2.CloseIdleConnections()
 	}
+	if t3 := t.h3transport; t3 != nil {
+		t3.closeIdleConnections()
+	}
 }
 
 // CancelRequest cancels an in-flight request by closing its connection.
@@ -0,0 +1,195 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package coverage writes the coverage data of programs built with
+// "go build -cover".
+//
+// When such a program exits, either by returning from main.main or by
+// calling os.Exit, it writes its coverage data to the directory named
+// by the GOCOVERDIR environment variable. If GOCOVERDIR is not set,
+// the program prints a warning and writes nothing. The data can be
+// examined and converted with "go tool covdata".
+//
+// Long-running programs that never exit, such as servers, can use the
+// functions in this package to write coverage data at other times.
+package coverage
+
+import (
+	"errors"
+	"fmt"
+	"internal/coverage"
+	"internal/coverage/rtcov"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// runtime_addExitHook is implemented in the runtime.
+func runtime_addExitHook(f func(), runOnNonZeroExit bool)
+
+func init() {
+	runtime_addExitHook(emitOnExit, true)
+}
+
+// emitOnExit writes the coverage data of an instrumented program to
+// $GOCOVERDIR as the program exits.
+func emitOnExit() {
+	if len(rtcov.Files) == 0 {
+		// Not built with -cover.
+		return
+	}
+	dir := os.Getenv("GOCOVERDIR")
+	if dir == "" {
+		fmt.Fprintf(os.Stderr, "warning: GOCOVERDIR not set, no coverage data emitted\n")
+		return
+	}
+	if err := WriteMetaDir(dir); err != nil {
+		fmt.Fprintf(os.Stderr, "error: coverage meta-data emit failed: %v\n", err)
+		return
+	}
+	if err := WriteCountersDir(dir); err != nil {
+		fmt.Fprintf(os.Stderr, "error: coverage counter data emit failed: %v\n", err)
+	}
+}
+
+var errNotCovered = errors.New("program not built with -cover")
+
+// meta returns the meta-data of the program.
+func meta() *coverage.Meta {
+	m := &coverage.Meta{Mode: rtcov.Mode}
+	for _, f := range rtcov.Files {
+		fm := coverage.FileMeta{
+			PkgPath: f.PkgPath,
+			Name:    f.Name,
+			Blocks:  make([]coverage.Block, len(f.Counters)),
+		}
+		for i := range fm.Blocks {
+			fm.Blocks[i] = coverage.Block{
+				StartLine: f.Pos[3*i+0],
+				StartCol:  f.Pos[3*i+2] & 0xFFFF,
+				EndLine:   f.Pos[3*i+1],
+				EndCol:    f.Pos[3*i+2] >> 16 & 0xFFFF,
+				NumStmt:   f.NumStmt[i],
+			}
+		}
+		m.Files = append(m.Files, fm)
+	}
+	return m
+}
+
+// counters returns a snapshot of the program's coverage counters.
+func counters(h coverage.Hash) *coverage.Counters {
+	c := &coverage.Counters{MetaHash: h}
+	for _, f := range rtcov.Files {
+		vals := make([]uint32, len(f.Counters))
+		for i := range f.Counters {
+			if rtcov.Mode == "atomic" {
+				vals[i] = atomic.LoadUint32(&f.Counters[i])
+			} else {
+				vals[i] = f.Counters[i]
+			}
+		}
+		c.Files = append(c.Files, vals)
+	}
+	return c
+}
+
+// WriteMetaDir writes a coverage meta-data file for the currently
+// running program to the directory specified in 'dir'. An error will
+// be returned if the operation can't be completed successfully (for
+// example, if the currently running program was not built with
+// "-cover", or if the directory does not exist).
+func WriteMetaDir(dir string) error {
+	if len(rtcov.Files) == 0 {
+		return errNotCovered
+	}
+	m := meta()
+	name := filepath.Join(dir, coverage.MetaFileName(m.Hash()))
+	if _, err := os.Stat(name); err == nil {
+		// All runs of a program share the same meta-data file.
+		return nil
+	}
+	// Write to a temporary file and rename it, so that a concurrently
+	// running copy of the program never sees a partial file.
+	tmp := filepath.Join(dir, fmt.Sprintf("tmp.%s.%d", filepath.Base(name), os.Getpid()))
+	if err := os.WriteFile(tmp, m.Encode(), 0666); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// WriteMeta writes the meta-data content (the payload that would
+// normally be emitted to a meta-data file) for the currently running
+// program to the writer 'w'. An error will be returned if the
+// operation can't be completed successfully (for example, if the
+// currently running program was not built with "-cover", or if a
+// write fails).
+func WriteMeta(w io.Writer) error {
+	if len(rtcov.Files) == 0 {
+		return errNotCovered
+	}
+	_, err := w.Write(meta().Encode())
+	return err
+}
+
+// WriteCountersDir writes a coverage counter-data file for the
+// currently running program to the directory specified in 'dir'. An
+// error will be returned if the operation can't be completed
+// successfully (for example, if the currently running program was
+// not built with "-cover", or if the directory does not exist). The
+// counter data written will be a snapshot taken at the point of the
+// call.
+func WriteCountersDir(dir string) error {
+	if len(rtcov.Files) == 0 {
+		return errNotCovered
+	}
+	c := counters(meta().Hash())
+	name := coverage.CounterFileName(c.MetaHash, os.Getpid(), time.Now().UnixNano())
+	return os.WriteFile(filepath.Join(dir, name), c.Encode(), 0666)
+}
+
+// WriteCounters writes coverage counter-data content for the
+// currently running program to the writer 'w'. An error will be
+// returned if the operation can't be completed successfully (for
+// example, if the currently running program was not built with
+// "-cover", or if a write fails). The counter data written will be a
+// snapshot taken at the point of the invocation.
+func WriteCounters(w io.Writer) error {
+	if len(rtcov.Files) == 0 {
+		return errNotCovered
+	}
+	_, err := w.Write(counters(meta().Hash()).Encode())
+	return err
+}
+
+// ClearCounters clears/resets all coverage counter variables in the
+// currently running program. It returns an error if the program in
+// question was not built with the "-cover" flag. Clearing of coverage
+// counters is also not supported for programs not using atomic
+// counter mode (see more detailed comments below for the rationale
+// here).
+func ClearCounters() error {
+	if len(rtcov.Files) == 0 {
+		return errNotCovered
+	}
+	// Clearing counters only makes sense in atomic mode: in the other
+	// modes, a goroutine running concurrently with the clear could
+	// write back a stale count.
+	if rtcov.Mode != "atomic" {
+		return fmt.Errorf("ClearCounters invoked for program built with -covermode=%s (please use -covermode=atomic)", rtcov.Mode)
+	}
+	for _, f := range rtcov.Files {
+		for i := range f.Counters {
+			atomic.StoreUint32(&f.Counters[i], 0)
+		}
+	}
+	return nil
+}
@@ -224,6 +224,36 @@ func abs64(a int64) int64 {
 	return a
 }
 
+func TestSetMemoryLimit(t *testing.T) {
+	// Test that the variable is being set and returned correctly.
+	old := SetMemoryLimit(123456789)
+	defer SetMemoryLimit(old)
+	if got := SetMemoryLimit(-1); got != 123456789 {
+		t.Errorf("SetMemoryLimit(123456789); SetMemoryLimit(-1) = %d, want 123456789", got)
+	}
+	if got := SetMemoryLimit(old); got != 123456789 {
+		t.Errorf("SetMemoryLimit(123456789); SetMemoryLimit(x) = %d, want 123456789", got)
+	}
+	if got := SetMemoryLimit(-1); got != old {
+		t.Errorf("SetMemoryLimit(x); SetMemoryLimit(-1) = %d, want %d", got, old)
+	}
+
+	// Test that the limit bounds the heap goal, even with GOGC=off.
+	defer SetGCPercent(SetGCPercent(-1))
+	runtime.GC()
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	limit := int64(ms.Sys) + 64<<20
+	SetMemoryLimit(limit)
+	runtime.ReadMemStats(&ms)
+	if ms.NextGC >= uint64(limit) {
+		t.Errorf("NextGC = %d MB with a memory limit of %d MB, want below the limit", ms.NextGC>>20, limit>>20)
+	}
+	if ms.NextGC <= ms.HeapAlloc {
+		t.Errorf("NextGC = %d MB with %d MB allocated, want some runway", ms.NextGC>>20, ms.HeapAlloc>>20)
+	}
+}
+
 func TestSetMaxThreadsOvf(t *testing.T) {
 	// Verify that a big threads count will not overflow the int32
 	// maxmcount variable, causing a panic (see Issue 16076).
@@ -90,6 +90,26 @@ type gcControllerState struct {
 
 	_ uint32 // padding so following 64-bit values are 8-byte aligned
 
+	// memoryLimit is the soft memory limit in bytes.
+	//
+	// Initialized from GOMEMLIMIT. GOMEMLIMIT=off is equivalent to
+	// maxInt64, which means no soft memory limit in practice.
+	//
+	// This is an int64 instead of a uint64 to more easily maintain
+	// parity with the SetMemoryLimit API, which sets a maximum at
+	// maxInt64. This value should never be negative.
+	memoryLimit atomic.Int64
+
+	// mappedReady is the number of bytes of memory mapped by the
+	// runtime that is in the Ready state (see mem.go), that is,
+	// memory that is either in use or may be used without further
+	// interaction with the OS. This is the runtime's view of its
+	// total memory footprint and is what memoryLimit is compared
+	// against.
+	//
+	// Maintained by the sys* wrappers in mem.go.
+	mappedReady atomic.Uint64
+
 	// heapMinimum is the minimum heap size at which to trigger GC.
 	// For small heaps, this overrides the usual GOGC*live set rule.
 	//
@@ -336,8 +356,9 @@ type gcControllerState struct {
 	_ cpu.CacheLinePad
 }
 
-func (c *gcControllerState) init(gcPercent int32) {
+func (c *gcControllerState) init(gcPercent int32, memoryLimit int64) {
 	c.heapMinimum = defaultHeapMinimum
+	c.memoryLimit.Store(memoryLimit)
 
 	if goexperiment.PacerRedesign {
 		c.consMarkController = piController{
@@ -825,6 +846,15 @@ func (c *gcControllerState) findRunnableGCWorker(_p_ *p) *g {
 		throw("gcControllerState.findRunnable: blackening not enabled")
 	}
 
+	// Since we have the current time, check if the GC CPU limiter
+	// hasn't had an update in a while. This check is necessary in
+	// case the limiter is on but hasn't been checked in a while and
+	// so may have left sufficient headroom to turn off again.
+	now := nanotime()
+	if gcCPULimiter.needUpdate(now) {
+		gcCPULimiter.update(now)
+	}
+
 	if !gcMarkWorkAvailable(_p_) {
 		// No work to be done right now. This can happen at
 		// the end of the mark phase when there are still
@@ -876,7 +906,7 @@ func (c *gcControllerState) findRunnableGCWorker(_p_ *p) *g {
 		// goal?
 		//
 		// This should be kept in sync with pollFractionalWorkerExit.
-		delta := nanotime() - c.markStartTime
+		delta := now - c.markStartTime
 		if delta > 0 && float64(_p_.gcFractionalMarkTime)/float64(delta) > c.fractionalUtilizationGoal {
 			// Nope. No need to run a fractional worker.
 			gcBgMarkWorkerPool.push(&node.node)
@@ -997,8 +1027,22 @@ func (c *gcControllerState) commit(triggerRatio float64) {
 		goal = c.heapMarked + (c.heapMarked+atomic.Load64(&c.stackScan)+atomic.Load64(&c.globalsScan))*uint64(gcPercent)/100
 	}
 
-	// Don't trigger below the minimum heap size.
+	// If the memory limit implies a lower goal than GOGC, use that
+	// instead. In this regime the goal is a hard ceiling: none of the
+	// bounds below may push it up again.
+	memoryLimited := false
+	if limitGoal := c.memoryLimitHeapGoal(); limitGoal < goal {
+		goal = limitGoal
+		memoryLimited = true
+	}
+
+	// Don't trigger below the minimum heap size. In the memory-limited
+	// regime the heap minimum doesn't apply: staying under the limit
+	// takes priority over amortizing the cost of GCs on small heaps.
 	minTrigger := c.heapMinimum
+	if memoryLimited {
+		minTrigger = c.heapMarked
+	}
 	if !isSweepDone() {
 		// Concurrent sweep happens in the heap growth
 		// from gcController.heapLive to trigger, so ensure
@@ -1080,7 +1124,13 @@ func (c *gcControllerState) commit(triggerRatio float64) {
 		trigger = maxTrigger
 	}
 	if trigger > goal {
-		goal = trigger
+		if memoryLimited {
+			// The goal reflects the memory limit, so pull
+			// the trigger down instead of pushing the goal up.
+			trigger = goal
+		} else {
+			goal = trigger
+		}
 	}
 
 	// Commit to the trigger and goal.
@@ -1096,6 +1146,66 @@ func (c *gcControllerState) commit(triggerRatio float64) {
 	}
 }
 
+const (
+	// memoryLimitHeapGoalHeadroom is the amount of headroom the pacer gives to
+	// the heap goal when operating in the memory-limited regime. That is,
+	// it'll reduce the heap goal by this many extra bytes off of the base
+	// calculation.
+	memoryLimitHeapGoalHeadroom = 1 << 20
+)
+
+// memoryLimitHeapGoal returns a heap goal derived from memoryLimit.
+//
+// The goal is the memory limit minus all the memory the runtime has
+// mapped and ready that is not part of the heap (stacks, runtime
+// metadata, and so on), less a small amount of headroom. It is never
+// smaller than heapMarked, since a lower goal could never be met.
+func (c *gcControllerState) memoryLimitHeapGoal() uint64 {
+	limit := c.memoryLimit.Load()
+	if limit == maxInt64 {
+		// No limit; skip the calculation entirely.
+		return ^uint64(0)
+	}
+	memoryLimit := uint64(limit)
+
+	// Compute how much memory the runtime is using outside of the
+	// heap. heapRetained is the heap's share of mappedReady; the two
+	// are updated independently, so be careful about underflow.
+	// Test copies have no memory of their own, so everything is heap.
+	mappedReady := c.mappedReady.Load()
+	var nonHeapMemory uint64
+	if !c.test {
+		if retained := heapRetained(); mappedReady > retained {
+			nonHeapMemory = mappedReady - retained
+		}
+	}
+
+	// If the runtime's total footprint already exceeds the limit, the
+	// excess has to come out of the heap too.
+	var overage uint64
+	if mappedReady > memoryLimit {
+		overage = mappedReady - memoryLimit
+	}
+	if nonHeapMemory+overage >= memoryLimit {
+		// Nothing is left for the heap. The best we can do is
+		// collect continuously.
+		return c.heapMarked
+	}
+	goal := memoryLimit - (nonHeapMemory + overage)
+
+	// Leave some headroom so that small fluctuations in non-heap
+	// memory don't push us over the limit.
+	if goal < 2*memoryLimitHeapGoalHeadroom {
+		goal = memoryLimitHeapGoalHeadroom
+	} else {
+		goal -= memoryLimitHeapGoalHeadroom
+	}
+	if goal < c.heapMarked {
+		goal = c.heapMarked
+	}
+	return goal
+}
+
 // oldCommit sets the trigger ratio and updates everything
 // derived from it: the absolute trigger, the heap goal, mark pacing,
 // and sweep pacing.
@@ -1255,7 +1365,7 @@ func setGCPercent(in int32) (out int32) {
 		lock(&mheap_.lock)
 		out = gcController.setGCPercent(in)
 		gcPaceSweeper(gcController.trigger)
-		gcPaceScavenger(gcController.heapGoal, gcController.lastHeapGoal)
+		gcPaceScavenger(gcController.memoryLimit.Load(), gcController.heapGoal, gcController.lastHeapGoal)
 		unlock(&mheap_.lock)
 	})
 
@@ -1268,6 +1378,47 @@ func setGCPercent(in int32) (out int32) {
 	return out
 }
 
+// setMemoryLimit updates memoryLimit and all related pacer state.
+// Returns the old value of memoryLimit. A negative in leaves the
+// limit unchanged.
+//
+// Calls gcControllerState.commit.
+//
+// The world must be stopped, or mheap_.lock must be held.
+func (c *gcControllerState) setMemoryLimit(in int64) int64 {
+	if !c.test {
+		assertWorldStoppedOrLockHeld(&mheap_.lock)
+	}
+
+	out := c.memoryLimit.Load()
+	if in >= 0 {
+		c.memoryLimit.Store(in)
+		// Update pacing in response to memoryLimit change.
+		c.commit(c.triggerRatio)
+	}
+	return out
+}
+
+//go:linkname setMemoryLimit runtime/debug.setMemoryLimit
+func setMemoryLimit(in int64) (out int64) {
+	// Run on the system stack since we grab the heap lock.
+	systemstack(func() {
+		lock(&mheap_.lock)
+		out = gcController.setMemoryLimit(in)
+		if in >= 0 && out != in {
+			gcPaceSweeper(gcController.trigger)
+			gcPaceScavenger(gcController.memoryLimit.Load(), gcController.heapGoal, gcController.lastHeapGoal)
+		}
+		unlock(&mheap_.lock)
+	})
+
+	// If we changed the limit, the scavenger may have new work.
+	if in >= 0 && out != in {
+		readyForScavenger()
+	}
+	return out
+}
+
 func readGOGC() int32 {
 	p := gogetenv("GOGC")
 	if p == "off" {
@@ -1279,6 +1430,21 @@ func readGOGC() int32 {
 	return 100
 }
 
+// readGOMEMLIMIT reads the memory limit from the GOMEMLIMIT
+// environment variable. An unset variable or "off" means no limit.
+func readGOMEMLIMIT() int64 {
+	p := gogetenv("GOMEMLIMIT")
+	if p == "" || p == "off" {
+		return maxInt64
+	}
+	n, ok := parseByteCount(p)
+	if !ok {
+		print("GOMEMLIMIT=", p, "\n")
+		throw("malformed GOMEMLIMIT; see `go doc runtime/debug.SetMemoryLimit`")
+	}
+	return n
+}
+
 type piController struct {
 	kp float64 // Proportional constant.
 	ti float64 // Integral time constant.
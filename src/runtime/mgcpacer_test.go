@@ -24,6 +24,7 @@ func TestGcPacer(t *testing.T) {
 			// Growth to an O(MiB) heap, then constant heap size, alloc/scan rates.
 			name:          "Steady",
 			gcPercent:     100,
+			memoryLimit:   math.MaxInt64,
 			globalsBytes:  32 << 10,
 			nCores:        8,
 			allocRate:     constant(33.0),
@@ -51,6 +52,7 @@ func TestGcPacer(t *testing.T) {
 			// Same as the steady-state case, but lots of stacks to scan relative to the heap size.
 			name:          "SteadyBigStacks",
 			gcPercent:     100,
+			memoryLimit:   math.MaxInt64,
 			globalsBytes:  32 << 10,
 			nCores:        8,
 			allocRate:     constant(132.0),
@@ -80,6 +82,7 @@ func TestGcPacer(t *testing.T) {
 			// Same as the steady-state case, but lots of globals to scan relative to the heap size.
 			name:          "SteadyBigGlobals",
 			gcPercent:     100,
+			memoryLimit:   math.MaxInt64,
 			globalsBytes:  128 << 20,
 			nCores:        8,
 			allocRate:     constant(132.0),
@@ -109,6 +112,7 @@ func TestGcPacer(t *testing.T) {
 			// This tests the GC pacer's response to a small change in allocation rate.
 			name:          "StepAlloc",
 			gcPercent:     100,
+			memoryLimit:   math.MaxInt64,
 			globalsBytes:  32 << 10,
 			nCores:        8,
 			allocRate:     constant(33.0).sum(ramp(66.0, 1).delay(50)),
@@ -131,6 +135,7 @@ func TestGcPacer(t *testing.T) {
 			// This tests the GC pacer's response to a large change in allocation rate.
 			name:          "HeavyStepAlloc",
 			gcPercent:     100,
+			memoryLimit:   math.MaxInt64,
 			globalsBytes:  32 << 10,
 			nCores:        8,
 			allocRate:     constant(33).sum(ramp(330, 1).delay(50)),
@@ -153,6 +158,7 @@ func TestGcPacer(t *testing.T) {
 			// This tests the GC pacer's response to a change in the fraction of the scannable heap.
 			name:          "StepScannableFrac",
 			gcPercent:     100,
+			memoryLimit:   math.MaxInt64,
 			globalsBytes:  32 << 10,
 			nCores:        8,
 			allocRate:     constant(128.0),
@@ -177,6 +183,7 @@ func TestGcPacer(t *testing.T) {
 			// utilization ends up sensitive
 			name:          "HighGOGC",
 			gcPercent:     1500,
+			memoryLimit:   math.MaxInt64,
 			globalsBytes:  32 << 10,
 			nCores:        8,
 			allocRate:     random(7, 0x53).offset(165),
@@ -217,6 +224,7 @@ func TestGcPacer(t *testing.T) {
 			// rate, the pacer does a reasonably good job of staying abreast of the changes.
 			name:          "OscAlloc",
 			gcPercent:     100,
+			memoryLimit:   math.MaxInt64,
 			globalsBytes:  32 << 10,
 			nCores:        8,
 			allocRate:     oscillate(13, 0, 8).offset(67),
@@ -245,6 +253,7 @@ func TestGcPacer(t *testing.T) {
 			// This test is the same as OscAlloc, but instead of oscillating, the allocation rate is jittery.
 			name:          "JitterAlloc",
 			gcPercent:     100,
+			memoryLimit:   math.MaxInt64,
 			globalsBytes:  32 << 10,
 			nCores:        8,
 			allocRate:     random(13, 0xf).offset(132),
@@ -274,6 +283,7 @@ func TestGcPacer(t *testing.T) {
 			// The jitter is proportionally the same.
 			name:          "HeavyJitterAlloc",
 			gcPercent:     100,
+			memoryLimit:   math.MaxInt64,
 			globalsBytes:  32 << 10,
 			nCores:        8,
 			allocRate:     random(33.0, 0x0).offset(330),
@@ -302,6 +312,139 @@ func TestGcPacer(t *testing.T) {
 				}
 			},
 		},
+		{
+			// The same as the steady-state case, but with a memory limit
+			// well above the steady-state heap size. The limit should have
+			// no effect.
+			name:          "SteadyMemoryLimit",
+			gcPercent:     100,
+			memoryLimit:   512 << 20,
+			globalsBytes:  32 << 10,
+			nCores:        8,
+			allocRate:     constant(33.0),
+			scanRate:      constant(1024.0),
+			growthRate:    constant(2.0).sum(ramp(-1.0, 12)),
+			scannableFrac: constant(1.0),
+			stackBytes:    constant(8192),
+			length:        50,
+			checker: func(t *testing.T, c []gcCycleResult) {
+				n := len(c)
+				if peak := c[n-1].heapPeak; peak >= (512<<20)-MemoryLimitHeapGoalHeadroom {
+					t.Errorf("peak heap size reaches heap limit: %d", peak)
+				}
+				if n >= 25 {
+					if goexperiment.PacerRedesign {
+						// At this alloc/scan rate, the pacer should be extremely close to the goal utilization.
+						assertInEpsilon(t, "GC utilization", c[n-1].gcUtilization, GCGoalUtilization, 0.005)
+					}
+
+					// Make sure the pacer settles into a non-degenerate state in at least 25 GC cycles.
+					assertInEpsilon(t, "GC utilization", c[n-1].gcUtilization, c[n-2].gcUtilization, 0.005)
+					assertInRange(t, "goal ratio", c[n-1].goalRatio(), 0.95, 1.05)
+				}
+			},
+		},
+		{
+			// This is the same as the previous test, but gcPercent = -1, so the heap *should* grow
+			// all the way to the memory limit.
+			name:          "SteadyMemoryLimitNoGCPercent",
+			gcPercent:     -1,
+			memoryLimit:   512 << 20,
+			globalsBytes:  32 << 10,
+			nCores:        8,
+			allocRate:     constant(33.0),
+			scanRate:      constant(1024.0),
+			growthRate:    constant(2.0).sum(ramp(-1.0, 12)),
+			scannableFrac: constant(1.0),
+			stackBytes:    constant(8192),
+			length:        50,
+			checker: func(t *testing.T, c []gcCycleResult) {
+				if !goexperiment.PacerRedesign {
+					// The memory limit is only respected by the redesigned pacer.
+					return
+				}
+				n := len(c)
+				if goal := c[n-1].heapGoal; goal != (512<<20)-MemoryLimitHeapGoalHeadroom {
+					t.Errorf("heap goal is not the heap limit: %d", goal)
+				}
+				if n >= 25 {
+					// At this alloc/scan rate, the pacer should be extremely close to the goal utilization.
+					assertInEpsilon(t, "GC utilization", c[n-1].gcUtilization, GCGoalUtilization, 0.005)
+
+					// Make sure the pacer settles into a non-degenerate state in at least 25 GC cycles.
+					assertInEpsilon(t, "GC utilization", c[n-1].gcUtilization, c[n-2].gcUtilization, 0.005)
+					assertInRange(t, "goal ratio", c[n-1].goalRatio(), 0.95, 1.05)
+				}
+			},
+		},
+		{
+			// This test ensures that the pacer doesn't fall over even when the live heap exceeds
+			// the memory limit. It also makes sure GC utilization actually rises to push back.
+			name:          "ExceedMemoryLimit",
+			gcPercent:     100,
+			memoryLimit:   512 << 20,
+			globalsBytes:  32 << 10,
+			nCores:        8,
+			allocRate:     constant(33.0),
+			scanRate:      constant(1024.0),
+			growthRate:    constant(3.5).sum(ramp(-2.5, 12)),
+			scannableFrac: constant(1.0),
+			stackBytes:    constant(8192),
+			length:        50,
+			checker: func(t *testing.T, c []gcCycleResult) {
+				if !goexperiment.PacerRedesign {
+					return
+				}
+				n := len(c)
+				if n > 12 {
+					// We're way over the memory limit, so we want to make sure our goal is set
+					// as low as it possibly can be. That's the live heap, plus the minimum
+					// runway startCycle always leaves for assists.
+					if goal, live := c[n-1].heapGoal, c[n-1].heapLive; goal > live+64<<10 {
+						t.Errorf("heap goal is not close to live heap: %d > %d", goal, live+64<<10)
+					}
+				}
+				if n >= 25 {
+					// Due to memory pressure, we should scale to 100% GC CPU utilization.
+					// Note that in practice this won't actually happen because of the CPU limiter,
+					// but it's not the responsibility of the GC pacer to limit CPU usage.
+					assertInEpsilon(t, "GC utilization", c[n-1].gcUtilization, 1.0, 0.005)
+				}
+			},
+		},
+		{
+			// This test ensures that the pacer maintains the memory limit as the heap grows.
+			name:          "MaintainMemoryLimit",
+			gcPercent:     100,
+			memoryLimit:   512 << 20,
+			globalsBytes:  32 << 10,
+			nCores:        8,
+			allocRate:     constant(33.0),
+			scanRate:      constant(1024.0),
+			growthRate:    constant(3.0).sum(ramp(-2.0, 12)),
+			scannableFrac: constant(1.0),
+			stackBytes:    constant(8192),
+			length:        50,
+			checker: func(t *testing.T, c []gcCycleResult) {
+				if !goexperiment.PacerRedesign {
+					return
+				}
+				n := len(c)
+				if n > 12 {
+					// We're trying to saturate the memory limit.
+					if goal := c[n-1].heapGoal; goal != (512<<20)-MemoryLimitHeapGoalHeadroom {
+						t.Errorf("heap goal is not the heap limit: %d", goal)
+					}
+				}
+				if n >= 25 {
+					// At this alloc/scan rate, the pacer should be extremely close to the goal utilization,
+					// even with the additional overhead of the memory limit.
+					assertInEpsilon(t, "GC utilization", c[n-1].gcUtilization, GCGoalUtilization, 0.005)
+					assertInEpsilon(t, "GC utilization", c[n-1].gcUtilization, c[n-2].gcUtilization, 0.005)
+					assertInRange(t, "goal ratio", c[n-1].goalRatio(), 0.95, 1.05)
+				}
+			},
+		},
 		// TODO(mknyszek): Write a test that exercises the pacer's hard goal.
 		// This is difficult in the idealized model this testing framework places
 		// the pacer in, because the calculated overshoot is directly proportional
@@ -313,7 +456,7 @@ func TestGcPacer(t *testing.T) {
 		t.Run(e.name, func(t *testing.T) {
 			t.Parallel()
 
-			c := NewGCController(e.gcPercent)
+			c := NewGCController(e.gcPercent, e.memoryLimit)
 			var bytesAllocatedBlackLast int64
 			results := make([]gcCycleResult, 0, e.length)
 			for i := 0; i < e.length; i++ {
@@ -467,6 +610,7 @@ type gcExecTest struct {
 	name string
 
 	gcPercent    int
+	memoryLimit  int64
 	globalsBytes uint64
 	nCores       int
 
@@ -41,7 +41,18 @@
 // that there's more unscavenged memory to allocate out of, since each allocation
 // out of scavenged memory incurs a potentially expensive page fault.
 //
-// The goal is updated after each GC and the scavenger's pacing parameters
+// The scavenger also has a second goal, derived from the memory limit (see
+// gcControllerState.memoryLimit). That goal is defined as:
+//   (100-reduceExtraPercent) / 100 * memoryLimit
+//
+// and is compared against the runtime's total mapped and ready memory rather
+// than just the heap, since that's what the memory limit applies to. The
+// background scavenger runs whenever either goal is exceeded. Because the
+// background scavenger is heavily rate-limited, allocating goroutines also
+// scavenge synchronously whenever an allocation would exceed the memory limit
+// (see mheap.allocSpan).
+//
+// The goals are updated after each GC and the scavenger's pacing parameters
 // (which live in mheap_) are updated to match. The pacing parameters work much
 // like the background sweeping parameters. The parameters define a line whose
 // horizontal axis is time and vertical axis is estimated heap RSS, and the
@@ -78,6 +89,17 @@ const (
 	// the ever-changing layout of the heap.
 	retainExtraPercent = 10
 
+	// reduceExtraPercent represents the amount of memory under the limit
+	// that the scavenger should target. For example, 5 means we target 95%
+	// of the limit.
+	//
+	// The purpose of shooting lower than the limit is to ensure that, once
+	// close to the limit, the scavenger is working hard to maintain it. If
+	// we have a memory limit set but are far away from it, there's no harm
+	// in leaving up to 100-retainExtraPercent live, and it's more efficient
+	// anyway, for the same reasons that retainExtraPercent exists.
+	reduceExtraPercent = 5
+
 	// maxPagesPerPhysPage is the maximum number of supported runtime pages per
 	// physical page, based on maxPhysPageSize.
 	maxPagesPerPhysPage = maxPhysPageSize / pageSize
@@ -105,11 +127,14 @@ func heapRetained() uint64 {
 }
 
 // gcPaceScavenger updates the scavenger's pacing, particularly
-// its rate and RSS goal. For this, it requires the current heapGoal,
-// and the heapGoal for the previous GC cycle.
+// its rate and RSS goals. For this, it requires the current memory
+// limit, the current heapGoal, and the heapGoal for the previous GC
+// cycle.
 //
 // The RSS goal is based on the current heap goal with a small overhead
-// to accommodate non-determinism in the allocator.
+// to accommodate non-determinism in the allocator. A second goal,
+// reduceExtraPercent below the memory limit, bounds the runtime's
+// total footprint.
 //
 // The pacing is based on scavengePageRate, which applies to both regular and
 // huge pages. See that constant for more information.
@@ -117,9 +142,21 @@ func heapRetained() uint64 {
 // Must be called whenever GC pacing is updated.
 //
 // mheap_.lock must be held or the world must be stopped.
-func gcPaceScavenger(heapGoal, lastHeapGoal uint64) {
+func gcPaceScavenger(memoryLimit int64, heapGoal, lastHeapGoal uint64) {
 	assertWorldStoppedOrLockHeld(&mheap_.lock)
 
+	// Start with the memory limit goal, which is simpler. We want to
+	// keep the runtime's total footprint at (100-reduceExtraPercent)%
+	// of the limit. If we're already below that, the background
+	// scavenger has nothing to do for the limit; allocations will
+	// still scavenge synchronously if they'd exceed the limit itself.
+	memoryLimitGoal := uint64(float64(memoryLimit) * (100.0 - reduceExtraPercent) / 100.0)
+	if gcController.mappedReady.Load() <= memoryLimitGoal {
+		atomic.Store64(&mheap_.scavengeMemoryLimitGoal, ^uint64(0))
+	} else {
+		atomic.Store64(&mheap_.scavengeMemoryLimitGoal, memoryLimitGoal)
+	}
+
 	// If we're called before the first GC completed, disable scavenging.
 	// We never scavenge before the 2nd GC cycle anyway (we don't have enough
 	// information about the heap yet) so this is fine, and avoids a fault
@@ -315,7 +352,8 @@ func bgscavenge(c chan int) {
 		for crit < minCritTime {
 			// If background scavenging is disabled or if there's no work to do just park.
 			retained, goal := heapRetained(), atomic.Load64(&mheap_.scavengeGoal)
-			if retained <= goal {
+			mappedReady, limitGoal := gcController.mappedReady.Load(), atomic.Load64(&mheap_.scavengeMemoryLimitGoal)
+			if retained <= goal && mappedReady <= limitGoal {
 				break
 			}
 